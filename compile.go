@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/compiler"
+	"github.com/SunilNeupane77/nepali/internal/imports"
+)
+
+// compileCommand handles `nepali compile <file>...`, which writes the
+// compiled file of each source file next to it
+func compileCommand(files []string) {
+	for _, file := range files {
+		warnings, err := compiler.CompileFile(file)
+		exitOnCompileError(err)
+		printWarnings(warnings)
+		fmt.Println(compiler.CompiledPath(file))
+	}
+}
+
+// disasmCommand handles `nepali disasm <file>`, which prints the
+// instructions a source or compiled file holds, under the source lines they
+// were compiled from
+func disasmCommand(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "usage: nepali disasm <file>\n")
+		os.Exit(2)
+	}
+	filename := args[0]
+
+	bytecode := loadBytecode(filename)
+
+	sourcePath := filename
+	if filepath.Ext(filename) == compiler.CompiledExt {
+		sourcePath = filename[:len(filename)-len(compiler.CompiledExt)] + imports.SourceExt
+	}
+	source, _ := os.ReadFile(sourcePath)
+
+	compiler.Disassemble(os.Stdout, bytecode, string(source))
+}
+
+// compileProgram compiles a parsed program, exiting if it cannot
+func compileProgram(program *ast.Program) *compiler.Bytecode {
+	c := compiler.New()
+	if err := c.Compile(program); err != nil {
+		fmt.Printf("Error compiling file: %s\n", err)
+		os.Exit(1)
+	}
+	return c.Bytecode()
+}
+
+// loadBytecode loads the bytecode of a source or compiled file, using the
+// compiled file of a source when it is up to date, exiting if it cannot
+func loadBytecode(filename string) *compiler.Bytecode {
+	bytecode, warnings, err := compiler.Load(filename)
+	exitOnCompileError(err)
+	printWarnings(warnings)
+	return bytecode
+}
+
+// exitOnCompileError reports err, from reading, parsing or compiling a file,
+// and exits, if it is not nil
+func exitOnCompileError(err error) {
+	var parseErrors compiler.ParseErrors
+	switch {
+	case errors.As(err, &parseErrors):
+		printParserErrors(parseErrors)
+		os.Exit(1)
+	case errors.Is(err, os.ErrNotExist):
+		fmt.Printf("Error reading file: %s\n", err)
+		os.Exit(1)
+	case err != nil:
+		fmt.Printf("Error compiling file: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/SunilNeupane77/nepali/internal/debug"
+)
+
+// debugCommand handles `nepali debug`, which runs a debug adapter for
+// editors, speaking the Debug Adapter Protocol on standard input and output
+func debugCommand() {
+	if err := debug.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "nepali debug: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// debugFile runs filename under the terminal debugger, reading its commands
+// from the REPL's input
+func debugFile(filename string, scanner *bufio.Scanner) {
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("Error reading file: %s\n", err)
+		return
+	}
+
+	s, err := debug.New(filename, string(source))
+	if errs, ok := err.(debug.ParseErrors); ok {
+		printParserErrors(errs)
+		return
+	}
+	printResult(debug.Terminal(s, scanner, os.Stdout))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/SunilNeupane77/nepali/internal/format"
+)
+
+// fmtCommand handles `nepali fmt [--check] [--diff]
+// [--digits=devanagari|ascii] <file>...`, which rewrites each file in the
+// canonical layout. With --check or --diff the files are left alone:
+// --check lists those not laid out canonically, and --diff shows what
+// formatting would change. Either exits with status 1 if any would change.
+func fmtCommand(args []string) {
+	var check, diff bool
+	var digits string
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	flags.BoolVar(&check, "check", false, "list files that are not formatted, without changing them")
+	flags.BoolVar(&diff, "diff", false, "show the changes formatting would make, without making them")
+	flags.StringVar(&digits, "digits", "", "write integer literals in devanagari or ascii digits")
+	flags.Parse(args)
+
+	var opts format.Options
+	switch digits {
+	case "":
+	case "devanagari":
+		opts.Digits = format.DevanagariDigits
+	case "ascii":
+		opts.Digits = format.ASCIIDigits
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "usage: nepali fmt [--check] [--diff] [--digits=devanagari|ascii] <file>...\n")
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		source, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Printf("Error reading file: %s\n", err)
+			os.Exit(1)
+		}
+
+		formatted, err := format.Source(string(source), opts)
+		if parseErrors, ok := err.(format.ParseErrors); ok {
+			fmt.Printf("%s: ", filename)
+			printParserErrors(parseErrors)
+			status = 1
+			continue
+		}
+		if formatted == string(source) {
+			continue
+		}
+
+		switch {
+		case check || diff:
+			if check {
+				fmt.Println(filename)
+			}
+			if diff {
+				fmt.Print(format.Diff(filename, string(source), formatted))
+			}
+			status = 1
+		default:
+			info, err := os.Stat(filename)
+			if err == nil {
+				err = os.WriteFile(filename, []byte(formatted), info.Mode().Perm())
+			}
+			if err != nil {
+				fmt.Printf("Error writing file: %s\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	os.Exit(status)
+}
@@ -49,9 +49,12 @@ func (p *Program) String() string {
 
 // LetStatement represents a let statement
 type LetStatement struct {
-	Token lexer.Token // the LET token
-	Name  *Identifier
-	Value Expression
+	Token      lexer.Token // the LET token
+	Name       *Identifier
+	Value      Expression
+	Decorators []Expression // @decorators, outermost first
+
+	IsDeclaration bool // written as फन नाम(...) { ... }
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -60,6 +63,9 @@ func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
+	for _, d := range ls.Decorators {
+		out.WriteString("@" + d.String() + "\n")
+	}
 	out.WriteString(ls.TokenLiteral() + " " + ls.Name.String())
 	out.WriteString(" = ")
 	out.WriteString(ls.Value.String())
@@ -104,6 +110,11 @@ func (es *ExpressionStatement) String() string { return es.Expression.String() }
 type Identifier struct {
 	Token lexer.Token // the IDENT token
 	Value string
+
+	// Set by the resolver: the variable lives in slot Slot of the scope
+	// Depth levels out from where the identifier appears
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode()      {}
@@ -208,6 +219,7 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      lexer.Token // the { token
 	Statements []Statement
+	Rbrace     lexer.Token // the closing } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -226,8 +238,34 @@ func (bs *BlockStatement) String() string {
 // FunctionLiteral represents a function literal
 type FunctionLiteral struct {
 	Token      lexer.Token // The 'fn' token
-	Parameters []*Identifier
+	Name       string      // the name it was declared or bound with, if any
+	Parameters []*Parameter
 	Body       *BlockStatement
+	IsAsync    bool     // declared with एसिन्क; calling it returns a promise
+	Locals     []string // set by the resolver: the slots of a call's scope, parameters first
+}
+
+// Parameter is one parameter of a function literal: a plain name, a name
+// with a default value, *rest collecting extra positional arguments, or
+// **rest collecting extra keyword arguments
+type Parameter struct {
+	Name        *Identifier
+	Default     Expression // nil when the parameter has no default
+	Rest        bool
+	KeywordRest bool
+}
+
+func (p *Parameter) String() string {
+	switch {
+	case p.Rest:
+		return "*" + p.Name.String()
+	case p.KeywordRest:
+		return "**" + p.Name.String()
+	case p.Default != nil:
+		return p.Name.String() + " = " + p.Default.String()
+	default:
+		return p.Name.String()
+	}
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -241,7 +279,13 @@ func (fl *FunctionLiteral) String() string {
 		params = append(params, p.String())
 	}
 
+	if fl.IsAsync {
+		out.WriteString(lexer.ASYNC + " ")
+	}
 	out.WriteString(fl.TokenLiteral())
+	if fl.Name != "" {
+		out.WriteString(" " + fl.Name)
+	}
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
@@ -250,11 +294,26 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// AwaitExpression suspends the current async function until a promise settles
+type AwaitExpression struct {
+	Token lexer.Token // the पर्ख token
+	Value Expression
+}
+
+func (ae *AwaitExpression) expressionNode()      {}
+func (ae *AwaitExpression) TokenLiteral() string { return ae.Token.Literal }
+
+func (ae *AwaitExpression) String() string {
+	return "(" + ae.TokenLiteral() + " " + ae.Value.String() + ")"
+}
+
 // CallExpression represents a function call
 type CallExpression struct {
 	Token     lexer.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparen    lexer.Token // the closing ) token
+	Tail      bool        // its result is the calling function's, so its frame can be reused
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -291,6 +350,7 @@ func (sl *StringLiteral) String() string { return sl.Token.Literal }
 type ArrayLiteral struct {
 	Token    lexer.Token // the '[' token
 	Elements []Expression
+	Rbracket lexer.Token // the closing ] token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
@@ -333,10 +393,52 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+// SliceExpression represents a slice such as a[start:end:step]. Omitted
+// parts are nil.
+type SliceExpression struct {
+	Token lexer.Token // The [ token
+	Left  Expression
+	Start Expression
+	End   Expression
+	Step  Expression
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	if se.Step != nil {
+		out.WriteString(":")
+		out.WriteString(se.Step.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
 // HashLiteral represents a hash literal
 type HashLiteral struct {
-	Token lexer.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  lexer.Token // the '{' token
+	Pairs  []HashPair  // in source order
+	Rbrace lexer.Token // the closing } token
+}
+
+// HashPair is one `key: value` entry of a hash literal
+type HashPair struct {
+	Key   Expression
+	Value Expression
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -346,8 +448,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+":"+pair.Value.String())
 	}
 
 	out.WriteString("{")
@@ -356,3 +458,343 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// ImportStatement binds another module, or names exported from it, in the
+// current scope
+type ImportStatement struct {
+	Token lexer.Token    // the आयात or बाट token
+	Path  *StringLiteral // the module path as written
+	Alias *Identifier    // set by `जस्तो नाम`; otherwise the module's base name is used
+	Names []*Identifier  // set by `बाट "path" आयात a, b`
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	if len(is.Names) > 0 {
+		names := []string{}
+		for _, n := range is.Names {
+			names = append(names, n.String())
+		}
+		out.WriteString(lexer.FROM + " \"" + is.Path.Value + "\" " + lexer.IMPORT + " ")
+		out.WriteString(strings.Join(names, ", "))
+	} else {
+		out.WriteString(lexer.IMPORT + " \"" + is.Path.Value + "\"")
+		if is.Alias != nil {
+			out.WriteString(" " + lexer.AS + " " + is.Alias.String())
+		}
+	}
+	out.WriteString(";\n")
+
+	return out.String()
+}
+
+// ExportStatement marks a top-level binding as visible to importers
+type ExportStatement struct {
+	Token     lexer.Token // the निर्यात token
+	Statement *LetStatement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+// MemberExpression looks up a named member, as in मोड्युल.नाम
+type MemberExpression struct {
+	Token    lexer.Token // the . token
+	Object   Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Property.String() + ")"
+}
+
+// SetLiteral represents a set literal such as {१, २}
+type SetLiteral struct {
+	Token    lexer.Token // the '{' token
+	Elements []Expression
+	Rbrace   lexer.Token // the closing } token
+}
+
+func (sl *SetLiteral) expressionNode()      {}
+func (sl *SetLiteral) TokenLiteral() string { return sl.Token.Literal }
+
+func (sl *SetLiteral) String() string {
+	elements := []string{}
+	for _, el := range sl.Elements {
+		elements = append(elements, el.String())
+	}
+
+	return "{" + strings.Join(elements, ", ") + "}"
+}
+
+// ComprehensionClause is one `लागि x मा iterable यदि condition` part of a
+// comprehension. Two names bind a key and value, or an index and element.
+type ComprehensionClause struct {
+	Token    lexer.Token // the लागि token
+	Names    []*Identifier
+	Iterable Expression
+	Filters  []Expression
+}
+
+func (cc *ComprehensionClause) String() string {
+	var out bytes.Buffer
+
+	names := []string{}
+	for _, n := range cc.Names {
+		names = append(names, n.String())
+	}
+
+	out.WriteString(" " + cc.TokenLiteral() + " ")
+	out.WriteString(strings.Join(names, ", "))
+	out.WriteString(" मा ")
+	out.WriteString(cc.Iterable.String())
+	for _, f := range cc.Filters {
+		out.WriteString(" यदि ")
+		out.WriteString(f.String())
+	}
+
+	return out.String()
+}
+
+func (cc *ComprehensionClause) TokenLiteral() string { return cc.Token.Literal }
+
+// ListComprehension builds an array, as in [x * x लागि x मा सूची]
+type ListComprehension struct {
+	Token   lexer.Token // the '[' token
+	Element Expression
+	Clauses []*ComprehensionClause
+	Locals  []string // set by the resolver: the slots of the loop variables' scope
+}
+
+func (lc *ListComprehension) expressionNode()      {}
+func (lc *ListComprehension) TokenLiteral() string { return lc.Token.Literal }
+
+func (lc *ListComprehension) String() string {
+	return "[" + lc.Element.String() + clausesString(lc.Clauses) + "]"
+}
+
+// SetComprehension builds a set, as in {x % ३ लागि x मा सूची}
+type SetComprehension struct {
+	Token   lexer.Token // the '{' token
+	Element Expression
+	Clauses []*ComprehensionClause
+	Locals  []string // set by the resolver: the slots of the loop variables' scope
+}
+
+func (sc *SetComprehension) expressionNode()      {}
+func (sc *SetComprehension) TokenLiteral() string { return sc.Token.Literal }
+
+func (sc *SetComprehension) String() string {
+	return "{" + sc.Element.String() + clausesString(sc.Clauses) + "}"
+}
+
+// HashComprehension builds a hash, as in {x: x * x लागि x मा सूची}
+type HashComprehension struct {
+	Token   lexer.Token // the '{' token
+	Key     Expression
+	Value   Expression
+	Clauses []*ComprehensionClause
+	Locals  []string // set by the resolver: the slots of the loop variables' scope
+}
+
+func (hc *HashComprehension) expressionNode()      {}
+func (hc *HashComprehension) TokenLiteral() string { return hc.Token.Literal }
+
+func (hc *HashComprehension) String() string {
+	return "{" + hc.Key.String() + ": " + hc.Value.String() + clausesString(hc.Clauses) + "}"
+}
+
+func clausesString(clauses []*ComprehensionClause) string {
+	var out bytes.Buffer
+	for _, c := range clauses {
+		out.WriteString(c.String())
+	}
+	return out.String()
+}
+
+// KeywordArgument passes an argument by name, as in f(आधार = १६)
+type KeywordArgument struct {
+	Token lexer.Token // the identifier token
+	Name  *Identifier
+	Value Expression
+}
+
+func (ka *KeywordArgument) expressionNode()      {}
+func (ka *KeywordArgument) TokenLiteral() string { return ka.Token.Literal }
+
+func (ka *KeywordArgument) String() string {
+	return ka.Name.String() + " = " + ka.Value.String()
+}
+
+// SpreadArgument spreads an array into positional arguments, as in f(*सूची),
+// or a hash into keyword arguments, as in f(**विकल्प)
+type SpreadArgument struct {
+	Token    lexer.Token // the first * token
+	Value    Expression
+	Keywords bool // ** rather than *
+}
+
+func (sa *SpreadArgument) expressionNode()      {}
+func (sa *SpreadArgument) TokenLiteral() string { return sa.Token.Literal }
+
+func (sa *SpreadArgument) String() string {
+	if sa.Keywords {
+		return "**" + sa.Value.String()
+	}
+	return "*" + sa.Value.String()
+}
+
+// WithStatement runs a block inside a context manager, as in
+// सँग खोल्नुहोस्("क.txt", "r") जस्तो फाइल { ... }
+type WithStatement struct {
+	Token   lexer.Token // the सँग token
+	Context Expression
+	Name    *Identifier // nil when the entered value is not bound
+	Body    *BlockStatement
+}
+
+func (ws *WithStatement) statementNode()       {}
+func (ws *WithStatement) TokenLiteral() string { return ws.Token.Literal }
+
+func (ws *WithStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ws.TokenLiteral() + " " + ws.Context.String())
+	if ws.Name != nil {
+		out.WriteString(" " + lexer.AS + " " + ws.Name.String())
+	}
+	out.WriteString(" " + ws.Body.String())
+
+	return out.String()
+}
+
+// MatchExpression runs the body of the first case whose pattern matches the
+// subject and whose guard holds, as in
+// मिलान x { अवस्था [a, *बाँकी] यदि a > ० { ... } अवस्था _ { ... } }
+type MatchExpression struct {
+	Token   lexer.Token // the मिलान token
+	Subject Expression
+	Cases   []*MatchCase
+	Rbrace  lexer.Token // the closing } token
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(me.TokenLiteral() + " " + me.Subject.String() + " {")
+	for _, c := range me.Cases {
+		out.WriteString(" " + c.String())
+	}
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// MatchCase is one `अवस्था pattern [यदि guard] { body }` arm
+type MatchCase struct {
+	Token   lexer.Token // the अवस्था token
+	Pattern Pattern
+	Guard   Expression // nil when there is no guard
+	Body    *BlockStatement
+	Locals  []string // set by the resolver: the slots of the case's scope
+}
+
+func (mc *MatchCase) String() string {
+	out := mc.Token.Literal + " " + mc.Pattern.String()
+	if mc.Guard != nil {
+		out += " " + lexer.IF + " " + mc.Guard.String()
+	}
+	return out + " " + mc.Body.String()
+}
+
+// Pattern is the left-hand side of a match case
+type Pattern interface {
+	Node
+	patternNode()
+}
+
+// LiteralPattern matches a value equal to a literal
+type LiteralPattern struct {
+	Token lexer.Token
+	Value Expression
+}
+
+func (lp *LiteralPattern) patternNode()         {}
+func (lp *LiteralPattern) TokenLiteral() string { return lp.Token.Literal }
+func (lp *LiteralPattern) String() string       { return lp.Value.String() }
+
+// BindingPattern matches anything and binds it to a name; the name _ only
+// matches
+type BindingPattern struct {
+	Name *Identifier
+}
+
+func (bp *BindingPattern) patternNode()         {}
+func (bp *BindingPattern) TokenLiteral() string { return bp.Name.TokenLiteral() }
+func (bp *BindingPattern) String() string       { return bp.Name.String() }
+
+// IsWildcard reports whether the pattern is _
+func (bp *BindingPattern) IsWildcard() bool { return bp.Name.Value == "_" }
+
+// ArrayPattern destructures an array. Rest, when present, collects the
+// elements between Before and After.
+type ArrayPattern struct {
+	Token  lexer.Token // the '[' token
+	Before []Pattern
+	Rest   *Identifier
+	After  []Pattern
+}
+
+func (ap *ArrayPattern) patternNode()         {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+
+func (ap *ArrayPattern) String() string {
+	parts := []string{}
+	for _, p := range ap.Before {
+		parts = append(parts, p.String())
+	}
+	if ap.Rest != nil {
+		parts = append(parts, "*"+ap.Rest.String())
+	}
+	for _, p := range ap.After {
+		parts = append(parts, p.String())
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// HashPattern destructures a hash holding at least the given keys. Rest,
+// when present, collects the other pairs.
+type HashPattern struct {
+	Token  lexer.Token // the '{' token
+	Keys   []Expression
+	Values []Pattern
+	Rest   *Identifier
+}
+
+func (hp *HashPattern) patternNode()         {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+
+func (hp *HashPattern) String() string {
+	parts := []string{}
+	for i, k := range hp.Keys {
+		parts = append(parts, k.String()+": "+hp.Values[i].String())
+	}
+	if hp.Rest != nil {
+		parts = append(parts, "**"+hp.Rest.String())
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -0,0 +1,290 @@
+// Package async runs the async function calls of both engines: the event
+// loop that drives them, the coroutines their bodies run on, and the
+// builtins that sleep and wait.
+package async
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SunilNeupane77/nepali/internal/eventloop"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// loop drives every async function call
+var loop = eventloop.New(eventloop.SystemClock{})
+
+// SetLoop replaces the event loop that drives async functions. Tests pass
+// a loop built on eventloop.NewVirtualClock so sleeps cost no real time.
+func SetLoop(l *eventloop.Loop) {
+	loop = l
+}
+
+// Loop returns the event loop that drives async functions
+func Loop() *eventloop.Loop {
+	return loop
+}
+
+// coroutine runs the body of one async function call on its own goroutine.
+// Control is handed back and forth over unbuffered channels, so only one
+// coroutine (or the loop itself) is ever running at a time.
+type coroutine struct {
+	resume chan struct{}
+	yield  chan struct{}
+
+	// switched is told as the coroutine resumes, with true, and as it
+	// suspends or finishes, with false, so that the engine can swap in
+	// the state its body runs with
+	switched func(resumed bool)
+
+	done     bool // once its body has finished, or been stopped
+	stopping bool // set to unwind its body when next resumed
+}
+
+// current is the coroutine whose body is executing, or nil when top-level
+// code is
+var current *coroutine
+
+// coroutines are those started and not yet done
+var coroutines = map[*coroutine]bool{}
+
+// stopCoroutine is the panic that unwinds a coroutine being stopped
+type stopCoroutine struct{}
+
+// Start schedules run on the event loop, on a coroutine of its own, and
+// returns a promise for what it returns. switched, if not nil, is called
+// on the loop's side as the coroutine resumes and suspends.
+func Start(run func() object.Object, switched func(resumed bool)) *object.Promise {
+	promise := &object.Promise{}
+	co := &coroutine{resume: make(chan struct{}), yield: make(chan struct{}), switched: switched}
+	coroutines[co] = true
+
+	go func() {
+		defer func() {
+			co.done = true
+			co.yield <- struct{}{}
+		}()
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(stopCoroutine); !ok {
+					panic(r)
+				}
+			}
+		}()
+
+		co.wait()
+
+		result := run()
+		if err, ok := result.(*object.Error); ok {
+			promise.Reject(err)
+		} else {
+			if result == nil {
+				result = object.NULL
+			}
+			promise.Resolve(result)
+		}
+	}()
+
+	loop.Schedule(co.step)
+	return promise
+}
+
+// Running reports whether the body of an async function call is executing
+func Running() bool {
+	return current != nil
+}
+
+// Stopping reports whether the body executing is being unwound by Stop,
+// which is not something the program does
+func Stopping() bool {
+	return current != nil && current.stopping
+}
+
+// step runs the coroutine until it finishes or suspends on an await
+func (co *coroutine) step() {
+	if co.done {
+		return // stopped before the loop got to it
+	}
+
+	prev := current
+	current = co
+	if co.switched != nil {
+		co.switched(true)
+	}
+
+	co.resume <- struct{}{}
+	<-co.yield
+
+	if co.switched != nil {
+		co.switched(false)
+	}
+	current = prev
+	if co.done {
+		delete(coroutines, co)
+	}
+}
+
+// suspend hands control back to the loop until step is called again
+func (co *coroutine) suspend() {
+	co.yield <- struct{}{}
+	co.wait()
+}
+
+// wait blocks until the coroutine is resumed, unwinding its body if it is
+// being stopped
+func (co *coroutine) wait() {
+	<-co.resume
+	if co.stopping {
+		panic(stopCoroutine{})
+	}
+}
+
+// Await waits for promise to settle and returns what it settled to, the
+// error it was rejected with included. Inside an async function the
+// coroutine is suspended; at top level the loop runs until it settles.
+func Await(promise *object.Promise) object.Object {
+	if !promise.Settled() {
+		if co := current; co != nil {
+			promise.OnSettle(func() { loop.Schedule(co.step) })
+			co.suspend()
+		} else {
+			loop.RunUntil(promise.Settled)
+		}
+	}
+
+	if !promise.Settled() {
+		Stop()
+		return &object.Error{Message: "promise can never settle: no pending tasks or timers"}
+	}
+
+	return promise.Value
+}
+
+// Finish runs the async work a top-level program started but never
+// awaited to completion, then stops what is left. Inside an async function
+// it does nothing, since the loop is already running.
+func Finish() {
+	if current != nil {
+		return
+	}
+	loop.Run()
+	Stop()
+}
+
+// Stop ends the coroutines left once the loop is idle. They wait on
+// promises that nothing is left to settle, so would otherwise keep their
+// goroutines forever.
+func Stop() {
+	for co := range coroutines {
+		co.stopping = true
+		co.step()
+	}
+}
+
+// Docs describes the async builtins, as builtins.Doc does the others
+var Docs = map[string]string{
+	"सुत्नुहोस्": "सुत्नुहोस्(ms) returns a promise that settles after ms milliseconds",
+	"अहिले":      "अहिले() returns the milliseconds elapsed on the event loop's clock",
+	"सबै":        "सबै(promises) returns a promise for the array of all their results",
+	"चलाउनुहोस्": "चलाउनुहोस्(promise) runs the event loop until promise settles",
+}
+
+// Builtins are the async builtins
+var Builtins = map[string]*object.Builtin{
+	// सुत्नुहोस्(ms) returns a promise that settles after ms milliseconds
+	"सुत्नुहोस्": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			ms, ok := args[0].(*object.Integer)
+			if !ok {
+				return newError("argument to `सुत्नुहोस्` must be INTEGER, got %s", args[0].Type())
+			}
+
+			promise := &object.Promise{}
+			loop.After(time.Duration(ms.Value)*time.Millisecond, func() {
+				promise.Resolve(object.NULL)
+			})
+			return promise
+		},
+	},
+	// अहिले() returns the milliseconds elapsed on the event loop's clock
+	"अहिले": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args))
+			}
+
+			return &object.Integer{Value: loop.Elapsed().Milliseconds()}
+		},
+	},
+	// सबै(promises) returns a promise for the array of all their results
+	"सबै": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `सबै` must be ARRAY, got %s", args[0].Type())
+			}
+
+			promise := &object.Promise{}
+			results := make([]object.Object, len(arr.Elements))
+			remaining := len(arr.Elements)
+
+			for i, el := range arr.Elements {
+				i := i
+				p, ok := el.(*object.Promise)
+				if !ok {
+					results[i] = el
+					remaining--
+					continue
+				}
+
+				p.OnSettle(func() {
+					if err, ok := p.Value.(*object.Error); ok {
+						promise.Reject(err)
+						return
+					}
+					results[i] = p.Value
+					remaining--
+					if remaining == 0 {
+						promise.Resolve(&object.Array{Elements: results})
+					}
+				})
+			}
+
+			if remaining == 0 {
+				promise.Resolve(&object.Array{Elements: results})
+			}
+			return promise
+		},
+	},
+	// चलाउनुहोस्(promise) runs the event loop until promise settles
+	"चलाउनुहोस्": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if current != nil {
+				return newError("`चलाउनुहोस्` cannot be called from a running async function; use पर्ख")
+			}
+
+			promise, ok := args[0].(*object.Promise)
+			if !ok {
+				return newError("argument to `चलाउनुहोस्` must be PROMISE, got %s", args[0].Type())
+			}
+
+			return Await(promise)
+		},
+	},
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
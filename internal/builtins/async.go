@@ -0,0 +1,10 @@
+package builtins
+
+import "github.com/SunilNeupane77/nepali/internal/async"
+
+func init() {
+	for name, fn := range async.Builtins {
+		builtins[name] = fn
+		docs[name] = async.Docs[name]
+	}
+}
@@ -1,12 +1,24 @@
 // Package builtins implements built-in functions for the Nepali programming language
 package builtins
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
 
-import "github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/grapheme"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
 
+// Output is where प्रिन्ट and the other printing builtins write. A debugger
+// replaces it, so that what the program prints does not mix with its own
+// output.
+var Output io.Writer = os.Stdout
 
 var builtins = map[string]*object.Builtin{
+	// लेन(value) returns the number of elements in an ARRAY or SET, of pairs
+	// in a HASH, or of characters (grapheme clusters) in a STRING
 	"लेन": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -15,27 +27,31 @@ var builtins = map[string]*object.Builtin{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(grapheme.Count(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
+			case *object.Set:
+				return &object.Integer{Value: int64(arg.Len())}
 			default:
-				return newError("argument to `लेन` must be STRING or ARRAY, got %s", args[0].Type())
+				return newError("argument to `लेन` must be STRING, ARRAY, HASH or SET, got %s", args[0].Type())
 			}
 		},
 	},
 	"प्रिन्ट": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Printf("%s ", arg.Inspect())
+				fmt.Fprintf(Output, "%s ", arg.Inspect())
 			}
-			fmt.Printf("\n")
+			fmt.Fprintf(Output, "\n")
 			return object.NULL
 		},
 	},
 	"प्रिन्टल": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Printf("%s\n", arg.Inspect())
+				fmt.Fprintf(Output, "%s\n", arg.Inspect())
 			}
 			return object.NULL
 		},
@@ -46,7 +62,17 @@ var builtins = map[string]*object.Builtin{
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
-			return &object.String{Value: strings.ToLower(args[0].Type())}
+			return &object.String{Value: strings.ToLower(string(args[0].Type()))}
+		},
+	},
+	// तुलना(a, b) returns -1, 0 or 1 as a sorts before, equal to or after b
+	"तुलना": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			return &object.Integer{Value: int64(object.Compare(args[0], args[1]))}
 		},
 	},
 	"स्ट्रिंग": &object.Builtin{
@@ -60,13 +86,57 @@ var builtins = map[string]*object.Builtin{
 	},
 }
 
+// CallFunction calls a function value with args. The evaluator sets it, so
+// that builtins taking callbacks, like क्रमबद्ध, can run user code.
+var CallFunction func(fn object.Object, args ...object.Object) object.Object
+
+// callFunction calls fn with args, whichever engine made it
+func callFunction(fn object.Object, args ...object.Object) object.Object {
+	switch fn := fn.(type) {
+	case object.Callable:
+		return fn.Call(args...)
+	case *object.Builtin:
+		return fn.Fn(args...)
+	}
+
+	if CallFunction == nil {
+		return newError("not a function: %s", fn.Type())
+	}
+	return CallFunction(fn, args...)
+}
+
+// methods holds the builtins reachable through member access, by receiver type
+var methods = map[object.ObjectType]map[string]*object.Builtin{}
+
 func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
-func Lookup(name string) object.Object {
+// Lookup returns the builtin function registered under name
+func Lookup(name string) (object.Object, bool) {
 	if builtin, ok := builtins[name]; ok {
-		return builtin
+		return builtin, true
+	}
+	return nil, false
+}
+
+// LookupMethod returns the method called name on obj, bound to obj
+func LookupMethod(obj object.Object, name string) (object.Object, bool) {
+	method, ok := methods[obj.Type()][name]
+	if !ok {
+		return nil, false
+	}
+
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return method.Fn(append([]object.Object{obj}, args...)...)
+		},
+	}, true
+}
+
+func nativeBool(input bool) *object.Boolean {
+	if input {
+		return object.TRUE
 	}
-	return nil
+	return object.FALSE
 }
@@ -0,0 +1,136 @@
+package builtins
+
+import "github.com/SunilNeupane77/nepali/internal/object"
+
+var collectionBuiltins = map[string]*object.Builtin{
+	// जमाउनुहोस्(value) makes value, and every array, hash and set inside
+	// it, read-only, and returns it
+	"जमाउनुहोस्": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return object.Freeze(args[0])
+		},
+	},
+	// जमेको(value) reports whether value has been frozen
+	"जमेको": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			return nativeBool(object.IsFrozen(args[0]))
+		},
+	},
+}
+
+var arrayMethods = map[string]*object.Builtin{
+	// थप(values...) appends values to the array in place
+	"थप": {
+		Fn: func(args ...object.Object) object.Object {
+			arr := args[0].(*object.Array)
+			if err := checkMutable(arr); err != nil {
+				return err
+			}
+
+			arr.Elements = append(arr.Elements, args[1:]...)
+			return object.NULL
+		},
+	},
+}
+
+var hashMethods = map[string]*object.Builtin{
+	// राख(key, value) stores value under key in place
+	"राख": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args)-1)
+			}
+			hash := args[0].(*object.Hash)
+			if err := checkMutable(hash); err != nil {
+				return err
+			}
+
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			hash.Set(key, args[2])
+			return object.NULL
+		},
+	},
+	// हटाउ(key) removes key, reporting whether it was present
+	"हटाउ": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args)-1)
+			}
+			hash := args[0].(*object.Hash)
+			if err := checkMutable(hash); err != nil {
+				return err
+			}
+
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", args[1].Type())
+			}
+			return nativeBool(hash.Delete(key))
+		},
+	},
+}
+
+var setMethods = map[string]*object.Builtin{
+	// थप(values...) adds values to the set in place
+	"थप": {
+		Fn: func(args ...object.Object) object.Object {
+			set := args[0].(*object.Set)
+			if err := checkMutable(set); err != nil {
+				return err
+			}
+
+			for _, arg := range args[1:] {
+				el, ok := arg.(object.Hashable)
+				if !ok {
+					return newError("unusable as set element: %s", arg.Type())
+				}
+				set.Add(el)
+			}
+			return object.NULL
+		},
+	},
+	// हटाउ(value) removes value, reporting whether it was present
+	"हटाउ": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args)-1)
+			}
+			set := args[0].(*object.Set)
+			if err := checkMutable(set); err != nil {
+				return err
+			}
+
+			el, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError("unusable as set element: %s", args[1].Type())
+			}
+			return nativeBool(set.Remove(el))
+		},
+	},
+}
+
+// checkMutable returns an error if obj has been frozen
+func checkMutable(obj object.Object) *object.Error {
+	if object.IsFrozen(obj) {
+		return newError("cannot modify frozen %s", obj.Type())
+	}
+	return nil
+}
+
+func init() {
+	for name, fn := range collectionBuiltins {
+		builtins[name] = fn
+	}
+	methods[object.ARRAY_OBJ] = arrayMethods
+	methods[object.HASH_OBJ] = hashMethods
+	methods[object.SET_OBJ] = setMethods
+}
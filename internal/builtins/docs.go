@@ -0,0 +1,83 @@
+package builtins
+
+import (
+	"sort"
+	"strings"
+)
+
+// docs describes each builtin function for tools such as the language
+// server, with its signature first. In a signature, a parameter ending in ?
+// may be left out, and one ending in ... takes any number of values.
+var docs = map[string]string{
+	"लेन":      "लेन(value) returns the number of elements in an ARRAY or SET, of pairs in a HASH, or of characters (grapheme clusters) in a STRING",
+	"प्रिन्ट":  "प्रिन्ट(values...) prints values on one line, separated by spaces",
+	"प्रिन्टल": "प्रिन्टल(values...) prints each value on a line of its own",
+	"टाइप":     "टाइप(value) returns the name of value's type, in lower case, as a STRING",
+	"तुलना":    "तुलना(a, b) returns -1, 0 or 1 as a sorts before, equal to or after b",
+	"स्ट्रिंग": "स्ट्रिंग(value) returns value written out as a STRING",
+
+	"जमाउनुहोस्": "जमाउनुहोस्(value) makes value, and every array, hash and set inside it, read-only, and returns it",
+	"जमेको":      "जमेको(value) reports whether value has been frozen",
+
+	"खोल्नुहोस्":    "खोल्नुहोस्(path, mode?) opens a file; mode defaults to \"r\"",
+	"फोल्डर_सूची":   "फोल्डर_सूची(path) returns the sorted names of the entries in a directory",
+	"अवस्थित":       "अवस्थित(path) reports whether a file or directory exists",
+	"पथ_जोड्नुहोस्": "पथ_जोड्नुहोस्(parts...) joins path elements with the OS separator",
+
+	"जेसन_पढ":  "जेसन_पढ(text) decodes a JSON document",
+	"जेसन_लेख": "जेसन_लेख(value, indent?) encodes value as JSON, indenting nested values by indent spaces when it is given",
+
+	"क्रमबद्ध":     "क्रमबद्ध(array, options?) returns a stable sorted copy of array. Options may hold \"कुञ्जी\", \"तुलना\", \"नेपाली\" and \"उल्टो\".",
+	"नेपाली_तुलना": "नेपाली_तुलना(a, b) compares like तुलना, but orders strings the way Nepali dictionaries do",
+
+	"अक्षरहरू": "अक्षरहरू(s) returns the characters of s as an ARRAY of STRINGs",
+	"रुनहरू":   "रुनहरू(s) returns the Unicode code points of s as INTEGERs",
+	"बाइटहरू":  "बाइटहरू(s) returns the UTF-8 bytes of s as INTEGERs",
+	"टुक्रा":   "टुक्रा(value, start?, end?, step?) is the builtin form of value[start:end:step]",
+}
+
+// Doc returns the documentation of the builtin function called name
+func Doc(name string) (string, bool) {
+	doc, ok := docs[name]
+	return doc, ok
+}
+
+// Arity returns how many arguments the builtin function called name takes,
+// from its signature; max is -1 if there is no limit
+func Arity(name string) (min, max int, ok bool) {
+	doc, ok := docs[name]
+	if !ok {
+		return 0, 0, false
+	}
+	open, close := strings.Index(doc, "("), strings.Index(doc, ")")
+	if open < 0 || close < open {
+		return 0, 0, false
+	}
+
+	params := strings.TrimSpace(doc[open+1 : close])
+	if params == "" {
+		return 0, 0, true
+	}
+	for _, param := range strings.Split(params, ",") {
+		switch param = strings.TrimSpace(param); {
+		case strings.HasSuffix(param, "..."):
+			return min, -1, true
+		case strings.HasSuffix(param, "?"):
+			max++
+		default:
+			min++
+			max++
+		}
+	}
+	return min, max, true
+}
+
+// Names returns the names of the builtin functions, sorted
+func Names() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,326 @@
+package builtins
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// fileModes maps the modes accepted by खोल्नुहोस् to os.OpenFile flags
+var fileModes = map[string]int{
+	"r":  os.O_RDONLY,
+	"r+": os.O_RDWR,
+	"w":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	"w+": os.O_RDWR | os.O_CREATE | os.O_TRUNC,
+	"a":  os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"a+": os.O_RDWR | os.O_CREATE | os.O_APPEND,
+	"x":  os.O_WRONLY | os.O_CREATE | os.O_EXCL,
+}
+
+var fileBuiltins = map[string]*object.Builtin{
+	// खोल्नुहोस्(path, mode) opens a file; mode defaults to "r"
+	"खोल्नुहोस्": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `खोल्नुहोस्` must be STRING, got %s", args[0].Type())
+			}
+
+			mode := "r"
+			if len(args) == 2 {
+				m, ok := args[1].(*object.String)
+				if !ok {
+					return newError("mode passed to `खोल्नुहोस्` must be STRING, got %s", args[1].Type())
+				}
+				mode = m.Value
+			}
+
+			flags, ok := fileModes[mode]
+			if !ok {
+				return newError("अमान्य फाइल मोड: %q", mode)
+			}
+
+			handle, err := os.OpenFile(path.Value, flags, 0o644)
+			if err != nil {
+				return fileError(err, path.Value)
+			}
+
+			file := &object.File{Path: path.Value, Mode: mode, Handle: handle}
+			if mode == "r" || strings.HasSuffix(mode, "+") {
+				file.Reader = bufio.NewReader(handle)
+			}
+			return file
+		},
+	},
+	// फोल्डर_सूची(path) returns the sorted names of the entries in a directory
+	"फोल्डर_सूची": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `फोल्डर_सूची` must be STRING, got %s", args[0].Type())
+			}
+
+			entries, err := os.ReadDir(path.Value)
+			if err != nil {
+				return fileError(err, path.Value)
+			}
+
+			names := make([]string, len(entries))
+			for i, e := range entries {
+				names[i] = e.Name()
+			}
+			sort.Strings(names)
+
+			elements := make([]object.Object, len(names))
+			for i, n := range names {
+				elements[i] = &object.String{Value: n}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// अवस्थित(path) reports whether a file or directory exists
+	"अवस्थित": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			path, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `अवस्थित` must be STRING, got %s", args[0].Type())
+			}
+
+			_, err := os.Stat(path.Value)
+			return nativeBool(err == nil)
+		},
+	},
+	// पथ_जोड्नुहोस्(parts...) joins path elements with the OS separator
+	"पथ_जोड्नुहोस्": {
+		Fn: func(args ...object.Object) object.Object {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				s, ok := arg.(*object.String)
+				if !ok {
+					return newError("arguments to `पथ_जोड्नुहोस्` must be STRING, got %s", arg.Type())
+				}
+				parts[i] = s.Value
+			}
+
+			return &object.String{Value: filepath.Join(parts...)}
+		},
+	},
+}
+
+// fileMethods are reached through member access on a file, as in f.पढ()
+var fileMethods = map[string]*object.Builtin{
+	// पढ() returns everything left in the file
+	"पढ": {
+		Fn: func(args ...object.Object) object.Object {
+			file, errObj := fileReceiver(args, 0, true)
+			if errObj != nil {
+				return errObj
+			}
+
+			data, err := io.ReadAll(file.Reader)
+			if err != nil {
+				return fileError(err, file.Path)
+			}
+			return &object.String{Value: string(data)}
+		},
+	},
+	// लाइन_पढ() returns the next line including its newline, or "" at the end
+	"लाइन_पढ": {
+		Fn: func(args ...object.Object) object.Object {
+			file, errObj := fileReceiver(args, 0, true)
+			if errObj != nil {
+				return errObj
+			}
+
+			line, err := file.Reader.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return fileError(err, file.Path)
+			}
+			return &object.String{Value: line}
+		},
+	},
+	// लाइनहरू() returns the remaining lines, without their newlines
+	"लाइनहरू": {
+		Fn: func(args ...object.Object) object.Object {
+			file, errObj := fileReceiver(args, 0, true)
+			if errObj != nil {
+				return errObj
+			}
+
+			lines, errObj := Lines(file)
+			if errObj != nil {
+				return errObj
+			}
+			return &object.Array{Elements: lines}
+		},
+	},
+	// लेख(text) writes text and returns the number of bytes written
+	"लेख": {
+		Fn: func(args ...object.Object) object.Object {
+			file, errObj := fileReceiver(args, 1, false)
+			if errObj != nil {
+				return errObj
+			}
+			if file.Mode == "r" {
+				return newError("फाइल लेख्नका लागि खोलिएको छैन: %s", file.Path)
+			}
+
+			text, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `लेख` must be STRING, got %s", args[1].Type())
+			}
+
+			if err := unread(file); err != nil {
+				return fileError(err, file.Path)
+			}
+			n, err := file.Handle.WriteString(text.Value)
+			if err != nil {
+				return fileError(err, file.Path)
+			}
+			return &object.Integer{Value: int64(n)}
+		},
+	},
+	// बन्द() closes the file; closing twice is harmless
+	"बन्द": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args)-1)
+			}
+			return closeFile(args[0].(*object.File))
+		},
+	},
+	// प्रवेश and निकास let a file be used with सँग, which closes it when
+	// the block ends
+	"प्रवेश": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args)-1)
+			}
+			return args[0]
+		},
+	},
+	"निकास": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args)-1)
+			}
+			if err := closeFile(args[0].(*object.File)); err != object.NULL {
+				return err
+			}
+			return object.FALSE
+		},
+	},
+}
+
+// Lines reads the lines left in file, without their newlines, as a loop
+// over a file does
+func Lines(file *object.File) ([]object.Object, *object.Error) {
+	if file.Closed {
+		return nil, newError("बन्द भइसकेको फाइल प्रयोग गर्न मिल्दैन: %s", file.Path)
+	}
+	if file.Reader == nil {
+		return nil, newError("फाइल पढ्नका लागि खोलिएको छैन: %s", file.Path)
+	}
+
+	lines := []object.Object{}
+	for {
+		line, err := file.Reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			lines = append(lines, &object.String{Value: line})
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, fileError(err, file.Path)
+		}
+	}
+}
+
+// unread gives back what file's reader has read ahead of the program, so
+// that a write lands just after what was read rather than after the read
+// ahead
+func unread(file *object.File) error {
+	if file.Reader == nil {
+		return nil
+	}
+	if n := file.Reader.Buffered(); n > 0 {
+		if _, err := file.Handle.Seek(-int64(n), io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+	file.Reader.Reset(file.Handle)
+	return nil
+}
+
+// closeFile closes file unless it is already closed
+func closeFile(file *object.File) object.Object {
+	if file.Closed {
+		return object.NULL
+	}
+
+	file.Closed = true
+	if err := file.Handle.Close(); err != nil {
+		return fileError(err, file.Path)
+	}
+	return object.NULL
+}
+
+// fileReceiver checks the arguments of a file method: the file itself
+// followed by want further arguments
+func fileReceiver(args []object.Object, want int, reading bool) (*object.File, *object.Error) {
+	if len(args) != want+1 {
+		return nil, newError("wrong number of arguments. got=%d, want=%d", len(args)-1, want)
+	}
+
+	file := args[0].(*object.File)
+	if file.Closed {
+		return nil, newError("बन्द भइसकेको फाइल प्रयोग गर्न मिल्दैन: %s", file.Path)
+	}
+	if reading && file.Reader == nil {
+		return nil, newError("फाइल पढ्नका लागि खोलिएको छैन: %s", file.Path)
+	}
+
+	return file, nil
+}
+
+// fileError converts an I/O error into a runtime error with a Nepali message
+func fileError(err error, path string) *object.Error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return newError("फाइल वा फोल्डर भेटिएन: %s", path)
+	case errors.Is(err, fs.ErrExist):
+		return newError("फाइल पहिले नै अवस्थित छ: %s", path)
+	case errors.Is(err, fs.ErrPermission):
+		return newError("अनुमति छैन: %s", path)
+	default:
+		return newError("फाइल त्रुटि (%s): %s", path, err)
+	}
+}
+
+func init() {
+	for name, fn := range fileBuiltins {
+		builtins[name] = fn
+	}
+	methods[object.FILE_OBJ] = fileMethods
+}
@@ -0,0 +1,143 @@
+package builtins
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+func call(t *testing.T, name string, args ...object.Object) object.Object {
+	t.Helper()
+
+	fn, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("builtin %s not found", name)
+	}
+	return fn.(*object.Builtin).Fn(args...)
+}
+
+func callMethod(t *testing.T, receiver object.Object, name string, args ...object.Object) object.Object {
+	t.Helper()
+
+	fn, ok := LookupMethod(receiver, name)
+	if !ok {
+		t.Fatalf("method %s not found on %s", name, receiver.Type())
+	}
+	return fn.(*object.Builtin).Fn(args...)
+}
+
+func str(s string) *object.String {
+	return &object.String{Value: s}
+}
+
+func TestFileWriteAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "नमुना.txt")
+
+	f := call(t, "खोल्नुहोस्", str(path), str("w"))
+	if n := callMethod(t, f, "लेख", str("पहिलो\nदोस्रो\n")); n.Inspect() != "35" {
+		t.Errorf("wrong byte count written. got=%s", n.Inspect())
+	}
+	callMethod(t, f, "बन्द")
+
+	f = call(t, "खोल्नुहोस्", str(path), str("a"))
+	callMethod(t, f, "लेख", str("तेस्रो"))
+	callMethod(t, f, "बन्द")
+
+	f = call(t, "खोल्नुहोस्", str(path))
+	if line := callMethod(t, f, "लाइन_पढ"); line.Inspect() != "पहिलो\n" {
+		t.Errorf("wrong first line. got=%q", line.Inspect())
+	}
+	if lines := callMethod(t, f, "लाइनहरू"); lines.Inspect() != "[दोस्रो, तेस्रो]" {
+		t.Errorf("wrong remaining lines. got=%s", lines.Inspect())
+	}
+	if rest := callMethod(t, f, "पढ"); rest.Inspect() != "" {
+		t.Errorf("expected nothing left to read. got=%q", rest.Inspect())
+	}
+	callMethod(t, f, "बन्द")
+}
+
+func TestFileWriteAfterRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "नमुना.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The write lands after the line read, not after what the reader read
+	// ahead of it
+	f := call(t, "खोल्नुहोस्", str(path), str("r+"))
+	callMethod(t, f, "लाइन_पढ")
+	callMethod(t, f, "लेख", str("XX"))
+	if line := callMethod(t, f, "लाइन_पढ"); line.Inspect() != "o\n" {
+		t.Errorf("wrong line after the write. got=%q", line.Inspect())
+	}
+	callMethod(t, f, "बन्द")
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "one\nXXo\nthree\n" {
+		t.Errorf("wrong file contents. got=%q, err=%v", data, err)
+	}
+}
+
+func TestFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "छ.txt")
+	if err := os.WriteFile(existing, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	closed := call(t, "खोल्नुहोस्", str(existing))
+	callMethod(t, closed, "बन्द")
+	writeOnly := call(t, "खोल्नुहोस्", str(filepath.Join(dir, "नयाँ.txt")), str("w"))
+	readOnly := call(t, "खोल्नुहोस्", str(existing))
+
+	tests := []struct {
+		result   object.Object
+		expected string
+	}{
+		{call(t, "खोल्नुहोस्", str(filepath.Join(dir, "छैन.txt"))), "फाइल वा फोल्डर भेटिएन: " + filepath.Join(dir, "छैन.txt")},
+		{call(t, "खोल्नुहोस्", str(existing), str("x")), "फाइल पहिले नै अवस्थित छ: " + existing},
+		{call(t, "खोल्नुहोस्", str(existing), str("q")), `अमान्य फाइल मोड: "q"`},
+		{callMethod(t, closed, "पढ"), "बन्द भइसकेको फाइल प्रयोग गर्न मिल्दैन: " + existing},
+		{callMethod(t, writeOnly, "पढ"), "फाइल पढ्नका लागि खोलिएको छैन: " + filepath.Join(dir, "नयाँ.txt")},
+		{callMethod(t, readOnly, "लेख", str("x")), "फाइल लेख्नका लागि खोलिएको छैन: " + existing},
+		{call(t, "फोल्डर_सूची", str(existing)), ""},
+	}
+
+	for i, tt := range tests {
+		err, ok := tt.result.(*object.Error)
+		if !ok {
+			t.Errorf("tests[%d] - expected ERROR, got %s", i, tt.result.Inspect())
+			continue
+		}
+		if tt.expected != "" && err.Message != tt.expected {
+			t.Errorf("tests[%d] - wrong message. expected=%q, got=%q", i, tt.expected, err.Message)
+		}
+	}
+}
+
+func TestDirectoryBuiltins(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ख.txt", "क.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if list := call(t, "फोल्डर_सूची", str(dir)); list.Inspect() != "[क.txt, ख.txt]" {
+		t.Errorf("wrong listing. got=%s", list.Inspect())
+	}
+
+	joined := call(t, "पथ_जोड्नुहोस्", str(dir), str("क.txt"))
+	if joined.Inspect() != filepath.Join(dir, "क.txt") {
+		t.Errorf("wrong joined path. got=%s", joined.Inspect())
+	}
+
+	if exists := call(t, "अवस्थित", joined); exists != object.TRUE {
+		t.Errorf("expected %s to exist", joined.Inspect())
+	}
+	if exists := call(t, "अवस्थित", str(filepath.Join(dir, "छैन"))); exists != object.FALSE {
+		t.Errorf("expected missing file not to exist")
+	}
+}
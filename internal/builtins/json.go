@@ -0,0 +1,237 @@
+package builtins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+var jsonBuiltins = map[string]*object.Builtin{
+	// जेसन_पढ(text) decodes a JSON document
+	"जेसन_पढ": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			text, ok := args[0].(*object.String)
+			if !ok {
+				return newError("argument to `जेसन_पढ` must be STRING, got %s", args[0].Type())
+			}
+
+			dec := json.NewDecoder(strings.NewReader(text.Value))
+			dec.UseNumber()
+
+			val, err := decodeJSON(dec)
+			if err == nil {
+				if _, tokErr := dec.Token(); tokErr != io.EOF {
+					err = fmt.Errorf("अनपेक्षित सामग्री कागजातको अन्त्यपछि")
+				}
+			}
+			if err != nil {
+				return newError("जेसन पढ्न सकिएन: %s", err)
+			}
+			return val
+		},
+	},
+	// जेसन_लेख(value, indent) encodes value as JSON, indenting nested values
+	// by indent spaces when it is given
+	"जेसन_लेख": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			e := &jsonEncoder{seen: map[object.Object]bool{}}
+			if len(args) == 2 {
+				n, ok := args[1].(*object.Integer)
+				if !ok || n.Value < 0 {
+					return newError("indent passed to `जेसन_लेख` must be a non-negative INTEGER, got %s", args[1].Inspect())
+				}
+				e.indent = strings.Repeat(" ", int(n.Value))
+			}
+
+			if err := e.encode(args[0], 0); err != nil {
+				return err
+			}
+			return &object.String{Value: e.buf.String()}
+		},
+	},
+}
+
+// decodeJSON reads one value from dec. Objects are read token by token so
+// their keys are inserted in document order.
+func decodeJSON(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '[':
+			elements := []object.Object{}
+			for dec.More() {
+				el, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, el)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return &object.Array{Elements: elements}, nil
+		case '{':
+			hash := object.NewHash()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key := &object.String{Value: keyTok.(string)}
+
+				value, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				hash.Set(key, value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return hash, nil
+		}
+		return nil, fmt.Errorf("अनपेक्षित %s", tok)
+	case json.Number:
+		n, err := strconv.ParseInt(tok.String(), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("संख्या %s पूर्णाङ्क होइन", tok)
+		}
+		return &object.Integer{Value: n}, nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case bool:
+		return nativeBool(tok), nil
+	default:
+		return object.NULL, nil
+	}
+}
+
+type jsonEncoder struct {
+	buf    bytes.Buffer
+	indent string
+	seen   map[object.Object]bool // arrays and hashes on the current path, to catch cycles
+}
+
+func (e *jsonEncoder) encode(obj object.Object, depth int) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		e.buf.WriteString("null")
+	case *object.Boolean:
+		e.buf.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		e.buf.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.String:
+		e.writeString(obj.Value)
+	case *object.Array:
+		if e.seen[obj] {
+			return newError("चक्रीय मान जेसनमा बदल्न मिल्दैन")
+		}
+		e.seen[obj] = true
+		defer delete(e.seen, obj)
+
+		e.buf.WriteByte('[')
+		for i, el := range obj.Elements {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.newline(depth + 1)
+			if err := e.encode(el, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(obj.Elements) > 0 {
+			e.newline(depth)
+		}
+		e.buf.WriteByte(']')
+	case *object.Hash:
+		if e.seen[obj] {
+			return newError("चक्रीय मान जेसनमा बदल्न मिल्दैन")
+		}
+		e.seen[obj] = true
+		defer delete(e.seen, obj)
+
+		e.buf.WriteByte('{')
+		for i, pair := range obj.Pairs() {
+			if i > 0 {
+				e.buf.WriteByte(',')
+			}
+			e.newline(depth + 1)
+
+			key, err := jsonKey(pair.Key)
+			if err != nil {
+				return err
+			}
+			e.writeString(key)
+			e.buf.WriteByte(':')
+			if e.indent != "" {
+				e.buf.WriteByte(' ')
+			}
+
+			if err := e.encode(pair.Value, depth+1); err != nil {
+				return err
+			}
+		}
+		if obj.Len() > 0 {
+			e.newline(depth)
+		}
+		e.buf.WriteByte('}')
+	default:
+		return newError("%s लाई जेसनमा बदल्न मिल्दैन", obj.Type())
+	}
+
+	return nil
+}
+
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.buf.WriteByte('\n')
+	e.buf.WriteString(strings.Repeat(e.indent, depth))
+}
+
+// writeString quotes s without escaping Devanagari or HTML characters
+func (e *jsonEncoder) writeString(s string) {
+	enc := json.NewEncoder(&e.buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	e.buf.Truncate(e.buf.Len() - 1) // Encode appends a newline
+}
+
+// jsonKey converts a hash key to the string used for it in a JSON object
+func jsonKey(key object.Object) (string, *object.Error) {
+	switch key := key.(type) {
+	case *object.String:
+		return key.Value, nil
+	case *object.Integer:
+		return strconv.FormatInt(key.Value, 10), nil
+	case *object.Boolean:
+		return strconv.FormatBool(key.Value), nil
+	default:
+		return "", newError("%s कुञ्जी जेसनमा बदल्न मिल्दैन", key.Type())
+	}
+}
+
+func init() {
+	for name, fn := range jsonBuiltins {
+		builtins[name] = fn
+	}
+}
@@ -0,0 +1,77 @@
+package builtins
+
+import (
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"नाम": "नेपाल", "जनसंख्या": 30000000, "सदस्य": true, "राजा": null}`,
+			`{"नाम":"नेपाल","जनसंख्या":30000000,"सदस्य":true,"राजा":null}`},
+		{`{"z": 1, "a": 2, "m": {"y": 1, "b": 2}}`, `{"z":1,"a":2,"m":{"y":1,"b":2}}`},
+		{`[1, [2, 3], {"a": []}, {}]`, `[1,[2,3],{"a":[]},{}]`},
+		{`"<a & b>"`, `"<a & b>"`},
+		{`"line\nbreak \"quoted\""`, `"line\nbreak \"quoted\""`},
+		{`-42`, `-42`},
+	}
+
+	for i, tt := range tests {
+		decoded := call(t, "जेसन_पढ", str(tt.input))
+		if err, ok := decoded.(*object.Error); ok {
+			t.Fatalf("tests[%d] - decode failed: %s", i, err.Message)
+		}
+
+		encoded := call(t, "जेसन_लेख", decoded)
+		if encoded.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - wrong encoding. expected=%s, got=%s", i, tt.expected, encoded.Inspect())
+		}
+	}
+}
+
+func TestJSONIndent(t *testing.T) {
+	value := call(t, "जेसन_पढ", str(`{"क": [1, 2], "ख": {}}`))
+	encoded := call(t, "जेसन_लेख", value, &object.Integer{Value: 2})
+
+	expected := `{
+  "क": [
+    1,
+    2
+  ],
+  "ख": {}
+}`
+	if encoded.Inspect() != expected {
+		t.Errorf("wrong indented encoding. expected=\n%s\ngot=\n%s", expected, encoded.Inspect())
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "फन"}, &object.Builtin{})
+
+	tests := []struct {
+		result   object.Object
+		expected string
+	}{
+		{call(t, "जेसन_लेख", &object.Function{}), "FUNCTION लाई जेसनमा बदल्न मिल्दैन"},
+		{call(t, "जेसन_लेख", hash), "BUILTIN लाई जेसनमा बदल्न मिल्दैन"},
+		{call(t, "जेसन_पढ", str(`1.5`)), "जेसन पढ्न सकिएन: संख्या 1.5 पूर्णाङ्क होइन"},
+		{call(t, "जेसन_पढ", str(`[1, 2] 3`)), "जेसन पढ्न सकिएन: अनपेक्षित सामग्री कागजातको अन्त्यपछि"},
+		{call(t, "जेसन_पढ", str(`{"a" 1}`)), ""},
+	}
+
+	for i, tt := range tests {
+		err, ok := tt.result.(*object.Error)
+		if !ok {
+			t.Errorf("tests[%d] - expected ERROR, got %s", i, tt.result.Inspect())
+			continue
+		}
+		if tt.expected != "" && err.Message != tt.expected {
+			t.Errorf("tests[%d] - wrong message. expected=%q, got=%q", i, tt.expected, err.Message)
+		}
+	}
+}
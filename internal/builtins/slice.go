@@ -0,0 +1,114 @@
+package builtins
+
+import (
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/grapheme"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Slice returns value[start:end:step] for an ARRAY or a STRING, whose
+// characters are grapheme clusters. A nil bound was left out. Negative bounds
+// count from the end, and bounds past either end are clamped, so slicing
+// never fails on a valid step. A negative step walks backwards from start.
+func Slice(value, start, end, step object.Object) object.Object {
+	var elements []object.Object
+	var chars []string
+
+	length := 0
+	switch value := value.(type) {
+	case *object.Array:
+		elements = value.Elements
+		length = len(elements)
+	case *object.String:
+		chars = grapheme.Split(value.Value)
+		length = len(chars)
+	default:
+		return newError("slice operator not supported: %s", value.Type())
+	}
+
+	indices, err := sliceIndices(length, start, end, step)
+	if err != nil {
+		return err
+	}
+
+	if chars != nil {
+		var out strings.Builder
+		for _, i := range indices {
+			out.WriteString(chars[i])
+		}
+		return &object.String{Value: out.String()}
+	}
+
+	result := make([]object.Object, len(indices))
+	for j, i := range indices {
+		result[j] = elements[i]
+	}
+	return &object.Array{Elements: result}
+}
+
+// sliceIndices returns the positions selected by [start:end:step] in a
+// sequence of the given length
+func sliceIndices(length int, start, end, step object.Object) ([]int, *object.Error) {
+	bounds := [3]int64{}
+	given := [3]bool{}
+	for i, b := range []object.Object{start, end, step} {
+		if b == nil || b == object.NULL {
+			continue
+		}
+		n, ok := b.(*object.Integer)
+		if !ok {
+			return nil, newError("slice indices must be INTEGER, got %s", b.Type())
+		}
+		bounds[i], given[i] = n.Value, true
+	}
+
+	inc := int64(1)
+	if given[2] {
+		inc = bounds[2]
+	}
+	if inc == 0 {
+		return nil, newError("slice step cannot be zero")
+	}
+
+	n := int64(length)
+	// clamp puts a bound in range; lo is -1 for a backwards walk, which
+	// stops before the first element
+	clamp := func(i, lo, hi int64) int64 {
+		if i < 0 {
+			i += n
+		}
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+
+	var from, to int64
+	if inc > 0 {
+		from, to = 0, n
+		if given[0] {
+			from = clamp(bounds[0], 0, n)
+		}
+		if given[1] {
+			to = clamp(bounds[1], 0, n)
+		}
+	} else {
+		from, to = n-1, -1
+		if given[0] {
+			from = clamp(bounds[0], -1, n-1)
+		}
+		if given[1] {
+			to = clamp(bounds[1], -1, n-1)
+		}
+	}
+
+	indices := []int{}
+	for i := from; (inc > 0 && i < to) || (inc < 0 && i > to); i += inc {
+		indices = append(indices, int(i))
+	}
+	return indices, nil
+}
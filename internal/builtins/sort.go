@@ -0,0 +1,149 @@
+package builtins
+
+import (
+	"sort"
+
+	"github.com/SunilNeupane77/nepali/internal/collate"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+var sortBuiltins = map[string]*object.Builtin{
+	// क्रमबद्ध(array, options) returns a sorted copy of array. The sort is
+	// stable. Options is a hash that may hold:
+	//   "कुञ्जी": a function mapping each element to the value sorted on
+	//   "तुलना": a comparator returning a negative, zero or positive INTEGER
+	//   "नेपाली": सत्य to order strings the way Nepali dictionaries do
+	//   "उल्टो": सत्य to sort in descending order
+	"क्रमबद्ध": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `क्रमबद्ध` must be ARRAY, got %s", args[0].Type())
+			}
+
+			opts := sortOptions{}
+			if len(args) == 2 {
+				hash, ok := args[1].(*object.Hash)
+				if !ok {
+					return newError("options passed to `क्रमबद्ध` must be HASH, got %s", args[1].Type())
+				}
+				opts.key, _ = hash.Get(&object.String{Value: "कुञ्जी"})
+				opts.cmp, _ = hash.Get(&object.String{Value: "तुलना"})
+				if n, ok := hash.Get(&object.String{Value: "नेपाली"}); ok {
+					opts.nepali = n == object.TRUE
+				}
+				if r, ok := hash.Get(&object.String{Value: "उल्टो"}); ok {
+					opts.reverse = r == object.TRUE
+				}
+			}
+
+			return sortArray(arr, opts)
+		},
+	},
+	// नेपाली_तुलना(a, b) compares like तुलना, but orders strings the way
+	// Nepali dictionaries do
+	"नेपाली_तुलना": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			return &object.Integer{Value: int64(nepaliCompare(args[0], args[1]))}
+		},
+	},
+}
+
+type sortOptions struct {
+	key     object.Object // called on each element; nil sorts the elements themselves
+	cmp     object.Object // user comparator; nil uses the builtin order
+	nepali  bool
+	reverse bool
+}
+
+// sortArray sorts a copy of arr as opts describe
+func sortArray(arr *object.Array, opts sortOptions) object.Object {
+	key, cmp := opts.key, opts.cmp
+
+	keys := arr.Elements
+	if key != nil {
+		keys = make([]object.Object, len(arr.Elements))
+		for i, el := range arr.Elements {
+			k := callFunction(key, el)
+			if isError(k) {
+				return k
+			}
+			keys[i] = k
+		}
+	}
+
+	var callErr object.Object
+	compare := func(a, b object.Object) int {
+		if cmp == nil {
+			if opts.nepali {
+				return nepaliCompare(a, b)
+			}
+			return object.Compare(a, b)
+		}
+
+		result := callFunction(cmp, a, b)
+		n, ok := result.(*object.Integer)
+		if !ok {
+			if callErr == nil {
+				if isError(result) {
+					callErr = result
+				} else {
+					callErr = newError("comparator passed to `क्रमबद्ध` must return INTEGER, got %s", result.Type())
+				}
+			}
+			return 0
+		}
+		return int(n.Value)
+	}
+
+	order := make([]int, len(arr.Elements))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		c := compare(keys[order[i]], keys[order[j]])
+		if opts.reverse {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	if callErr != nil {
+		return callErr
+	}
+
+	sorted := make([]object.Object, len(order))
+	for i, idx := range order {
+		sorted[i] = arr.Elements[idx]
+	}
+	return &object.Array{Elements: sorted}
+}
+
+// nepaliCompare orders strings with Nepali collation and everything else
+// with object.Compare
+func nepaliCompare(a, b object.Object) int {
+	as, aok := a.(*object.String)
+	bs, bok := b.(*object.String)
+	if aok && bok {
+		return collate.Compare(as.Value, bs.Value)
+	}
+	return object.Compare(a, b)
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+func init() {
+	for name, fn := range sortBuiltins {
+		builtins[name] = fn
+	}
+}
@@ -0,0 +1,88 @@
+package builtins
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/grapheme"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Strings are measured, indexed and sliced in grapheme clusters, the
+// characters a reader sees; the rune and byte views expose the encoding
+var stringBuiltins = map[string]*object.Builtin{
+	// अक्षरहरू(s) returns the characters of s as an ARRAY of STRINGs
+	"अक्षरहरू": {
+		Fn: func(args ...object.Object) object.Object {
+			s, err := stringArg("अक्षरहरू", args)
+			if err != nil {
+				return err
+			}
+
+			clusters := grapheme.Split(s)
+			elements := make([]object.Object, len(clusters))
+			for i, c := range clusters {
+				elements[i] = &object.String{Value: c}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// रुनहरू(s) returns the Unicode code points of s as INTEGERs
+	"रुनहरू": {
+		Fn: func(args ...object.Object) object.Object {
+			s, err := stringArg("रुनहरू", args)
+			if err != nil {
+				return err
+			}
+
+			elements := []object.Object{}
+			for _, r := range s {
+				elements = append(elements, &object.Integer{Value: int64(r)})
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// बाइटहरू(s) returns the UTF-8 bytes of s as INTEGERs
+	"बाइटहरू": {
+		Fn: func(args ...object.Object) object.Object {
+			s, err := stringArg("बाइटहरू", args)
+			if err != nil {
+				return err
+			}
+
+			elements := make([]object.Object, len(s))
+			for i := 0; i < len(s); i++ {
+				elements[i] = &object.Integer{Value: int64(s[i])}
+			}
+			return &object.Array{Elements: elements}
+		},
+	},
+	// टुक्रा(value, start, end, step) is the builtin form of
+	// value[start:end:step]; the bounds are optional
+	"टुक्रा": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 || len(args) > 4 {
+				return newError("wrong number of arguments. got=%d, want=1 to 4", len(args))
+			}
+
+			bounds := make([]object.Object, 3)
+			copy(bounds, args[1:])
+			return Slice(args[0], bounds[0], bounds[1], bounds[2])
+		},
+	},
+}
+
+func stringArg(name string, args []object.Object) (string, *object.Error) {
+	if len(args) != 1 {
+		return "", newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return "", newError("argument to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+	return s.Value, nil
+}
+
+func init() {
+	for name, fn := range stringBuiltins {
+		builtins[name] = fn
+	}
+}
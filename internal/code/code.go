@@ -0,0 +1,348 @@
+// Package code defines the bytecode instruction set of the Nepali virtual machine
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Instructions is a sequence of encoded instructions
+type Instructions []byte
+
+// Opcode identifies an instruction
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpTrue
+	OpFalse
+	OpNull
+
+	// Operators
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpLess
+	OpGreater
+	OpLessEqual
+	OpGreaterEqual
+	OpMinus
+	OpBang
+
+	// Control flow
+	OpJump
+	OpJumpNotTruthy
+
+	// Variables
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetFree
+
+	// Functions
+	OpClosure
+	OpCall
+	OpCallSpread
+	OpReturnValue
+	OpReturn
+	OpDecorate
+	OpPreserve
+
+	// Collections
+	OpArray
+	OpHash
+	OpSet
+	OpIndex
+	OpSlice
+	OpMember
+	OpAppend
+	OpSetAdd
+	OpSetPair
+	OpArgument
+
+	// Loops
+	OpIter
+	OpIterNext
+
+	// Pattern matching
+	OpMatchArray
+	OpMatchHash
+	OpHasKey
+	OpHashRest
+
+	// Context managers
+	OpWithEnter
+	OpWithExit
+
+	// Modules
+	OpImport
+	OpImportName
+
+	// Async functions
+	OpAwait
+
+	// Tail calls
+	OpTailCall
+	OpTailCallSpread
+)
+
+// Definition describes an opcode: its name and the byte widths of its operands
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLess:         {"OpLess", []int{}},
+	OpGreater:      {"OpGreater", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	OpMinus:        {"OpMinus", []int{}},
+	OpBang:         {"OpBang", []int{}},
+
+	// Jump targets are absolute offsets into the function's instructions
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{2}},
+	OpSetLocal:  {"OpSetLocal", []int{2}},
+	OpGetFree:   {"OpGetFree", []int{1}},
+
+	// The operand is the constant holding the function; its default values
+	// are on the stack
+	OpClosure: {"OpClosure", []int{2}},
+	// The operand is the number of arguments on the stack above the function
+	OpCall: {"OpCall", []int{1}},
+	// Calls the function below an array of arguments and a hash of keyword
+	// arguments
+	OpCallSpread:  {"OpCallSpread", []int{}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	// Turns [decorator, function] into [function, decorator, function], ready
+	// for OpCall 1 and OpPreserve
+	OpDecorate: {"OpDecorate", []int{}},
+	// Replaces [function, wrapper] with the wrapper carrying the function's
+	// name and docstring
+	OpPreserve: {"OpPreserve", []int{}},
+
+	// Operands count the elements, pairs or members taken from the stack
+	OpArray: {"OpArray", []int{2}},
+	OpHash:  {"OpHash", []int{2}},
+	OpSet:   {"OpSet", []int{2}},
+	OpIndex: {"OpIndex", []int{}},
+	// The operand flags which of start, end and step are on the stack
+	OpSlice: {"OpSlice", []int{1}},
+	// The operand is the constant holding the member's name
+	OpMember: {"OpMember", []int{2}},
+	// OpAppend, OpSetAdd and OpSetPair add the values above a collection to
+	// it, leaving the collection
+	OpAppend:  {"OpAppend", []int{}},
+	OpSetAdd:  {"OpSetAdd", []int{}},
+	OpSetPair: {"OpSetPair", []int{}},
+	// Adds the value on top to the argument array and keyword hash below it
+	// for OpCallSpread. Operands are the argument's kind and, for keyword
+	// arguments, the constant holding its name.
+	OpArgument: {"OpArgument", []int{1, 2}},
+
+	// The operand of both is the number of names each pass binds; OpIterNext
+	// jumps to its second operand once the iterator is exhausted
+	OpIter:     {"OpIter", []int{1}},
+	OpIterNext: {"OpIterNext", []int{1, 2}},
+
+	// Operands are the number of fixed elements and whether a rest pattern
+	// takes the others
+	OpMatchArray: {"OpMatchArray", []int{2, 1}},
+	OpMatchHash:  {"OpMatchHash", []int{}},
+	OpHasKey:     {"OpHasKey", []int{}},
+	// The operand is the number of keys to leave out of the rest
+	OpHashRest: {"OpHashRest", []int{2}},
+
+	// OpWithEnter protects the code up to its operand, where OpWithExit
+	// runs the exit hook
+	OpWithEnter: {"OpWithEnter", []int{2}},
+	OpWithExit:  {"OpWithExit", []int{}},
+
+	// The operand indexes the program's imports
+	OpImport: {"OpImport", []int{2}},
+	// Replaces a module with its export named by the constant at the operand
+	OpImportName: {"OpImportName", []int{2}},
+
+	// Replaces a promise with what it settles to, suspending the running
+	// async call until it does; other values are left as they are
+	OpAwait: {"OpAwait", []int{}},
+
+	// Call as OpCall and OpCallSpread do, from where the calling function
+	// returns what the call does, so that a call of a closure can take over
+	// the caller's frame
+	OpTailCall:       {"OpTailCall", []int{1}},
+	OpTailCallSpread: {"OpTailCallSpread", []int{}},
+}
+
+// Slice operand flags
+const (
+	SliceStart = 1 << iota
+	SliceEnd
+	SliceStep
+)
+
+// Argument kinds of OpArgument
+const (
+	ArgPositional = iota
+	ArgSpread
+	ArgKeyword
+	ArgKeywordSpread
+)
+
+// Lookup returns the definition of op
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes op with its operands
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction described by def,
+// returning them and the number of bytes they take
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+// ReadUint16 decodes a two-byte operand
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+// ReadUint8 decodes a one-byte operand
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
+
+// String lists the instructions one per line, prefixed by their offsets
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		text, width := ins.Format(i)
+		fmt.Fprintf(&out, "%04d %s\n", i, text)
+		i += width
+	}
+
+	return out.String()
+}
+
+// Format formats the instruction at offset i, returning it and its width
+func (ins Instructions) Format(i int) (string, int) {
+	def, err := Lookup(ins[i])
+	if err != nil {
+		return fmt.Sprintf("ERROR: %s", err), 1
+	}
+
+	operands, read := ReadOperands(def, ins[i+1:])
+	return ins.fmtInstruction(def, operands), 1 + read
+}
+
+// LineTable maps instructions to the source lines they were compiled from.
+// Each entry holds from its offset up to the next entry's.
+type LineTable []LineEntry
+
+// LineEntry starts a run of instructions compiled from one line
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// Line returns the line of the instruction at offset, or 0 if unknown
+func (lt LineTable) Line(offset int) int {
+	line := 0
+	for _, e := range lt {
+		if e.Offset > offset {
+			break
+		}
+		line = e.Line
+	}
+	return line
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
@@ -0,0 +1,169 @@
+// Package collate orders strings the way Nepali dictionaries do
+package collate
+
+import "strings"
+
+// Nepali dictionaries (वर्णमाला order) sort letter by letter. Each syllable
+// is weighed by its base letter first, then its vowel sign, then any nasal
+// or visarga mark:
+//
+//   - digits come before letters, and vowels before consonants
+//   - a consonant with the inherent vowel comes before the same consonant
+//     with a vowel sign, and the signs follow the order of the vowels
+//     (का कि की कु कू कृ के कै को कौ)
+//   - a consonant joined by halant to the next one sorts after every vowel
+//     sign, so क्ष and क्क follow कौ
+//   - anusvara and chandrabindu sort before the bare syllable (कं before क)
+//     and visarga after it
+//   - nukta letters (ड़, फ़) sort with their base letter
+//
+// Characters outside Devanagari sort after it, in code point order.
+
+var vowels = []rune("अआइईउऊऋॠऌएऐओऔ")
+
+var consonants = []rune("कखगघङचछजझञटठडढणतथदधनपफबभमयरलवशषसह")
+
+// vowelSigns lists dependent vowel signs in the order of the vowels they stand for
+var vowelSigns = []rune("ािीुूृॄॢेैोौ")
+
+const (
+	halant       = '्'
+	nukta        = '़'
+	anusvara     = 'ं'
+	chandrabindu = 'ँ'
+	visarga      = 'ः'
+)
+
+// nuktaForms maps precomposed nukta letters to their base letter
+var nuktaForms = map[rune]rune{
+	'\u0958': 'क', '\u0959': 'ख', '\u095A': 'ग', '\u095B': 'ज',
+	'\u095C': 'ड', '\u095D': 'ढ', '\u095E': 'फ', '\u095F': 'य',
+}
+
+// Primary weights by class; each class is wide enough for its members
+const (
+	weightSpace     = 1
+	weightDigit     = 10
+	weightVowel     = 100
+	weightConsonant = 200
+	weightOther     = 1000
+)
+
+// Secondary weights: the vowel a syllable carries
+const (
+	vowelInherent = 0
+	vowelSignBase = 1                  // plus the sign's index in vowelSigns
+	vowelHalant   = vowelSignBase + 20 // after every vowel sign
+	vowelNone     = vowelHalant + 1    // independent vowels and non-letters
+)
+
+// Tertiary weights: nasal and visarga marks
+const (
+	nasalFirst   = 0 // anusvara or chandrabindu
+	nasalNone    = 1
+	nasalVisarga = 2
+)
+
+// Key returns the collation key of s. Two strings compare in Nepali
+// dictionary order exactly when their keys compare lexicographically.
+func Key(s string) []int {
+	runes := []rune(s)
+	key := make([]int, 0, len(runes)*3)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if base, ok := nuktaForms[r]; ok {
+			r = base
+		}
+
+		primary, syllabic := primaryWeight(r)
+		if !syllabic {
+			key = append(key, primary, vowelNone, nasalNone)
+			continue
+		}
+
+		vowel := vowelInherent
+		if indexOf(vowels, r) >= 0 {
+			vowel = vowelNone
+		}
+		nasal := nasalNone
+
+		// Consume the marks that belong to this syllable
+	marks:
+		for ; i+1 < len(runes); i++ {
+			next := runes[i+1]
+			switch {
+			case next == nukta:
+			case next == halant && vowel == vowelInherent:
+				vowel = vowelHalant
+			case indexOf(vowelSigns, next) >= 0 && vowel == vowelInherent:
+				vowel = vowelSignBase + indexOf(vowelSigns, next)
+			case next == anusvara || next == chandrabindu:
+				nasal = nasalFirst
+			case next == visarga:
+				nasal = nasalVisarga
+			default:
+				break marks
+			}
+		}
+
+		key = append(key, primary, vowel, nasal)
+	}
+
+	return key
+}
+
+// Compare returns -1, 0 or 1 as a sorts before, equal to or after b in Nepali
+// dictionary order. Strings with equal keys fall back to byte order, so only
+// identical strings compare equal.
+func Compare(a, b string) int {
+	ka, kb := Key(a), Key(b)
+	for i := 0; i < len(ka) && i < len(kb); i++ {
+		if ka[i] != kb[i] {
+			if ka[i] < kb[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case len(ka) < len(kb):
+		return -1
+	case len(ka) > len(kb):
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// primaryWeight returns the base weight of r and whether r starts a
+// syllable that vowel signs and marks can attach to
+func primaryWeight(r rune) (int, bool) {
+	switch {
+	case r == ' ' || r == '\t' || r == '\n':
+		return weightSpace, false
+	case '0' <= r && r <= '9':
+		return weightDigit + int(r-'0'), false
+	case '०' <= r && r <= '९':
+		return weightDigit + int(r-'०'), false
+	}
+
+	if i := indexOf(vowels, r); i >= 0 {
+		return weightVowel + i, true
+	}
+	if i := indexOf(consonants, r); i >= 0 {
+		return weightConsonant + i, true
+	}
+
+	return weightOther + int(r), false
+}
+
+func indexOf(set []rune, r rune) int {
+	for i, c := range set {
+		if c == r {
+			return i
+		}
+	}
+	return -1
+}
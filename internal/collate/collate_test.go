@@ -0,0 +1,58 @@
+package collate
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestCompareOrder(t *testing.T) {
+	// each word must sort strictly before the next
+	tests := [][]string{
+		{"१", "अ", "आ", "क"},
+		{"9", "अनार"},
+		{"क", "का", "कि", "की", "कु", "के", "कौ", "क्ष"},
+		{"कं", "क", "कः"},
+		{"कमल", "कलम", "खरायो"},
+		{"अँगुर", "अगाडि"},
+		{"घर", "घरमा"},
+		{"क", "z"},
+	}
+
+	for _, words := range tests {
+		for i := 0; i+1 < len(words); i++ {
+			if Compare(words[i], words[i+1]) >= 0 {
+				t.Errorf("expected %q before %q", words[i], words[i+1])
+			}
+			if Compare(words[i+1], words[i]) <= 0 {
+				t.Errorf("expected %q after %q", words[i+1], words[i])
+			}
+		}
+	}
+}
+
+func TestCompareNukta(t *testing.T) {
+	// precomposed and combining nukta forms sort with their base letter
+	for _, word := range []string{"ड़ा", "ड़ा"} {
+		if Compare("ड", word) >= 0 || Compare(word, "डि") >= 0 {
+			t.Errorf("expected %q between ड and डि", word)
+		}
+	}
+}
+
+func TestCompareEqual(t *testing.T) {
+	if Compare("नेपाल", "नेपाल") != 0 {
+		t.Errorf("identical strings must compare equal")
+	}
+}
+
+func TestSortWords(t *testing.T) {
+	words := []string{"नेपाल", "आमा", "काठमाडौं", "अमृत", "कमल", "१२"}
+	sort.Slice(words, func(i, j int) bool { return Compare(words[i], words[j]) < 0 })
+
+	expected := []string{"१२", "अमृत", "आमा", "कमल", "काठमाडौं", "नेपाल"}
+	for i := range expected {
+		if words[i] != expected[i] {
+			t.Fatalf("wrong order. want=%v, got=%v", expected, words)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package compiler
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+// CompiledExt is the file extension of compiled Nepali files
+const CompiledExt = ".nbc"
+
+// CompiledPath returns where the compiled form of the source file at path
+// is kept: next to it, with the compiled extension
+func CompiledPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + CompiledExt
+}
+
+// ParseErrors reports a source file that does not parse
+type ParseErrors []string
+
+func (e ParseErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+// CompileSource parses and compiles source, returning the parser's
+// warnings with the bytecode
+func CompileSource(source string) (*Bytecode, []string, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, nil, ParseErrors(p.Errors())
+	}
+
+	c := New()
+	if err := c.Compile(program); err != nil {
+		return nil, nil, err
+	}
+	return c.Bytecode(), p.Warnings(), nil
+}
+
+// Load returns the bytecode of the file at path, which may be a source or a
+// compiled file. For a source file it reuses the compiled file next to it
+// if that was compiled from the same source, or if the source is missing,
+// as when only compiled files are deployed. Otherwise it compiles the
+// source, leaving writing a compiled file to CompileFile. Parser warnings
+// are only returned when the source is compiled.
+func Load(path string) (*Bytecode, []string, error) {
+	if filepath.Ext(path) == CompiledExt {
+		return loadCompiled(path, nil)
+	}
+
+	compiledPath := CompiledPath(path)
+	source, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, statErr := os.Stat(compiledPath); statErr == nil {
+			return loadCompiled(compiledPath, nil)
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hash := SourceHash(source)
+	if bc, _, err := loadCompiled(compiledPath, hash[:]); err == nil {
+		return bc, nil, nil
+	}
+
+	return CompileSource(string(source))
+}
+
+// CompileFile compiles the source file at path and writes its compiled file
+// next to it, for Load to use while the source is unchanged. It returns the
+// parser's warnings.
+func CompileFile(path string) ([]string, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bc, warnings, err := CompileSource(string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := Encode(bc, SourceHash(source))
+	if err != nil {
+		return nil, err
+	}
+	return warnings, writeFileAtomic(CompiledPath(path), data)
+}
+
+// loadCompiled reads the compiled file at path, failing unless it was
+// compiled from the source with the given hash, when there is one
+func loadCompiled(path string, sourceHash []byte) (*Bytecode, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	bc, hash, err := Decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sourceHash != nil && string(hash[:]) != string(sourceHash) {
+		return nil, nil, errors.New("compiled file is out of date")
+	}
+	return bc, nil, nil
+}
+
+// writeFileAtomic writes data to path through a temporary file, so that a
+// concurrent run never reads a partly written file
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
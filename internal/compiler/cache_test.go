@@ -0,0 +1,95 @@
+package compiler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadUsesCompiledFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.nep")
+	compiled := filepath.Join(dir, "main.nbc")
+
+	write := func(source string) {
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func() *Bytecode {
+		t.Helper()
+		bc, _, err := Load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bc
+	}
+
+	// Loading a source compiles it without writing a compiled file
+	write("लेट x = १;")
+	if got := load().Globals; len(got) != 1 || got[0] != "x" {
+		t.Errorf("wrong globals: %v", got)
+	}
+	if _, err := os.Stat(compiled); err == nil {
+		t.Fatalf("compiled file written by Load")
+	}
+
+	if _, err := CompileFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(compiled); err != nil {
+		t.Fatalf("compiled file not written: %s", err)
+	}
+
+	// A changed source is compiled again rather than taken from the
+	// compiled file
+	write("लेट y = १;")
+	if got := load().Globals; len(got) != 1 || got[0] != "y" {
+		t.Errorf("changed source not recompiled: globals=%v", got)
+	}
+
+	// A corrupt compiled file is ignored
+	if err := os.WriteFile(compiled, []byte("NBC\x00garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := load().Globals; len(got) != 1 || got[0] != "y" {
+		t.Errorf("corrupt compiled file used: globals=%v", got)
+	}
+
+	// Without the source the compiled file is used, directly or in its place
+	if _, err := CompileFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := load().Globals; len(got) != 1 || got[0] != "y" {
+		t.Errorf("compiled file not used without source: globals=%v", got)
+	}
+	if bc, _, err := Load(compiled); err != nil || bc.Globals[0] != "y" {
+		t.Errorf("compiled file not loaded directly: %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "खराब.nep")
+	if err := os.WriteFile(path, []byte("लेट = ;"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := Load(path)
+	if _, ok := err.(ParseErrors); !ok {
+		t.Errorf("expected ParseErrors, got=%v", err)
+	}
+	if _, err := CompileFile(path); err == nil {
+		t.Errorf("expected CompileFile to fail")
+	}
+	if _, err := os.Stat(CompiledPath(path)); err == nil {
+		t.Errorf("compiled file written for a source that does not parse")
+	}
+
+	if _, _, err := Load(filepath.Join(dir, "छैन.nep")); !os.IsNotExist(err) {
+		t.Errorf("expected a missing file error, got=%v", err)
+	}
+}
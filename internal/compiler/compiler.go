@@ -0,0 +1,772 @@
+// Package compiler compiles Nepali programs to bytecode for the virtual machine
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/code"
+	"github.com/SunilNeupane77/nepali/internal/imports"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Bytecode is a compiled program: its top level, compiled like a function
+// body, the names of its globals by slot, the import paths OpImport refers
+// to and the globals it exports
+type Bytecode struct {
+	Main    *object.CompiledFunction
+	Globals []string
+	Imports []string
+	Exports []string
+}
+
+// Compiler compiles one program
+type Compiler struct {
+	scopes      []*compilationScope
+	symbolTable *SymbolTable
+	imports     []string
+	exports     []string
+}
+
+// compilationScope collects the code of the function being compiled
+type compilationScope struct {
+	instructions code.Instructions
+	constants    []object.Object
+	lines        code.LineTable
+	line         int // the line of the node being compiled
+}
+
+// New returns a compiler for a program
+func New() *Compiler {
+	return &Compiler{
+		scopes:      []*compilationScope{{}},
+		symbolTable: NewSymbolTable(),
+	}
+}
+
+// Compile compiles a program. The program's value is that of its last
+// statement when that is an expression, as in the tree-walker.
+func (c *Compiler) Compile(program *ast.Program) error {
+	for i, stmt := range program.Statements {
+		if es, ok := stmt.(*ast.ExpressionStatement); ok && i == len(program.Statements)-1 {
+			defer c.at(lineOf(es))()
+			if err := c.compileExpression(es.Expression); err != nil {
+				return err
+			}
+			c.emit(code.OpReturnValue)
+			return nil
+		}
+
+		if err := c.compileStatement(stmt); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpReturn)
+	return nil
+}
+
+// Bytecode returns the compiled program
+func (c *Compiler) Bytecode() *Bytecode {
+	scope := c.scope()
+	return &Bytecode{
+		Main: &object.CompiledFunction{
+			Instructions: scope.instructions,
+			Constants:    scope.constants,
+			NumLocals:    c.symbolTable.NumLocals(),
+			LocalNames:   c.symbolTable.LocalNames(),
+			Lines:        scope.lines,
+		},
+		Globals: c.symbolTable.GlobalNames(),
+		Imports: c.imports,
+		Exports: c.exports,
+	}
+}
+
+func (c *Compiler) compileStatement(stmt ast.Statement) error {
+	defer c.at(lineOf(stmt))()
+
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		if err := c.compileExpression(stmt.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.LetStatement:
+		return c.compileLetStatement(stmt)
+
+	case *ast.ReturnStatement:
+		if err := c.compileExpression(stmt.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WithStatement:
+		return c.compileWithStatement(stmt)
+
+	case *ast.ImportStatement:
+		return c.compileImportStatement(stmt)
+
+	case *ast.ExportStatement:
+		if !c.symbolTable.TopLevel() {
+			return fmt.Errorf("%s is only allowed at the top level of a module", lexer.EXPORT)
+		}
+		if err := c.compileLetStatement(stmt.Statement); err != nil {
+			return err
+		}
+		c.exports = append(c.exports, stmt.Statement.Name.Value)
+	case *ast.BlockStatement:
+		return c.compileStatements(stmt.Statements)
+
+	default:
+		return fmt.Errorf("cannot compile %T", stmt)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileStatements(stmts []ast.Statement) error {
+	for _, s := range stmts {
+		if err := c.compileStatement(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileBlockValue compiles a block that produces a value: that of its last
+// statement when it is an expression, otherwise निल
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	stmts := block.Statements
+	if len(stmts) == 0 {
+		c.emit(code.OpNull)
+		return nil
+	}
+
+	if err := c.compileStatements(stmts[:len(stmts)-1]); err != nil {
+		return err
+	}
+
+	if es, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement); ok {
+		return c.compileExpression(es.Expression)
+	}
+
+	if err := c.compileStatement(stmts[len(stmts)-1]); err != nil {
+		return err
+	}
+	c.emit(code.OpNull)
+	return nil
+}
+
+func (c *Compiler) compileLetStatement(let *ast.LetStatement) error {
+	for _, d := range let.Decorators {
+		if err := c.compileExpression(d); err != nil {
+			return err
+		}
+	}
+
+	name := let.Name.Value
+	symbol := c.symbolTable.DefinePending(name)
+
+	if err := c.compileExpression(let.Value); err != nil {
+		return err
+	}
+	for range let.Decorators {
+		c.emit(code.OpDecorate)
+		c.emit(code.OpCall, 1)
+		c.emit(code.OpPreserve)
+	}
+
+	c.symbolTable.Settle(name)
+	c.storeSymbol(symbol)
+	return nil
+}
+
+// declare declares the names stmts bind in the current scope, including
+// those bound in the यदि and सँग blocks that share it
+func (c *Compiler) declare(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			c.symbolTable.Declare(stmt.Name.Value)
+		case *ast.WithStatement:
+			if stmt.Name != nil {
+				c.symbolTable.Declare(stmt.Name.Value)
+			}
+			c.declare(stmt.Body.Statements)
+		case *ast.ExpressionStatement:
+			if ie, ok := stmt.Expression.(*ast.IfExpression); ok {
+				c.declare(ie.Consequence.Statements)
+				if ie.Alternative != nil {
+					c.declare(ie.Alternative.Statements)
+				}
+			}
+		}
+	}
+}
+
+// compileImportStatement binds the module at the import's path, or the
+// names it exports, as the tree-walker does
+func (c *Compiler) compileImportStatement(node *ast.ImportStatement) error {
+	c.emit(code.OpImport, c.addImport(node.Path.Value))
+
+	if len(node.Names) == 0 {
+		name := imports.Name(node.Path.Value)
+		if node.Alias != nil {
+			name = node.Alias.Value
+		}
+		c.storeSymbol(c.symbolTable.Define(name))
+		return nil
+	}
+
+	module := c.symbolTable.DefineTemp()
+	c.storeSymbol(module)
+	for _, n := range node.Names {
+		c.loadSymbol(module)
+		c.emit(code.OpImportName, c.addConstant(&object.String{Value: n.Value}))
+		c.storeSymbol(c.symbolTable.Define(n.Value))
+	}
+	return nil
+}
+
+func (c *Compiler) addImport(path string) int {
+	for i, p := range c.imports {
+		if p == path {
+			return i
+		}
+	}
+	c.imports = append(c.imports, path)
+	return len(c.imports) - 1
+}
+
+func (c *Compiler) compileExpression(node ast.Expression) error {
+	defer c.at(lineOf(node))()
+
+	switch node := node.(type) {
+	case *ast.Identifier:
+		c.loadSymbol(c.symbolTable.Resolve(node.Value))
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.compileExpression(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("unknown operator %s", node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(node)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		return c.compileCallExpression(node)
+
+	case *ast.ArrayLiteral:
+		if err := c.compileExpressions(node.Elements); err != nil {
+			return err
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.compileExpressions([]ast.Expression{pair.Key, pair.Value}); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs))
+
+	case *ast.SetLiteral:
+		if err := c.compileExpressions(node.Elements); err != nil {
+			return err
+		}
+		c.emit(code.OpSet, len(node.Elements))
+
+	case *ast.IndexExpression:
+		if err := c.compileExpressions([]ast.Expression{node.Left, node.Index}); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.SliceExpression:
+		return c.compileSliceExpression(node)
+
+	case *ast.MemberExpression:
+		if err := c.compileExpression(node.Object); err != nil {
+			return err
+		}
+		c.emit(code.OpMember, c.addConstant(&object.String{Value: node.Property.Value}))
+
+	case *ast.ListComprehension:
+		return c.compileComprehension(code.OpArray, node.Clauses, func() error {
+			if err := c.compileExpression(node.Element); err != nil {
+				return err
+			}
+			c.emit(code.OpAppend)
+			return nil
+		})
+
+	case *ast.SetComprehension:
+		return c.compileComprehension(code.OpSet, node.Clauses, func() error {
+			if err := c.compileExpression(node.Element); err != nil {
+				return err
+			}
+			c.emit(code.OpSetAdd)
+			return nil
+		})
+
+	case *ast.HashComprehension:
+		return c.compileComprehension(code.OpHash, node.Clauses, func() error {
+			if err := c.compileExpressions([]ast.Expression{node.Key, node.Value}); err != nil {
+				return err
+			}
+			c.emit(code.OpSetPair)
+			return nil
+		})
+
+	case *ast.MatchExpression:
+		return c.compileMatchExpression(node)
+
+	case *ast.AwaitExpression:
+		if err := c.compileExpression(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpAwait)
+
+	default:
+		return fmt.Errorf("cannot compile %T", node)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileExpressions(exps []ast.Expression) error {
+	for _, e := range exps {
+		if err := c.compileExpression(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLess,
+	">":  code.OpGreater,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	op, ok := infixOpcodes[node.Operator]
+	if !ok {
+		return fmt.Errorf("unknown operator %s", node.Operator)
+	}
+
+	if err := c.compileExpressions([]ast.Expression{node.Left, node.Right}); err != nil {
+		return err
+	}
+	c.emit(op)
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.compileExpression(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthy := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+	jump := c.emit(code.OpJump, 9999)
+
+	c.changeOperand(jumpNotTruthy, len(c.scope().instructions))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jump, len(c.scope().instructions))
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	// Defaults are evaluated where the function is defined
+	params := make([]*object.Parameter, len(node.Parameters))
+	for i, p := range node.Parameters {
+		params[i] = &object.Parameter{
+			Name:        p.Name.Value,
+			HasDefault:  p.Default != nil,
+			Rest:        p.Rest,
+			KeywordRest: p.KeywordRest,
+		}
+		if p.Default != nil {
+			if err := c.compileExpression(p.Default); err != nil {
+				return err
+			}
+		}
+	}
+
+	c.enterScope()
+	for _, p := range params {
+		c.symbolTable.Define(p.Name)
+	}
+	c.declare(node.Body.Statements)
+
+	if err := c.compileBlockValue(node.Body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	table := c.symbolTable
+	scope := c.leaveScope()
+
+	free := make([]object.FreeVariable, len(table.FreeSymbols))
+	for i, s := range table.FreeSymbols {
+		free[i] = object.FreeVariable{Name: s.Name, Local: s.Scope == LocalScope, Index: s.Index}
+	}
+
+	fn := &object.CompiledFunction{
+		Name:         node.Name,
+		Doc:          docString(node.Body),
+		Body:         node.Body.String(),
+		Instructions: scope.instructions,
+		Constants:    scope.constants,
+		Parameters:   params,
+		NumLocals:    table.NumLocals(),
+		LocalNames:   table.LocalNames(),
+		Free:         free,
+		Lines:        scope.lines,
+		IsAsync:      node.IsAsync,
+	}
+	c.emit(code.OpClosure, c.addConstant(fn))
+	return nil
+}
+
+func (c *Compiler) compileCallExpression(node *ast.CallExpression) error {
+	if err := c.compileExpression(node.Function); err != nil {
+		return err
+	}
+
+	simple := true
+	for _, arg := range node.Arguments {
+		switch arg.(type) {
+		case *ast.KeywordArgument, *ast.SpreadArgument:
+			simple = false
+		}
+	}
+
+	if simple {
+		if err := c.compileExpressions(node.Arguments); err != nil {
+			return err
+		}
+		if node.Tail {
+			c.emit(code.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
+		return nil
+	}
+
+	c.emit(code.OpArray, 0)
+	c.emit(code.OpHash, 0)
+	for _, arg := range node.Arguments {
+		switch arg := arg.(type) {
+		case *ast.KeywordArgument:
+			if err := c.compileExpression(arg.Value); err != nil {
+				return err
+			}
+			c.emit(code.OpArgument, code.ArgKeyword, c.addConstant(&object.String{Value: arg.Name.Value}))
+		case *ast.SpreadArgument:
+			if err := c.compileExpression(arg.Value); err != nil {
+				return err
+			}
+			kind := code.ArgSpread
+			if arg.Keywords {
+				kind = code.ArgKeywordSpread
+			}
+			c.emit(code.OpArgument, kind, 0)
+		default:
+			if err := c.compileExpression(arg); err != nil {
+				return err
+			}
+			c.emit(code.OpArgument, code.ArgPositional, 0)
+		}
+	}
+	if node.Tail {
+		c.emit(code.OpTailCallSpread)
+	} else {
+		c.emit(code.OpCallSpread)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileSliceExpression(node *ast.SliceExpression) error {
+	if err := c.compileExpression(node.Left); err != nil {
+		return err
+	}
+
+	flags := 0
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		if err := c.compileExpression(exp); err != nil {
+			return err
+		}
+		flags |= 1 << i
+	}
+
+	c.emit(code.OpSlice, flags)
+	return nil
+}
+
+// compileComprehension compiles a comprehension building a collection made
+// by newOp, with element adding the current element to the collection on
+// the stack. The loop variables get a scope of their own.
+func (c *Compiler) compileComprehension(newOp code.Opcode, clauses []*ast.ComprehensionClause, element func() error) error {
+	c.symbolTable.PushBlock()
+	defer c.symbolTable.PopBlock()
+
+	result := c.symbolTable.DefineTemp()
+	c.emit(newOp, 0)
+	c.storeSymbol(result)
+
+	err := c.compileClauses(clauses, func() error {
+		c.loadSymbol(result)
+		if err := element(); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	c.loadSymbol(result)
+	return nil
+}
+
+func (c *Compiler) compileClauses(clauses []*ast.ComprehensionClause, body func() error) error {
+	if len(clauses) == 0 {
+		return body()
+	}
+	clause := clauses[0]
+
+	if err := c.compileExpression(clause.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter, len(clause.Names))
+	iter := c.symbolTable.DefineTemp()
+	c.storeSymbol(iter)
+
+	loop := len(c.scope().instructions)
+	c.loadSymbol(iter)
+	next := c.emit(code.OpIterNext, len(clause.Names), 9999)
+
+	symbols := make([]Symbol, len(clause.Names))
+	for i, name := range clause.Names {
+		symbols[i] = c.symbolTable.Define(name.Value)
+	}
+	for i := len(symbols) - 1; i >= 0; i-- {
+		c.storeSymbol(symbols[i])
+	}
+
+	for _, filter := range clause.Filters {
+		if err := c.compileExpression(filter); err != nil {
+			return err
+		}
+		c.emit(code.OpJumpNotTruthy, loop)
+	}
+
+	if err := c.compileClauses(clauses[1:], body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, loop)
+
+	c.changeOperands(next, len(clause.Names), len(c.scope().instructions))
+	return nil
+}
+
+func (c *Compiler) compileWithStatement(node *ast.WithStatement) error {
+	if err := c.compileExpression(node.Context); err != nil {
+		return err
+	}
+
+	enter := c.emit(code.OpWithEnter, 9999)
+	if node.Name != nil {
+		c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+	} else {
+		c.emit(code.OpPop)
+	}
+
+	if err := c.compileStatements(node.Body.Statements); err != nil {
+		return err
+	}
+	c.emit(code.OpWithExit)
+
+	c.changeOperand(enter, len(c.scope().instructions))
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) scope() *compilationScope {
+	return c.scopes[len(c.scopes)-1]
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, &compilationScope{line: c.scope().line})
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() *compilationScope {
+	scope := c.scope()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.symbolTable = c.symbolTable.Outer
+	return scope
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	scope := c.scope()
+	scope.constants = append(scope.constants, obj)
+	return len(scope.constants) - 1
+}
+
+// emit appends an instruction, returning its position
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	scope := c.scope()
+	pos := len(scope.instructions)
+	scope.instructions = append(scope.instructions, code.Make(op, operands...)...)
+
+	if n := len(scope.lines); scope.line != 0 && (n == 0 || scope.lines[n-1].Line != scope.line) {
+		scope.lines = append(scope.lines, code.LineEntry{Offset: pos, Line: scope.line})
+	}
+	return pos
+}
+
+// at attributes the code emitted from here to line, when it is known,
+// returning a function that restores the line before
+func (c *Compiler) at(line int) func() {
+	scope := c.scope()
+	prev := scope.line
+	if line != 0 {
+		scope.line = line
+	}
+	return func() { scope.line = prev }
+}
+
+func (c *Compiler) changeOperand(pos int, operand int) {
+	c.changeOperands(pos, operand)
+}
+
+func (c *Compiler) changeOperands(pos int, operands ...int) {
+	ins := c.scope().instructions
+	op := code.Opcode(ins[pos])
+	copy(ins[pos:], code.Make(op, operands...))
+}
+
+// lineOf returns the line node starts on, or 0 if it does not record one
+func lineOf(node ast.Node) int {
+	var tok lexer.Token
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		tok = node.Token
+	case *ast.ReturnStatement:
+		tok = node.Token
+	case *ast.ExpressionStatement:
+		tok = node.Token
+	case *ast.WithStatement:
+		tok = node.Token
+	case *ast.ImportStatement:
+		tok = node.Token
+	case *ast.ExportStatement:
+		tok = node.Token
+	case *ast.Identifier:
+		tok = node.Token
+	case *ast.PrefixExpression:
+		tok = node.Token
+	case *ast.InfixExpression:
+		tok = node.Token
+	case *ast.IfExpression:
+		tok = node.Token
+	case *ast.CallExpression:
+		tok = node.Token
+	case *ast.IndexExpression:
+		tok = node.Token
+	case *ast.SliceExpression:
+		tok = node.Token
+	case *ast.MemberExpression:
+		tok = node.Token
+	case *ast.MatchExpression:
+		tok = node.Token
+	}
+	return tok.Line
+}
+
+// docString returns the string literal that opens a function body, if any
+func docString(body *ast.BlockStatement) string {
+	if body == nil || len(body.Statements) == 0 {
+		return ""
+	}
+
+	stmt, ok := body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return ""
+	}
+	if lit, ok := stmt.Expression.(*ast.StringLiteral); ok {
+		return lit.Value
+	}
+	return ""
+}
@@ -0,0 +1,166 @@
+package compiler
+
+import (
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/code"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func compile(t *testing.T, input string) *Bytecode {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	c := New()
+	if err := c.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return c.Bytecode()
+}
+
+func concat(instructions ...[]byte) code.Instructions {
+	out := code.Instructions{}
+	for _, ins := range instructions {
+		out = append(out, ins...)
+	}
+	return out
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected code.Instructions
+	}{
+		{
+			"१ + २",
+			concat(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"लेट x = १; x",
+			concat(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"यदि (सत्य) { १ }; २",
+			concat(
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"लेन([])",
+			concat(
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpReturnValue),
+			),
+		},
+		{
+			"लेट x = ५;",
+			concat(
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpReturn),
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		bytecode := compile(t, tt.input)
+		if got := bytecode.Main.Instructions; got.String() != tt.expected.String() {
+			t.Errorf("%s: wrong instructions.\nwant=\n%s\ngot=\n%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCompileClosures(t *testing.T) {
+	bytecode := compile(t, "फन बाहिर(a) { लेट b = १; फन() { a + b } }")
+
+	outer := bytecode.Main.Constants[0].(*object.CompiledFunction)
+	if outer.Name != "बाहिर" || outer.NumLocals != 2 {
+		t.Fatalf("wrong outer function: name=%q, locals=%d", outer.Name, outer.NumLocals)
+	}
+
+	var inner *object.CompiledFunction
+	for _, c := range outer.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			inner = fn
+		}
+	}
+	if inner == nil {
+		t.Fatalf("inner function not compiled")
+	}
+
+	expected := []object.FreeVariable{
+		{Name: "a", Local: true, Index: 0},
+		{Name: "b", Local: true, Index: 1},
+	}
+	if len(inner.Free) != len(expected) {
+		t.Fatalf("wrong free variables. want=%v, got=%v", expected, inner.Free)
+	}
+	for i, fv := range expected {
+		if inner.Free[i] != fv {
+			t.Errorf("free[%d] wrong. want=%v, got=%v", i, fv, inner.Free[i])
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("क")
+
+	fn := NewEnclosedSymbolTable(global)
+	fn.Define("ख")
+	fn.Declare("ग")
+	fn.DefinePending("क")
+
+	inner := NewEnclosedSymbolTable(fn)
+
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		// a pending binding is invisible to its own function only
+		{fn, "क", Symbol{Name: "क", Scope: GlobalScope, Index: 0}},
+		{inner, "क", Symbol{Name: "क", Scope: FreeScope, Index: 0}},
+		{inner, "ख", Symbol{Name: "ख", Scope: FreeScope, Index: 1}},
+		// a declared name is bound on first use by a nested function
+		{fn, "ग", Symbol{Name: "ग", Scope: GlobalScope, Index: 1}},
+		{inner, "ग", Symbol{Name: "ग", Scope: FreeScope, Index: 2}},
+		{inner, "घ", Symbol{Name: "घ", Scope: GlobalScope, Index: 2}},
+	}
+
+	for _, tt := range tests {
+		if got := tt.table.Resolve(tt.name); got != tt.expected {
+			t.Errorf("%s: wrong symbol. want=%+v, got=%+v", tt.name, tt.expected, got)
+		}
+	}
+
+	if got := fn.Define("ग"); got.Scope != LocalScope || got.Index != 2 {
+		t.Errorf("ग not bound in the slot its closure uses: %+v", got)
+	}
+}
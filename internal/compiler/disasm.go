@@ -0,0 +1,133 @@
+package compiler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/code"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Disassemble writes bc's functions as readable instructions, the top level
+// first. Each run of instructions is headed by the source line it was
+// compiled from, taken from source when that is given, and operands that
+// name constants, variables or imports are explained in comments.
+func Disassemble(w io.Writer, bc *Bytecode, source string) {
+	var lines []string
+	if source != "" {
+		lines = strings.Split(source, "\n")
+	}
+
+	d := &disassembler{w: w, bc: bc, lines: lines}
+	d.function(bc.Main, "== main ==")
+}
+
+type disassembler struct {
+	w     io.Writer
+	bc    *Bytecode
+	lines []string
+}
+
+func (d *disassembler) function(fn *object.CompiledFunction, title string) {
+	fmt.Fprintln(d.w, title)
+	if len(fn.Parameters) > 0 {
+		params := []string{}
+		for _, p := range fn.Parameters {
+			// Defaults are only known once the closure is made
+			if p.HasDefault {
+				params = append(params, p.Name+" = ...")
+			} else {
+				params = append(params, p.String())
+			}
+		}
+		fmt.Fprintf(d.w, "parameters: %s\n", strings.Join(params, ", "))
+	}
+	if fn.NumLocals > 0 {
+		fmt.Fprintf(d.w, "locals: %d\n", fn.NumLocals)
+	}
+	for _, fv := range fn.Free {
+		fmt.Fprintf(d.w, "free: %s\n", fv.Name)
+	}
+
+	line := 0
+	defined := map[int]int{} // line of each function constant's OpClosure
+	for i := 0; i < len(fn.Instructions); {
+		if l := fn.Lines.Line(i); l != line && l != 0 {
+			line = l
+			fmt.Fprintln(d.w, d.sourceLine(line))
+		}
+
+		if code.Opcode(fn.Instructions[i]) == code.OpClosure {
+			defined[int(code.ReadUint16(fn.Instructions[i+1:]))] = line
+		}
+
+		text, width := fn.Instructions.Format(i)
+		if comment := d.comment(fn, fn.Instructions[i:i+width]); comment != "" {
+			text = fmt.Sprintf("%-24s ; %s", text, comment)
+		}
+		fmt.Fprintf(d.w, "%04d %s\n", i, text)
+		i += width
+	}
+
+	for i, c := range fn.Constants {
+		if inner, ok := c.(*object.CompiledFunction); ok {
+			fmt.Fprintln(d.w)
+			d.function(inner, fmt.Sprintf("== %s (line %d) ==", functionName(inner), defined[i]))
+		}
+	}
+}
+
+// sourceLine formats the heading of the instructions compiled from line
+func (d *disassembler) sourceLine(line int) string {
+	if line > len(d.lines) {
+		return fmt.Sprintf("%5d|", line)
+	}
+	return fmt.Sprintf("%5d| %s", line, strings.TrimSpace(d.lines[line-1]))
+}
+
+// comment explains the operand of ins, if it refers to something by number
+func (d *disassembler) comment(fn *object.CompiledFunction, ins code.Instructions) string {
+	def, err := code.Lookup(ins[0])
+	if err != nil {
+		return ""
+	}
+	operands, _ := code.ReadOperands(def, ins[1:])
+
+	switch code.Opcode(ins[0]) {
+	case code.OpConstant, code.OpMember, code.OpImportName:
+		return constantString(fn.Constants[operands[0]])
+	case code.OpClosure:
+		return functionName(fn.Constants[operands[0]].(*object.CompiledFunction))
+	case code.OpArgument:
+		if operands[0] == code.ArgKeyword {
+			return constantString(fn.Constants[operands[1]])
+		}
+	case code.OpGetGlobal, code.OpSetGlobal:
+		return d.bc.Globals[operands[0]]
+	case code.OpGetLocal, code.OpSetLocal:
+		if name := fn.LocalNames[operands[0]]; name != "" {
+			return name
+		}
+		return "(temporary)"
+	case code.OpGetFree:
+		return fn.Free[operands[0]].Name
+	case code.OpImport:
+		return fmt.Sprintf("%q", d.bc.Imports[operands[0]])
+	}
+	return ""
+}
+
+func constantString(obj object.Object) string {
+	if s, ok := obj.(*object.String); ok {
+		return fmt.Sprintf("%q", s.Value)
+	}
+	return obj.Inspect()
+}
+
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return "फन <anonymous>"
+	}
+	return "फन " + fn.Name
+}
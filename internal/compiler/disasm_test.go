@@ -0,0 +1,50 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	source := `लेट x = "क";
+फन f(a, b = १) {
+	a + x
+}
+f(२)`
+
+	bc, _, err := CompileSource(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	Disassemble(&out, bc, source)
+
+	expected := `== main ==
+    1| लेट x = "क";
+0000 OpConstant 0             ; "क"
+0003 OpSetGlobal 0            ; x
+    2| फन f(a, b = १) {
+0006 OpConstant 1             ; 1
+0009 OpClosure 2              ; फन f
+0012 OpSetGlobal 1            ; f
+    5| f(२)
+0015 OpGetGlobal 1            ; f
+0018 OpConstant 3             ; 2
+0021 OpCall 1
+0023 OpReturnValue
+
+== फन f (line 2) ==
+parameters: a, b = ...
+locals: 2
+    3| a + x
+0000 OpGetLocal 0             ; a
+0003 OpGetGlobal 0            ; x
+0006 OpAdd
+    2| फन f(a, b = १) {
+0007 OpReturnValue
+`
+	if out.String() != expected {
+		t.Errorf("wrong disassembly.\nwant=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,368 @@
+package compiler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+
+	"github.com/SunilNeupane77/nepali/internal/code"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// A compiled file starts with a header:
+//
+//	magic        4 bytes   "NBC\x00"
+//	version      uint16    FormatVersion
+//	source hash  32 bytes  SHA-256 of the source it was compiled from
+//	checksum     uint32    CRC-32 (IEEE) of the body
+//
+// The body follows: the program's imports, globals and exports, then its
+// functions, the top level first and then every function it defines, depth
+// first. A function is its name, docstring and body source, whether it is
+// async, its parameters, locals, free variables, instructions, constants
+// and line table.
+//
+// Numbers in the header are big-endian, like instruction operands. In the
+// body, counts and lengths are uvarints and strings are a length and their
+// bytes. Constants are a tag and a value: an integer as a varint, a string,
+// or a function as its index in the function list.
+
+// FormatVersion is the version of the compiled file format. It changes
+// whenever the format or the instruction set does.
+const FormatVersion = 3
+
+var magic = []byte("NBC\x00")
+
+const headerSize = 4 + 2 + sha256.Size + 4
+
+// Constant tags
+const (
+	tagInteger byte = iota + 1
+	tagString
+	tagFunction
+)
+
+// Parameter flags
+const (
+	paramDefault byte = 1 << iota
+	paramRest
+	paramKeywordRest
+)
+
+// SourceHash returns the hash a compiled file records of its source
+func SourceHash(source []byte) [sha256.Size]byte {
+	return sha256.Sum256(source)
+}
+
+// Encode returns bc in the compiled file format, recording the hash of the
+// source it was compiled from
+func Encode(bc *Bytecode, sourceHash [sha256.Size]byte) ([]byte, error) {
+	e := &encoder{index: map[*object.CompiledFunction]int{}}
+	e.collect(bc.Main)
+
+	e.strings(bc.Imports)
+	e.strings(bc.Globals)
+	e.strings(bc.Exports)
+	e.uvarint(len(e.functions))
+	for _, fn := range e.functions {
+		if err := e.function(fn); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	out.Write(magic)
+	binary.Write(&out, binary.BigEndian, uint16(FormatVersion))
+	out.Write(sourceHash[:])
+	binary.Write(&out, binary.BigEndian, crc32.ChecksumIEEE(e.buf.Bytes()))
+	out.Write(e.buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// Decode reads a compiled file, returning its bytecode and the hash of the
+// source it was compiled from
+func Decode(data []byte) (*Bytecode, [sha256.Size]byte, error) {
+	var hash [sha256.Size]byte
+
+	if len(data) < headerSize || !bytes.Equal(data[:4], magic) {
+		return nil, hash, errors.New("not a compiled Nepali file")
+	}
+	if version := binary.BigEndian.Uint16(data[4:]); version != FormatVersion {
+		return nil, hash, fmt.Errorf("unsupported compiled format version %d, want %d", version, FormatVersion)
+	}
+	copy(hash[:], data[6:])
+
+	body := data[headerSize:]
+	if binary.BigEndian.Uint32(data[headerSize-4:]) != crc32.ChecksumIEEE(body) {
+		return nil, hash, errors.New("compiled file is corrupt: checksum mismatch")
+	}
+
+	d := &decoder{data: body}
+	bc := &Bytecode{
+		Imports: d.strings(),
+		Globals: d.strings(),
+		Exports: d.strings(),
+	}
+
+	functions := make([]*object.CompiledFunction, d.count())
+	for i := range functions {
+		functions[i] = &object.CompiledFunction{}
+	}
+	for _, fn := range functions {
+		d.function(fn, functions)
+	}
+
+	if d.err == nil && len(functions) == 0 {
+		d.err = errors.New("compiled file has no code")
+	}
+	if d.err == nil && d.pos != len(d.data) {
+		d.err = errors.New("compiled file has trailing data")
+	}
+	if d.err != nil {
+		return nil, hash, d.err
+	}
+
+	bc.Main = functions[0]
+	return bc, hash, nil
+}
+
+type encoder struct {
+	buf       bytes.Buffer
+	functions []*object.CompiledFunction
+	index     map[*object.CompiledFunction]int
+}
+
+// collect numbers fn and the functions it defines, depth first
+func (e *encoder) collect(fn *object.CompiledFunction) {
+	e.index[fn] = len(e.functions)
+	e.functions = append(e.functions, fn)
+
+	for _, c := range fn.Constants {
+		if inner, ok := c.(*object.CompiledFunction); ok {
+			e.collect(inner)
+		}
+	}
+}
+
+func (e *encoder) function(fn *object.CompiledFunction) error {
+	e.string(fn.Name)
+	e.string(fn.Doc)
+	e.string(fn.Body)
+	e.bool(fn.IsAsync)
+
+	e.uvarint(len(fn.Parameters))
+	for _, p := range fn.Parameters {
+		var flags byte
+		if p.HasDefault {
+			flags |= paramDefault
+		}
+		if p.Rest {
+			flags |= paramRest
+		}
+		if p.KeywordRest {
+			flags |= paramKeywordRest
+		}
+		e.string(p.Name)
+		e.buf.WriteByte(flags)
+	}
+
+	e.uvarint(fn.NumLocals)
+	e.strings(fn.LocalNames)
+
+	e.uvarint(len(fn.Free))
+	for _, fv := range fn.Free {
+		e.string(fv.Name)
+		e.bool(fv.Local)
+		e.uvarint(fv.Index)
+	}
+
+	e.uvarint(len(fn.Instructions))
+	e.buf.Write(fn.Instructions)
+
+	e.uvarint(len(fn.Constants))
+	for _, c := range fn.Constants {
+		switch c := c.(type) {
+		case *object.Integer:
+			e.buf.WriteByte(tagInteger)
+			e.varint(c.Value)
+		case *object.String:
+			e.buf.WriteByte(tagString)
+			e.string(c.Value)
+		case *object.CompiledFunction:
+			e.buf.WriteByte(tagFunction)
+			e.uvarint(e.index[c])
+		default:
+			return fmt.Errorf("cannot encode constant of type %s", c.Type())
+		}
+	}
+
+	e.uvarint(len(fn.Lines))
+	for _, l := range fn.Lines {
+		e.uvarint(l.Offset)
+		e.uvarint(l.Line)
+	}
+
+	return nil
+}
+
+func (e *encoder) uvarint(n int) {
+	e.buf.Write(binary.AppendUvarint(nil, uint64(n)))
+}
+
+func (e *encoder) varint(n int64) {
+	e.buf.Write(binary.AppendVarint(nil, n))
+}
+
+func (e *encoder) bool(b bool) {
+	if b {
+		e.buf.WriteByte(1)
+	} else {
+		e.buf.WriteByte(0)
+	}
+}
+
+func (e *encoder) string(s string) {
+	e.uvarint(len(s))
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) strings(list []string) {
+	e.uvarint(len(list))
+	for _, s := range list {
+		e.string(s)
+	}
+}
+
+// decoder reads a body, remembering the first error; once there is one,
+// reads return zero values
+type decoder struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+var errTruncated = errors.New("compiled file is truncated")
+
+func (d *decoder) function(fn *object.CompiledFunction, functions []*object.CompiledFunction) {
+	fn.Name = d.string()
+	fn.Doc = d.string()
+	fn.Body = d.string()
+	fn.IsAsync = d.byte() == 1
+
+	fn.Parameters = make([]*object.Parameter, d.count())
+	for i := range fn.Parameters {
+		name := d.string()
+		flags := d.byte()
+		fn.Parameters[i] = &object.Parameter{
+			Name:        name,
+			HasDefault:  flags&paramDefault != 0,
+			Rest:        flags&paramRest != 0,
+			KeywordRest: flags&paramKeywordRest != 0,
+		}
+	}
+
+	fn.NumLocals = d.uvarint()
+	fn.LocalNames = d.strings()
+
+	fn.Free = make([]object.FreeVariable, d.count())
+	for i := range fn.Free {
+		fn.Free[i] = object.FreeVariable{Name: d.string(), Local: d.byte() == 1, Index: d.uvarint()}
+	}
+
+	fn.Instructions = code.Instructions(d.bytes())
+
+	fn.Constants = make([]object.Object, d.count())
+	for i := range fn.Constants {
+		switch tag := d.byte(); tag {
+		case tagInteger:
+			fn.Constants[i] = &object.Integer{Value: d.varint()}
+		case tagString:
+			fn.Constants[i] = &object.String{Value: d.string()}
+		case tagFunction:
+			idx := d.uvarint()
+			if idx >= len(functions) {
+				d.fail(fmt.Errorf("compiled file refers to function %d of %d", idx, len(functions)))
+				return
+			}
+			fn.Constants[i] = functions[idx]
+		default:
+			d.fail(fmt.Errorf("compiled file has unknown constant tag %d", tag))
+			return
+		}
+	}
+
+	fn.Lines = make(code.LineTable, d.count())
+	for i := range fn.Lines {
+		fn.Lines[i] = code.LineEntry{Offset: d.uvarint(), Line: d.uvarint()}
+	}
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.pos = len(d.data)
+}
+
+func (d *decoder) uvarint() int {
+	n, read := binary.Uvarint(d.data[d.pos:])
+	if read <= 0 || n > math.MaxInt32 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.pos += read
+	return int(n)
+}
+
+// count reads the length of a list, each element of which takes at least a
+// byte
+func (d *decoder) count() int {
+	n := d.uvarint()
+	if n > len(d.data)-d.pos {
+		d.fail(errTruncated)
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) varint() int64 {
+	n, read := binary.Varint(d.data[d.pos:])
+	if read <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.pos += read
+	return n
+}
+
+func (d *decoder) byte() byte {
+	if d.pos >= len(d.data) {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.pos++
+	return d.data[d.pos-1]
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	b := append([]byte{}, d.data[d.pos:d.pos+n]...)
+	d.pos += n
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) strings() []string {
+	list := make([]string, d.count())
+	for i := range list {
+		list[i] = d.string()
+	}
+	return list
+}
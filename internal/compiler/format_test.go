@@ -0,0 +1,87 @@
+package compiler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const formatProgram = `
+आयात "./गणित" जस्तो ग;
+लेट x = -५;
+फन जोड(a, b = २, *बाँकी, **kw) {
+	"दुई संख्या जोड्छ"
+	फन() { a + b + x }
+}
+निर्यात लेट y = [जोड(१)() लागि _ मा "नमस्ते"];
+`
+
+func TestEncodeDecode(t *testing.T) {
+	bc, _, err := CompileSource(formatProgram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := SourceHash([]byte(formatProgram))
+
+	data, err := Encode(bc, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, decodedHash, err := Decode(data)
+	if err != nil {
+		t.Fatalf("decode failed: %s", err)
+	}
+	if decodedHash != hash {
+		t.Errorf("wrong source hash")
+	}
+
+	again, err := Encode(decoded, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, again) {
+		t.Errorf("decoding and encoding again changed the file")
+	}
+
+	var want, got strings.Builder
+	Disassemble(&want, bc, formatProgram)
+	Disassemble(&got, decoded, formatProgram)
+	if want.String() != got.String() {
+		t.Errorf("decoded program differs.\nwant=\n%s\ngot=\n%s", want.String(), got.String())
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	bc, _, err := CompileSource(formatProgram)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encode(bc, SourceHash([]byte(formatProgram)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modify := func(f func(d []byte) []byte) []byte {
+		return f(append([]byte{}, data...))
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"empty", nil, "not a compiled Nepali file"},
+		{"source", []byte(formatProgram), "not a compiled Nepali file"},
+		{"version", modify(func(d []byte) []byte { d[5]++; return d }), "unsupported compiled format version 4, want 3"},
+		{"flipped bit", modify(func(d []byte) []byte { d[len(d)-3] ^= 1; return d }), "compiled file is corrupt: checksum mismatch"},
+		{"truncated", modify(func(d []byte) []byte { return d[:len(d)-10] }), "compiled file is corrupt: checksum mismatch"},
+	}
+
+	for _, tt := range tests {
+		_, _, err := Decode(tt.data)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: wrong error. expected=%q, got=%v", tt.name, tt.expected, err)
+		}
+	}
+}
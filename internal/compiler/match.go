@@ -0,0 +1,152 @@
+package compiler
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/code"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// compileMatchExpression tries the cases in order, each in a scope of its
+// own. A failed test jumps to the next case; with none left the value is
+// निल.
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression) error {
+	if err := c.compileExpression(node.Subject); err != nil {
+		return err
+	}
+	subject := c.symbolTable.DefineTemp()
+	c.storeSymbol(subject)
+
+	ends := []int{}
+	for _, mc := range node.Cases {
+		c.symbolTable.PushBlock()
+
+		fails := []int{}
+		load := func() { c.loadSymbol(subject) }
+		if err := c.compilePattern(mc.Pattern, load, &fails); err != nil {
+			return err
+		}
+
+		if mc.Guard != nil {
+			if err := c.compileExpression(mc.Guard); err != nil {
+				return err
+			}
+			fails = append(fails, c.emit(code.OpJumpNotTruthy, 9999))
+		}
+
+		c.declare(mc.Body.Statements)
+		if err := c.compileBlockValue(mc.Body); err != nil {
+			return err
+		}
+		ends = append(ends, c.emit(code.OpJump, 9999))
+
+		c.symbolTable.PopBlock()
+		for _, pos := range fails {
+			c.changeOperand(pos, len(c.scope().instructions))
+		}
+	}
+
+	c.emit(code.OpNull)
+	for _, pos := range ends {
+		c.changeOperand(pos, len(c.scope().instructions))
+	}
+
+	return nil
+}
+
+// compilePattern compiles the tests of pattern against the value load
+// pushes, binding its names. Each failing test leaves the stack as it found
+// it and jumps to a position added to fails.
+func (c *Compiler) compilePattern(pattern ast.Pattern, load func(), fails *[]int) error {
+	fail := func() {
+		*fails = append(*fails, c.emit(code.OpJumpNotTruthy, 9999))
+	}
+
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		load()
+		if err := c.compileExpression(pattern.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpEqual)
+		fail()
+
+	case *ast.BindingPattern:
+		if !pattern.IsWildcard() {
+			load()
+			c.storeSymbol(c.symbolTable.Define(pattern.Name.Value))
+		}
+
+	case *ast.ArrayPattern:
+		load()
+		hasRest := 0
+		if pattern.Rest != nil {
+			hasRest = 1
+		}
+		c.emit(code.OpMatchArray, len(pattern.Before)+len(pattern.After), hasRest)
+		fail()
+
+		element := func(index int) func() {
+			return func() {
+				load()
+				c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(index)}))
+				c.emit(code.OpIndex)
+			}
+		}
+		for i, p := range pattern.Before {
+			if err := c.compilePattern(p, element(i), fails); err != nil {
+				return err
+			}
+		}
+		for i, p := range pattern.After {
+			if err := c.compilePattern(p, element(i-len(pattern.After)), fails); err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			load()
+			c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(len(pattern.Before))}))
+			flags := code.SliceStart
+			if len(pattern.After) > 0 {
+				c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: int64(-len(pattern.After))}))
+				flags |= code.SliceEnd
+			}
+			c.emit(code.OpSlice, flags)
+			c.storeSymbol(c.symbolTable.Define(pattern.Rest.Value))
+		}
+
+	case *ast.HashPattern:
+		load()
+		c.emit(code.OpMatchHash)
+		fail()
+
+		for i, k := range pattern.Keys {
+			load()
+			if err := c.compileExpression(k); err != nil {
+				return err
+			}
+			c.emit(code.OpHasKey)
+			fail()
+
+			value := func() {
+				load()
+				c.compileExpression(k)
+				c.emit(code.OpIndex)
+			}
+			if err := c.compilePattern(pattern.Values[i], value, fails); err != nil {
+				return err
+			}
+		}
+
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			load()
+			if err := c.compileExpressions(pattern.Keys); err != nil {
+				return err
+			}
+			c.emit(code.OpHashRest, len(pattern.Keys))
+			c.storeSymbol(c.symbolTable.Define(pattern.Rest.Value))
+		}
+	}
+
+	return nil
+}
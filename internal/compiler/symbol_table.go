@@ -0,0 +1,238 @@
+package compiler
+
+// SymbolScope tells the VM where a variable lives
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	FreeScope   SymbolScope = "FREE"
+)
+
+// Symbol is a resolved variable
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable resolves the names of one function, or of the program's top
+// level. Scopes inside it mirror the environments the tree-walker creates:
+// comprehensions and मिलान cases open a block of their own, while यदि
+// blocks share the scope around them. Names bound at the top level of the
+// program are globals; everything else is a slot in the function's frame,
+// never reused, so closures can keep referring to it.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	blocks      []map[string]*entry
+	declared    []map[string]bool
+	numLocals   int
+	localNames  []string
+	FreeSymbols []Symbol // what each free variable refers to in Outer
+	free        map[string]Symbol
+
+	globals *globalTable
+}
+
+type entry struct {
+	Symbol
+	pending bool // bound by a लेट whose value is being compiled
+}
+
+// globalTable numbers the globals of a program, shared by all its functions
+type globalTable struct {
+	names []string
+	index map[string]int
+}
+
+// NewSymbolTable returns the table of a program's top level
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		blocks:   []map[string]*entry{{}},
+		declared: []map[string]bool{{}},
+		free:     map[string]Symbol{},
+		globals:  &globalTable{index: map[string]int{}},
+	}
+}
+
+// NewEnclosedSymbolTable returns the table of a function defined where
+// outer resolves names
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{
+		Outer:    outer,
+		blocks:   []map[string]*entry{{}},
+		declared: []map[string]bool{{}},
+		free:     map[string]Symbol{},
+		globals:  outer.globals,
+	}
+}
+
+// GlobalNames lists the program's globals by slot
+func (s *SymbolTable) GlobalNames() []string {
+	return s.globals.names
+}
+
+// NumLocals returns how many slots the function's frame needs
+func (s *SymbolTable) NumLocals() int {
+	return s.numLocals
+}
+
+// LocalNames lists the function's locals by slot; temporaries have no name
+func (s *SymbolTable) LocalNames() []string {
+	return s.localNames
+}
+
+// TopLevel reports whether names are being bound at the top level of the
+// program, as globals
+func (s *SymbolTable) TopLevel() bool {
+	return s.Outer == nil && len(s.blocks) == 1
+}
+
+// PushBlock opens a nested scope
+func (s *SymbolTable) PushBlock() {
+	s.blocks = append(s.blocks, map[string]*entry{})
+	s.declared = append(s.declared, map[string]bool{})
+}
+
+// PopBlock closes the innermost scope
+func (s *SymbolTable) PopBlock() {
+	s.blocks = s.blocks[:len(s.blocks)-1]
+	s.declared = s.declared[:len(s.declared)-1]
+}
+
+// Declare notes that the innermost scope binds name further on. A nested
+// function that uses name before then, and finds it bound nowhere else,
+// refers to that binding: in the tree-walker it would find it when called.
+func (s *SymbolTable) Declare(name string) {
+	s.declared[len(s.declared)-1][name] = true
+}
+
+// Define binds name in the innermost scope, reusing its slot if the scope
+// already binds it
+func (s *SymbolTable) Define(name string) Symbol {
+	return s.define(name, false)
+}
+
+// DefinePending binds name like Define, but code in this function keeps
+// resolving name as before until Settle is called. Functions nested in the
+// value see the new binding, as they would in the tree-walker, where they
+// look names up only when called.
+func (s *SymbolTable) DefinePending(name string) Symbol {
+	return s.define(name, true)
+}
+
+// Settle makes a pending binding visible
+func (s *SymbolTable) Settle(name string) {
+	if e, ok := s.blocks[len(s.blocks)-1][name]; ok {
+		e.pending = false
+	}
+}
+
+func (s *SymbolTable) define(name string, pending bool) Symbol {
+	block := s.blocks[len(s.blocks)-1]
+	if e, ok := block[name]; ok {
+		e.pending = e.pending && pending
+		return e.Symbol
+	}
+
+	var symbol Symbol
+	if s.TopLevel() {
+		symbol = s.globals.define(name)
+		pending = false
+	} else {
+		symbol = Symbol{Name: name, Scope: LocalScope, Index: s.allocate(name)}
+	}
+
+	block[name] = &entry{Symbol: symbol, pending: pending}
+	return symbol
+}
+
+// DefineTemp reserves an unnamed local slot for the compiler's own use
+func (s *SymbolTable) DefineTemp() Symbol {
+	return Symbol{Scope: LocalScope, Index: s.allocate("")}
+}
+
+func (s *SymbolTable) allocate(name string) int {
+	s.localNames = append(s.localNames, name)
+	s.numLocals++
+	return s.numLocals - 1
+}
+
+// Resolve finds what name refers to. A name bound nowhere resolves to a
+// global, which the VM looks up among the builtins while it is unset.
+func (s *SymbolTable) Resolve(name string) Symbol {
+	if symbol, ok := s.resolve(name, false); ok {
+		return symbol
+	}
+	if symbol, ok := s.resolveDeclared(name, false); ok {
+		return symbol
+	}
+	return s.globals.define(name)
+}
+
+// resolve looks name up through the enclosing functions. Pending bindings
+// are visible only to nested functions.
+func (s *SymbolTable) resolve(name string, nested bool) (Symbol, bool) {
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		if e, ok := s.blocks[i][name]; ok && (nested || !e.pending) {
+			return e.Symbol, true
+		}
+	}
+
+	if symbol, ok := s.free[name]; ok {
+		return symbol, true
+	}
+
+	if s.Outer == nil {
+		return Symbol{}, false
+	}
+
+	symbol, ok := s.Outer.resolve(name, true)
+	if !ok || symbol.Scope == GlobalScope {
+		return symbol, ok
+	}
+	return s.defineFree(symbol), true
+}
+
+// resolveDeclared binds a name the enclosing functions declare but have not
+// bound yet, as a pending binding in the scope declaring it
+func (s *SymbolTable) resolveDeclared(name string, nested bool) (Symbol, bool) {
+	if nested {
+		for i := len(s.declared) - 1; i >= 0; i-- {
+			if s.declared[i][name] {
+				symbol := Symbol{Name: name, Scope: LocalScope, Index: s.allocate(name)}
+				s.blocks[i][name] = &entry{Symbol: symbol, pending: true}
+				return symbol, true
+			}
+		}
+	}
+
+	if s.Outer == nil {
+		return Symbol{}, false
+	}
+
+	symbol, ok := s.Outer.resolveDeclared(name, true)
+	if !ok {
+		return symbol, false
+	}
+	return s.defineFree(symbol), true
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Scope: FreeScope, Index: len(s.FreeSymbols) - 1}
+	s.free[original.Name] = symbol
+	return symbol
+}
+
+func (g *globalTable) define(name string) Symbol {
+	index, ok := g.index[name]
+	if !ok {
+		index = len(g.names)
+		g.names = append(g.names, name)
+		g.index[name] = index
+	}
+	return Symbol{Name: name, Scope: GlobalScope, Index: index}
+}
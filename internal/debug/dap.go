@@ -0,0 +1,442 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// threadID is the one thread a program has
+const threadID = 1
+
+// Serve speaks the Debug Adapter Protocol with a client, reading its
+// requests from r and writing responses and events to w, until the client
+// disconnects or r ends. It debugs one program, the one the client
+// launches. What the program prints is sent as output events.
+func Serve(r io.Reader, w io.Writer) error {
+	a := &adapter{out: w}
+	in := bufio.NewReader(r)
+
+	output := builtins.Output
+	builtins.Output = outputWriter{a}
+	defer func() { builtins.Output = output }()
+
+	for {
+		content, err := readMessage(in)
+		if err == io.EOF {
+			a.end()
+			return nil
+		}
+		if err != nil {
+			a.end()
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			a.end()
+			return err
+		}
+		if req.Command == "disconnect" {
+			a.end()
+			a.respond(req, nil, nil)
+			return a.writeErr()
+		}
+		a.handle(req)
+		if err := a.writeErr(); err != nil {
+			a.end()
+			return err
+		}
+	}
+}
+
+// adapter is the state of a debugging session with a client. Requests are
+// handled one at a time; events of the program are sent from a goroutine of
+// their own, so writing is guarded.
+type adapter struct {
+	mu  sync.Mutex
+	out io.Writer
+	err error // the first error writing to out
+	seq int
+
+	session     *Session
+	stopOnEntry bool
+	done        chan struct{} // closed once the program's events are all sent
+
+	// What the client may ask for the variables of while the program is
+	// stopped, each an *object.Environment or an object.Object with parts,
+	// referred to by its index plus one
+	handles []interface{}
+}
+
+func (a *adapter) write(v interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.err != nil {
+		return
+	}
+	a.seq++
+	switch v := v.(type) {
+	case *response:
+		v.Seq = a.seq
+	case *event:
+		v.Seq = a.seq
+	}
+	a.err = writeMessage(a.out, v)
+}
+
+func (a *adapter) writeErr() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+func (a *adapter) respond(req request, body interface{}, err error) {
+	res := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		res.Message = err.Error()
+	}
+	a.write(res)
+}
+
+func (a *adapter) send(name string, body interface{}) {
+	a.write(&event{Type: "event", Event: name, Body: body})
+}
+
+// outputWriter sends what the program prints as output events
+type outputWriter struct {
+	a *adapter
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.a.send("output", outputEvent{Category: "stdout", Output: string(p)})
+	return len(p), nil
+}
+
+func (a *adapter) handle(req request) {
+	// Commands that resume the program answer first, so that the client
+	// hears of the request before the stop it leads to
+	resume := map[string]func() error{
+		"continue": func() error { return a.session.Continue() },
+		"next":     func() error { return a.session.StepOver() },
+		"stepIn":   func() error { return a.session.StepIn() },
+		"stepOut":  func() error { return a.session.StepOut() },
+	}
+	if step, ok := resume[req.Command]; ok {
+		if err := a.stopped(); err != nil {
+			a.respond(req, nil, err)
+			return
+		}
+		var body interface{}
+		if req.Command == "continue" {
+			body = map[string]bool{"allThreadsContinued": true}
+		}
+		a.handles = nil
+		a.respond(req, body, nil)
+		step()
+		return
+	}
+
+	var body interface{}
+	var err error
+	switch req.Command {
+	case "initialize":
+		body = capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}
+	case "launch":
+		err = a.launch(req.Arguments)
+		if err == nil {
+			a.respond(req, nil, nil)
+			a.send("initialized", nil)
+			return
+		}
+	case "setBreakpoints":
+		body, err = a.setBreakpoints(req.Arguments)
+	case "setExceptionBreakpoints":
+		body = map[string]interface{}{"breakpoints": []breakpoint{}}
+	case "configurationDone":
+		if a.session == nil {
+			err = errors.New("no program has been launched")
+			break
+		}
+		a.respond(req, nil, nil)
+		a.start()
+		return
+	case "threads":
+		body = map[string][]thread{"threads": {{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		body, err = a.stackTrace()
+	case "scopes":
+		body, err = a.scopes(req.Arguments)
+	case "variables":
+		body, err = a.variables(req.Arguments)
+	case "evaluate":
+		body, err = a.evaluate(req.Arguments)
+	case "pause":
+		// A stopped program has nothing to pause
+		if a.session != nil && a.stopped() == ErrRunning {
+			a.session.Pause()
+		}
+	case "terminate":
+		a.respond(req, nil, nil)
+		if a.session != nil {
+			a.session.Terminate()
+		}
+		return
+	default:
+		err = fmt.Errorf("unsupported request %s", req.Command)
+	}
+	a.respond(req, body, err)
+}
+
+func (a *adapter) launch(arguments json.RawMessage) error {
+	if a.session != nil {
+		return errors.New("a program has already been launched")
+	}
+	var args launchArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return err
+	}
+	if args.Program == "" {
+		return errors.New("no program to launch")
+	}
+
+	source, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	s, err := New(args.Program, string(source))
+	if err != nil {
+		return fmt.Errorf("%s: %s", args.Program, err)
+	}
+	a.session, a.stopOnEntry = s, args.StopOnEntry
+	return nil
+}
+
+// start runs the program, sending its stops and end as events
+func (a *adapter) start() {
+	a.done = make(chan struct{})
+	a.session.Start(a.stopOnEntry)
+
+	go func() {
+		defer close(a.done)
+		for ev := range a.session.Events() {
+			if ev.Reason != "exited" {
+				a.send("stopped", stoppedEvent{Reason: ev.Reason, ThreadID: threadID, AllThreadsStopped: true})
+				continue
+			}
+
+			code := 0
+			if ev.Terminated {
+				code = 1
+			} else if err, ok := ev.Result.(*object.Error); ok {
+				code = 1
+				var msg strings.Builder
+				fmt.Fprintf(&msg, "%s\n", err.Inspect())
+				for _, name := range err.Backtrace() {
+					fmt.Fprintf(&msg, "\tफन %s भित्र\n", name)
+				}
+				a.send("output", outputEvent{Category: "stderr", Output: msg.String()})
+			}
+			a.send("exited", exitedEvent{ExitCode: code})
+			a.send("terminated", nil)
+		}
+	}()
+}
+
+// end stops the program, if it is running, and waits for it to finish
+func (a *adapter) end() {
+	if a.done == nil {
+		return
+	}
+	a.session.Terminate()
+	<-a.done
+}
+
+func (a *adapter) stopped() error {
+	if a.session == nil {
+		return errors.New("no program has been launched")
+	}
+	_, err := a.session.Frames()
+	return err
+}
+
+func (a *adapter) setBreakpoints(arguments json.RawMessage) (interface{}, error) {
+	if a.session == nil {
+		return nil, errors.New("no program has been launched")
+	}
+	var args setBreakpointsArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+
+	result := make([]breakpoint, len(args.Breakpoints))
+	if !a.sameFile(args.Source.Path) {
+		for i, b := range args.Breakpoints {
+			result[i] = breakpoint{Line: b.Line, Message: "only the launched program can have breakpoints"}
+		}
+		return map[string][]breakpoint{"breakpoints": result}, nil
+	}
+
+	bps := make([]Breakpoint, len(args.Breakpoints))
+	for i, b := range args.Breakpoints {
+		bps[i] = Breakpoint{Line: b.Line, Condition: b.Condition}
+	}
+	for i, bp := range a.session.SetBreakpoints(bps) {
+		result[i] = breakpoint{Verified: bp.Verified, Line: bp.Line, Message: bp.Message}
+	}
+	return map[string][]breakpoint{"breakpoints": result}, nil
+}
+
+func (a *adapter) sameFile(path string) bool {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path == a.session.File()
+}
+
+func (a *adapter) stackTrace() (interface{}, error) {
+	if err := a.stopped(); err != nil {
+		return nil, err
+	}
+	frames, _ := a.session.Frames()
+
+	result := make([]stackFrame, len(frames))
+	for i, f := range frames {
+		result[i] = stackFrame{
+			ID:     i + 1,
+			Name:   f.Name,
+			Source: source{Name: filepath.Base(f.File), Path: f.File},
+			Line:   f.Line,
+			Column: 1,
+		}
+	}
+	return map[string]interface{}{"stackFrames": result, "totalFrames": len(result)}, nil
+}
+
+// frame returns the stopped program's frame a client's frame ID refers to,
+// the innermost for none
+func (a *adapter) frame(id int) (Frame, error) {
+	if err := a.stopped(); err != nil {
+		return Frame{}, err
+	}
+	frames, _ := a.session.Frames()
+	if id == 0 {
+		id = 1
+	}
+	if id < 1 || id > len(frames) {
+		return Frame{}, fmt.Errorf("no frame %d", id)
+	}
+	return frames[id-1], nil
+}
+
+func (a *adapter) scopes(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	f, err := a.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []scope
+	for _, sc := range Scopes(f.Env) {
+		result = append(result, scope{Name: sc.Name, VariablesReference: a.reference(sc.Env)})
+	}
+	return map[string][]scope{"scopes": result}, nil
+}
+
+func (a *adapter) variables(arguments json.RawMessage) (interface{}, error) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if err := a.stopped(); err != nil {
+		return nil, err
+	}
+	ref := args.VariablesReference
+	if ref < 1 || ref > len(a.handles) {
+		return nil, fmt.Errorf("no variables %d", ref)
+	}
+
+	var vars []Variable
+	switch h := a.handles[ref-1].(type) {
+	case *object.Environment:
+		vars = Variables(h)
+	case object.Object:
+		vars = Children(h)
+	}
+
+	result := make([]variable, len(vars))
+	for i, v := range vars {
+		result[i] = variable{
+			Name:               v.Name,
+			Value:              v.Value.Inspect(),
+			Type:               string(v.Value.Type()),
+			VariablesReference: a.valueHandle(v.Value),
+		}
+	}
+	return map[string][]variable{"variables": result}, nil
+}
+
+func (a *adapter) evaluate(arguments json.RawMessage) (interface{}, error) {
+	var args evaluateArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return nil, err
+	}
+	if _, err := a.frame(args.FrameID); err != nil {
+		return nil, err
+	}
+	if args.FrameID == 0 {
+		args.FrameID = 1
+	}
+
+	value, err := a.session.Evaluate(args.FrameID-1, args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if err, ok := value.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	if value == nil {
+		value = object.NULL
+	}
+	return evaluateResult{
+		Result:             value.Inspect(),
+		Type:               string(value.Type()),
+		VariablesReference: a.valueHandle(value),
+	}, nil
+}
+
+// reference returns a reference to h for the client to ask the variables of
+func (a *adapter) reference(h interface{}) int {
+	a.handles = append(a.handles, h)
+	return len(a.handles)
+}
+
+// valueHandle returns a reference to a value's parts, or 0 for a value
+// without them
+func (a *adapter) valueHandle(value object.Object) int {
+	if len(Children(value)) == 0 {
+		return 0
+	}
+	return a.reference(value)
+}
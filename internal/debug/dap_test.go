@@ -0,0 +1,116 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSessions plays the scripted sessions in testdata. In a script, a line
+// starting "--> " is a message the client sends, and one starting "<-- " is
+// the next message the adapter must send, compared as JSON without its seq.
+// ${testdata} stands for the absolute path of testdata. Lines starting with
+// # are comments.
+func TestSessions(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no sessions in testdata")
+	}
+	dir, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, script := range scripts {
+		script := script
+		t.Run(strings.TrimSuffix(filepath.Base(script), ".txt"), func(t *testing.T) {
+			playSession(t, script, dir)
+		})
+	}
+}
+
+func playSession(t *testing.T, script, dir string) {
+	data, err := os.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.ReplaceAll(string(data), "${testdata}", dir))
+
+	clientR, clientW := io.Pipe()
+	serverR, serverW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(clientR, serverW)
+		serverW.Close()
+	}()
+
+	// Collect what the adapter sends as it sends it, so that it never waits
+	// on the script
+	messages := make(chan []byte, 100)
+	go func() {
+		in := bufio.NewReader(serverR)
+		for {
+			content, err := readMessage(in)
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- content
+		}
+	}()
+
+	for n, line := range strings.Split(string(data), "\n") {
+		n++
+		switch {
+		case strings.HasPrefix(line, "--> "):
+			if err := writeMessage(clientW, json.RawMessage(line[4:])); err != nil {
+				t.Fatalf("line %d: sending: %s", n, err)
+			}
+		case strings.HasPrefix(line, "<-- "):
+			select {
+			case got, ok := <-messages:
+				if !ok {
+					t.Fatalf("line %d: the adapter stopped before sending %s", n, line[4:])
+				}
+				if !sameMessage(t, got, []byte(line[4:])) {
+					t.Errorf("line %d: wrong message.\nexpected: %s\ngot:      %s", n, line[4:], got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("line %d: timed out waiting for %s", n, line[4:])
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			t.Fatalf("line %d: not part of a session: %q", n, line)
+		}
+	}
+
+	clientW.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned an error: %s", err)
+	}
+	for extra := range messages {
+		t.Errorf("unexpected message: %s", extra)
+	}
+}
+
+// sameMessage reports whether the message got is expected, ignoring its seq
+func sameMessage(t *testing.T, got, expected []byte) bool {
+	var vg, ve map[string]interface{}
+	if err := json.Unmarshal(got, &vg); err != nil {
+		t.Fatalf("bad JSON %s: %s", got, err)
+	}
+	if err := json.Unmarshal(expected, &ve); err != nil {
+		t.Fatalf("bad JSON %s: %s", expected, err)
+	}
+	delete(vg, "seq")
+	return reflect.DeepEqual(vg, ve)
+}
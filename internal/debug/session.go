@@ -0,0 +1,560 @@
+// Package debug runs programs under the tree-walking evaluator one statement
+// at a time, stopping at breakpoints and steps so their calls and variables
+// can be inspected. It serves the Debug Adapter Protocol, for `nepali
+// debug`, and a terminal debugger, for the REPL's :debug.
+package debug
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/evaluator"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+// ParseErrors reports source that does not parse, and so cannot be run
+type ParseErrors []string
+
+func (e ParseErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+// ErrRunning is returned for what can only be done while the program is
+// stopped
+var ErrRunning = errors.New("the program is not stopped")
+
+// Event is a stop of the program, or its end
+type Event struct {
+	// Reason is why the program stopped: "entry", "breakpoint", "step" or
+	// "pause"; or "exited" once it has finished
+	Reason string
+	Line   int
+
+	// Result is what the program evaluated to, for "exited": perhaps an
+	// *object.Error
+	Result object.Object
+
+	// Terminated is set, for "exited", when Terminate ended the program
+	Terminated bool
+}
+
+// Breakpoint stops the program before a statement starting on Line runs,
+// if its Condition, an expression, is empty or true there
+type Breakpoint struct {
+	Line      int
+	Condition string
+
+	// Set by SetBreakpoints: whether the program can stop there, and if
+	// not, why
+	Verified bool
+	Message  string
+
+	condition ast.Expression
+}
+
+// Frame is a call in progress
+type Frame struct {
+	Name string // the function's name, or <anonymous> or <module>
+	File string
+	Line int
+	Env  *object.Environment // the scope of the statement it is running
+}
+
+type action int
+
+const (
+	continueAction action = iota
+	stepInAction
+	stepOverAction
+	stepOutAction
+	terminateAction
+)
+
+// Session runs one program under the debugger. The program runs on a
+// goroutine of its own, which stops before statements of the program's
+// file as the breakpoints and steps say, and reports each stop on Events.
+//
+// Only one session can run at a time, as the evaluator has one instrument.
+type Session struct {
+	file    string
+	source  []string // by line, from 0
+	program *ast.Program
+	lines   map[int]bool // the lines statements start on
+
+	events  chan Event
+	actions chan action
+
+	mu          sync.Mutex
+	breakpoints map[int]*Breakpoint
+	pause       bool
+	terminate   bool
+	stopped     bool
+	frames      []Frame // while stopped, innermost first
+
+	// How the program goes on from its last stop, touched only by its
+	// goroutine
+	step                action
+	stepDepth           int
+	fromLine, fromDepth int // where it last stopped, until it leaves that line
+	entry               bool
+}
+
+// New parses source, the contents of the file at path, for a session
+func New(path, source string) (*Session, error) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, ParseErrors(p.Errors())
+	}
+
+	s := &Session{
+		file:        path,
+		source:      strings.Split(source, "\n"),
+		program:     program,
+		lines:       make(map[int]bool),
+		events:      make(chan Event),
+		actions:     make(chan action),
+		breakpoints: make(map[int]*Breakpoint),
+	}
+	statementLines(program.Statements, s.lines)
+	return s, nil
+}
+
+// File returns the path of the program's file
+func (s *Session) File() string {
+	return s.file
+}
+
+// Source returns the text of line n of the program's file, from 1
+func (s *Session) Source(n int) string {
+	if n < 1 || n > len(s.source) {
+		return ""
+	}
+	return strings.TrimRight(s.source[n-1], "\r")
+}
+
+// Lines returns the number of lines in the program's file
+func (s *Session) Lines() int {
+	return len(s.source)
+}
+
+// Events returns the channel the session reports stops on. It is closed
+// after the "exited" event.
+func (s *Session) Events() <-chan Event {
+	return s.events
+}
+
+// SetBreakpoints replaces the session's breakpoints, returning them with
+// Verified and Message set
+func (s *Session) SetBreakpoints(bps []Breakpoint) []Breakpoint {
+	set := make(map[int]*Breakpoint)
+	for i := range bps {
+		bp := &bps[i]
+		bp.Verified, bp.Message, bp.condition = false, "", nil
+
+		if !s.lines[bp.Line] {
+			bp.Message = fmt.Sprintf("no statement starts on line %d", bp.Line)
+			continue
+		}
+		if bp.Condition != "" {
+			exp, err := parseExpression(bp.Condition)
+			if err != nil {
+				bp.Message = fmt.Sprintf("condition %s: %s", bp.Condition, err)
+				continue
+			}
+			bp.condition = exp
+		}
+
+		bp.Verified = true
+		copied := *bp
+		set[bp.Line] = &copied
+	}
+
+	s.mu.Lock()
+	s.breakpoints = set
+	s.mu.Unlock()
+	return bps
+}
+
+// Start runs the program, stopping before its first statement if
+// stopOnEntry is set
+func (s *Session) Start(stopOnEntry bool) {
+	s.entry = stopOnEntry
+
+	go func() {
+		evaluator.SetStatementHook(s.statement)
+		result := evaluator.EvalFile(s.program, s.file, object.NewEnvironment())
+		evaluator.SetStatementHook(nil)
+
+		s.mu.Lock()
+		terminated := s.terminate
+		s.mu.Unlock()
+		s.events <- Event{Reason: "exited", Result: result, Terminated: terminated}
+		close(s.events)
+	}()
+}
+
+// Continue runs the stopped program until it next stops
+func (s *Session) Continue() error { return s.resume(continueAction) }
+
+// StepIn runs the stopped program to the next statement, in whatever call
+func (s *Session) StepIn() error { return s.resume(stepInAction) }
+
+// StepOver runs the stopped program to the next statement in the same call
+// or one it returns to
+func (s *Session) StepOver() error { return s.resume(stepOverAction) }
+
+// StepOut runs the stopped program until the call it is in returns
+func (s *Session) StepOut() error { return s.resume(stepOutAction) }
+
+// Pause stops the running program before its next statement
+func (s *Session) Pause() {
+	s.mu.Lock()
+	s.pause = true
+	s.mu.Unlock()
+}
+
+// Terminate ends the program before its next statement runs
+func (s *Session) Terminate() {
+	s.mu.Lock()
+	s.terminate = true
+	stopped := s.stopped
+	s.mu.Unlock()
+
+	if stopped {
+		s.resume(terminateAction)
+	}
+}
+
+func (s *Session) resume(a action) error {
+	s.mu.Lock()
+	if !s.stopped {
+		s.mu.Unlock()
+		return ErrRunning
+	}
+	s.stopped, s.frames = false, nil
+	s.mu.Unlock()
+
+	s.actions <- a
+	return nil
+}
+
+// Frames returns the calls in progress while the program is stopped,
+// innermost first
+func (s *Session) Frames() ([]Frame, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return nil, ErrRunning
+	}
+	return s.frames, nil
+}
+
+// Evaluate evaluates the expression source in the scope of the stopped
+// program's frame, numbered from 0 for the innermost
+func (s *Session) Evaluate(frame int, source string) (object.Object, error) {
+	frames, err := s.Frames()
+	if err != nil {
+		return nil, err
+	}
+	if frame < 0 || frame >= len(frames) {
+		return nil, fmt.Errorf("no frame %d", frame)
+	}
+
+	exp, err := parseExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	return evaluator.EvalExpression(exp, frames[frame].Env), nil
+}
+
+// statement is the evaluator's statement hook. It stops the program, if it
+// should stop before stmt, until the controller resumes it.
+func (s *Session) statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	s.mu.Lock()
+	terminate := s.terminate
+	s.mu.Unlock()
+	if terminate {
+		return &object.Error{Message: "terminated by the debugger"}
+	}
+
+	// Statements of imported modules run without stopping
+	if evaluator.File(env) != s.file {
+		return nil
+	}
+
+	stack := evaluator.CallStack()
+	line, depth := stack[len(stack)-1].Line, len(stack)
+	reason := s.reason(line, depth, env)
+	if reason == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	if s.terminate {
+		s.mu.Unlock()
+		return &object.Error{Message: "terminated by the debugger"}
+	}
+	s.stopped, s.frames = true, frames(stack)
+	s.mu.Unlock()
+
+	s.events <- Event{Reason: reason, Line: line}
+	a := <-s.actions
+	if a == terminateAction {
+		return &object.Error{Message: "terminated by the debugger"}
+	}
+	s.step, s.stepDepth = a, depth
+	s.fromLine, s.fromDepth = line, depth
+	return nil
+}
+
+// reason returns why the program should stop before a statement on line,
+// depth calls deep, or "" if it should not
+func (s *Session) reason(line, depth int, env *object.Environment) string {
+	s.mu.Lock()
+	bp := s.breakpoints[line]
+	pause := s.pause
+	s.pause = false
+	s.mu.Unlock()
+
+	switch {
+	case s.entry:
+		s.entry = false
+		return "entry"
+	case pause:
+		return "pause"
+	}
+
+	// The rest of the line the program last stopped on runs without
+	// stopping again, but not calls it makes
+	if s.fromLine != 0 {
+		if line == s.fromLine && depth == s.fromDepth {
+			return ""
+		}
+		if depth <= s.fromDepth {
+			s.fromLine = 0
+		}
+	}
+
+	switch {
+	case s.step == stepInAction,
+		s.step == stepOverAction && depth <= s.stepDepth,
+		s.step == stepOutAction && depth < s.stepDepth:
+		return "step"
+	case bp != nil && (bp.condition == nil || holds(bp.condition, env)):
+		return "breakpoint"
+	}
+	return ""
+}
+
+// holds reports whether a breakpoint's condition is true in env. A
+// condition that fails counts as true, so that the mistake can be seen.
+func holds(condition ast.Expression, env *object.Environment) bool {
+	result := evaluator.EvalExpression(condition, env)
+	if _, ok := result.(*object.Error); ok {
+		return true
+	}
+	return evaluator.IsTruthy(result)
+}
+
+// frames describes the evaluator's call stack, innermost first
+func frames(stack []*evaluator.Frame) []Frame {
+	out := make([]Frame, len(stack))
+	for i, f := range stack {
+		name := "<module>"
+		if f.Function != nil {
+			name = f.Function.Name
+			if name == "" {
+				name = "<anonymous>"
+			}
+		}
+		out[len(stack)-1-i] = Frame{Name: name, File: evaluator.File(f.Env), Line: f.Line, Env: f.Env}
+	}
+	return out
+}
+
+// Scope is one environment of a frame's chain
+type Scope struct {
+	Name string // Locals, Enclosing or Globals
+	Env  *object.Environment
+}
+
+// Variable is a named value: a variable of a scope, or a part of a value
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Scopes returns the chain of environments env is in, innermost first
+func Scopes(env *object.Environment) []Scope {
+	var scopes []Scope
+	for e := env; e != nil; e = e.Outer() {
+		name := "Enclosing"
+		switch {
+		case e.Outer() == nil:
+			name = "Globals"
+		case e == env:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{Name: name, Env: e})
+	}
+	return scopes
+}
+
+// Variables returns the variables env binds, in the order of their slots
+func Variables(env *object.Environment) []Variable {
+	var vars []Variable
+	for slot, name := range env.Names() {
+		if value := env.Lookup(0, slot); value != nil {
+			vars = append(vars, Variable{Name: name, Value: value})
+		}
+	}
+	return vars
+}
+
+// Children returns the parts of a value that has them: the elements of an
+// array or set, and the pairs of a hash, named by their keys
+func Children(obj object.Object) []Variable {
+	var vars []Variable
+	switch obj := obj.(type) {
+	case *object.Array:
+		for i, el := range obj.Elements {
+			vars = append(vars, Variable{Name: fmt.Sprint(i), Value: el})
+		}
+	case *object.Set:
+		for i, el := range obj.Elements() {
+			vars = append(vars, Variable{Name: fmt.Sprint(i), Value: el})
+		}
+	case *object.Hash:
+		for _, pair := range obj.Pairs() {
+			vars = append(vars, Variable{Name: pair.Key.Inspect(), Value: pair.Value})
+		}
+	}
+	return vars
+}
+
+// parseExpression parses source as a single expression
+func parseExpression(source string) (ast.Expression, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, ParseErrors(p.Errors())
+	}
+	if len(program.Statements) != 1 {
+		return nil, errors.New("expected one expression")
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil, errors.New("expected an expression")
+	}
+	return stmt.Expression, nil
+}
+
+// lineFinder records the lines statements start on, including those inside
+// functions and the bodies of expressions
+type lineFinder map[int]bool
+
+func statementLines(stmts []ast.Statement, lines map[int]bool) {
+	lineFinder(lines).statements(stmts)
+}
+
+func (f lineFinder) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		f.statement(stmt)
+	}
+}
+
+func (f lineFinder) block(block *ast.BlockStatement) {
+	if block != nil {
+		f.statements(block.Statements)
+	}
+}
+
+func (f lineFinder) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		f[stmt.Token.Line] = true
+		f.expressions(stmt.Decorators)
+		f.expression(stmt.Value)
+	case *ast.ExportStatement:
+		f[stmt.Token.Line] = true
+		f.statement(stmt.Statement)
+	case *ast.ReturnStatement:
+		f[stmt.Token.Line] = true
+		f.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		f[stmt.Token.Line] = true
+		f.expression(stmt.Expression)
+	case *ast.ImportStatement:
+		f[stmt.Token.Line] = true
+	case *ast.WithStatement:
+		f[stmt.Token.Line] = true
+		f.expression(stmt.Context)
+		f.block(stmt.Body)
+	case *ast.BlockStatement:
+		f.block(stmt)
+	}
+}
+
+func (f lineFinder) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		f.expression(exp)
+	}
+}
+
+func (f lineFinder) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		f.expression(exp.Right)
+	case *ast.InfixExpression:
+		f.expression(exp.Left)
+		f.expression(exp.Right)
+	case *ast.IfExpression:
+		f.expression(exp.Condition)
+		f.block(exp.Consequence)
+		f.block(exp.Alternative)
+	case *ast.FunctionLiteral:
+		for _, p := range exp.Parameters {
+			f.expression(p.Default)
+		}
+		f.block(exp.Body)
+	case *ast.CallExpression:
+		f.expression(exp.Function)
+		f.expressions(exp.Arguments)
+	case *ast.KeywordArgument:
+		f.expression(exp.Value)
+	case *ast.SpreadArgument:
+		f.expression(exp.Value)
+	case *ast.ArrayLiteral:
+		f.expressions(exp.Elements)
+	case *ast.IndexExpression:
+		f.expression(exp.Left)
+		f.expression(exp.Index)
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			f.expression(pair.Key)
+			f.expression(pair.Value)
+		}
+	case *ast.SetLiteral:
+		f.expressions(exp.Elements)
+	case *ast.AwaitExpression:
+		f.expression(exp.Value)
+	case *ast.MemberExpression:
+		f.expression(exp.Object)
+	case *ast.MatchExpression:
+		f.expression(exp.Subject)
+		for _, mc := range exp.Cases {
+			f.block(mc.Body)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package debug
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+)
+
+func newSession(t *testing.T, path string) *Session {
+	t.Helper()
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(path, string(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func quiet(t *testing.T) {
+	output := builtins.Output
+	builtins.Output = io.Discard
+	t.Cleanup(func() { builtins.Output = output })
+}
+
+func TestStops(t *testing.T) {
+	quiet(t)
+
+	tests := []struct {
+		name        string
+		stopOnEntry bool
+		breakpoints []Breakpoint
+		actions     []string // taken at each stop, in turn; then continue
+		stops       []string
+	}{
+		{
+			name:        "step over",
+			stopOnEntry: true,
+			actions:     []string{"next", "next", "next", "next", "next"},
+			stops:       []string{"entry 1", "step 5", "step 6", "step 7", "step 8", "step 9"},
+		},
+		{
+			name:        "step in",
+			stopOnEntry: true,
+			actions:     []string{"in", "in", "in", "in", "in"},
+			stops:       []string{"entry 1", "step 5", "step 6", "step 2", "step 3", "step 7"},
+		},
+		{
+			name:        "breakpoint",
+			breakpoints: []Breakpoint{{Line: 2}},
+			stops:       []string{"breakpoint 2", "breakpoint 2"},
+		},
+		{
+			name:        "conditional breakpoint",
+			breakpoints: []Breakpoint{{Line: 2, Condition: "n > ५"}},
+			stops:       []string{"breakpoint 2"},
+		},
+		{
+			name:        "step out",
+			breakpoints: []Breakpoint{{Line: 3}},
+			actions:     []string{"out"},
+			stops:       []string{"breakpoint 3", "step 7", "breakpoint 3"},
+		},
+		{
+			name:        "breakpoint while stepping over",
+			stopOnEntry: true,
+			breakpoints: []Breakpoint{{Line: 2}},
+			actions:     []string{"next", "next", "next"},
+			stops:       []string{"entry 1", "step 5", "step 6", "breakpoint 2", "breakpoint 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSession(t, "testdata/square.nep")
+			s.SetBreakpoints(tt.breakpoints)
+			s.Start(tt.stopOnEntry)
+
+			var stops []string
+			for ev := range s.Events() {
+				if ev.Reason == "exited" {
+					break
+				}
+				stops = append(stops, fmt.Sprintf("%s %d", ev.Reason, ev.Line))
+
+				action := "continue"
+				if len(stops) <= len(tt.actions) {
+					action = tt.actions[len(stops)-1]
+				}
+				switch action {
+				case "next":
+					s.StepOver()
+				case "in":
+					s.StepIn()
+				case "out":
+					s.StepOut()
+				default:
+					s.Continue()
+				}
+			}
+
+			if !reflect.DeepEqual(stops, tt.stops) {
+				t.Errorf("wrong stops.\nexpected: %q\ngot:      %q", tt.stops, stops)
+			}
+		})
+	}
+}
+
+func TestBreakpointVerification(t *testing.T) {
+	s := newSession(t, "testdata/square.nep")
+	bps := s.SetBreakpoints([]Breakpoint{
+		{Line: 2},
+		{Line: 4},
+		{Line: 7, Condition: "x >"},
+	})
+
+	expected := []struct {
+		verified bool
+		message  string
+	}{
+		{true, ""},
+		{false, "no statement starts on line 4"},
+		{false, "condition x >: no prefix parse function for EOF found"},
+	}
+	for i, e := range expected {
+		if bps[i].Verified != e.verified || bps[i].Message != e.message {
+			t.Errorf("breakpoint on line %d: expected %t %q, got %t %q",
+				bps[i].Line, e.verified, e.message, bps[i].Verified, bps[i].Message)
+		}
+	}
+}
+
+func TestInspection(t *testing.T) {
+	quiet(t)
+
+	s := newSession(t, "testdata/square.nep")
+	s.SetBreakpoints([]Breakpoint{{Line: 3}})
+	s.Start(false)
+
+	ev := <-s.Events()
+	if ev.Reason != "breakpoint" || ev.Line != 3 {
+		t.Fatalf("expected a stop at the breakpoint, got %s %d", ev.Reason, ev.Line)
+	}
+
+	frames, err := s.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls []string
+	for _, f := range frames {
+		calls = append(calls, fmt.Sprintf("%s:%d", f.Name, f.Line))
+	}
+	if expected := []string{"वर्ग:3", "<module>:6"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("wrong frames. expected %q, got %q", expected, calls)
+	}
+
+	evaluations := []struct {
+		frame    int
+		input    string
+		expected string
+	}{
+		{0, "y + n", "20"},
+		{1, "सूची", "[1, 2, 3]"},
+		{1, "y", "ERROR: identifier not found: y"},
+	}
+	for _, e := range evaluations {
+		value, err := s.Evaluate(e.frame, e.input)
+		if err != nil {
+			t.Errorf("%s: %s", e.input, err)
+			continue
+		}
+		if value.Inspect() != e.expected {
+			t.Errorf("%s in frame %d: expected %s, got %s", e.input, e.frame, e.expected, value.Inspect())
+		}
+	}
+
+	var scopes []string
+	for _, sc := range Scopes(frames[0].Env) {
+		var vars []string
+		for _, v := range Variables(sc.Env) {
+			if v.Name != "वर्ग" {
+				vars = append(vars, v.Name+"="+v.Value.Inspect())
+			}
+		}
+		scopes = append(scopes, fmt.Sprint(sc.Name, vars))
+	}
+	if expected := []string{"Locals[n=4 y=16]", "Globals[सूची=[1, 2, 3]]"}; !reflect.DeepEqual(scopes, expected) {
+		t.Errorf("wrong scopes. expected %q, got %q", expected, scopes)
+	}
+
+	s.Terminate()
+	ev = <-s.Events()
+	if ev.Reason != "exited" || !ev.Terminated {
+		t.Errorf("expected the program to be terminated, got %+v", ev)
+	}
+	if _, ok := <-s.Events(); ok {
+		t.Errorf("expected the events to end")
+	}
+}
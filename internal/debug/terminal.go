@@ -0,0 +1,236 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+const terminalHelp = `commands:
+	break N [if EXPR]   stop before line N, when EXPR is true
+	delete N            remove the breakpoint on line N
+	breakpoints         list the breakpoints
+	continue, c         run until the next breakpoint
+	next, n             run to the next line, stepping over calls
+	step, s             run to the next line, stepping into calls
+	out, o              run until the current call returns
+	where, bt           show the calls in progress
+	vars, v             show the variables in scope
+	print, p EXPR       show the value of EXPR
+	watch EXPR          show the value of EXPR at every stop
+	unwatch N           remove watch N
+	list, l             show the source around the current line
+	quit, q             end the program
+`
+
+// Terminal debugs the session's program with commands read from in, one a
+// line, writing what they show to out, as the REPL's :debug does. The
+// program stops before its first statement. Terminal returns what the
+// program evaluated to, or nil if it was ended with quit or in ran out.
+func Terminal(s *Session, in *bufio.Scanner, out io.Writer) object.Object {
+	t := &terminal{session: s, in: in, out: out, breakpoints: make(map[int]string)}
+	fmt.Fprintf(out, "debugging %s; type help for commands\n", s.File())
+
+	s.Start(true)
+	for ev := range s.Events() {
+		if ev.Reason == "exited" {
+			if ev.Terminated {
+				return nil
+			}
+			return ev.Result
+		}
+		t.stopped(ev)
+		t.commands()
+	}
+	return nil
+}
+
+type terminal struct {
+	session     *Session
+	in          *bufio.Scanner
+	out         io.Writer
+	line        int            // where the program is stopped
+	breakpoints map[int]string // conditions by line
+	watches     []string
+}
+
+func (t *terminal) stopped(ev Event) {
+	t.line = ev.Line
+	fmt.Fprintf(t.out, "stopped at line %d (%s): %s\n", ev.Line, ev.Reason, strings.TrimSpace(t.session.Source(ev.Line)))
+	for i, w := range t.watches {
+		fmt.Fprintf(t.out, "\t%d: %s = %s\n", i+1, w, t.evaluate(w))
+	}
+}
+
+// commands carries out commands until one resumes the program
+func (t *terminal) commands() {
+	for {
+		fmt.Fprintf(t.out, "(debug) ")
+		if !t.in.Scan() {
+			fmt.Fprintln(t.out)
+			t.session.Terminate()
+			return
+		}
+		command, arg, _ := strings.Cut(strings.TrimSpace(t.in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+
+		switch command {
+		case "":
+		case "continue", "c":
+			t.session.Continue()
+			return
+		case "next", "n":
+			t.session.StepOver()
+			return
+		case "step", "s":
+			t.session.StepIn()
+			return
+		case "out", "o":
+			t.session.StepOut()
+			return
+		case "quit", "q":
+			t.session.Terminate()
+			return
+		case "break", "b":
+			t.setBreakpoint(arg)
+		case "delete", "d":
+			line, err := strconv.Atoi(arg)
+			if _, ok := t.breakpoints[line]; err != nil || !ok {
+				fmt.Fprintf(t.out, "no breakpoint on line %s\n", arg)
+				break
+			}
+			delete(t.breakpoints, line)
+			t.updateBreakpoints()
+		case "breakpoints":
+			for _, line := range t.breakpointLines() {
+				fmt.Fprintf(t.out, "line %d", line)
+				if cond := t.breakpoints[line]; cond != "" {
+					fmt.Fprintf(t.out, " if %s", cond)
+				}
+				fmt.Fprintln(t.out)
+			}
+		case "where", "bt":
+			frames, _ := t.session.Frames()
+			for i, f := range frames {
+				fmt.Fprintf(t.out, "#%d %s at %s:%d\n", i, f.Name, f.File, f.Line)
+			}
+		case "vars", "v":
+			t.variables()
+		case "print", "p":
+			fmt.Fprintln(t.out, t.evaluate(arg))
+		case "watch", "w":
+			if arg == "" {
+				fmt.Fprintln(t.out, "watch what?")
+				break
+			}
+			t.watches = append(t.watches, arg)
+			fmt.Fprintf(t.out, "\t%d: %s = %s\n", len(t.watches), arg, t.evaluate(arg))
+		case "unwatch":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 || n > len(t.watches) {
+				fmt.Fprintf(t.out, "no watch %s\n", arg)
+				break
+			}
+			t.watches = append(t.watches[:n-1], t.watches[n:]...)
+		case "list", "l":
+			for n := t.line - 3; n <= t.line+3; n++ {
+				if n < 1 || n > t.session.Lines() {
+					continue
+				}
+				marker := " "
+				if n == t.line {
+					marker = ">"
+				}
+				fmt.Fprintf(t.out, "%s %4d  %s\n", marker, n, t.session.Source(n))
+			}
+		case "help", "h":
+			fmt.Fprint(t.out, terminalHelp)
+		default:
+			fmt.Fprintf(t.out, "unknown command %s; type help for commands\n", command)
+		}
+	}
+}
+
+// setBreakpoint sets a breakpoint as "N" or "N if EXPR" says
+func (t *terminal) setBreakpoint(arg string) {
+	lineText, cond, _ := strings.Cut(arg, " ")
+	line, err := strconv.Atoi(lineText)
+	if err != nil {
+		fmt.Fprintln(t.out, "usage: break N [if EXPR]")
+		return
+	}
+	cond = strings.TrimSpace(cond)
+	if cond != "" {
+		rest, ok := strings.CutPrefix(cond, "if ")
+		if !ok {
+			fmt.Fprintln(t.out, "usage: break N [if EXPR]")
+			return
+		}
+		cond = strings.TrimSpace(rest)
+	}
+
+	t.breakpoints[line] = cond
+	for _, bp := range t.updateBreakpoints() {
+		if bp.Line != line {
+			continue
+		}
+		if !bp.Verified {
+			fmt.Fprintf(t.out, "cannot stop on line %d: %s\n", line, bp.Message)
+			delete(t.breakpoints, line)
+			t.updateBreakpoints()
+			return
+		}
+		fmt.Fprintf(t.out, "breakpoint on line %d\n", line)
+	}
+}
+
+func (t *terminal) breakpointLines() []int {
+	var lines []int
+	for line := range t.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+func (t *terminal) updateBreakpoints() []Breakpoint {
+	var bps []Breakpoint
+	for _, line := range t.breakpointLines() {
+		bps = append(bps, Breakpoint{Line: line, Condition: t.breakpoints[line]})
+	}
+	return t.session.SetBreakpoints(bps)
+}
+
+func (t *terminal) variables() {
+	frames, err := t.session.Frames()
+	if err != nil {
+		return
+	}
+	for _, sc := range Scopes(frames[0].Env) {
+		vars := Variables(sc.Env)
+		if len(vars) == 0 {
+			continue
+		}
+		fmt.Fprintf(t.out, "%s:\n", sc.Name)
+		for _, v := range vars {
+			fmt.Fprintf(t.out, "\t%s = %s\n", v.Name, v.Value.Inspect())
+		}
+	}
+}
+
+// evaluate returns what source evaluates to in the innermost frame, shown
+func (t *terminal) evaluate(source string) string {
+	value, err := t.session.Evaluate(0, source)
+	if err != nil {
+		return err.Error()
+	}
+	if value == nil {
+		return object.NULL.Inspect()
+	}
+	return value.Inspect()
+}
@@ -0,0 +1,61 @@
+package debug
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestTerminal(t *testing.T) {
+	quiet(t)
+
+	commands := `break 2 if n > ५
+break 4
+watch x
+continue
+where
+vars
+print y
+next
+out
+unwatch 1
+quit
+`
+	expected := `debugging testdata/square.nep; type help for commands
+stopped at line 1 (entry): फन वर्ग(n) {
+(debug) breakpoint on line 2
+(debug) cannot stop on line 4: no statement starts on line 4
+(debug) 	1: x = ERROR: identifier not found: x
+(debug) stopped at line 2 (breakpoint): लेट y = n * n
+	1: x = 16
+(debug) #0 वर्ग at testdata/square.nep:2
+#1 <module> at testdata/square.nep:8
+(debug) Locals:
+	n = 16
+Globals:
+	वर्ग = फन वर्ग(n) {
+लेट y = (n * n);
+प्रतिफल y;
+
+}
+	सूची = [1, 2, 3]
+	x = 16
+(debug) ERROR: identifier not found: y
+(debug) stopped at line 3 (step): प्रतिफल y
+	1: x = 16
+(debug) stopped at line 9 (step): प्रिन्टल(z)
+	1: x = 16
+(debug) (debug) `
+
+	s := newSession(t, "testdata/square.nep")
+	var out strings.Builder
+	result := Terminal(s, bufio.NewScanner(strings.NewReader(commands)), &out)
+
+	got := strings.ReplaceAll(out.String(), s.File(), "testdata/square.nep")
+	if got != expected {
+		t.Errorf("wrong transcript.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+	if result != nil {
+		t.Errorf("expected no result after quit, got %s", result.Inspect())
+	}
+}
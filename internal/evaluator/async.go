@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/async"
+	"github.com/SunilNeupane77/nepali/internal/eventloop"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// SetEventLoop replaces the event loop that drives async functions. Tests
+// pass a loop built on eventloop.NewVirtualClock so sleeps cost no real
+// time.
+func SetEventLoop(l *eventloop.Loop) {
+	async.SetLoop(l)
+}
+
+// startAsync schedules fn's body on the event loop, to run in env, and
+// returns a promise for its result
+func startAsync(fn *object.Function, env *object.Environment) *object.Promise {
+	// frames are the calls the coroutine has in progress while it is
+	// suspended, kept for CallStack
+	var frames []*Frame
+	var base int
+
+	// calls is the coroutine's depth while it runs, and outer that of the
+	// code it was resumed from
+	var calls, outer int
+
+	return async.Start(func() object.Object {
+		return runFunction(fn, env)
+	}, func(resumed bool) {
+		if resumed {
+			outer, depth = depth, calls
+			base = len(callStack)
+			callStack = append(callStack, frames...)
+			return
+		}
+		calls, depth = depth, outer
+		if len(callStack) >= base {
+			frames = append([]*Frame(nil), callStack[base:]...)
+			callStack = callStack[:base]
+		}
+	})
+}
+
+func evalAwaitExpression(node *ast.AwaitExpression, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	promise, ok := val.(*object.Promise)
+	if !ok {
+		// Awaiting a plain value just yields it
+		return val
+	}
+
+	return async.Await(promise)
+}
@@ -0,0 +1,130 @@
+package evaluator
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/SunilNeupane77/nepali/internal/async"
+	"github.com/SunilNeupane77/nepali/internal/eventloop"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func testEval(t *testing.T, input string) object.Object {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return Eval(program, object.NewEnvironment())
+}
+
+func useVirtualClock(t *testing.T) {
+	t.Helper()
+
+	prev := async.Loop()
+	SetEventLoop(eventloop.New(eventloop.NewVirtualClock()))
+	t.Cleanup(func() { SetEventLoop(prev) })
+}
+
+func TestAwaitAsyncFunction(t *testing.T) {
+	useVirtualClock(t)
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"लेट f = एसिन्क फन(x) { x * २ }; पर्ख f(२१)", 42},
+		{"लेट f = एसिन्क फन() { पर्ख सुत्नुहोस्(५००); प्रतिफल ७ }; पर्ख f()", 7},
+		{"लेट f = एसिन्क फन(x) { x + १ }; लेट g = एसिन्क फन(x) { पर्ख f(x) * २ }; पर्ख g(४)", 10},
+		{"पर्ख ५", 5},
+		{"चलाउनुहोस्(एसिन्क फन() { ९ }())", 9},
+	}
+
+	for i, tt := range tests {
+		result, ok := testEval(t, tt.input).(*object.Integer)
+		if !ok {
+			t.Fatalf("tests[%d] - result is not INTEGER", i)
+		}
+		if result.Value != tt.expected {
+			t.Errorf("tests[%d] - wrong value. expected=%d, got=%d", i, tt.expected, result.Value)
+		}
+	}
+}
+
+func TestSleepUsesVirtualTime(t *testing.T) {
+	useVirtualClock(t)
+
+	input := `
+लेट काम = एसिन्क फन(ms) { पर्ख सुत्नुहोस्(ms); प्रतिफल ms };
+लेट नतिजा = पर्ख सबै([काम(३६०००००), काम(६००००), काम(१)]);
+[नतिजा, अहिले()]
+`
+	start := time.Now()
+	result := testEval(t, input)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("virtual sleep took %s of real time", elapsed)
+	}
+
+	expected := "[[3600000, 60000, 1], 3600000]"
+	if result.Inspect() != expected {
+		t.Errorf("wrong result. expected=%s, got=%s", expected, result.Inspect())
+	}
+}
+
+func TestConcurrentSleepsOverlap(t *testing.T) {
+	useVirtualClock(t)
+
+	input := `
+लेट काम = एसिन्क फन(ms) { पर्ख सुत्नुहोस्(ms); अहिले() };
+[पर्ख सबै([काम(३०), काम(१०), काम(२०)]), अहिले()]
+`
+	expected := "[[30, 10, 20], 30]"
+	if result := testEval(t, input); result.Inspect() != expected {
+		t.Errorf("wrong result. expected=%s, got=%s", expected, result.Inspect())
+	}
+}
+
+func TestAwaitRejectedPromise(t *testing.T) {
+	useVirtualClock(t)
+
+	input := "लेट f = एसिन्क फन() { पर्ख सुत्नुहोस्(१); -मिथ्या }; पर्ख f()"
+	err, ok := testEval(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("expected ERROR from rejected promise")
+	}
+	if err.Message != "unknown operator: -BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+}
+
+func TestUnsettledPromiseStopsCoroutines(t *testing.T) {
+	useVirtualClock(t)
+
+	before := runtime.NumGoroutine()
+
+	// f awaits its own promise, which can never settle
+	input := "लेट f = एसिन्क फन() { पर्ख p }; लेट p = f(); लेट g = एसिन्क फन() { पर्ख p }; g(); पर्ख p"
+	err, ok := testEval(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("expected ERROR from a promise that never settles")
+	}
+	if err.Message != "promise can never settle: no pending tasks or timers" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+
+	// The coroutines' goroutines have ended
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("coroutines leaked: %d goroutines before, %d after", before, after)
+	}
+}
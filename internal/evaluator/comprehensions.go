@@ -0,0 +1,212 @@
+package evaluator
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/grapheme"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+func evalSetLiteral(node *ast.SetLiteral, env *object.Environment) object.Object {
+	set := object.NewSet()
+
+	for _, el := range node.Elements {
+		val := Eval(el, env)
+		if isError(val) {
+			return val
+		}
+		if err := addToSet(set, val); err != nil {
+			return err
+		}
+	}
+
+	return set
+}
+
+func evalListComprehension(node *ast.ListComprehension, env *object.Environment) object.Object {
+	elements := []object.Object{}
+
+	err := evalComprehension(node.Clauses, node.Locals, env, func(env *object.Environment) object.Object {
+		val := Eval(node.Element, env)
+		if isError(val) {
+			return val
+		}
+		elements = append(elements, orNull(val))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+func evalSetComprehension(node *ast.SetComprehension, env *object.Environment) object.Object {
+	set := object.NewSet()
+
+	err := evalComprehension(node.Clauses, node.Locals, env, func(env *object.Environment) object.Object {
+		val := Eval(node.Element, env)
+		if isError(val) {
+			return val
+		}
+		if err := addToSet(set, val); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return set
+}
+
+func evalHashComprehension(node *ast.HashComprehension, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	err := evalComprehension(node.Clauses, node.Locals, env, func(env *object.Environment) object.Object {
+		key := Eval(node.Key, env)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey, orNull(value))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return hash
+}
+
+// evalComprehension runs emit once for every combination of values the
+// clauses bind that passes their filters. The loop variables live in an
+// environment of their own, with the given slots, so they do not leak into
+// env. It returns the first error, from the clauses or from emit.
+func evalComprehension(clauses []*ast.ComprehensionClause, locals []string, env *object.Environment, emit func(*object.Environment) object.Object) object.Object {
+	return evalClauses(clauses, object.NewScope(env, locals), emit)
+}
+
+func evalClauses(clauses []*ast.ComprehensionClause, env *object.Environment, emit func(*object.Environment) object.Object) object.Object {
+	if len(clauses) == 0 {
+		return emit(env)
+	}
+	clause := clauses[0]
+
+	iterable := Eval(clause.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	items, err := iterationValues(iterable, len(clause.Names))
+	if err != nil {
+		return err
+	}
+
+items:
+	for _, values := range items {
+		for i, name := range clause.Names {
+			env.Assign(name.Slot, values[i])
+			bound(name.Value, values[i], env)
+		}
+
+		for _, filter := range clause.Filters {
+			cond := Eval(filter, env)
+			if isError(cond) {
+				return cond
+			}
+			if !isTruthy(cond) {
+				continue items
+			}
+		}
+
+		if result := evalClauses(clauses[1:], env, emit); isError(result) {
+			return result
+		}
+	}
+
+	return nil
+}
+
+// iterationValues lists what a loop over obj binds on each pass. With one
+// name that is each element of an array or set, each character of a string,
+// each key of a hash or each line left in a file; with two, an index and
+// element, or a key and value.
+func iterationValues(obj object.Object, names int) ([][]object.Object, *object.Error) {
+	var items [][]object.Object
+	indexed := func(elements []object.Object) {
+		for i, el := range elements {
+			items = append(items, []object.Object{&object.Integer{Value: int64(i)}, el})
+		}
+	}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		indexed(obj.Elements)
+	case *object.String:
+		chars := []object.Object{}
+		for _, c := range grapheme.Split(obj.Value) {
+			chars = append(chars, &object.String{Value: c})
+		}
+		indexed(chars)
+	case *object.Set:
+		if names == 2 {
+			return nil, newError("cannot bind 2 names when iterating over SET")
+		}
+		for _, el := range obj.Elements() {
+			items = append(items, []object.Object{el})
+		}
+		return items, nil
+	case *object.Hash:
+		for _, pair := range obj.Pairs() {
+			items = append(items, []object.Object{pair.Key, pair.Value})
+		}
+		if names == 1 {
+			for i := range items {
+				items[i] = items[i][:1]
+			}
+		}
+		return items, nil
+	case *object.File:
+		lines, err := builtins.Lines(obj)
+		if err != nil {
+			return nil, err
+		}
+		indexed(lines)
+	default:
+		return nil, newError("cannot iterate over %s", obj.Type())
+	}
+
+	if names == 1 {
+		for i := range items {
+			items[i] = items[i][1:]
+		}
+	}
+	return items, nil
+}
+
+func addToSet(set *object.Set, val object.Object) *object.Error {
+	key, ok := val.(object.Hashable)
+	if !ok {
+		return newError("unusable as set element: %s", val.Type())
+	}
+	set.Add(key)
+	return nil
+}
+
+func orNull(obj object.Object) object.Object {
+	if obj == nil {
+		return object.NULL
+	}
+	return obj
+}
@@ -0,0 +1,75 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComprehensions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[x * x लागि x मा [१, २, ३]]", "[1, 4, 9]"},
+		{"[x लागि x मा [१, २, ३, ४] यदि x > २]", "[3, 4]"},
+		{"[x लागि x मा [१, २, ३, ४, ५, ६] यदि x > १ यदि x < ५]", "[2, 3, 4]"},
+		{"[[x, y] लागि x मा [१, २] लागि y मा [x, ३]]", "[[1, 1], [1, 3], [2, 2], [2, 3]]"},
+		{"[x + y लागि x मा [१, २] यदि x > १ लागि y मा [१०, २०]]", "[12, 22]"},
+		{"[c लागि c मा \"नेपाल\"]", "[ने, पा, ल]"},
+		{"[i लागि i, c मा \"कख\"]", "[0, 1]"},
+		{`[k लागि k मा {"a": १, "b": २}]`, "[a, b]"},
+		{`[v लागि k, v मा {"a": १, "b": २}]`, "[1, 2]"},
+		{"[x लागि x मा []]", "[]"},
+		{"{x: x * x लागि x मा [१, २, ३]}", "{1: 1, 2: 4, 3: 9}"},
+		{`{v: k लागि k, v मा {"a": "x", "b": "y"} यदि k != "a"}`, "{y: b}"},
+		{"{x - x / ३ * ३ लागि x मा [१, २, ३, ४, ५, ६]}", "सेट{1, 2, 0}"},
+		{"{१, २, १, ३}", "सेट{1, 2, 3}"},
+		{"{}", "{}"},
+		{`{"a": १, "b": २,}`, "{a: 1, b: 2}"},
+		{"लेन({१, २, २})", "2"},
+		{"{१, २} == {२, १}", "सत्य"},
+		{"[x लागि x मा {३, १}]", "[3, 1]"},
+		{"लेट x = ५; लेट y = [x लागि x मा [१, २]]; x", "5"},
+		{"[y लागि x मा [१]]", "ERROR: identifier not found: y"},
+		{"[x लागि x मा ५]", "ERROR: cannot iterate over INTEGER"},
+		{"{[x] लागि x मा [१]}", "ERROR: unusable as set element: ARRAY"},
+		{"{[x]: x लागि x मा [१]}", "ERROR: unusable as hash key: ARRAY"},
+		{"[x लागि x मा [१, २] यदि x + सत्य]", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestComprehensionVariablesDoNotLeak(t *testing.T) {
+	result := testEval(t, "लेट a = [x लागि x मा [१, २]]; x")
+	if result.Inspect() != "ERROR: identifier not found: x" {
+		t.Errorf("loop variable leaked. got=%s", result.Inspect())
+	}
+}
+
+func TestComprehensionOverFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "सूची.txt")
+	if err := os.WriteFile(path, []byte("क\nख\r\nग"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[l लागि l मा खोल्नुहोस्("` + path + `")]`, "[क, ख, ग]"},
+		{`लेट f = खोल्नुहोस्("` + path + `"); f.लाइन_पढ(); [[i, l] लागि i, l मा f]`, "[[0, ख], [1, ग]]"},
+		{`[l लागि l मा खोल्नुहोस्("` + path + `", "a")]`, "ERROR: फाइल पढ्नका लागि खोलिएको छैन: " + path},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] wrong. expected=%q, got=%q", i, tt.expected, result.Inspect())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func TestConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"स्थिर पाई = ३; पाई * २", "6"},
+		{"स्थिर x = १; फन f() { लेट x = २; x }; [f(), x]", "[2, 1]"},
+		{"स्थिर x = १; [x * २ लागि x मा [५]]", "[10]"},
+		{"स्थिर x = १; मिलान २ { अवस्था x { x } }", "2"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestConstantReassignmentIsRejectedWhenParsing(t *testing.T) {
+	tests := []struct {
+		input string
+		name  string
+	}{
+		{"स्थिर x = १; लेट x = २;", "x"},
+		{"स्थिर x = १; स्थिर x = २;", "x"},
+		{"स्थिर f = १; फन f() { }", "f"},
+		{"फन g() { स्थिर x = १; संख्या x = २; }", "x"},
+		{"स्थिर x = १; निर्यात लेट x = २;", "x"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != "cannot reassign constant "+tt.name {
+			t.Errorf("%s: wrong parser errors. got=%v", tt.input, errors)
+		}
+	}
+}
+
+func TestConstantReassignmentIsRejectedAtRuntime(t *testing.T) {
+	// each line is parsed on its own, as in the REPL
+	env := object.NewEnvironment()
+	lines := []string{"स्थिर x = १;", "लेट x = २;"}
+
+	var result object.Object
+	for _, line := range lines {
+		p := parser.New(lexer.New(line))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+		result = Eval(program, env)
+	}
+
+	if result == nil || result.Inspect() != "ERROR: cannot reassign constant x" {
+		t.Fatalf("expected reassignment error, got=%v", result)
+	}
+	if x, _ := env.Get("x"); x.Inspect() != "1" {
+		t.Errorf("constant changed. got=%s", x.Inspect())
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"लेट a = [१]; a.थप(२, ३); a", "[1, 2, 3]"},
+		{`लेट h = {"क": १}; h.राख("ख", २); h.हटाउ("क"); h`, "{ख: 2}"},
+		{`लेट h = {"क": १}; h.हटाउ("ग")`, "असत्य"},
+		{"लेट s = {१}; s.थप(२, १); s.हटाउ(१); s", "सेट{2}"},
+		{"लेट a = जमाउनुहोस्([१]); a.थप(२)", "ERROR: cannot modify frozen ARRAY"},
+		{`लेट h = जमाउनुहोस्({"क": १}); h.राख("ख", २)`, "ERROR: cannot modify frozen HASH"},
+		{`लेट h = जमाउनुहोस्({"क": १}); h.हटाउ("क")`, "ERROR: cannot modify frozen HASH"},
+		{"लेट s = जमाउनुहोस्({१}); s.थप(२)", "ERROR: cannot modify frozen SET"},
+		// freezing is deep
+		{`लेट c = जमाउनुहोस्({"सूची": [१], "भित्र": {"क": {१}}}); c["सूची"].थप(२)`, "ERROR: cannot modify frozen ARRAY"},
+		{`लेट c = जमाउनुहोस्({"भित्र": {"क": {१}}}); c["भित्र"]["क"].थप(२)`, "ERROR: cannot modify frozen SET"},
+		{"लेट a = [१]; लेट b = जमाउनुहोस्([a]); a.थप(२)", "ERROR: cannot modify frozen ARRAY"},
+		{"[जमेको(जमाउनुहोस्([])), जमेको([]), जमेको(५)]", "[सत्य, असत्य, असत्य]"},
+		// copies made from a frozen value are not frozen
+		{"लेट a = जमाउनुहोस्([३, १]); लेट b = a[:]; b.थप(२); [a, b]", "[[3, 1], [3, 1, 2]]"},
+		{"लेट a = जमाउनुहोस्([३, १]); लेट b = क्रमबद्ध(a); b.थप(२); b", "[1, 3, 2]"},
+		{"जमाउनुहोस्([१]) == [१]", "सत्य"},
+		{`लेट h = {"थप": १}; h.थप`, "1"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestFreezeHandlesCycles(t *testing.T) {
+	input := "लेट a = []; a.थप(a); जमाउनुहोस्(a); a.थप(१)"
+
+	if result := testEval(t, input); result.Inspect() != "ERROR: cannot modify frozen ARRAY" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
@@ -0,0 +1,78 @@
+package evaluator
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Names of the hooks a context manager provides, as hash entries or as
+// builtin methods
+const (
+	enterHook = "प्रवेश"
+	exitHook  = "निकास"
+)
+
+// evalWithStatement runs node's body inside a context manager. प्रवेश() is
+// called first and its result bound to the statement's name; once it has
+// succeeded निकास(error) is called however the block ends, with निल or the
+// error it raised, as Error.Value gives it. A truthy result from निकास,
+// other than निल, suppresses that error.
+func evalWithStatement(node *ast.WithStatement, env *object.Environment) object.Object {
+	ctx := Eval(node.Context, env)
+	if isError(ctx) {
+		return ctx
+	}
+
+	enter, exit, err := contextHooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	entered := applyFunction(enter, nil, nil)
+	if isError(entered) {
+		return entered
+	}
+
+	var result object.Object
+	if node.Name != nil {
+		result = bind(env, node.Name, entered, false)
+	}
+	if result == nil {
+		result = Eval(node.Body, env)
+	}
+
+	inFlight := object.Object(object.NULL)
+	if err, ok := result.(*object.Error); ok {
+		inFlight = err.Value()
+	}
+
+	suppress := applyFunction(exit, []object.Object{inFlight}, nil)
+	if isError(suppress) {
+		return suppress
+	}
+	if isError(result) && suppress != nil && suppress != object.NULL && isTruthy(suppress) {
+		return nil
+	}
+
+	return result
+}
+
+// contextHooks finds the enter and exit hooks of a context manager
+func contextHooks(ctx object.Object) (object.Object, object.Object, *object.Error) {
+	hooks := [2]object.Object{}
+
+	for i, name := range []string{enterHook, exitHook} {
+		if hash, ok := ctx.(*object.Hash); ok {
+			hooks[i], _ = hash.Get(&object.String{Value: name})
+		} else {
+			hooks[i], _ = builtins.LookupMethod(ctx, name)
+		}
+
+		if hooks[i] == nil {
+			return nil, nil, newError("%s is not a context manager: missing %s", typeOf(ctx), name)
+		}
+	}
+
+	return hooks[0], hooks[1], nil
+}
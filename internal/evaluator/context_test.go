@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func TestWithStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`सँग {"प्रवेश": फन() { ५ }, "निकास": फन(e) { मिथ्या }} जस्तो x { x + १ }`, "6"},
+		{`लेट m = {"प्रवेश": फन() { १ }, "निकास": फन(e) { e }};
+		  सँग m { "भित्र" }`, "भित्र"},
+		// the exit hook receives निल, or the in-flight error
+		{`सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { १ + e }} { "भित्र" }`, "ERROR: type mismatch: INTEGER + NULL"},
+		{`सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { e.सन्देश == "type mismatch: INTEGER + BOOLEAN" }} { १ + सत्य }; "पछि"`, "पछि"},
+		{`सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { मिथ्या }} { १ + सत्य }; "पछि"`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { प्रिन्टल() }} { १ + सत्य }`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		// returning from inside the block still runs the exit hook
+		{`फन f() {
+			सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { १ + e }} { प्रतिफल "फिर्ता" }
+			"पुगिएन"
+		  }
+		  f()`, "ERROR: type mismatch: INTEGER + NULL"},
+		{`फन f() {
+			सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { मिथ्या }} { प्रतिफल "फिर्ता" }
+			"पुगिएन"
+		  }
+		  f()`, "फिर्ता"},
+		{`सँग {"प्रवेश": फन() { १ + सत्य }, "निकास": फन(e) { सत्य }} { "भित्र" }`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { १ + सत्य }} { "भित्र" }`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`सँग {"प्रवेश": फन() { १ }} { "भित्र" }`, "ERROR: HASH is not a context manager: missing निकास"},
+		{`सँग ५ { "भित्र" }`, "ERROR: INTEGER is not a context manager: missing प्रवेश"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] wrong. expected=%q, got=%q", i, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestWithStatementExitSeesError(t *testing.T) {
+	input := `लेट देखेको = []
+	फन भित्र() { १ + सत्य }; फन बाहिर() { भित्र() }
+	सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { देखेको.थप(e); सत्य }} { बाहिर() }
+	देखेको`
+	expected := "[{सन्देश: type mismatch: INTEGER + BOOLEAN, ट्रेस: [भित्र, बाहिर]}]"
+	if result := testEval(t, input); result.Inspect() != expected {
+		t.Errorf("wrong error given to निकास. expected=%q, got=%q", expected, result.Inspect())
+	}
+}
+
+func TestWithStatementExitsWhenBindingFails(t *testing.T) {
+	// A constant declared by an earlier program, as in the REPL, is only
+	// found to clash as the block binds its name
+	env := object.NewEnvironment()
+	eval := func(input string) object.Object {
+		p := parser.New(lexer.New(input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+		return Eval(program, env)
+	}
+	eval("स्थिर x = १; लेट लग = []")
+
+	input := `सँग {"प्रवेश": फन() { २ }, "निकास": फन(e) { लग.थप(e.सन्देश) }} जस्तो x { लग.थप("भित्र") }`
+	if result := eval(input); result.Inspect() != "ERROR: cannot reassign constant x" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+	if log := eval("लग"); log.Inspect() != "[cannot reassign constant x]" {
+		t.Errorf("निकास not called with the binding's error. got=%s", log.Inspect())
+	}
+}
+
+func TestWithStatementClosesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "नोट.txt")
+	input := `सँग खोल्नुहोस्("` + path + `", "w") जस्तो f { f.लेख("नमस्ते") }; f.लेख("फेरि")`
+
+	result := testEval(t, input)
+	if result.Inspect() != "ERROR: बन्द भइसकेको फाइल प्रयोग गर्न मिल्दैन: "+path {
+		t.Errorf("file was not closed after the block. got=%s", result.Inspect())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "नमस्ते" {
+		t.Errorf("wrong file contents. got=%q, err=%v", data, err)
+	}
+}
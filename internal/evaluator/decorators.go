@@ -0,0 +1,56 @@
+package evaluator
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// applyDecorators wraps val in decorators, listed outermost first, so that
+// @a @b फन f() {} binds f to a(b(f)). A function a decorator returns in place
+// of another takes over that function's name and docstring.
+func applyDecorators(decorators []object.Object, val object.Object) object.Object {
+	for i := len(decorators) - 1; i >= 0; i-- {
+		wrapped := applyFunction(decorators[i], []object.Object{val}, nil)
+		if isError(wrapped) {
+			return wrapped
+		}
+		val = preserveIdentity(wrapped, val)
+	}
+
+	return val
+}
+
+// preserveIdentity returns wrapper carrying the name and docstring of the
+// function it wraps. The wrapper is copied, since the decorator may hand out
+// the same function more than once.
+func preserveIdentity(wrapper, original object.Object) object.Object {
+	w, ok := wrapper.(*object.Function)
+	if !ok || w == original {
+		return orNull(wrapper)
+	}
+	orig, ok := original.(*object.Function)
+	if !ok {
+		return w
+	}
+
+	copied := *w
+	copied.Name = orig.Name
+	copied.Doc = orig.Doc
+	return &copied
+}
+
+// docString returns the string literal that opens a function body, if any
+func docString(body *ast.BlockStatement) string {
+	if body == nil || len(body.Statements) == 0 {
+		return ""
+	}
+
+	stmt, ok := body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return ""
+	}
+	if lit, ok := stmt.Expression.(*ast.StringLiteral); ok {
+		return lit.Value
+	}
+	return ""
+}
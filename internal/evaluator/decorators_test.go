@@ -0,0 +1,82 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+func TestDecorators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`फन दोब्बर(f) { फन(x) { f(x) * २ } }
+		  @दोब्बर
+		  फन थप(x) { x + १ }
+		  थप(३)`, "8"},
+		// stacked decorators apply nearest first
+		{`फन थप(f) { फन(x) { f(x) + १ } }
+		  फन दोब्बर(f) { फन(x) { f(x) * २ } }
+		  @थप
+		  @दोब्बर
+		  फन पहिचान(x) { x }
+		  पहिचान(५)`, "11"},
+		{`फन गुणा(n) { फन(f) { फन(x) { f(x) * n } } }
+		  @गुणा(३)
+		  लेट वर्ग = फन(x) { x * x };
+		  वर्ग(२)`, "12"},
+		// forwarding every argument through a wrapper
+		{`फन लग(f) { फन(*args, **kwargs) { f(*args, **kwargs) } }
+		  @लग
+		  फन जोड(a, b = १०) { a + b }
+		  [जोड(१), जोड(१, b = २)]`, "[11, 3]"},
+		{`फन पहिचान(f) { f }
+		  @पहिचान
+		  फन नमस्ते() { "नमस्ते" }
+		  नमस्ते()`, "नमस्ते"},
+		{`फन लग(f) { फन(*args) { f(*args) } }
+		  @लग
+		  फन जोड(a, b) { "दुई संख्या जोड्छ"; a + b }
+		  [जोड.नाम, जोड.विवरण]`, "[जोड, दुई संख्या जोड्छ]"},
+		{`फन लग(f) { फन(*args) { f(*args) } }
+		  @लग
+		  फन जोड(a) { a }
+		  जोड`, "फन जोड(*args) {\nf(*args)\n}"},
+		{"@५ फन f() { १ }", "ERROR: not a function: INTEGER"},
+		{"@अज्ञात फन f() { १ }", "ERROR: identifier not found: अज्ञात"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] wrong. expected=%q, got=%q", i, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestDecoratedFunctionIsCopied(t *testing.T) {
+	input := `लेट साझा = फन(x) { x };
+	फन फर्काउ(f) { साझा }
+	@फर्काउ
+	फन क() { १ }
+	[क.नाम, साझा.नाम]`
+
+	if result := testEval(t, input); result.Inspect() != "[क, साझा]" {
+		t.Errorf("decorator's function was renamed in place. got=%s", result.Inspect())
+	}
+}
+
+func TestErrorTraceNamesFunctions(t *testing.T) {
+	input := `फन भित्री() { १ + सत्य }
+	फन बाहिरी() { भित्री() }
+	बाहिरी()`
+
+	result := testEval(t, input)
+	err, ok := result.(*object.Error)
+	if !ok {
+		t.Fatalf("expected error. got=%s", result.Inspect())
+	}
+	if len(err.Trace) != 2 || err.Trace[0] != "भित्री" || err.Trace[1] != "बाहिरी" {
+		t.Errorf("wrong trace. got=%v", err.Trace)
+	}
+}
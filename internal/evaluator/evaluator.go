@@ -3,20 +3,41 @@ package evaluator
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/async"
 	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/grapheme"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
 	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/resolver"
 )
 
-// Eval evaluates an AST node
+func init() {
+	builtins.CallFunction = func(fn object.Object, args ...object.Object) object.Object {
+		return applyFunction(fn, args, nil)
+	}
+}
+
+// Eval evaluates an AST node. A program is taken to be the one being run,
+// not a module it imports, and so the async work it starts but never
+// awaits runs to completion after it.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if program, ok := node.(*ast.Program); ok {
+		return finish(evalProgram(program, env))
+	}
+	if instrument != nil {
+		return evalInstrumented(node, env)
+	}
+	return eval(node, env)
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
 	case *ast.LetStatement:
-		evalLetStatement(node, env)
+		return evalLetStatement(node, env)
 	case *ast.ReturnStatement:
 		return evalReturnStatement(node, env)
 	case *ast.ExpressionStatement:
@@ -36,11 +57,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.FunctionLiteral:
-		params := evalFunctionParameters(node.Parameters)
+		params, err := evalFunctionParameters(node.Parameters, env)
+		if err != nil {
+			return err
+		}
 		return &object.Function{
+			Name:       node.Name,
+			Doc:        docString(node.Body),
 			Parameters: params,
-			Body:      node.Body,
-			Env:       env,
+			Body:       node.Body,
+			Locals:     node.Locals,
+			Env:        env,
+			IsAsync:    node.IsAsync,
 		}
 	case *ast.CallExpression:
 		return evalCallExpression(node, env)
@@ -50,8 +78,30 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalArrayLiteral(node, env)
 	case *ast.IndexExpression:
 		return evalIndexExpression(node, env)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
+	case *ast.SetLiteral:
+		return evalSetLiteral(node, env)
+	case *ast.ListComprehension:
+		return evalListComprehension(node, env)
+	case *ast.SetComprehension:
+		return evalSetComprehension(node, env)
+	case *ast.HashComprehension:
+		return evalHashComprehension(node, env)
+	case *ast.AwaitExpression:
+		return evalAwaitExpression(node, env)
+	case *ast.WithStatement:
+		return evalWithStatement(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return evalExportStatement(node, env)
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
 	}
 
 	return nil
@@ -60,7 +110,23 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
+	if program == checked.program && env == checked.env {
+		checked.program, checked.env = nil, nil
+	} else {
+		resolver.Resolve(program, env, isBuiltin)
+	}
+
+	if pushFrame(nil, env) {
+		defer popFrame()
+	}
+
 	for _, statement := range program.Statements {
+		if instrument != nil {
+			if err := atStatement(statement, env); err != nil {
+				return err
+			}
+		}
+
 		result = Eval(statement, env)
 
 		switch result := result.(type) {
@@ -74,13 +140,53 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
+// finish lets the async work the program being run started but never
+// awaited run to completion, unless result, the program's, is an error.
+// Modules do not, as the program importing them may still be awaiting.
+func finish(result object.Object) object.Object {
+	if !isError(result) {
+		async.Finish()
+	}
+	return result
+}
+
 func evalLetStatement(let *ast.LetStatement, env *object.Environment) object.Object {
+	decorators := make([]object.Object, len(let.Decorators))
+	for i, d := range let.Decorators {
+		decorators[i] = Eval(d, env)
+		if isError(decorators[i]) {
+			return decorators[i]
+		}
+	}
+
 	val := Eval(let.Value, env)
 	if isError(val) {
 		return val
 	}
 
-	env.Set(let.Name.Value, val)
+	if len(decorators) > 0 {
+		val = applyDecorators(decorators, val)
+		if isError(val) {
+			return val
+		}
+	}
+
+	return bind(env, let.Name, val, let.Token.Type == lexer.CONST)
+}
+
+// bind binds the resolved name in env, refusing to rebind a constant of
+// that scope
+func bind(env *object.Environment, name *ast.Identifier, val object.Object, constant bool) object.Object {
+	if env.IsConstSlot(name.Slot) {
+		return newError("cannot reassign constant %s", name.Value)
+	}
+
+	if constant {
+		env.AssignConst(name.Slot, orNull(val))
+	} else {
+		env.Assign(name.Slot, orNull(val))
+	}
+	bound(name.Value, orNull(val), env)
 	return nil
 }
 
@@ -94,10 +200,18 @@ func evalReturnStatement(ret *ast.ReturnStatement, env *object.Environment) obje
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if val, ok := env.Get(node.Value); ok {
+	if val := env.Lookup(node.Depth, node.Slot); val != nil {
 		return val
 	}
 
+	// The slot is unbound, as when its लेट has not run yet, so the name
+	// may still be bound further out
+	if outer := env.Ancestor(node.Depth).Outer(); outer != nil {
+		if val, ok := outer.Get(node.Value); ok {
+			return val
+		}
+	}
+
 	if builtin, ok := builtins.Lookup(node.Value); ok {
 		return builtin
 	}
@@ -105,6 +219,37 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError("identifier not found: " + node.Value)
 }
 
+// isBuiltin reports whether name is a builtin function
+func isBuiltin(name string) bool {
+	_, ok := builtins.Lookup(name)
+	return ok
+}
+
+// BuiltinNames returns the names of all builtin functions, sorted
+func BuiltinNames() []string {
+	return builtins.Names()
+}
+
+// BuiltinDoc returns the documentation of the builtin function called name
+func BuiltinDoc(name string) (string, bool) {
+	return builtins.Doc(name)
+}
+
+// checked is the program Check last resolved and the environment it was
+// resolved for, which evaluating it there need not resolve again
+var checked struct {
+	program *ast.Program
+	env     *object.Environment
+}
+
+// Check resolves program for the top-level environment env and returns
+// the resolver's warnings: names used before they are defined, and names
+// not defined at all
+func Check(program *ast.Program, env *object.Environment) []string {
+	checked.program, checked.env = program, env
+	return resolver.Resolve(program, env, isBuiltin)
+}
+
 func evalPrefixExpression(node *ast.PrefixExpression, env *object.Environment) object.Object {
 	right := Eval(node.Right, env)
 	if isError(right) {
@@ -136,26 +281,44 @@ func evalInfixExpression(node *ast.InfixExpression, env *object.Environment) obj
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(node, left, right)
 	case node.Operator == "==":
-		return nativeBoolToBooleanObject(left == right)
-	case node.Operator == "!":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
+	case node.Operator == "!=":
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s", left.Type(), node.Operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(node, left, right)
-	case left.Type() == object.ARRAY_OBJ && node.Operator == "==":
-		return nativeBoolToBooleanObject(left == right)
-	case left.Type() == object.ARRAY_OBJ && node.Operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
-	case left.Type() == object.HASH_OBJ && node.Operator == "==":
-		return nativeBoolToBooleanObject(left == right)
-	case left.Type() == object.HASH_OBJ && node.Operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+	case left.Type() == object.ARRAY_OBJ && isOrderingOperator(node.Operator):
+		return evalOrderingExpression(node.Operator, object.Compare(left, right))
 	default:
 		return newError("unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 }
 
+func isOrderingOperator(operator string) bool {
+	switch operator {
+	case "<", ">", "<=", ">=":
+		return true
+	default:
+		return false
+	}
+}
+
+// evalOrderingExpression applies an ordering operator to the result of
+// object.Compare
+func evalOrderingExpression(operator string, cmp int) object.Object {
+	switch operator {
+	case "<":
+		return nativeBoolToBooleanObject(cmp < 0)
+	case ">":
+		return nativeBoolToBooleanObject(cmp > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(cmp <= 0)
+	default:
+		return nativeBoolToBooleanObject(cmp >= 0)
+	}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -175,13 +338,19 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Object
 
 	for _, statement := range block.Statements {
+		if instrument != nil {
+			if err := atStatement(statement, env); err != nil {
+				return err
+			}
+		}
+
 		result = Eval(statement, env)
 
+		// A return leaves every enclosing block; the function or program
+		// unwraps it
 		if result != nil {
-			switch result := result.(type) {
-			case *object.ReturnValue:
-				return result.Value
-			case *object.Error:
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
 				return result
 			}
 		}
@@ -190,28 +359,23 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
-func evalFunctionParameters(params []*ast.Identifier) []*object.Parameter {
-	result := make([]*object.Parameter, len(params))
-
-	for i, param := range params {
-		result[i] = &object.Parameter{Name: param.Value}
-	}
-
-	return result
-}
-
 func evalCallExpression(node *ast.CallExpression, env *object.Environment) object.Object {
 	function := Eval(node.Function, env)
 	if isError(function) {
 		return function
 	}
 
-	args := evalExpressions(node.Arguments, env)
-	if len(args) == 1 && isError(args[0]) {
-		return args[0]
+	args, kwargs, err := evalCallArguments(node.Arguments, env)
+	if err != nil {
+		return err
 	}
 
-	return applyFunction(function, args)
+	// The calling function makes a call in tail position once its own
+	// body has finished, in runFunction
+	if node.Tail {
+		return &tailCall{fn: function, args: args, kwargs: kwargs}
+	}
+	return applyFunction(function, args, kwargs)
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
@@ -250,29 +414,89 @@ func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) obj
 	return evalIndex(left, right)
 }
 
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := make([]object.Object, 3)
+	for i, exp := range []ast.Expression{node.Start, node.End, node.Step} {
+		if exp == nil {
+			continue
+		}
+		bounds[i] = Eval(exp, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	return builtins.Slice(left, bounds[0], bounds[1], bounds[2])
+}
+
+func evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	left := Eval(node.Object, env)
+	if isError(left) {
+		return left
+	}
+
+	name := node.Property.Value
+
+	switch left := left.(type) {
+	case *object.Module:
+		val, ok := left.Exports[name]
+		if !ok {
+			return newError("module %s has no exported name %s", left.Name, name)
+		}
+		return val
+	case *object.Hash:
+		// Keys win over the hash methods they share a name with
+		if val, ok := left.Get(&object.String{Value: name}); ok {
+			return val
+		}
+		if method, ok := builtins.LookupMethod(left, name); ok {
+			return method
+		}
+		return nil
+	case *object.Function:
+		switch name {
+		case "नाम":
+			return &object.String{Value: left.Name}
+		case "विवरण":
+			return &object.String{Value: left.Doc}
+		}
+	}
+
+	if method, ok := builtins.LookupMethod(left, name); ok {
+		return method
+	}
+
+	return newError("member access not supported: %s.%s", left.Type(), name)
+}
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: " + key.Type())
+			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func evalIntegerInfixExpression(node *ast.InfixExpression, left, right object.Object) object.Object {
@@ -287,11 +511,18 @@ func evalIntegerInfixExpression(node *ast.InfixExpression, left, right object.Ob
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
@@ -302,7 +533,11 @@ func evalIntegerInfixExpression(node *ast.InfixExpression, left, right object.Ob
 }
 
 func evalStringInfixExpression(node *ast.InfixExpression, left, right object.Object) object.Object {
-	if node.Operator != "" {
+	if isOrderingOperator(node.Operator) {
+		return evalOrderingExpression(node.Operator, object.Compare(left, right))
+	}
+
+	if node.Operator != "+" {
 		return newError("unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 
@@ -340,6 +575,11 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return FALSE
 }
 
+// IsTruthy reports whether यदि takes obj as true
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case nil:
@@ -351,27 +591,139 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// applyFunction calls fn with positional args and keyword arguments kwargs,
+// which may be nil
+func applyFunction(fn object.Object, args []object.Object, kwargs *object.Hash) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		env, err := extendFunctionEnv(fn, args, kwargs)
+		if err != nil {
+			return err
+		}
+		if fn.IsAsync {
+			return startAsync(fn, env)
+		}
+		return runFunction(fn, env)
 	case *object.Builtin:
+		if kwargs != nil && kwargs.Len() > 0 {
+			return newError("builtin functions do not take keyword arguments")
+		}
 		return fn.Fn(args...)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+// runFunction runs fn's body to completion in env, which holds its arguments.
+// A tail call made by the body comes back as a *tailCall, which runs in
+// place of fn rather than on top of it, so tail recursion runs in constant
+// space. An error leaving a named function records the name in its trace,
+// and a run of calls to the same function that replaced one another is
+// recorded once, with their number.
+func runFunction(fn *object.Function, env *object.Environment) (result object.Object) {
+	if depth == MaxDepth {
+		return newError("stack overflow")
+	}
+	depth++
+	defer func() { depth-- }()
+
+	var frames []frameRun
+
+	pushed := pushFrame(fn, env)
+	if pushed {
+		defer popFrame()
+	}
+
+	var calls []*object.Function // told to the instrument
+	if instrument != nil {
+		defer func() { returned(calls, result) }()
+	}
+
+	for {
+		frames = appendFrame(frames, fn.Name)
+		if pushed {
+			calls = append(calls, fn)
+			calling(fn, env)
+		}
+
+		evaluated := unwrapReturnValue(Eval(fn.Body, env))
+
+		call, ok := evaluated.(*tailCall)
+		if !ok {
+			return traced(evaluated, frames)
+		}
+
+		next, ok := call.fn.(*object.Function)
+		if !ok || next.IsAsync {
+			return traced(applyFunction(call.fn, call.args, call.kwargs), frames)
+		}
+
+		nextEnv, err := extendFunctionEnv(next, call.args, call.kwargs)
+		if err != nil {
+			return traced(err, frames)
+		}
+		fn, env = next, nextEnv
+		if pushed {
+			frame := callStack[len(callStack)-1]
+			frame.Function, frame.Env = fn, env
+		}
+	}
+}
+
+// MaxDepth is how deeply calls can nest, as frames can on the VM. Deeper
+// recursion stops the program with a stack overflow error rather than
+// running out of Go stack.
+const MaxDepth = 1 << 14
+
+// depth is the number of calls in progress, on the coroutine running if
+// any; each coroutine has its own
+var depth int
+
+// tailCall is a call in tail position, made by the function that returns it
+type tailCall struct {
+	fn     object.Object
+	args   []object.Object
+	kwargs *object.Hash
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// frameRun is a run of calls to one named function, each replacing the
+// last through a tail call
+type frameRun struct {
+	name  string
+	count int
+}
+
+func appendFrame(frames []frameRun, name string) []frameRun {
+	switch {
+	case name == "":
+		return frames
+	case len(frames) > 0 && frames[len(frames)-1].name == name:
+		frames[len(frames)-1].count++
+		return frames
+	default:
+		return append(frames, frameRun{name: name, count: 1})
+	}
+}
 
-	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Name, args[paramIdx])
+// traced adds frames to the trace of obj, if it is an error, innermost
+// first
+func traced(obj object.Object, frames []frameRun) object.Object {
+	err, ok := obj.(*object.Error)
+	if !ok {
+		return obj
 	}
 
-	return env
+	for i := len(frames) - 1; i >= 0; i-- {
+		if frames[i].count == 1 {
+			err.Trace = append(err.Trace, frames[i].name)
+		} else {
+			err.Trace = append(err.Trace, fmt.Sprintf("%s (×%d by tail calls)", frames[i].name, frames[i].count))
+		}
+	}
+	return err
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -385,6 +737,8 @@ func evalIndex(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndex(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndex(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndex(left, index)
 	default:
@@ -394,30 +748,55 @@ func evalIndex(left, index object.Object) object.Object {
 
 func evalArrayIndex(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
 
-	max := int64(len(arrayObject.Elements) - 1)
-
-	if idx < 0 || idx > max {
-		return nil
+	idx, err := sequenceIndex(index, len(arrayObject.Elements))
+	if err != nil {
+		return err
 	}
 
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndex returns the character (grapheme cluster) at index
+func evalStringIndex(str, index object.Object) object.Object {
+	s := str.(*object.String).Value
+
+	idx, err := sequenceIndex(index, grapheme.Count(s))
+	if err != nil {
+		return err
+	}
+
+	return &object.String{Value: grapheme.Slice(s, idx, idx+1)}
+}
+
+// sequenceIndex resolves an index into a sequence of the given length,
+// counting negative indexes from the end
+func sequenceIndex(index object.Object, length int) (int, *object.Error) {
+	idx := index.(*object.Integer).Value
+	if idx < 0 {
+		idx += int64(length)
+	}
+
+	if idx < 0 || idx >= int64(length) {
+		return 0, newError("index out of range: %d with length %d", index.(*object.Integer).Value, length)
+	}
+
+	return int(idx), nil
+}
+
 func evalHashIndex(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: " + index.Type())
+		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return nil
 	}
 
-	return pair.Value
+	return value
 }
 
 func newError(format string, a ...interface{}) *object.Error {
@@ -432,10 +811,10 @@ func isError(obj object.Object) bool {
 }
 
 // TRUE represents the boolean true value
-var TRUE = &object.Boolean{Value: true}
+var TRUE = object.TRUE
 
 // FALSE represents the boolean false value
-var FALSE = &object.Boolean{Value: false}
+var FALSE = object.FALSE
 
 // NULL represents the null value
-var NULL = &object.Null{}
+var NULL = object.NULL
@@ -0,0 +1,215 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func TestHashLiteralKeepsSourceOrder(t *testing.T) {
+	input := `{"ग": १, "क": २, ३: "ख", सत्य: [], "क": ४}`
+
+	expected := "{ग: 1, क: 4, 3: ख, सत्य: []}"
+	if result := testEval(t, input); result.Inspect() != expected {
+		t.Errorf("wrong hash. expected=%s, got=%s", expected, result.Inspect())
+	}
+}
+
+func TestStructuralComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[१, २] == [१, २]", "सत्य"},
+		{"[१, [२]] != [१, [३]]", "सत्य"},
+		{`{"a": [१], "b": २} == {"b": २, "a": [१]}`, "सत्य"},
+		{`{"a": १} == {"a": "१"}`, "असत्य"},
+		{`१ == "१"`, "असत्य"},
+		{`"क" + "ख"`, "कख"},
+		{`"क" < "ख"`, "सत्य"},
+		{`"ख" <= "ख"`, "सत्य"},
+		{`"abc" > "abd"`, "असत्य"},
+		{`[१, २] < [१, ३]`, "सत्य"},
+		{"३ >= ३", "सत्य"},
+		{"तुलना([२], [१, ५])", "1"},
+		{`"क" - "ख"`, "ERROR: unknown operator: STRING - STRING"},
+		{`"क" < १`, "ERROR: type mismatch: STRING < INTEGER"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestSortBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"क्रमबद्ध([३, १, २])", "[1, 2, 3]"},
+		{`क्रमबद्ध(["ख", १, [], सत्य])`, "[सत्य, 1, ख, []]"},
+		{`क्रमबद्ध([३, १, २], {"उल्टो": सत्य})`, "[3, 2, 1]"},
+		{`क्रमबद्ध(["कि", "क", "का"], {"तुलना": नेपाली_तुलना})`, "[क, का, कि]"},
+		{`क्रमबद्ध(["क्ष", "कौ", "अ", "कं"], {"नेपाली": सत्य})`, "[अ, कं, कौ, क्ष]"},
+		{`क्रमबद्ध(["क्ष", "कौ"])`, "[कौ, क्ष]"},
+		{`क्रमबद्ध([[२, "क"], [१, "ख"], [२, "ग"], [१, "घ"]], {"कुञ्जी": फन(x) { x[०] }})`,
+			"[[1, ख], [1, घ], [2, क], [2, ग]]"},
+		{`क्रमबद्ध([१, २, ३], {"तुलना": फन(a, b) { b - a }})`, "[3, 2, 1]"},
+		{"लेट a = [२, १]; क्रमबद्ध(a); a", "[2, 1]"},
+		{`नेपाली_तुलना("अ", "क")`, "-1"},
+		{`क्रमबद्ध([१, २], {"कुञ्जी": फन(x) { x + "" }})`, "ERROR: type mismatch: INTEGER + STRING"},
+		{`क्रमबद्ध([१, २], {"तुलना": फन(a, b) { "" }})`, "ERROR: comparator passed to `क्रमबद्ध` must return INTEGER, got STRING"},
+		{"क्रमबद्ध(१)", "ERROR: argument to `क्रमबद्ध` must be ARRAY, got INTEGER"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestGraphemeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`लेन("नेपाल")`, "3"},
+		{`लेन("क्षत्रिय")`, "3"},
+		{`लेन("")`, "0"},
+		{`"नेपाल"[०]`, "ने"},
+		{`"नेपाल"[२]`, "ल"},
+		{`"नेपाल"[३]`, "ERROR: index out of range: 3 with length 3"},
+		{`अक्षरहरू("केटा")`, "[के, टा]"},
+		{`लेन(रुनहरू("के"))`, "2"},
+		{`रुनहरू("के")`, "[2325, 2375]"},
+		{`लेन(बाइटहरू("के"))`, "6"},
+		{`टुक्रा("नमस्ते", १)`, "मस्ते"},
+		{`टुक्रा("नमस्ते", ०, २)`, "नम"},
+		{`टुक्रा([१, २, ३], १, २)`, "[2]"},
+		{`टुक्रा("नमस्ते", -१, ०, -१)`, "स्तेम"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestIndexAndSlice(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[१, २, ३][-१]", "3"},
+		{"[१, २, ३][-३]", "1"},
+		{"[१, २, ३][३]", "ERROR: index out of range: 3 with length 3"},
+		{"[१, २, ३][-४]", "ERROR: index out of range: -4 with length 3"},
+		{"[][०]", "ERROR: index out of range: 0 with length 0"},
+		{`"नमस्ते"[-१]`, "स्ते"},
+		{"[१, २, ३, ४, ५][१:३]", "[2, 3]"},
+		{"[१, २, ३, ४, ५][:२]", "[1, 2]"},
+		{"[१, २, ३, ४, ५][३:]", "[4, 5]"},
+		{"[१, २, ३, ४, ५][:]", "[1, 2, 3, 4, 5]"},
+		{"[१, २, ३, ४, ५][::२]", "[1, 3, 5]"},
+		{"[१, २, ३, ४, ५][::-१]", "[5, 4, 3, 2, 1]"},
+		{"[१, २, ३, ४, ५][-२:]", "[4, 5]"},
+		{"[१, २, ३, ४, ५][३:१:-१]", "[4, 3]"},
+		{"[१, २, ३][१:१००]", "[2, 3]"},
+		{"[१, २, ३][५:]", "[]"},
+		{"लेट a = [१, २]; लेट b = a[:]; b == a", "सत्य"},
+		{`"नमस्ते"[१:]`, "मस्ते"},
+		{`"नमस्ते"[::-१]`, "स्तेमन"},
+		{"[१, २][::०]", "ERROR: slice step cannot be zero"},
+		{`[१, २]["a":]`, "ERROR: slice indices must be INTEGER, got STRING"},
+		{"५[१:]", "ERROR: slice operator not supported: INTEGER"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestReturnLeavesNestedBlocks(t *testing.T) {
+	input := `फन f(x) {
+		यदि (x > ०) { प्रतिफल "धनात्मक" }
+		"अन्य"
+	}
+	[f(१), f(-१)]`
+
+	if result := testEval(t, input); result.Inspect() != "[धनात्मक, अन्य]" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Slots are found through each kind of scope
+		{"लेट x = १; फन f(y) { फन(z) { [x, y, z] } }; f(२)(३)", "[1, 2, 3]"},
+		{"लेट x = १; [[x, y] लागि y मा [२]]", "[[1, 2]]"},
+		{"लेट x = १; मिलान [२] { अवस्था [y] { फन() { x + y }() } }", "3"},
+		// A function sees what is defined after it
+		{"फन f() { g() }; फन g() { १ }; f()", "1"},
+		{"फन f() { फन h() { k }; लेट k = २; h() }; f()", "2"},
+		// Until its लेट runs, a name still means what it did further out
+		{"लेट x = १; फन f() { लेट x = x + १; x }; [f(), x]", "[2, 1]"},
+		{"लेट x = १; फन f() { यदि (मिथ्या) { लेट x = २ }; x }; f()", "1"},
+		{"लेट x = १००; [x लागि x मा [x]]", "[100]"},
+		{"लेन([१, २])", "2"},
+		{"फन f() { y }; f()", "ERROR: identifier not found: y"},
+		// Closures share the scope they were made in
+		{"फन f() { लेट a = फन() { n }; लेट n = ५; a }; f()()", "5"},
+	}
+
+	for _, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s - wrong result. expected=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestEnvironmentAcrossPrograms(t *testing.T) {
+	env := object.NewEnvironment()
+	lines := []string{"लेट x = १;", "फन f() { x + y };", "लेट y = २;", "f()"}
+
+	var result object.Object
+	for _, line := range lines {
+		p := parser.New(lexer.New(line))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+		result = Eval(program, env)
+	}
+
+	if result.Inspect() != "3" {
+		t.Errorf("wrong result. expected=3, got=%s", result.Inspect())
+	}
+	if names := strings.Join(env.Names(), " "); names != "x f y" {
+		t.Errorf("wrong names. expected=%q, got=%q", "x f y", names)
+	}
+}
+
+func TestBuiltinDocs(t *testing.T) {
+	for _, name := range BuiltinNames() {
+		doc, ok := BuiltinDoc(name)
+		if !ok {
+			t.Errorf("builtin %s has no documentation", name)
+			continue
+		}
+		if !strings.HasPrefix(doc, name+"(") {
+			t.Errorf("documentation of %s does not start with its signature: %q", name, doc)
+		}
+	}
+}
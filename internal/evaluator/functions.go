@@ -0,0 +1,219 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// evalFunctionParameters converts a function literal's parameters,
+// evaluating default values once, where the function is defined
+func evalFunctionParameters(params []*ast.Parameter, env *object.Environment) ([]*object.Parameter, object.Object) {
+	result := make([]*object.Parameter, len(params))
+
+	for i, param := range params {
+		result[i] = &object.Parameter{
+			Name:        param.Name.Value,
+			Rest:        param.Rest,
+			KeywordRest: param.KeywordRest,
+		}
+
+		if param.Default != nil {
+			def := Eval(param.Default, env)
+			if isError(def) {
+				return nil, def
+			}
+			result[i].Default = orNull(def)
+		}
+	}
+
+	return result, nil
+}
+
+// evalCallArguments evaluates the arguments of a call, spreading *array and
+// **hash arguments, and returns the positional and keyword arguments, the
+// latter nil when there are none
+func evalCallArguments(exps []ast.Expression, env *object.Environment) ([]object.Object, *object.Hash, object.Object) {
+	args := []object.Object{}
+	var kwargs *object.Hash // made for the first keyword argument
+
+	setKeyword := func(name string, val object.Object) object.Object {
+		if kwargs == nil {
+			kwargs = object.NewHash()
+		}
+		key := &object.String{Value: name}
+		if _, ok := kwargs.Get(key); ok {
+			return newError("keyword argument %s given more than once", name)
+		}
+		kwargs.Set(key, val)
+		return nil
+	}
+
+	for _, exp := range exps {
+		switch exp := exp.(type) {
+		case *ast.KeywordArgument:
+			val := Eval(exp.Value, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			if err := setKeyword(exp.Name.Value, orNull(val)); err != nil {
+				return nil, nil, err
+			}
+		case *ast.SpreadArgument:
+			val := Eval(exp.Value, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+
+			if !exp.Keywords {
+				arr, ok := val.(*object.Array)
+				if !ok {
+					return nil, nil, newError("cannot spread %s into positional arguments", typeOf(val))
+				}
+				args = append(args, arr.Elements...)
+				continue
+			}
+
+			hash, ok := val.(*object.Hash)
+			if !ok {
+				return nil, nil, newError("cannot spread %s into keyword arguments", typeOf(val))
+			}
+			for _, pair := range hash.Pairs() {
+				name, ok := pair.Key.(*object.String)
+				if !ok {
+					return nil, nil, newError("keyword argument names must be STRING, got %s", pair.Key.Type())
+				}
+				if err := setKeyword(name.Value, pair.Value); err != nil {
+					return nil, nil, err
+				}
+			}
+		default:
+			val := Eval(exp, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			args = append(args, orNull(val))
+		}
+	}
+
+	return args, kwargs, nil
+}
+
+// extendFunctionEnv binds a call's arguments to fn's parameters in a fresh
+// environment enclosed by fn's own. Positional arguments fill the parameters
+// before any *rest in order, keyword arguments fill parameters by name, and
+// defaults fill what is left. Anything unbound or unclaimed is an error.
+func extendFunctionEnv(fn *object.Function, args []object.Object, kwargs *object.Hash) (*object.Environment, *object.Error) {
+	// The parameters take the first slots, in order
+	env := object.NewScope(fn.Env, fn.Locals)
+	bound := make([]bool, len(fn.Parameters)) // by parameter
+
+	rest, keywordRest := -1, -1
+	positional := 0 // parameters that can be passed by position
+	next := 0       // next positional argument to bind
+
+	for i, param := range fn.Parameters {
+		switch {
+		case param.Rest:
+			rest = i
+		case param.KeywordRest:
+			keywordRest = i
+		case rest == -1:
+			positional++
+			if next < len(args) {
+				env.Assign(i, args[next])
+				bound[i] = true
+				next++
+			}
+		}
+	}
+
+	if rest != -1 {
+		env.Assign(rest, &object.Array{Elements: append([]object.Object{}, args[next:]...)})
+	} else if next < len(args) {
+		return nil, arityError(fn, len(args), positional)
+	}
+
+	var extra *object.Hash
+	if keywordRest != -1 {
+		extra = object.NewHash()
+	}
+	if kwargs != nil {
+		for _, pair := range kwargs.Pairs() {
+			name := pair.Key.(*object.String).Value
+
+			i := findParameter(fn, name)
+			switch {
+			case i >= 0 && bound[i]:
+				return nil, newError("got multiple values for argument %s", name)
+			case i >= 0:
+				env.Assign(i, pair.Value)
+				bound[i] = true
+			case keywordRest != -1:
+				extra.Set(pair.Key.(*object.String), pair.Value)
+			default:
+				return nil, newError("unexpected keyword argument %s", name)
+			}
+		}
+	}
+	if keywordRest != -1 {
+		env.Assign(keywordRest, extra)
+	}
+
+	var missing []string
+	for i, param := range fn.Parameters {
+		if param.Rest || param.KeywordRest || bound[i] {
+			continue
+		}
+		if param.Default != nil {
+			env.Assign(i, param.Default)
+			continue
+		}
+		missing = append(missing, param.Name)
+	}
+
+	switch len(missing) {
+	case 0:
+		return env, nil
+	case 1:
+		return nil, newError("missing argument %s", missing[0])
+	default:
+		return nil, newError("missing arguments %s", strings.Join(missing, ", "))
+	}
+}
+
+// arityError reports too many positional arguments
+func arityError(fn *object.Function, got, positional int) *object.Error {
+	required := 0
+	for _, param := range fn.Parameters[:positional] {
+		if param.Default == nil {
+			required++
+		}
+	}
+
+	want := fmt.Sprint(positional)
+	if required < positional {
+		want = fmt.Sprintf("%d to %d", required, positional)
+	}
+	return newError("wrong number of arguments. got=%d, want=%s", got, want)
+}
+
+// findParameter returns the index of fn's parameter called name that can
+// be passed by keyword, or -1
+func findParameter(fn *object.Function, name string) int {
+	for i, param := range fn.Parameters {
+		if param.Name == name && !param.Rest && !param.KeywordRest {
+			return i
+		}
+	}
+	return -1
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"लेट f = फन(a, b = १०) { a + b }; f(१)", "11"},
+		{"लेट f = फन(a, b = १०) { a + b }; f(१, २)", "3"},
+		{"लेट f = फन(a, b = १०) { a + b }; f(b = ५, a = १)", "6"},
+		{"लेट f = फन(a, *rest) { rest }; f(१, २, ३)", "[2, 3]"},
+		{"लेट f = फन(a, *rest) { rest }; f(१)", "[]"},
+		{"लेट f = फन(**kw) { kw }; f(x = १, y = २)", "{x: 1, y: 2}"},
+		{"लेट f = फन(a, *rest, b = ०, **kw) { [a, rest, b, kw] }; f(१, २, b = ३, c = ४)", "[1, [2], 3, {c: 4}]"},
+		{"लेट f = फन(*rest, sep) { [rest, sep] }; f(१, २, sep = \"-\")", "[[1, 2], -]"},
+		{"लेट f = फन(a, b, c) { [a, b, c] }; f(*[१, २, ३])", "[1, 2, 3]"},
+		{"लेट f = फन(a, b, c) { [a, b, c] }; f(१, *[२], c = ३)", "[1, 2, 3]"},
+		{`लेट f = फन(a, b) { a - b }; f(**{"b": १, "a": ५})`, "4"},
+		{`लेट f = फन(*args, **kwargs) { [args, kwargs] }; लेट g = फन(*args, **kwargs) { f(*args, **kwargs) }; g(१, x = २)`,
+			"[[1], {x: 2}]"},
+		{"लेट n = १; लेट f = फन(a = n) { a }; लेट n = २; f()", "1"},
+		{"लेट f = फन(a, b = a) { b }; f(१)", "ERROR: identifier not found: a"},
+		{"फन(a, b = २, *r, **k) { a }", "फन(a, b = 2, *r, **k) {\na\n}"},
+		{"लेट f = फन(a, b) { a }; f(१)", "ERROR: missing argument b"},
+		{"लेट f = फन(a, b) { a }; f()", "ERROR: missing arguments a, b"},
+		{"लेट f = फन(a) { a }; f(१, २)", "ERROR: wrong number of arguments. got=2, want=1"},
+		{"लेट f = फन(a, b = १) { a }; f(१, २, ३)", "ERROR: wrong number of arguments. got=3, want=1 to 2"},
+		{"लेट f = फन(a) { a }; f(१, a = २)", "ERROR: got multiple values for argument a"},
+		{"लेट f = फन(a) { a }; f(b = २)", "ERROR: unexpected keyword argument b"},
+		{"लेट f = फन(*r) { r }; f(r = १)", "ERROR: unexpected keyword argument r"},
+		{`लेट f = फन(**k) { k }; f(a = १, **{"a": २})`, "ERROR: keyword argument a given more than once"},
+		{"लेट f = फन(a) { a }; f(*५)", "ERROR: cannot spread INTEGER into positional arguments"},
+		{"लेट f = फन(a) { a }; f(**[१])", "ERROR: cannot spread ARRAY into keyword arguments"},
+		{"लेट f = फन(**k) { k }; f(**{१: २})", "ERROR: keyword argument names must be STRING, got INTEGER"},
+		{"लेन(*[[१, २]])", "2"},
+		{"लेन(x = [१])", "ERROR: builtin functions do not take keyword arguments"},
+		{"लेट f = फन(a, b) { a }; f(१, २ + सत्य)", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestParameterListErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"फन(a, a) {}", "duplicate parameter a"},
+		{"फन(a = १, b) {}", "parameter b without a default follows a parameter with one"},
+		{"फन(*a, *b) {}", "only one *rest parameter is allowed"},
+		{"फन(**a, b) {}", "parameter b follows **a"},
+		{"f(a = १, २)", "positional argument follows keyword argument"},
+		{"f(**a, *b)", "positional argument follows keyword argument"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong parser errors. expected first=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestAsyncFunctionArityIsCheckedAtCall(t *testing.T) {
+	useVirtualClock(t)
+
+	result := testEval(t, "लेट f = एसिन्क फन(a) { a }; f()")
+	if result.Inspect() != "ERROR: missing argument a" {
+		t.Errorf("expected arity error. got=%s", result.Inspect())
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Deep enough to exhaust the Go stack without tail calls
+		{"फन जोड(n, acc) { यदि (n == ०) { प्रतिफल acc }; प्रतिफल जोड(n - १, acc + n) }; जोड(१०००००, ०)", "5000050000"},
+		{"फन जोड(n, acc) { यदि (n == ०) { acc } अन्यथा { जोड(n - १, acc + n) } }; जोड(१०००००, ०)", "5000050000"},
+		{`फन सम(n) { मिलान n { अवस्था ० { सत्य } अवस्था _ { विषम(n - १) } } }
+		  फन विषम(n) { यदि (n == ०) { मिथ्या } अन्यथा { सम(n - १) } }
+		  सम(१०००००)`, "सत्य"},
+		{"फन f(n, acc = []) { यदि (n == ०) { acc } अन्यथा { f(n - १, acc = [n]) } }; f(३)", "[1]"},
+		{"फन f(n) { यदि (n == ०) { लेन([१, २]) } अन्यथा { f(n - १) } }; f(१०)", "2"},
+		// Calls whose result is used are not tail calls
+		{"फन f(n) { यदि (n == ०) { ० } अन्यथा { f(n - १) + १ } }; f(१०)", "10"},
+	}
+
+	for _, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("%s - wrong result. expected=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestTailCallTrace(t *testing.T) {
+	input := `फन गल्ती(n) { यदि (n == ०) { १ + सत्य } अन्यथा { गल्ती(n - १) } }
+	फन बाहिरी() { गल्ती(१००) + ० }
+	बाहिरी()`
+
+	err, ok := testEval(t, input).(*object.Error)
+	if !ok {
+		t.Fatal("expected error")
+	}
+	expected := []string{"गल्ती (×101 by tail calls)", "बाहिरी"}
+	if !reflect.DeepEqual(err.Trace, expected) {
+		t.Errorf("wrong trace. expected=%q, got=%q", expected, err.Trace)
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	result := testEval(t, "फन f(n) { f(n + १) + १ }; f(०)")
+
+	err, ok := result.(*object.Error)
+	if !ok || err.Message != "stack overflow" {
+		t.Fatalf("expected stack overflow, got=%s", result.Inspect())
+	}
+	if bt := err.Backtrace(); len(bt) != 1 || bt[0] != fmt.Sprintf("f (×%d)", MaxDepth) {
+		t.Errorf("wrong backtrace: %v", bt)
+	}
+
+	// The depth unwinds with the calls, in async calls too
+	input := "लेट f = एसिन्क फन(n) { यदि (n == ०) { ० } अन्यथा { (पर्ख f(n - १)) + १ } }; पर्ख f(५००)"
+	if result := testEval(t, input); result.Inspect() != "500" || depth != 0 {
+		t.Errorf("wrong result. got=%s, depth=%d", result.Inspect(), depth)
+	}
+}
@@ -0,0 +1,220 @@
+package evaluator
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/async"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/resolver"
+)
+
+// Instrument is told what the evaluator does as a program runs, as
+// debuggers and tracers need. Its methods are called on the goroutine
+// evaluating the program, or the coroutine running in its place, one at a
+// time.
+type Instrument interface {
+	// Statement is called before each statement runs, with the scope it
+	// runs in. Returning an error ends the program with it.
+	Statement(stmt ast.Statement, env *object.Environment) *object.Error
+
+	// Expression is called with the value each expression evaluates to,
+	// unless evaluating it fails
+	Expression(exp ast.Expression, value object.Object, env *object.Environment)
+
+	// Call is called as a call of fn starts, with the scope it runs in,
+	// which holds its arguments. A tail call is reported as a call made
+	// by the function that makes it, though it runs in that call's place.
+	Call(fn *object.Function, env *object.Environment)
+
+	// Return is called with what a call of fn returned, perhaps an error
+	Return(fn *object.Function, result object.Object)
+
+	// Bind is called as name is bound to value in env: by लेट, by a call's
+	// parameters, by लागि in a comprehension, by a pattern, by सँग or by
+	// an import
+	Bind(name string, value object.Object, env *object.Environment)
+
+	// Error is called when evaluating node fails, with the error that
+	// then propagates out of the nodes enclosing it
+	Error(err *object.Error, node ast.Node)
+}
+
+// Frame is a call in progress: of a function, or of the top level of the
+// program or a module
+type Frame struct {
+	Function *object.Function    // nil for a top level
+	Env      *object.Environment // the scope of the statement running, perhaps nested in the call's own
+	Line     int                 // of the statement running
+}
+
+var (
+	instrument Instrument
+	callStack  []*Frame      // kept only while instrument is set
+	lastError  *object.Error // the last error reported, so it is reported once
+)
+
+// SetInstrument sets inst to be told what the evaluator does. While an
+// instrument is set the evaluator keeps the call stack CallStack returns.
+// nil removes the instrument.
+func SetInstrument(inst Instrument) {
+	instrument = inst
+	callStack = nil
+	lastError = nil
+}
+
+// StatementHook is called before the evaluator runs each statement, with
+// the scope it runs in. Returning an error ends the program with it.
+type StatementHook func(stmt ast.Statement, env *object.Environment) *object.Error
+
+// SetStatementHook sets hook to be called before each statement runs, as a
+// debugger does to stop the program: an instrument told only of
+// statements. nil removes the hook.
+func SetStatementHook(hook StatementHook) {
+	if hook == nil {
+		SetInstrument(nil)
+		return
+	}
+	SetInstrument(hook)
+}
+
+func (hook StatementHook) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	return hook(stmt, env)
+}
+
+func (StatementHook) Expression(ast.Expression, object.Object, *object.Environment) {}
+func (StatementHook) Call(*object.Function, *object.Environment)                    {}
+func (StatementHook) Return(*object.Function, object.Object)                        {}
+func (StatementHook) Bind(string, object.Object, *object.Environment)               {}
+func (StatementHook) Error(*object.Error, ast.Node)                                 {}
+
+// CallStack returns the calls in progress, outermost first
+func CallStack() []*Frame {
+	return callStack
+}
+
+// File returns the file whose top level encloses env, or "" for code that
+// did not come from a file
+func File(env *object.Environment) string {
+	return env.File()
+}
+
+// EvalExpression evaluates exp in the scope of env, as a debugger evaluates
+// a watch expression: its names are looked up by name in env and the
+// scopes enclosing it, and the instrument is not told of it
+func EvalExpression(exp ast.Expression, env *object.Environment) object.Object {
+	inst := instrument
+	instrument = nil
+	defer func() { instrument = inst }()
+
+	// The expression gets a scope of its own, so resolving it adds no slots
+	// to env
+	scope := object.NewEnclosedEnvironment(env)
+	program := &ast.Program{Statements: []ast.Statement{&ast.ExpressionStatement{Expression: exp}}}
+	resolver.Resolve(program, scope, isBuiltin)
+
+	return unwrapReturnValue(Eval(exp, scope))
+}
+
+// evalInstrumented evaluates node, telling the instrument what it
+// evaluated to
+func evalInstrumented(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+
+	switch value := result.(type) {
+	case nil, *tailCall:
+	case *object.Error:
+		if value != lastError {
+			lastError = value
+			instrument.Error(value, node)
+		}
+	case *object.ReturnValue:
+		if exp, ok := node.(ast.Expression); ok {
+			instrument.Expression(exp, value.Value, env)
+		}
+	default:
+		if exp, ok := node.(ast.Expression); ok {
+			instrument.Expression(exp, value, env)
+		}
+	}
+	return result
+}
+
+// pushFrame records a call of fn, or of a top level when fn is nil, running
+// in env, if an instrument is set. It returns whether it did.
+func pushFrame(fn *object.Function, env *object.Environment) bool {
+	if instrument == nil {
+		return false
+	}
+	callStack = append(callStack, &Frame{Function: fn, Env: env})
+	return true
+}
+
+func popFrame() {
+	// Removing the instrument drops the call stack
+	if len(callStack) > 0 {
+		callStack = callStack[:len(callStack)-1]
+	}
+}
+
+// atStatement tells the instrument of stmt, first recording it as where
+// the innermost call is
+func atStatement(stmt ast.Statement, env *object.Environment) *object.Error {
+	if _, ok := stmt.(*ast.BlockStatement); ok {
+		return nil // its statements are reported one by one
+	}
+	if len(callStack) > 0 {
+		frame := callStack[len(callStack)-1]
+		frame.Env, frame.Line = env, statementLine(stmt)
+	}
+	return instrument.Statement(stmt, env)
+}
+
+// calling tells the instrument of a call of fn in env, and of the
+// parameters bound there
+func calling(fn *object.Function, env *object.Environment) {
+	instrument.Call(fn, env)
+	for slot, name := range env.Names() {
+		if val := env.Lookup(0, slot); val != nil {
+			instrument.Bind(name, val, env)
+		}
+	}
+}
+
+// returned tells the instrument that the calls, each made by the one
+// before it, returned result
+func returned(calls []*object.Function, result object.Object) {
+	if instrument == nil || async.Stopping() {
+		return // removed while the calls ran, or not returning but stopped
+	}
+	for i := len(calls) - 1; i >= 0; i-- {
+		instrument.Return(calls[i], result)
+	}
+}
+
+// bound tells the instrument, if one is set, that name was bound to val in
+// env
+func bound(name string, val object.Object, env *object.Environment) {
+	if instrument != nil {
+		instrument.Bind(name, val, env)
+	}
+}
+
+// statementLine returns the line stmt starts on
+func statementLine(stmt ast.Statement) int {
+	var tok lexer.Token
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		tok = stmt.Token
+	case *ast.ReturnStatement:
+		tok = stmt.Token
+	case *ast.ExpressionStatement:
+		tok = stmt.Token
+	case *ast.WithStatement:
+		tok = stmt.Token
+	case *ast.ImportStatement:
+		tok = stmt.Token
+	case *ast.ExportStatement:
+		tok = stmt.Token
+	}
+	return tok.Line
+}
@@ -0,0 +1,148 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func TestStatementHook(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // line:depth of each statement, in the order they run
+	}{
+		{"फन f(n) {\n लेट m = n + १\n m\n}\nलेट a = f(१)\nf(a)",
+			"1:1 5:1 2:2 3:2 6:1 2:2 3:2"},
+		// यदि blocks run in the call they are in
+		{"फन f(n) {\n यदि (n > ०) {\n प्रतिफल n\n }\n}\nf(१)",
+			"1:1 6:1 2:2 3:2"},
+		// Tail calls replace the call that makes them
+		{"फन f(n) {\n यदि (n == ०) { प्रतिफल ० }\n f(n - १)\n}\nf(२)",
+			"1:1 5:1 2:2 3:2 2:2 3:2 2:2 2:2"},
+	}
+
+	for _, tt := range tests {
+		var ran []string
+		SetStatementHook(func(stmt ast.Statement, env *object.Environment) *object.Error {
+			stack := CallStack()
+			ran = append(ran, fmt.Sprintf("%d:%d", stack[len(stack)-1].Line, len(stack)))
+			return nil
+		})
+		testEval(t, tt.input)
+		SetStatementHook(nil)
+
+		if got := strings.Join(ran, " "); got != tt.expected {
+			t.Errorf("%q - wrong statements. expected=%s, got=%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestStatementHookError(t *testing.T) {
+	SetStatementHook(func(stmt ast.Statement, env *object.Environment) *object.Error {
+		if x, ok := env.Get("x"); ok && x.Inspect() == "2" {
+			return &object.Error{Message: "stopped"}
+		}
+		return nil
+	})
+	defer SetStatementHook(nil)
+
+	result := testEval(t, "लेट x = १\nलेट x = २\nलेट x = ३\nx")
+	if result.Inspect() != "ERROR: stopped" {
+		t.Errorf("wrong result. expected=ERROR: stopped, got=%s", result.Inspect())
+	}
+}
+
+func TestEvalExpression(t *testing.T) {
+	env := object.NewEnvironment()
+	Eval(parser.New(lexer.New("लेट x = २; फन f(y) { x * y }")).ParseProgram(), env)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x + १", "3"},
+		{"f(x)", "4"},
+		{"लेन([x, x])", "2"},
+		{"z", "ERROR: identifier not found: z"},
+	}
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		exp := program.Statements[0].(*ast.ExpressionStatement).Expression
+		if result := EvalExpression(exp, env); result.Inspect() != tt.expected {
+			t.Errorf("%s - wrong result. expected=%s, got=%s", tt.input, tt.expected, result.Inspect())
+		}
+	}
+	if names := strings.Join(env.Names(), " "); names != "x f" {
+		t.Errorf("evaluating expressions changed the environment: %q", names)
+	}
+}
+
+// recorder is an instrument that notes what it is told
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	r.events = append(r.events, fmt.Sprintf("line %d", statementLine(stmt)))
+	return nil
+}
+
+func (r *recorder) Expression(exp ast.Expression, value object.Object, env *object.Environment) {
+	if _, ok := exp.(*ast.InfixExpression); ok {
+		r.events = append(r.events, fmt.Sprintf("%s => %s", exp.String(), value.Inspect()))
+	}
+}
+
+func (r *recorder) Call(fn *object.Function, env *object.Environment) {
+	r.events = append(r.events, "call "+fn.Name)
+}
+
+func (r *recorder) Return(fn *object.Function, result object.Object) {
+	r.events = append(r.events, fmt.Sprintf("return %s %s", fn.Name, result.Inspect()))
+}
+
+func (r *recorder) Bind(name string, value object.Object, env *object.Environment) {
+	shown := value.Inspect()
+	if fn, ok := value.(*object.Function); ok {
+		shown = "फन " + fn.Name
+	}
+	r.events = append(r.events, fmt.Sprintf("bind %s %s", name, shown))
+}
+
+func (r *recorder) Error(err *object.Error, node ast.Node) {
+	r.events = append(r.events, fmt.Sprintf("error %s at %s", err.Message, node.String()))
+}
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"फन f(a) {\n a * २\n}\nलेट x = f(३)",
+			"line 1, bind f फन f, line 4, call f, bind a 3, line 2, (a * २) => 6, return f 6, bind x 6"},
+		// A tail call returns through the call that made it
+		{"फन f(n) {\n यदि (n > ०) { प्रतिफल f(n - १) }\n n\n}\nf(१)",
+			"line 1, bind f फन f, line 5, call f, bind n 1, line 2, (n > ०) => सत्य, line 2, (n - १) => 0, " +
+				"call f, bind n 0, line 2, (n > ०) => असत्य, line 3, return f 0, return f 0"},
+		// An error is reported once, where it happens
+		{"लेट x = १ / ०\nx",
+			"line 1, error division by zero at (१ / ०)"},
+	}
+
+	for _, tt := range tests {
+		r := &recorder{}
+		SetInstrument(r)
+		testEval(t, tt.input)
+		SetInstrument(nil)
+
+		if got := strings.Join(r.events, ", "); got != tt.expected {
+			t.Errorf("%q - wrong events.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
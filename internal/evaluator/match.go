@@ -0,0 +1,132 @@
+package evaluator
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// evalMatchExpression tries node's cases in order. A case's bindings live
+// in an environment of its own, enclosed by env, which its guard and body
+// see. Guards are tested for truthiness as यदि conditions are. With no case
+// matching, the result is निल, as for यदि without अन्यथा.
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(node.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+	subject = orNull(subject)
+
+	for _, c := range node.Cases {
+		caseEnv := object.NewScope(env, c.Locals)
+
+		matched, err := matchPattern(c.Pattern, subject, caseEnv)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			continue
+		}
+
+		if c.Guard != nil {
+			cond := Eval(c.Guard, caseEnv)
+			if isError(cond) {
+				return cond
+			}
+			if !isTruthy(cond) {
+				continue
+			}
+		}
+
+		return Eval(c.Body, caseEnv)
+	}
+
+	return NULL
+}
+
+// matchPattern reports whether val matches pattern, binding the names the
+// pattern introduces in env
+func matchPattern(pattern ast.Pattern, val object.Object, env *object.Environment) (bool, object.Object) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		lit := Eval(pattern.Value, env)
+		if isError(lit) {
+			return false, lit
+		}
+		return object.Equal(lit, val), nil
+
+	case *ast.BindingPattern:
+		if !pattern.IsWildcard() {
+			env.Assign(pattern.Name.Slot, val)
+			bound(pattern.Name.Value, val, env)
+		}
+		return true, nil
+
+	case *ast.ArrayPattern:
+		arr, ok := val.(*object.Array)
+		if !ok {
+			return false, nil
+		}
+
+		fixed := len(pattern.Before) + len(pattern.After)
+		n := len(arr.Elements)
+		if n < fixed || (pattern.Rest == nil && n != fixed) {
+			return false, nil
+		}
+
+		for i, p := range pattern.Before {
+			if ok, err := matchPattern(p, arr.Elements[i], env); !ok || err != nil {
+				return false, err
+			}
+		}
+		for i, p := range pattern.After {
+			if ok, err := matchPattern(p, arr.Elements[n-len(pattern.After)+i], env); !ok || err != nil {
+				return false, err
+			}
+		}
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			elements := arr.Elements[len(pattern.Before) : n-len(pattern.After)]
+			rest := &object.Array{Elements: append([]object.Object{}, elements...)}
+			env.Assign(pattern.Rest.Slot, rest)
+			bound(pattern.Rest.Value, rest, env)
+		}
+		return true, nil
+
+	case *ast.HashPattern:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return false, nil
+		}
+
+		used := object.NewHash()
+		for i, k := range pattern.Keys {
+			key, ok := Eval(k, env).(object.Hashable)
+			if !ok {
+				return false, newError("unusable as hash key: %s", k.String())
+			}
+
+			v, found := hash.Get(key)
+			if !found {
+				return false, nil
+			}
+			if ok, err := matchPattern(pattern.Values[i], v, env); !ok || err != nil {
+				return false, err
+			}
+			used.Set(key, TRUE)
+		}
+
+		if pattern.Rest != nil && pattern.Rest.Value != "_" {
+			rest := object.NewHash()
+			for _, pair := range hash.Pairs() {
+				key := pair.Key.(object.Hashable)
+				if _, ok := used.Get(key); !ok {
+					rest.Set(key, pair.Value)
+				}
+			}
+			env.Assign(pattern.Rest.Slot, rest)
+			bound(pattern.Rest.Value, rest, env)
+		}
+		return true, nil
+	}
+
+	return false, newError("unknown pattern: %s", pattern.String())
+}
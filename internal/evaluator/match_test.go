@@ -0,0 +1,135 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func TestMatchExpression(t *testing.T) {
+	classify := `फन वर्ग(x) {
+		मिलान x {
+			अवस्था ० { "शून्य" }
+			अवस्था -१ { "ऋण एक" }
+			अवस्था "नमस्ते" { "अभिवादन" }
+			अवस्था सत्य { "सत्य" }
+			अवस्था [] { "खाली" }
+			अवस्था [a] { "एउटा " + स्ट्रिंग(a) }
+			अवस्था [a, b] यदि a > b { "घट्दो" }
+			अवस्था [पहिलो, *बाँकी, अन्तिम] { [पहिलो, बाँकी, अन्तिम] }
+			अवस्था {"नाम": n, "उमेर": u} यदि u >= १८ { n + " वयस्क" }
+			अवस्था {"नाम": n, **अरू} { [n, अरू] }
+			अवस्था n यदि टाइप(n) == "integer" { यदि (n > १००) { "ठूलो" } अन्यथा { "सानो" } }
+			अवस्था _ { "अरू" }
+		}
+	};`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"वर्ग(०)", "शून्य"},
+		{"वर्ग(-१)", "ऋण एक"},
+		{`वर्ग("नमस्ते")`, "अभिवादन"},
+		{"वर्ग(सत्य)", "सत्य"},
+		{"वर्ग(मिथ्या)", "अरू"},
+		{"वर्ग([])", "खाली"},
+		{"वर्ग([७])", "एउटा 7"},
+		{"वर्ग([२, १])", "घट्दो"},
+		{"वर्ग([१, २])", "[1, [], 2]"},
+		{"वर्ग([१, २, ३, ४])", "[1, [2, 3], 4]"},
+		{`वर्ग({"नाम": "राम", "उमेर": २०})`, "राम वयस्क"},
+		{`वर्ग({"उमेर": १०, "नाम": "सीता", "गाउँ": "क"})`, "[सीता, {उमेर: 10, गाउँ: क}]"},
+		{"वर्ग(५००)", "ठूलो"},
+		{"वर्ग(५)", "सानो"},
+		{`वर्ग({"उमेर": १०})`, "अरू"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, classify+tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestMatchScopingAndResults(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`मिलान ५ { अवस्था ६ { "छ" } }`, "निल"},
+		{`लेट x = १; मिलान [२] { अवस्था [x] { x } }`, "2"},
+		{`लेट x = १; मिलान [२] { अवस्था [x] { x } }; x`, "1"},
+		{`मिलान [[१, २], ३] { अवस्था [[a, _], b] { a + b } }`, "4"},
+		{`मिलान [१, २] { अवस्था [*_, z] { z } }`, "2"},
+		{`फन f(x) { मिलान x { अवस्था १ { प्रतिफल "एक" } }; "अरू" }; [f(१), f(२)]`, "[एक, अरू]"},
+		{`मिलान १ { अवस्था x यदि x + सत्य { १ } }`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`मिलान १ + सत्य { अवस्था _ { १ } }`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for i, tt := range tests {
+		if result := testEval(t, tt.input); result.Inspect() != tt.expected {
+			t.Errorf("tests[%d] - %s wrong. expected=%s, got=%s", i, tt.input, tt.expected, result.Inspect())
+		}
+	}
+}
+
+func TestMatchUnreachableCaseWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`मिलान x { अवस्था १ { } अवस्था _ { } }`, nil},
+		{`मिलान x { अवस्था n यदि n > १ { } अवस्था _ { } }`, nil},
+		{`मिलान x { अवस्था १ यदि सत्य { } अवस्था १ { } }`, nil},
+		{`मिलान x { अवस्था _ { } अवस्था १ { } अवस्था [a] { } }`, []string{
+			"unreachable case 2 (१): case 1 (_) matches every value",
+			"unreachable case 3 ([a]): case 1 (_) matches every value",
+		}},
+		{`मिलान x { अवस्था "क" { } अवस्था "क" { } }`, []string{
+			"unreachable case 2 (क): case 1 has the same pattern",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("%s: parser errors: %v", tt.input, p.Errors())
+		}
+
+		warnings := p.Warnings()
+		if len(warnings) != len(tt.expected) {
+			t.Errorf("%s: wrong warnings. expected=%q, got=%q", tt.input, tt.expected, warnings)
+			continue
+		}
+		for i := range warnings {
+			if warnings[i] != tt.expected[i] {
+				t.Errorf("%s: wrong warning. expected=%q, got=%q", tt.input, tt.expected[i], warnings[i])
+			}
+		}
+	}
+}
+
+func TestPatternErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"मिलान x { अवस्था [*a, *b] { } }", "only one *rest is allowed in an array pattern"},
+		{"मिलान x { अवस्था {a: १} { } }", "hash pattern keys must be literals"},
+		{`मिलान x { अवस्था {**a, "b": १} { } }`, "**rest must come last in a hash pattern"},
+		{"मिलान x { अवस्था (१) { } }", "unexpected ( in pattern"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong parser errors. expected first=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
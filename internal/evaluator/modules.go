@@ -0,0 +1,160 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/imports"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+// SourceExt is the file extension of Nepali source files
+const SourceExt = imports.SourceExt
+
+// ModuleLoader resolves, evaluates and caches imported modules
+type ModuleLoader struct {
+	// SearchPath lists directories tried, in order, for imports that are not
+	// relative to the importing file
+	SearchPath []string
+
+	cache   map[string]*object.Module
+	exports map[*object.Environment][]string // names exported so far by each file being evaluated, in order
+	loading []string                         // files being evaluated, outermost first
+}
+
+// NewModuleLoader creates a ModuleLoader that searches the given directories
+func NewModuleLoader(searchPath ...string) *ModuleLoader {
+	return &ModuleLoader{
+		SearchPath: searchPath,
+		cache:      make(map[string]*object.Module),
+		exports:    make(map[*object.Environment][]string),
+	}
+}
+
+// modules loads every module imported by the evaluator
+var modules = NewModuleLoader()
+
+// SetModuleLoader replaces the loader used to resolve imports
+func SetModuleLoader(ml *ModuleLoader) {
+	modules = ml
+}
+
+// EvalFile evaluates program as the contents of the file at path, so that
+// its imports resolve relative to that file
+func EvalFile(program *ast.Program, path string, env *object.Environment) object.Object {
+	result, _ := modules.evalFile(program, path, env)
+	return finish(result)
+}
+
+// evalFile evaluates program as the contents of the file at path, returning
+// its result and the names it exported
+func (ml *ModuleLoader) evalFile(program *ast.Program, path string, env *object.Environment) (object.Object, []string) {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+
+	env.SetFile(path)
+	ml.loading = append(ml.loading, path)
+	ml.exports[env] = nil
+	defer func() {
+		ml.loading = ml.loading[:len(ml.loading)-1]
+		delete(ml.exports, env)
+	}()
+
+	result := evalProgram(program, env)
+	return result, ml.exports[env]
+}
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := modules.load(node.Path.Value, env.File())
+	if isError(module) {
+		return module
+	}
+	mod := module.(*object.Module)
+
+	if len(node.Names) == 0 {
+		name := &ast.Identifier{Value: mod.Name}
+		if node.Alias != nil {
+			name = node.Alias
+		}
+		name.Slot = env.Define(name.Value)
+		return bind(env, name, mod, false)
+	}
+
+	for _, n := range node.Names {
+		val, ok := mod.Exports[n.Value]
+		if !ok {
+			return newError("module %s has no exported name %s", mod.Name, n.Value)
+		}
+		if err := bind(env, n, val, false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func evalExportStatement(node *ast.ExportStatement, env *object.Environment) object.Object {
+	if env.Outer() != nil {
+		return newError("%s is only allowed at the top level of a module", lexer.EXPORT)
+	}
+
+	if result := evalLetStatement(node.Statement, env); isError(result) {
+		return result
+	}
+
+	// Only a file being evaluated is a module others can import from
+	if names, ok := modules.exports[env]; ok {
+		modules.exports[env] = append(names, node.Statement.Name.Value)
+	}
+	return nil
+}
+
+// load returns the module for importPath, evaluating it on first use
+func (ml *ModuleLoader) load(importPath, from string) object.Object {
+	path, ok := imports.Resolve(importPath, from, ml.SearchPath)
+	if !ok {
+		return newError("module not found: %s", importPath)
+	}
+
+	if mod, ok := ml.cache[path]; ok {
+		return mod
+	}
+
+	if cycle, ok := imports.Cycle(ml.loading, path); ok {
+		return newError("circular import: %s", cycle)
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return newError("could not read module %s: %s", importPath, err)
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("parse errors in module %s: %s", importPath, strings.Join(p.Errors(), "; "))
+	}
+
+	env := object.NewEnvironment()
+	result, exports := ml.evalFile(program, path, env)
+	if isError(result) {
+		return result
+	}
+
+	mod := &object.Module{
+		Name:    imports.Name(path),
+		Path:    path,
+		Exports: make(map[string]object.Object),
+	}
+	for _, name := range exports {
+		mod.Exports[name], _ = env.Get(name)
+	}
+
+	ml.cache[path] = mod
+	return mod
+}
@@ -0,0 +1,168 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func writeModules(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testEvalFile(t *testing.T, path string, searchPath ...string) object.Object {
+	t.Helper()
+
+	prev := modules
+	SetModuleLoader(NewModuleLoader(searchPath...))
+	t.Cleanup(func() { SetModuleLoader(prev) })
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+
+	return EvalFile(program, path, object.NewEnvironment())
+}
+
+func TestImportModule(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"विद्यार्थी.nep": `
+निर्यात लेट नयाँ = फन(नाम, कक्षा) { {"नाम": नाम, "कक्षा": कक्षा} };
+निर्यात लेट पूर्वनिर्धारित_कक्षा = १०;
+लेट निजी = ४२;
+`,
+		"main.nep": `
+आयात "./विद्यार्थी";
+आयात "./विद्यार्थी" जस्तो व;
+बाट "./विद्यार्थी" आयात नयाँ;
+[विद्यार्थी.नयाँ("राम", व.पूर्वनिर्धारित_कक्षा)["कक्षा"], नयाँ("सीता", ९)["नाम"], विद्यार्थी == व]
+`,
+	})
+
+	result := testEvalFile(t, filepath.Join(dir, "main.nep"))
+	expected := "[10, सीता, सत्य]"
+	if result.Inspect() != expected {
+		t.Errorf("wrong result. expected=%s, got=%s", expected, result.Inspect())
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"क.nep":       `आयात "./ख"; निर्यात लेट x = १;`,
+		"ख.nep":       `आयात "./ग";`,
+		"ग.nep":       `आयात "./क";`,
+		"निजी.nep":    `लेट गोप्य = १; निर्यात लेट खुला = २;`,
+		"cycle.nep":   `आयात "./क";`,
+		"private.nep": `आयात "./निजी"; निजी.गोप्य`,
+		"missing.nep": `आयात "./छैन";`,
+		"from.nep":    `बाट "./निजी" आयात गोप्य;`,
+	})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"cycle.nep", "circular import: क.nep -> ख.nep -> ग.nep -> क.nep"},
+		{"private.nep", "module निजी has no exported name गोप्य"},
+		{"missing.nep", "module not found: ./छैन"},
+		{"from.nep", "module निजी has no exported name गोप्य"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEvalFile(t, filepath.Join(dir, tt.file)).(*object.Error)
+		if !ok {
+			t.Errorf("%s - expected ERROR", tt.file)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("%s - wrong error. expected=%q, got=%q", tt.file, tt.expected, err.Message)
+		}
+	}
+}
+
+func TestImportLeavesAsyncWorkRunning(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"m.nep": `निर्यात लेट x = १;`,
+		"main.nep": `लेट लग = []
+			लेट f = एसिन्क फन() { पर्ख सुत्नुहोस्(१); लग.थप("f") }
+			लेट p = f()
+			आयात "./m"
+			लग.थप("आयात")
+			पर्ख p
+			लग`,
+	})
+
+	// The import must not run f to completion
+	result := testEvalFile(t, filepath.Join(dir, "main.nep"))
+	if result.Inspect() != "[आयात, f]" {
+		t.Errorf("wrong result. expected=[आयात, f], got=%s", result.Inspect())
+	}
+}
+
+func TestImportSearchPathAndCaching(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	writeModules(t, dir, map[string]string{
+		"lib/गणित.nep": `निर्यात लेट वर्ग = फन(x) { x * x };`,
+		"lib/क.nep":    `आयात "गणित"; निर्यात लेट ग = गणित;`,
+		"main.nep":     `आयात "गणित"; आयात "क"; [गणित.वर्ग(७), गणित == क.ग]`,
+	})
+
+	result := testEvalFile(t, filepath.Join(dir, "main.nep"), lib)
+	expected := "[49, सत्य]"
+	if result.Inspect() != expected {
+		t.Errorf("wrong result. expected=%s, got=%s", expected, result.Inspect())
+	}
+}
+
+func TestImportKeepsNothingPerRun(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"गणित.nep": `निर्यात लेट वर्ग = फन(x) { x * x };`,
+		"खराब.nep": `निर्यात लेट y = १; १ + सत्य`,
+	})
+
+	prev := modules
+	SetModuleLoader(NewModuleLoader())
+	t.Cleanup(func() { SetModuleLoader(prev) })
+
+	// As in the REPL, every line is its own program in one environment
+	env := object.NewEnvironment()
+	env.SetFile(filepath.Join(dir, "repl"))
+	for i := 0; i < 3; i++ {
+		for _, input := range []string{`आयात "./गणित"; गणित.वर्ग(३)`, `आयात "./खराब"`, `निर्यात लेट x = १`} {
+			Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+		}
+	}
+
+	if len(modules.exports) != 0 {
+		t.Errorf("exports kept for %d environments", len(modules.exports))
+	}
+	if len(modules.cache) != 1 {
+		t.Errorf("wrong number of cached modules: %d", len(modules.cache))
+	}
+}
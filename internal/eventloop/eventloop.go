@@ -0,0 +1,139 @@
+// Package eventloop implements the single-threaded event loop that drives
+// async functions in the Nepali programming language
+package eventloop
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Clock is the source of time for the loop's timers
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is a Clock backed by the wall clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+func (SystemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// VirtualClock is a Clock whose Sleep advances time instantly, so scripts
+// that sleep for minutes finish as soon as their work is done
+type VirtualClock struct {
+	now time.Time
+}
+
+// NewVirtualClock creates a VirtualClock starting at the Unix epoch
+func NewVirtualClock() *VirtualClock {
+	return &VirtualClock{now: time.Unix(0, 0)}
+}
+
+func (c *VirtualClock) Now() time.Time { return c.now }
+
+func (c *VirtualClock) Sleep(d time.Duration) {
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+}
+
+// Loop runs scheduled tasks one at a time, in order, and fires timers once
+// the clock reaches them
+type Loop struct {
+	clock  Clock
+	start  time.Time
+	ready  []func()
+	timers timerQueue
+	seq    uint64
+}
+
+// New creates a Loop driven by clock
+func New(clock Clock) *Loop {
+	return &Loop{clock: clock, start: clock.Now()}
+}
+
+// Elapsed returns the clock time passed since the loop was created
+func (l *Loop) Elapsed() time.Duration {
+	return l.clock.Now().Sub(l.start)
+}
+
+// Schedule queues task to run on the next turn of the loop
+func (l *Loop) Schedule(task func()) {
+	l.ready = append(l.ready, task)
+}
+
+// After queues task to run once d has passed on the loop's clock
+func (l *Loop) After(d time.Duration, task func()) {
+	l.seq++
+	heap.Push(&l.timers, &timer{when: l.clock.Now().Add(d), seq: l.seq, task: task})
+}
+
+// Pending reports whether any tasks or timers are still queued
+func (l *Loop) Pending() bool {
+	return len(l.ready) > 0 || len(l.timers) > 0
+}
+
+// Run runs the loop until no tasks or timers remain
+func (l *Loop) Run() {
+	l.RunUntil(func() bool { return false })
+}
+
+// RunUntil runs the loop until done reports true or no work remains
+func (l *Loop) RunUntil(done func() bool) {
+	for !done() && l.step() {
+	}
+}
+
+// step runs one ready task, waiting for the next timer if none are ready.
+// It returns false once the loop is idle.
+func (l *Loop) step() bool {
+	if len(l.ready) == 0 {
+		if len(l.timers) == 0 {
+			return false
+		}
+
+		next := l.timers[0]
+		l.clock.Sleep(next.when.Sub(l.clock.Now()))
+
+		now := l.clock.Now()
+		for len(l.timers) > 0 && !l.timers[0].when.After(now) {
+			l.ready = append(l.ready, heap.Pop(&l.timers).(*timer).task)
+		}
+		return true
+	}
+
+	task := l.ready[0]
+	l.ready = l.ready[1:]
+	task()
+	return true
+}
+
+type timer struct {
+	when time.Time
+	seq  uint64 // breaks ties so timers due together fire in creation order
+	task func()
+}
+
+type timerQueue []*timer
+
+func (q timerQueue) Len() int { return len(q) }
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].when.Equal(q[j].when) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].when.Before(q[j].when)
+}
+
+func (q timerQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *timerQueue) Push(x any) { *q = append(*q, x.(*timer)) }
+
+func (q *timerQueue) Pop() any {
+	old := *q
+	t := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return t
+}
@@ -0,0 +1,50 @@
+package eventloop
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimersFireInOrder(t *testing.T) {
+	clock := NewVirtualClock()
+	l := New(clock)
+
+	var fired []int
+	l.After(30*time.Millisecond, func() { fired = append(fired, 30) })
+	l.After(10*time.Millisecond, func() { fired = append(fired, 10) })
+	l.After(10*time.Millisecond, func() { fired = append(fired, 11) })
+	l.Schedule(func() { fired = append(fired, 0) })
+
+	l.Run()
+
+	expected := []int{0, 10, 11, 30}
+	if len(fired) != len(expected) {
+		t.Fatalf("wrong number of tasks run. expected=%d, got=%d", len(expected), len(fired))
+	}
+	for i, v := range expected {
+		if fired[i] != v {
+			t.Errorf("fired[%d] wrong. expected=%d, got=%d", i, v, fired[i])
+		}
+	}
+
+	if l.Elapsed() != 30*time.Millisecond {
+		t.Errorf("wrong elapsed time. expected=30ms, got=%s", l.Elapsed())
+	}
+}
+
+func TestRunUntil(t *testing.T) {
+	l := New(NewVirtualClock())
+
+	done := false
+	l.After(time.Hour, func() { done = true })
+	l.After(2*time.Hour, func() { t.Error("timer after done should not fire") })
+
+	l.RunUntil(func() bool { return done })
+
+	if !done {
+		t.Fatal("RunUntil returned before done")
+	}
+	if !l.Pending() {
+		t.Error("expected the later timer to still be pending")
+	}
+}
@@ -0,0 +1,125 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines a hunk shows around a change
+const diffContext = 3
+
+// Diff returns a unified diff from the lines of a to those of b, labelled
+// with name, or "" if they are the same
+func Diff(name, a, b string) string {
+	if a == b {
+		return ""
+	}
+	edits := diffLines(splitLines(a), splitLines(b))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s (formatted)\n", name, name)
+
+	for start := 0; start < len(edits); {
+		// Find the next change, and the hunk around it: changes less than
+		// twice the context apart share one
+		first := start
+		for first < len(edits) && edits[first].op == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		last := first
+		for i := first; i < len(edits) && i-last <= 2*diffContext; i++ {
+			if edits[i].op != ' ' {
+				last = i
+			}
+		}
+
+		from := max(first-diffContext, 0)
+		to := min(last+diffContext+1, len(edits))
+		hunk := edits[from:to]
+
+		aStart, bStart := edits[from].aLine, edits[from].bLine
+		aCount, bCount := 0, 0
+		for _, e := range hunk {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, e := range hunk {
+			fmt.Fprintf(&out, "%c%s\n", e.op, e.text)
+		}
+
+		start = to
+	}
+
+	return out.String()
+}
+
+// edit is one line of a diff: kept (' '), removed ('-') or added ('+'),
+// with the numbers, from 1, of the lines of a and b it comes at
+type edit struct {
+	op           byte
+	text         string
+	aLine, bLine int
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the edits turning a into b, keeping a longest common
+// subsequence of their lines
+func diffLines(a, b []string) []edit {
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			edits = append(edits, edit{'-', a[i], i + 1, j + 1})
+			i++
+		default:
+			edits = append(edits, edit{'+', b[j], i + 1, j + 1})
+			j++
+		}
+	}
+	return edits
+}
+
+// hunkRange writes the start and length of a hunk's lines in one file.
+// An empty range starts at the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,765 @@
+// Package format rewrites source in one canonical layout, keeping its
+// comments, for `nepali fmt`
+package format
+
+import (
+	"bytes"
+	"math"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+// Digits says how integer literals are written
+type Digits int
+
+const (
+	KeepDigits       Digits = iota // as in the source
+	DevanagariDigits               // ०-९
+	ASCIIDigits                    // 0-9
+)
+
+// Options adjust the canonical layout
+type Options struct {
+	Digits Digits
+}
+
+// ParseErrors reports source that does not parse, and so cannot be
+// formatted
+type ParseErrors []string
+
+func (e ParseErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+const indentUnit = "    "
+
+// Source returns source laid out canonically: one statement to a line,
+// without semicolons unless the next line would otherwise continue the
+// statement; blocks indented by four spaces; single spaces around infix
+// operators and after commas and colons; strings in double quotes unless
+// they hold one; and parentheses only where grouping needs them. A blank
+// line in the source before a statement or comment is kept as one blank
+// line. Hash and array literals that start a new line after their opening
+// bracket are written an element to a line; others are written on one, as
+// are the arguments of calls, unless that would move a comment among them.
+// Those are written an element to a line too.
+//
+// Comments stay before the statement, element or closing brace they come
+// before in the source. A comment following code on its line stays at the
+// end of the line the code before it is printed on.
+//
+// Formatting formatted source gives it back unchanged.
+func Source(source string, opts Options) (string, error) {
+	l := lexer.New(source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", ParseErrors(p.Errors())
+	}
+
+	pr := &printer{opts: opts, comments: l.Comments(), blank: blankLines(source), atStart: true}
+	pr.statements(program.Statements)
+	pr.flush(math.MaxInt)
+
+	return pr.out.String(), nil
+}
+
+// Expression returns exp as Source writes it, for messages that quote
+// code. Any blocks in it take more than one line.
+func Expression(exp ast.Expression) string {
+	p := &printer{}
+	p.expression(exp)
+	return p.out.String()
+}
+
+// Statement returns stmt as Source writes it, as Expression does an
+// expression
+func Statement(stmt ast.Statement) string {
+	p := &printer{}
+	p.statement(stmt)
+	return p.out.String()
+}
+
+// blankLines reports, by line number, whether each line of source is blank
+func blankLines(source string) []bool {
+	lines := strings.Split(source, "\n")
+	blank := make([]bool, len(lines)+1)
+	for i, line := range lines {
+		blank[i+1] = strings.TrimSpace(line) == ""
+	}
+	return blank
+}
+
+type printer struct {
+	opts   Options
+	out    bytes.Buffer
+	indent int
+
+	comments    []lexer.Comment // those not printed yet
+	blank       []bool          // by source line
+	atStart     bool            // at the start of the program or a block, where blank lines are dropped
+	lastComment bool            // whether the last line printed is a comment of its own
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+	p.lastComment = false
+}
+
+func (p *printer) newline() {
+	p.out.WriteString("\n")
+}
+
+func (p *printer) writeIndent() {
+	p.out.WriteString(strings.Repeat(indentUnit, p.indent))
+}
+
+func (p *printer) isBlank(line int) bool {
+	return line > 0 && line < len(p.blank) && p.blank[line]
+}
+
+// line starts the output line of what starts on line of the source,
+// printing the comments before it
+func (p *printer) line(line int) {
+	p.flush(line)
+	if !p.atStart && p.isBlank(line-1) {
+		p.newline()
+	}
+	p.writeIndent()
+	p.atStart = false
+}
+
+// flush prints the comments that come before line of the source. It is
+// called at the start of an output line.
+func (p *printer) flush(line int) {
+	for len(p.comments) > 0 && p.comments[0].Line < line {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if c.Trailing && !p.lastComment && p.out.Len() > 0 {
+			p.out.Truncate(p.out.Len() - 1)
+			p.out.WriteString(" " + c.Text)
+			p.newline()
+			continue
+		}
+
+		if !p.atStart && p.isBlank(c.Line-1) {
+			p.newline()
+		}
+		p.writeIndent()
+		p.out.WriteString(c.Text)
+		p.newline()
+		p.atStart = false
+		p.lastComment = true
+	}
+}
+
+func (p *printer) commentsBefore(line int) bool {
+	return len(p.comments) > 0 && p.comments[0].Line < line
+}
+
+// open starts the lines inside a bracket just written
+func (p *printer) open() {
+	p.newline()
+	p.indent++
+	p.atStart = true
+}
+
+// close ends the lines inside a bracket, printing the comments before the
+// source line of the closing one, and starts the line the closing bracket
+// goes on
+func (p *printer) close(line int) {
+	p.flush(line)
+	p.indent--
+	p.writeIndent()
+	p.atStart = false
+}
+
+func (p *printer) statements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		p.line(startLine(stmt))
+		p.statement(stmt)
+		if i+1 < len(stmts) && p.needsSemicolon(stmts[i+1]) {
+			p.write(";")
+		}
+		p.newline()
+	}
+}
+
+// needsSemicolon reports whether the statement before next needs a
+// semicolon to end it, because next starts with what would otherwise
+// continue it, as ( and [ continue an expression with a call or index
+func (p *printer) needsSemicolon(next ast.Statement) bool {
+	scratch := &printer{opts: p.opts}
+	scratch.statement(next)
+
+	switch scratch.out.String()[0] {
+	case '(', '[', '-':
+		return true
+	}
+	return false
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	p.write("{")
+	if len(block.Statements) > 0 || p.commentsBefore(block.Rbrace.Line) {
+		p.open()
+		p.statements(block.Statements)
+		p.close(block.Rbrace.Line)
+	}
+	p.write("}")
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.decorators(stmt.Decorators, stmt.Token.Line)
+		p.let(stmt)
+	case *ast.ExportStatement:
+		p.decorators(stmt.Statement.Decorators, stmt.Token.Line)
+		p.write(stmt.Token.Literal + " ")
+		p.let(stmt.Statement)
+	case *ast.ReturnStatement:
+		p.write(stmt.Token.Literal + " ")
+		p.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression)
+	case *ast.ImportStatement:
+		if len(stmt.Names) > 0 {
+			p.write(lexer.FROM + " " + quote(stmt.Path.Value) + " " + lexer.IMPORT + " ")
+			p.identifiers(stmt.Names)
+		} else {
+			p.write(lexer.IMPORT + " " + quote(stmt.Path.Value))
+			if stmt.Alias != nil {
+				p.write(" " + lexer.AS + " " + stmt.Alias.Value)
+			}
+		}
+	case *ast.WithStatement:
+		p.write(stmt.Token.Literal + " ")
+		p.expression(stmt.Context)
+		if stmt.Name != nil {
+			p.write(" " + lexer.AS + " " + stmt.Name.Value)
+		}
+		p.write(" ")
+		p.block(stmt.Body)
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+// decorators writes each decorator on a line of its own, starting the line
+// of the declaration, on line of the source, after them
+func (p *printer) decorators(decorators []ast.Expression, line int) {
+	for i, d := range decorators {
+		if i > 0 {
+			p.line(firstLine(d))
+		}
+		p.write("@")
+		p.expression(d)
+		p.newline()
+	}
+	if len(decorators) > 0 {
+		p.line(line)
+	}
+}
+
+func (p *printer) let(let *ast.LetStatement) {
+	if fn, ok := let.Value.(*ast.FunctionLiteral); ok && let.IsDeclaration {
+		p.function(fn, let.Name.Value)
+		return
+	}
+	p.write(let.Token.Literal + " " + let.Name.Value + " = ")
+	p.expression(let.Value)
+}
+
+func (p *printer) identifiers(ids []*ast.Identifier) {
+	for i, id := range ids {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.write(id.Value)
+	}
+}
+
+func (p *printer) expressions(exps []ast.Expression) {
+	for i, exp := range exps {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(exp)
+	}
+}
+
+// precedences of the infix operators, as the parser has them
+var precedences = map[string]int{
+	lexer.EQ: 1, lexer.NOT_EQ: 1,
+	lexer.LT: 2, lexer.GT: 2, lexer.LT_EQ: 2, lexer.GT_EQ: 2,
+	lexer.PLUS: 3, lexer.MINUS: 3,
+	lexer.ASTERISK: 4, lexer.SLASH: 4,
+}
+
+func (p *printer) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		p.write(p.digits(exp.Token.Literal))
+	case *ast.StringLiteral:
+		p.write(quote(exp.Value))
+	case *ast.Boolean:
+		p.write(exp.Token.Literal)
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		p.operand(exp.Right, false)
+	case *ast.AwaitExpression:
+		p.write(exp.Token.Literal + " ")
+		p.operand(exp.Value, false)
+	case *ast.InfixExpression:
+		precedence := precedences[exp.Operator]
+		p.side(exp.Left, precedence, false)
+		p.write(" " + exp.Operator + " ")
+		p.side(exp.Right, precedence, true)
+	case *ast.IfExpression:
+		p.write(exp.Token.Literal + " (")
+		p.expression(exp.Condition)
+		p.write(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			// Comments after the } go before अन्यथा, on its line
+			if p.commentsBefore(exp.Alternative.Token.Line) {
+				p.newline()
+				p.flush(exp.Alternative.Token.Line)
+				p.writeIndent()
+				p.write(lexer.ELSE + " ")
+			} else {
+				p.write(" " + lexer.ELSE + " ")
+			}
+			p.block(exp.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.function(exp, "")
+	case *ast.CallExpression:
+		p.operand(exp.Function, true)
+		p.write("(")
+		p.list(exp.Arguments, exp.Rparen.Line, false)
+		p.write(")")
+	case *ast.KeywordArgument:
+		p.write(exp.Name.Value + " = ")
+		p.expression(exp.Value)
+	case *ast.SpreadArgument:
+		if exp.Keywords {
+			p.write("**")
+		} else {
+			p.write("*")
+		}
+		p.expression(exp.Value)
+	case *ast.IndexExpression:
+		p.operand(exp.Left, true)
+		p.write("[")
+		p.expression(exp.Index)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.operand(exp.Left, true)
+		p.write("[")
+		p.optional(exp.Start)
+		p.write(":")
+		p.optional(exp.End)
+		if exp.Step != nil {
+			p.write(":")
+			p.expression(exp.Step)
+		}
+		p.write("]")
+	case *ast.MemberExpression:
+		p.operand(exp.Object, true)
+		p.write("." + exp.Property.Value)
+	case *ast.ArrayLiteral:
+		p.array(exp)
+	case *ast.HashLiteral:
+		p.hash(exp)
+	case *ast.SetLiteral:
+		p.write("{")
+		p.list(exp.Elements, exp.Rbrace.Line, false)
+		p.write("}")
+	case *ast.ListComprehension:
+		p.write("[")
+		p.expression(exp.Element)
+		p.clauses(exp.Clauses)
+		p.write("]")
+	case *ast.SetComprehension:
+		p.write("{")
+		p.expression(exp.Element)
+		p.clauses(exp.Clauses)
+		p.write("}")
+	case *ast.HashComprehension:
+		p.write("{")
+		p.expression(exp.Key)
+		p.write(": ")
+		p.expression(exp.Value)
+		p.clauses(exp.Clauses)
+		p.write("}")
+	case *ast.MatchExpression:
+		p.match(exp)
+	}
+}
+
+func (p *printer) optional(exp ast.Expression) {
+	if exp != nil {
+		p.expression(exp)
+	}
+}
+
+// side writes an operand of an infix operator of the given precedence,
+// which groups to the left
+func (p *printer) side(exp ast.Expression, precedence int, right bool) {
+	if in, ok := exp.(*ast.InfixExpression); ok {
+		inner := precedences[in.Operator]
+		if inner < precedence || (right && inner == precedence) {
+			p.parenthesized(exp)
+			return
+		}
+	}
+	p.expression(exp)
+}
+
+// operand writes the operand of a prefix operator, or, if postfix, what a
+// call, index or member expression applies to
+func (p *printer) operand(exp ast.Expression, postfix bool) {
+	switch exp.(type) {
+	case *ast.InfixExpression:
+		p.parenthesized(exp)
+	case *ast.PrefixExpression, *ast.AwaitExpression:
+		if postfix {
+			p.parenthesized(exp)
+		} else {
+			p.expression(exp)
+		}
+	default:
+		p.expression(exp)
+	}
+}
+
+func (p *printer) parenthesized(exp ast.Expression) {
+	p.write("(")
+	p.expression(exp)
+	p.write(")")
+}
+
+// function writes a function literal, or with a name, a declaration
+func (p *printer) function(fn *ast.FunctionLiteral, name string) {
+	if fn.IsAsync {
+		p.write(lexer.ASYNC + " ")
+	}
+	p.write(fn.Token.Literal)
+	if name != "" {
+		p.write(" " + name)
+	}
+	p.write("(")
+	for i, param := range fn.Parameters {
+		if i > 0 {
+			p.write(", ")
+		}
+		switch {
+		case param.Rest:
+			p.write("*" + param.Name.Value)
+		case param.KeywordRest:
+			p.write("**" + param.Name.Value)
+		case param.Default != nil:
+			p.write(param.Name.Value + " = ")
+			p.expression(param.Default)
+		default:
+			p.write(param.Name.Value)
+		}
+	}
+	p.write(") ")
+	p.block(fn.Body)
+}
+
+func (p *printer) array(array *ast.ArrayLiteral) {
+	multiline := len(array.Elements) > 0 && firstLine(array.Elements[0]) > array.Token.Line
+
+	p.write("[")
+	p.list(array.Elements, array.Rbracket.Line, multiline)
+	p.write("]")
+}
+
+// list writes the elements of a literal or the arguments of a call, whose
+// closing bracket is on line end of the source, between the brackets. They
+// go an element to a line if multiline, or if comments among them would
+// move on one.
+func (p *printer) list(exps []ast.Expression, end int, multiline bool) {
+	if !multiline && !p.commentsAmong(exps, end) {
+		p.expressions(exps)
+		return
+	}
+
+	p.open()
+	for i, el := range exps {
+		p.line(firstLine(el))
+		p.expression(el)
+		if i+1 < len(exps) {
+			p.write(",")
+		}
+		p.newline()
+	}
+	p.close(end)
+}
+
+// commentsAmong reports whether comments come before any of exps or
+// between the last and line end, other than those in their blocks, which
+// are written with the blocks wherever they go
+func (p *printer) commentsAmong(exps []ast.Expression, end int) bool {
+	scratch := &printer{opts: p.opts, comments: p.comments}
+	for _, exp := range exps {
+		if scratch.commentsBefore(firstLine(exp)) {
+			return true
+		}
+		scratch.expression(exp)
+	}
+	return scratch.commentsBefore(end)
+}
+
+func (p *printer) hash(hash *ast.HashLiteral) {
+	multiline := len(hash.Pairs) > 0 && firstLine(hash.Pairs[0].Key) > hash.Token.Line
+	if !multiline {
+		var exps []ast.Expression
+		for _, pair := range hash.Pairs {
+			exps = append(exps, pair.Key, pair.Value)
+		}
+		multiline = p.commentsAmong(exps, hash.Rbrace.Line)
+	}
+
+	p.write("{")
+	if multiline {
+		p.open()
+	}
+	for i, pair := range hash.Pairs {
+		if multiline {
+			p.line(firstLine(pair.Key))
+		} else if i > 0 {
+			p.write(", ")
+		}
+		p.expression(pair.Key)
+		p.write(": ")
+		p.expression(pair.Value)
+		if multiline {
+			if i+1 < len(hash.Pairs) {
+				p.write(",")
+			}
+			p.newline()
+		}
+	}
+	if multiline {
+		p.close(hash.Rbrace.Line)
+	}
+	p.write("}")
+}
+
+func (p *printer) clauses(clauses []*ast.ComprehensionClause) {
+	for _, c := range clauses {
+		p.write(" " + c.Token.Literal + " ")
+		p.identifiers(c.Names)
+		p.write(" " + lexer.IN + " ")
+		p.expression(c.Iterable)
+		for _, f := range c.Filters {
+			p.write(" " + lexer.IF + " ")
+			p.expression(f)
+		}
+	}
+}
+
+func (p *printer) match(match *ast.MatchExpression) {
+	p.write(match.Token.Literal + " ")
+	p.expression(match.Subject)
+	p.write(" {")
+	if len(match.Cases) == 0 && !p.commentsBefore(match.Rbrace.Line) {
+		p.write("}")
+		return
+	}
+
+	p.open()
+	for _, c := range match.Cases {
+		p.line(c.Token.Line)
+		p.write(c.Token.Literal + " ")
+		p.pattern(c.Pattern)
+		if c.Guard != nil {
+			p.write(" " + lexer.IF + " ")
+			p.expression(c.Guard)
+		}
+		p.write(" ")
+		p.block(c.Body)
+		p.newline()
+	}
+	p.close(match.Rbrace.Line)
+	p.write("}")
+}
+
+func (p *printer) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		p.expression(pattern.Value)
+	case *ast.BindingPattern:
+		p.write(pattern.Name.Value)
+	case *ast.ArrayPattern:
+		p.write("[")
+		parts := 0
+		for _, el := range pattern.Before {
+			p.separate(&parts)
+			p.pattern(el)
+		}
+		if pattern.Rest != nil {
+			p.separate(&parts)
+			p.write("*" + pattern.Rest.Value)
+		}
+		for _, el := range pattern.After {
+			p.separate(&parts)
+			p.pattern(el)
+		}
+		p.write("]")
+	case *ast.HashPattern:
+		p.write("{")
+		parts := 0
+		for i, key := range pattern.Keys {
+			p.separate(&parts)
+			p.expression(key)
+			p.write(": ")
+			p.pattern(pattern.Values[i])
+		}
+		if pattern.Rest != nil {
+			p.separate(&parts)
+			p.write("**" + pattern.Rest.Value)
+		}
+		p.write("}")
+	}
+}
+
+// separate writes the comma before each part of a list after the first,
+// counting them in parts
+func (p *printer) separate(parts *int) {
+	if *parts > 0 {
+		p.write(", ")
+	}
+	*parts++
+}
+
+// digits writes the digits of an integer literal as opts ask
+func (p *printer) digits(literal string) string {
+	out := []rune(literal)
+	for i, ch := range out {
+		switch {
+		case p.opts.Digits == DevanagariDigits && '0' <= ch && ch <= '9':
+			out[i] = '०' + (ch - '0')
+		case p.opts.Digits == ASCIIDigits && '०' <= ch && ch <= '९':
+			out[i] = '0' + (ch - '०')
+		}
+	}
+	return string(out)
+}
+
+// quote quotes a string with ", or with ' if it holds a "
+func quote(s string) string {
+	if strings.Contains(s, `"`) {
+		return "'" + s + "'"
+	}
+	return `"` + s + `"`
+}
+
+// startLine returns the line stmt starts on, before its decorators
+func startLine(stmt ast.Statement) int {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if len(stmt.Decorators) > 0 {
+			return firstLine(stmt.Decorators[0])
+		}
+	case *ast.ExportStatement:
+		if len(stmt.Statement.Decorators) > 0 {
+			return firstLine(stmt.Statement.Decorators[0])
+		}
+	}
+	return firstToken(stmt).Line
+}
+
+// firstLine returns the line exp starts on
+func firstLine(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return firstLine(exp.Left)
+	case *ast.CallExpression:
+		return firstLine(exp.Function)
+	case *ast.IndexExpression:
+		return firstLine(exp.Left)
+	case *ast.SliceExpression:
+		return firstLine(exp.Left)
+	case *ast.MemberExpression:
+		return firstLine(exp.Object)
+	}
+	return firstToken(exp).Line
+}
+
+// firstToken returns the token a node keeps, which for nodes that do not
+// start with an operand is the one they start with
+func firstToken(node ast.Node) lexer.Token {
+	switch node := node.(type) {
+	case *ast.LetStatement:
+		return node.Token
+	case *ast.ExportStatement:
+		return node.Token
+	case *ast.ReturnStatement:
+		return node.Token
+	case *ast.ExpressionStatement:
+		return node.Token
+	case *ast.ImportStatement:
+		return node.Token
+	case *ast.WithStatement:
+		return node.Token
+	case *ast.BlockStatement:
+		return node.Token
+	case *ast.Identifier:
+		return node.Token
+	case *ast.IntegerLiteral:
+		return node.Token
+	case *ast.StringLiteral:
+		return node.Token
+	case *ast.Boolean:
+		return node.Token
+	case *ast.PrefixExpression:
+		return node.Token
+	case *ast.InfixExpression:
+		return node.Token
+	case *ast.IfExpression:
+		return node.Token
+	case *ast.FunctionLiteral:
+		return node.Token
+	case *ast.AwaitExpression:
+		return node.Token
+	case *ast.CallExpression:
+		return node.Token
+	case *ast.KeywordArgument:
+		return node.Token
+	case *ast.SpreadArgument:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	case *ast.SliceExpression:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	case *ast.SetLiteral:
+		return node.Token
+	case *ast.MemberExpression:
+		return node.Token
+	case *ast.ListComprehension:
+		return node.Token
+	case *ast.SetComprehension:
+		return node.Token
+	case *ast.HashComprehension:
+		return node.Token
+	case *ast.MatchExpression:
+		return node.Token
+	}
+	return lexer.Token{}
+}
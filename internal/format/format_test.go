@@ -0,0 +1,211 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"लेट   x=१+२*३;x", "लेट x = १ + २ * ३\nx\n"},
+		// Parentheses are kept only where grouping needs them
+		{"((१ + २)) * (३ * ४) - (५ - ६)", "(१ + २) * (३ * ४) - (५ - ६)\n"},
+		{"-(a + b); !सत्य; (-a).b; (a + b)(c)", "-(a + b)\n!सत्य;\n(-a).b;\n(a + b)(c)\n"},
+		// A semicolon stays where the next line would continue the statement
+		{"लेट f = g; (f)(१); [१]; -२", "लेट f = g\nf(१);\n[१];\n-२\n"},
+		{`'क' + 'ख"ग' + "घ"`, "\"क\" + 'ख\"ग' + \"घ\"\n"},
+		{
+			"फन  जोड(a,b=१,*c,**d){प्रतिफल a+b}",
+			"फन जोड(a, b = १, *c, **d) {\n    प्रतिफल a + b\n}\n",
+		},
+		{
+			"लेट f = एसिन्क फन(x) { पर्ख x }; f(१, *z, y = २, **w)",
+			"लेट f = एसिन्क फन(x) {\n    पर्ख x\n}\nf(१, *z, y = २, **w)\n",
+		},
+		{
+			"यदि (x > १) { १ } अन्यथा { यदि x { २ } }",
+			"यदि (x > १) {\n    १\n} अन्यथा {\n    यदि (x) {\n        २\n    }\n}\n",
+		},
+		{"फन f() {}", "फन f() {}\n"},
+		{
+			"@स्मरण\n@लग(१) निर्यात फन f(n) { n }",
+			"@स्मरण\n@लग(१)\nनिर्यात फन f(n) {\n    n\n}\n",
+		},
+		{
+			"आयात 'गणित' जस्तो ग; बाट \"सूची\" आयात a,b\nसँग खोल(\"क\") जस्तो फ { फ }",
+			"आयात \"गणित\" जस्तो ग\nबाट \"सूची\" आयात a, b\nसँग खोल(\"क\") जस्तो फ {\n    फ\n}\n",
+		},
+		{
+			"a[१:]; a[:२:३]; a[::२]; [x*x लागि x मा a यदि x>१]; {x:१ लागि x, y मा h}; {१,२}; {x लागि x मा a}",
+			"a[१:]\na[:२:३]\na[::२];\n[x * x लागि x मा a यदि x > १]\n{x: १ लागि x, y मा h}\n{१, २}\n{x लागि x मा a}\n",
+		},
+		{
+			"मिलान x { अवस्था [a, *b, c] यदि a>१ { b } अवस्था {\"क\": -१, **r} { r } अवस्था _ {} }",
+			"मिलान x {\n    अवस्था [a, *b, c] यदि a > १ {\n        b\n    }\n    अवस्था {\"क\": -१, **r} {\n        r\n    }\n    अवस्था _ {}\n}\n",
+		},
+		// Literals broken after their opening bracket keep an element to a line
+		{
+			"लेट h = {\"क\": १, \"ख\": [२,\n ३]}; लेट g = {\n\"क\": १, \"ख\": [\n२, ३]}",
+			"लेट h = {\"क\": १, \"ख\": [२, ३]}\nलेट g = {\n    \"क\": १,\n    \"ख\": [\n        २,\n        ३\n    ]\n}\n",
+		},
+		// Blank lines between statements are kept, one at most
+		{"\n\nx\n\n\n\ny\nz\n\n", "x\n\ny\nz\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := Source(tt.input, Options{})
+		if err != nil {
+			t.Errorf("%q - unexpected error: %s", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%q - wrong layout.\nexpected:\n%s\ngot:\n%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"# शीर्षक\n\n# बारे\nx  # x हो\n# अन्त्य", "# शीर्षक\n\n# बारे\nx # x हो\n# अन्त्य\n"},
+		{
+			"फन f() { # f ले\n  # पहिले\n  x\n\n  # पछि\n} # f को अन्त्य\ny",
+			"फन f() { # f ले\n    # पहिले\n    x\n\n    # पछि\n} # f को अन्त्य\ny\n",
+		},
+		{"फन f() {\n# केही छैन\n}", "फन f() {\n    # केही छैन\n}\n"},
+		// Comments inside an expression written on one line move after it,
+		// but keep calls and literals an element to a line
+		{"लेट x = १ + # एक\n२\ny", "लेट x = १ + २ # एक\ny\n"},
+		{"लेट x = f(१, # एक\n२)\ny", "लेट x = f(\n    १, # एक\n    २\n)\ny\n"},
+		{"प्रिन्ट(1, # first arg\n 2)", "प्रिन्ट(\n    1, # first arg\n    2\n)\n"},
+		{"[\n 1, # one\n 2  # two\n]\nx", "[\n    1, # one\n    2 # two\n]\nx\n"},
+		{"f([1, 2], # सूची\n फन() {\n # भित्र\n x\n })", "f(\n    [1, 2], # सूची\n    फन() {\n        # भित्र\n        x\n    }\n)\n"},
+		{"f(फन() {\n # भित्र\n x\n })", "f(फन() {\n    # भित्र\n    x\n})\n"},
+		{"{१, # एक\n २}", "{\n    १, # एक\n    २\n}\n"},
+		{"लेट h = {\"क\": १, # क\n \"ख\": २}", "लेट h = {\n    \"क\": १, # क\n    \"ख\": २\n}\n"},
+		{"f( # कुनै छैन\n)", "f( # कुनै छैन\n)\n"},
+		{"लेट h = { # विकल्प\n  \"क\": १, # क\n  # ख\n  \"ख\": २\n}", "लेट h = { # विकल्प\n    \"क\": १, # क\n    # ख\n    \"ख\": २\n}\n"},
+		{
+			"मिलान x {\n  # शून्य\n  अवस्था ० { १ }\n  # अरू\n}",
+			"मिलान x {\n    # शून्य\n    अवस्था ० {\n        १\n    }\n    # अरू\n}\n",
+		},
+		{"a # क\n\n# ख", "a # क\n\n# ख\n"},
+		{
+			"यदि (x) { # c1\n  a\n} # c2\nअन्यथा { # c3\n  b\n} # c4",
+			"यदि (x) { # c1\n    a\n} # c2\nअन्यथा { # c3\n    b\n} # c4\n",
+		},
+		{
+			"फन f() {\n  यदि (x) { a }\n  # c2\n  अन्यथा { b }\n}",
+			"फन f() {\n    यदि (x) {\n        a\n    }\n    # c2\n    अन्यथा {\n        b\n    }\n}\n",
+		},
+		{"यदि (x) { a } अन्यथा { # c3\n b }", "यदि (x) {\n    a\n} अन्यथा { # c3\n    b\n}\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := Source(tt.input, Options{})
+		if err != nil {
+			t.Errorf("%q - unexpected error: %s", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%q - wrong layout.\nexpected:\n%s\ngot:\n%s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDigits(t *testing.T) {
+	input := "लेट x१ = 12 + ३४ # 5६\nमिलान x१ { अवस्था -7 { ०8 } }"
+
+	tests := []struct {
+		digits   Digits
+		expected string
+	}{
+		{KeepDigits, "लेट x१ = 12 + ३४ # 5६\nमिलान x१ {\n    अवस्था -7 {\n        ०8\n    }\n}\n"},
+		{DevanagariDigits, "लेट x१ = १२ + ३४ # 5६\nमिलान x१ {\n    अवस्था -७ {\n        ०८\n    }\n}\n"},
+		{ASCIIDigits, "लेट x१ = 12 + 34 # 5६\nमिलान x१ {\n    अवस्था -7 {\n        08\n    }\n}\n"},
+	}
+
+	for _, tt := range tests {
+		got, err := Source(input, Options{Digits: tt.digits})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got != tt.expected {
+			t.Errorf("digits %d - wrong layout.\nexpected:\n%s\ngot:\n%s", tt.digits, tt.expected, got)
+		}
+	}
+}
+
+// TestIdempotent checks that formatted source formats to itself
+func TestIdempotent(t *testing.T) {
+	inputs := []string{
+		"लेट f = फन(x) { यदि (x) { x } अन्यथा { -x } }(१)\n[१]",
+		"यदि (x) { a } # c2\n# c3\nअन्यथा { # c4\n b }",
+		"लेट x = f(१, # एक\n२ # दुई\n)\n# तीन\n\n\ny",
+		"लेट x = [ # सूची\n  १, # एक\n\n  २\n  # अन्त्य\n] # पछि",
+		"फन f() { # f\n}\n\n\n# बीच\n\n\nफन g() { १ } # g",
+		"# केवल टिप्पणी",
+		"",
+		"यदि (a) {\n x\n}\n# अन्यथा अघि\nअन्यथा { y } # पछि",
+		"लेट s = \"धेरै\n\nलाइन\"\nx",
+		"@d # सजावट\nफन f() {\n  १\n\n} # f",
+	}
+
+	for _, input := range inputs {
+		once, err := Source(input, Options{})
+		if err != nil {
+			t.Errorf("%q - unexpected error: %s", input, err)
+			continue
+		}
+		twice, err := Source(once, Options{})
+		if err != nil {
+			t.Errorf("%q - formatted source does not parse: %s\n%s", input, err, once)
+			continue
+		}
+		if once != twice {
+			t.Errorf("%q - not idempotent.\nonce:\n%s\ntwice:\n%s", input, once, twice)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Source("लेट = १", Options{})
+	if _, ok := err.(ParseErrors); !ok {
+		t.Errorf("expected ParseErrors, got=%v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	b := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+
+	expected := strings.Join([]string{
+		"--- क.nep",
+		"+++ क.nep (formatted)",
+		"@@ -1,5 +1,5 @@",
+		" a",
+		"-b",
+		"+B",
+		" c",
+		" d",
+		" e",
+		"@@ -10,3 +10,4 @@",
+		" j",
+		" k",
+		" l",
+		"+m",
+		"",
+	}, "\n")
+
+	if got := Diff("क.nep", a, b); got != expected {
+		t.Errorf("wrong diff.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+	if got := Diff("क.nep", a, a); got != "" {
+		t.Errorf("expected no diff for the same text, got:\n%s", got)
+	}
+}
@@ -0,0 +1,148 @@
+// Package grapheme splits strings into user-perceived characters
+//
+// A grapheme cluster is what a reader counts as one letter: a base character
+// together with the vowel signs and marks written on it, so के is one
+// character, not two. Conjuncts joined by a virama (halant) also stay
+// together, so क्ष and त्रि are one character each. The rules follow the
+// extended grapheme clusters of Unicode UAX #29, including the Indic conjunct
+// rule, except that Hangul jamo sequences are not combined.
+package grapheme
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	zwnj = '\u200C'
+	zwj  = '\u200D'
+)
+
+// indicScripts are the scripts whose consonants join across a virama
+var indicScripts = []*unicode.RangeTable{
+	unicode.Devanagari,
+	unicode.Bengali,
+	unicode.Gujarati,
+	unicode.Oriya,
+	unicode.Telugu,
+	unicode.Malayalam,
+}
+
+// viramas are the linkers that join two consonants into a conjunct
+var viramas = map[rune]bool{
+	'्': true, // Devanagari
+	'্': true, // Bengali
+	'્': true, // Gujarati
+	'୍': true, // Oriya
+	'్': true, // Telugu
+	'്': true, // Malayalam
+}
+
+// Next returns the length in bytes of the first grapheme cluster in s
+func Next(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	r, i := utf8.DecodeRuneInString(s)
+	if r == '\r' && i < len(s) && s[i] == '\n' {
+		return i + 1
+	}
+	if unicode.IsControl(r) {
+		return i
+	}
+
+	script := indicScript(r) // script of the consonant that can take a conjunct
+	linked := false          // a virama is waiting for the next consonant
+	regional := isRegional(r)
+	prev := r
+
+	for i < len(s) {
+		r, w := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case isExtend(r):
+			if viramas[r] && script != nil {
+				linked = true
+			}
+		case linked && unicode.Is(script, r) && unicode.IsLetter(r):
+			linked = false
+		case prev == zwj && unicode.Is(unicode.So, r):
+			// emoji joined by a zero-width joiner, such as family emoji
+		case regional && isRegional(r):
+			// a pair of regional indicators forms one flag
+			regional = false
+		default:
+			return i
+		}
+
+		prev = r
+		i += w
+	}
+
+	return i
+}
+
+// Split returns the grapheme clusters of s in order
+func Split(s string) []string {
+	clusters := []string{}
+	for s != "" {
+		n := Next(s)
+		clusters = append(clusters, s[:n])
+		s = s[n:]
+	}
+	return clusters
+}
+
+// Count returns the number of grapheme clusters in s
+func Count(s string) int {
+	n := 0
+	for s != "" {
+		s = s[Next(s):]
+		n++
+	}
+	return n
+}
+
+// Slice returns the grapheme clusters of s from start up to but not
+// including end. The bounds must lie within s.
+func Slice(s string, start, end int) string {
+	from := 0
+	for i := 0; i < start; i++ {
+		from += Next(s[from:])
+	}
+	to := from
+	for i := start; i < end; i++ {
+		to += Next(s[to:])
+	}
+	return s[from:to]
+}
+
+// isExtend reports whether r attaches to the character before it
+func isExtend(r rune) bool {
+	switch {
+	case unicode.Is(unicode.M, r):
+		return true
+	case r == zwj || r == zwnj:
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji skin tone modifiers
+		return true
+	}
+	return false
+}
+
+func isRegional(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+func indicScript(r rune) *unicode.RangeTable {
+	if !unicode.IsLetter(r) {
+		return nil
+	}
+	for _, script := range indicScripts {
+		if unicode.Is(script, r) {
+			return script
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package grapheme
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"abc", []string{"a", "b", "c"}},
+		{"नेपाल", []string{"ने", "पा", "ल"}},
+		{"क्षत्रिय", []string{"क्ष", "त्रि", "य"}},
+		{"कं", []string{"कं"}},
+		{"क्‍ष", []string{"क्‍ष"}},     // half form with a zero-width joiner
+		{"अर्थ", []string{"अ", "र्थ"}}, // reph
+		{"विद्यालय", []string{"वि", "द्या", "ल", "य"}},
+		{"é", []string{"é"}},
+		{"a\r\nb", []string{"a", "\r\n", "b"}},
+		{"👍🏽!", []string{"👍🏽", "!"}},
+		{"👨‍👩‍👧", []string{"👨‍👩‍👧"}},
+		{"🇳🇵🇮🇳", []string{"🇳🇵", "🇮🇳"}},
+	}
+
+	for _, tt := range tests {
+		got := Split(tt.input)
+		if strings.Join(got, "|") != strings.Join(tt.expected, "|") || len(got) != len(tt.expected) {
+			t.Errorf("Split(%q) wrong. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+		if n := Count(tt.input); n != len(tt.expected) {
+			t.Errorf("Count(%q) wrong. want=%d, got=%d", tt.input, len(tt.expected), n)
+		}
+	}
+}
+
+func TestSplitDanglingVirama(t *testing.T) {
+	// a virama at the end of a word, or before a non-letter, ends the cluster
+	expected := []string{"क्", " ", "ख"}
+	if got := Split("क् ख"); strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("wrong clusters. want=%q, got=%q", expected, got)
+	}
+}
+
+func TestSlice(t *testing.T) {
+	tests := []struct {
+		start, end int
+		expected   string
+	}{
+		{0, 1, "ने"},
+		{1, 3, "पाल"},
+		{2, 2, ""},
+		{0, 3, "नेपाल"},
+	}
+
+	for _, tt := range tests {
+		if got := Slice("नेपाल", tt.start, tt.end); got != tt.expected {
+			t.Errorf("Slice(नेपाल, %d, %d) wrong. want=%q, got=%q", tt.start, tt.end, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package imports finds the files Nepali imports refer to, for every engine
+package imports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SourceExt is the file extension of Nepali source files
+const SourceExt = ".nep"
+
+// Resolve finds the file an import path refers to. Paths starting with ./
+// or ../ are relative to the importing file; anything else is looked up on
+// the search path and then next to the importing file.
+func Resolve(importPath, from string, searchPath []string) (string, bool) {
+	if filepath.Ext(importPath) == "" {
+		importPath += SourceExt
+	}
+
+	dir := "."
+	if from != "" {
+		dir = filepath.Dir(from)
+	}
+
+	var candidates []string
+	switch {
+	case filepath.IsAbs(importPath):
+		candidates = []string{importPath}
+	case strings.HasPrefix(importPath, "./") || strings.HasPrefix(importPath, "../"):
+		candidates = []string{filepath.Join(dir, importPath)}
+	default:
+		for _, p := range searchPath {
+			candidates = append(candidates, filepath.Join(p, importPath))
+		}
+		candidates = append(candidates, filepath.Join(dir, importPath))
+	}
+
+	for _, c := range candidates {
+		if info, err := os.Stat(c); err == nil && !info.IsDir() {
+			if abs, err := filepath.Abs(c); err == nil {
+				return abs, true
+			}
+			return c, true
+		}
+	}
+
+	return "", false
+}
+
+// Name returns the name a module is bound to when imported whole: the base
+// name of its file
+func Name(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+}
+
+// Cycle reports whether importing path from the innermost of the files
+// being loaded, outermost first, closes a cycle, returning the cycle's
+// file names
+func Cycle(loading []string, path string) (string, bool) {
+	for i, l := range loading {
+		if l == path {
+			cycle := append(append([]string{}, loading[i:]...), path)
+			for j := range cycle {
+				cycle[j] = filepath.Base(cycle[j])
+			}
+			return strings.Join(cycle, " -> "), true
+		}
+	}
+	return "", false
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/builtins"
 	"github.com/SunilNeupane77/nepali/internal/object"
 )
 
@@ -42,8 +43,8 @@ func (i *interpreter) Eval(node ast.Node, env *object.Environment) object.Object
 		params := i.evalFunctionParameters(node.Parameters)
 		return &object.Function{
 			Parameters: params,
-			Body:      node.Body,
-			Env:       env,
+			Body:       node.Body,
+			Env:        env,
 		}
 	case *ast.CallExpression:
 		return i.evalCallExpression(node, env)
@@ -101,7 +102,7 @@ func (i *interpreter) evalIdentifier(node *ast.Identifier, env *object.Environme
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := builtins.Lookup(node.Value); ok {
 		return builtin
 	}
 
@@ -120,7 +121,7 @@ func (i *interpreter) evalPrefixExpression(node *ast.PrefixExpression, env *obje
 	case "-":
 		return i.evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: " + node.Operator + right.Type())
+		return newError("unknown operator: %s%s", node.Operator, right.Type())
 	}
 }
 
@@ -143,7 +144,7 @@ func (i *interpreter) evalInfixExpression(node *ast.InfixExpression, env *object
 	case node.Operator == "!=":
 		return i.nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: " + left.Type() + " " + node.Operator + " " + right.Type())
+		return newError("type mismatch: %s %s %s", left.Type(), node.Operator, right.Type())
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return i.evalStringInfixExpression(node, left, right)
 	case left.Type() == object.ARRAY_OBJ && node.Operator == "==":
@@ -155,7 +156,7 @@ func (i *interpreter) evalInfixExpression(node *ast.InfixExpression, env *object
 	case left.Type() == object.HASH_OBJ && node.Operator == "!=":
 		return i.nativeBoolToBooleanObject(left != right)
 	default:
-		return newError("unknown operator: " + left.Type() + " " + node.Operator + " " + right.Type())
+		return newError("unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 }
 
@@ -165,7 +166,7 @@ func (i *interpreter) evalIfExpression(ie *ast.IfExpression, env *object.Environ
 		return condition
 	}
 
-	if isTruthy(condition) {
+	if i.isTruthy(condition) {
 		return i.Eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
 		return i.Eval(ie.Alternative, env)
@@ -193,11 +194,11 @@ func (i *interpreter) evalBlockStatement(block *ast.BlockStatement, env *object.
 	return result
 }
 
-func (i *interpreter) evalFunctionParameters(params []*ast.Identifier) []*object.Parameter {
+func (i *interpreter) evalFunctionParameters(params []*ast.Parameter) []*object.Parameter {
 	result := make([]*object.Parameter, len(params))
 
 	for i, param := range params {
-		result[i] = &object.Parameter{Name: param.Value}
+		result[i] = &object.Parameter{Name: param.Name.Value}
 	}
 
 	return result
@@ -214,9 +215,23 @@ func (i *interpreter) evalCallExpression(node *ast.CallExpression, env *object.E
 		return args[0]
 	}
 
+	// A call in tail position is made by applyFunction once the calling
+	// function's body has finished
+	if node.Tail {
+		return &tailCall{fn: function, args: args}
+	}
 	return i.applyFunction(function, args)
 }
 
+// tailCall is a call in tail position, made by the function that returns it
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
 func (i *interpreter) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
@@ -254,28 +269,28 @@ func (i *interpreter) evalIndexExpression(node *ast.IndexExpression, env *object
 }
 
 func (i *interpreter) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
-		key := i.Eval(keyNode, env)
+	for _, pair := range node.Pairs {
+		key := i.Eval(pair.Key, env)
 		if isError(key) {
 			return key
 		}
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: " + key.Type())
+			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := i.Eval(valueNode, env)
+		value := i.Eval(pair.Value, env)
 		if isError(value) {
 			return value
 		}
 
-		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func (i *interpreter) evalIntegerInfixExpression(node *ast.InfixExpression, left, right object.Object) object.Object {
@@ -300,13 +315,13 @@ func (i *interpreter) evalIntegerInfixExpression(node *ast.InfixExpression, left
 	case "!=":
 		return i.nativeBoolToBooleanObject(leftVal != rightVal)
 	default:
-		return newError("unknown operator: " + left.Type() + " " + node.Operator + " " + right.Type())
+		return newError("unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 }
 
 func (i *interpreter) evalStringInfixExpression(node *ast.InfixExpression, left, right object.Object) object.Object {
 	if node.Operator != "" {
-		return newError("unknown operator: " + left.Type() + " " + node.Operator + " " + right.Type())
+		return newError("unknown operator: %s %s %s", left.Type(), node.Operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
@@ -329,7 +344,7 @@ func (i *interpreter) evalBangOperatorExpression(right object.Object) object.Obj
 
 func (i *interpreter) evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -" + right.Type())
+		return newError("unknown operator: -%s", right.Type())
 	}
 
 	value := right.(*object.Integer).Value
@@ -357,13 +372,26 @@ func (i *interpreter) isTruthy(obj object.Object) bool {
 func (i *interpreter) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
-		extendedEnv := i.extendFunctionEnv(fn, args)
-		evaluated := i.Eval(fn.Body, extendedEnv)
-		return i.unwrapReturnValue(evaluated)
+		// Tail calls replace the function that made them, so tail
+		// recursion runs in constant space
+		for {
+			extendedEnv := i.extendFunctionEnv(fn, args)
+			evaluated := i.unwrapReturnValue(i.Eval(fn.Body, extendedEnv))
+
+			call, ok := evaluated.(*tailCall)
+			if !ok {
+				return evaluated
+			}
+			next, ok := call.fn.(*object.Function)
+			if !ok {
+				return i.applyFunction(call.fn, call.args)
+			}
+			fn, args = next, call.args
+		}
 	case *object.Builtin:
 		return fn.Fn(args...)
 	default:
-		return newError("not a function: " + fn.Type())
+		return newError("not a function: %s", fn.Type())
 	}
 }
 
@@ -391,7 +419,7 @@ func (i *interpreter) evalIndex(left, index object.Object) object.Object {
 	case left.Type() == object.HASH_OBJ:
 		return i.evalHashIndex(left, index)
 	default:
-		return newError("index operator not supported: " + left.Type())
+		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
@@ -412,15 +440,15 @@ func (i *interpreter) evalHashIndex(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: " + index.Type())
+		return newError("unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return nil
 	}
 
-	return pair.Value
+	return value
 }
 
 func newError(format string, a ...interface{}) *object.Error {
@@ -435,10 +463,10 @@ func isError(obj object.Object) bool {
 }
 
 // TRUE represents the boolean true value
-var TRUE = &object.Boolean{Value: true}
+var TRUE = object.TRUE
 
 // FALSE represents the boolean false value
-var FALSE = &object.Boolean{Value: false}
+var FALSE = object.FALSE
 
 // NULL represents the null value
-var NULL = &object.Null{}
+var NULL = object.NULL
@@ -0,0 +1,33 @@
+package interpreter
+
+import (
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"लेट जोड = फन(n, acc) { यदि (n == ०) { acc } अन्यथा { प्रतिफल जोड(n - १, acc + n) } }; जोड(१०००००, ०)", "5000050000"},
+		{"लेट जोड = फन(n, acc) { यदि (n == ०) { acc } अन्यथा { जोड(n - १, acc + n) } }; जोड(१०००००, ०)", "5000050000"},
+		{"लेट f = फन(n) { यदि (n == ०) { ० } अन्यथा { f(n - १) + १ } }; f(१०)", "10"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors: %v", p.Errors())
+		}
+
+		result := Interpreter.Eval(program, object.NewEnvironment())
+		if result == nil || result.Inspect() != tt.expected {
+			t.Errorf("%s - wrong result. expected=%s, got=%v", tt.input, tt.expected, result)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 // Package lexer implements the lexical analyzer for the Nepali programming language
 package lexer
 
-import "unicode"
-
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
 
 // TokenType represents the type of a token
 type TokenType string
@@ -11,6 +15,8 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // 1-based line the token starts on
+	Column  int // 1-based column, in characters, the token starts at
 }
 
 const (
@@ -31,8 +37,10 @@ const (
 	ASTERISK = "*"
 	SLASH    = "/"
 
-	LT = "<"
-	GT = ">"
+	LT    = "<"
+	GT    = ">"
+	LT_EQ = "<="
+	GT_EQ = ">="
 
 	EQ     = "=="
 	NOT_EQ = "!="
@@ -41,6 +49,8 @@ const (
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
+	AT        = "@"
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -59,18 +69,63 @@ const (
 	RETURN   = "प्रतिफल"
 	VAR      = "संख्या"
 	PRINT    = "लेख्नुहोस्"
+	ASYNC    = "एसिन्क"
+	AWAIT    = "पर्ख"
+	IMPORT   = "आयात"
+	EXPORT   = "निर्यात"
+	FROM     = "बाट"
+	AS       = "जस्तो"
+	FOR      = "लागि"
+	IN       = "मा"
+	WITH     = "सँग"
+	MATCH    = "मिलान"
+	CASE     = "अवस्था"
+	CONST    = "स्थिर"
 )
 
 var keywords = map[string]TokenType{
 	"फन":         FUNCTION,
 	"लेट":        LET,
 	"सत्य":       TRUE,
-	"मिथ्या":      FALSE,
-	"यदि":         IF,
-	"अन्यथा":       ELSE,
-	"प्रतिफल":     RETURN,
+	"मिथ्या":     FALSE,
+	"यदि":        IF,
+	"अन्यथा":     ELSE,
+	"प्रतिफल":    RETURN,
 	"संख्या":     VAR,
 	"लेख्नुहोस्": PRINT,
+	"एसिन्क":     ASYNC,
+	"पर्ख":       AWAIT,
+	"आयात":       IMPORT,
+	"निर्यात":    EXPORT,
+	"बाट":        FROM,
+	"जस्तो":      AS,
+	"लागि":       FOR,
+	"मा":         IN,
+	"सँग":        WITH,
+	"मिलान":      MATCH,
+	"अवस्था":     CASE,
+	"स्थिर":      CONST,
+}
+
+// Keywords returns the language's keywords, sorted. It leaves out
+// लेख्नुहोस्, which stays reserved but no longer begins any statement.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word, tokenType := range keywords {
+		if tokenType != PRINT {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Comment is a `#` comment, which runs to the end of its line. The lexer
+// skips comments, but keeps them for tools that rewrite the source.
+type Comment struct {
+	Text     string // from the # on, without trailing space
+	Line     int
+	Trailing bool // whether it follows a token on its line
 }
 
 // Lexer represents a lexer for the Nepali programming language
@@ -79,23 +134,40 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           rune // current char under examination
+	width        int  // byte width of ch
+	line         int  // line of ch
+	column       int  // column of ch
+
+	comments  []Comment
+	tokenLine int // the line the last token ended on, 0 before the first
 }
 
 // New creates a new Lexer
 func New(input string) *Lexer {
 	l := &Lexer{
 		input: input,
+		line:  1,
 	}
 	l.readChar()
 	return l
 }
 
-// NextToken returns the next token in the input
-func (l *Lexer) NextToken() Token {
-	var tok Token
+// Comments returns the comments skipped so far, in source order
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
 
+// NextToken returns the next token in the input
+func (l *Lexer) NextToken() (tok Token) {
 	l.skipWhitespace()
 
+	line, column := l.line, l.column
+	defer func() {
+		tok.Line = line
+		tok.Column = column
+		l.tokenLine = l.line
+	}()
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -124,15 +196,29 @@ func (l *Lexer) NextToken() Token {
 	case '*':
 		tok = newToken(ASTERISK, l.ch)
 	case '<':
-		tok = newToken(LT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Type: LT_EQ, Literal: "<="}
+		} else {
+			tok = newToken(LT, l.ch)
+		}
 	case '>':
-		tok = newToken(GT, l.ch)
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = Token{Type: GT_EQ, Literal: ">="}
+		} else {
+			tok = newToken(GT, l.ch)
+		}
 	case ';':
 		tok = newToken(SEMICOLON, l.ch)
 	case ',':
 		tok = newToken(COMMA, l.ch)
 	case ':':
 		tok = newToken(COLON, l.ch)
+	case '@':
+		tok = newToken(AT, l.ch)
+	case '.':
+		tok = newToken(DOT, l.ch)
 	case '(':
 		tok = newToken(LPAREN, l.ch)
 	case ')':
@@ -145,18 +231,18 @@ func (l *Lexer) NextToken() Token {
 		tok = newToken(LBRACKET, l.ch)
 	case ']':
 		tok = newToken(RBRACKET, l.ch)
-	case '"':
+	case '"', '\'':
 		tok.Type = STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readString(l.ch)
 	case 0:
 		tok.Literal = ""
 		tok.Type = EOF
 	default:
-		if l.isLetter(l.ch) {
+		if IsLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = lookupIdent(tok.Literal)
 			return tok
-		} else if l.isDigit(l.ch) {
+		} else if IsDigit(l.ch) {
 			tok.Type = INT
 			tok.Literal = l.readNumber()
 			return tok
@@ -169,32 +255,62 @@ func (l *Lexer) NextToken() Token {
 	return tok
 }
 
+// skipWhitespace skips whitespace and comments
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch l.ch {
+		case ' ', '\t', '\n', '\r':
+			l.readChar()
+		case '#':
+			l.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+// skipComment skips a comment, which runs from # to the end of the line,
+// keeping it for the formatter
+func (l *Lexer) skipComment() {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+	l.comments = append(l.comments, Comment{
+		Text:     strings.TrimRightFunc(l.input[position:l.position], unicode.IsSpace),
+		Line:     l.line,
+		Trailing: l.tokenLine == l.line,
+	})
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
+		l.width = 0
 	} else {
-		l.ch = rune(l.input[l.readPosition])
+		// Devanagari is multi-byte, so decode a whole rune at a time
+		l.ch, l.width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += l.width
 }
 
 func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	}
-	return rune(l.input[l.readPosition])
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
 }
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for l.isLetter(l.ch) {
+	for IsLetter(l.ch) || IsDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
@@ -202,33 +318,35 @@ func (l *Lexer) readIdentifier() string {
 
 func (l *Lexer) readNumber() string {
 	position := l.position
-	for l.isDigit(l.ch) {
+	for IsDigit(l.ch) {
 		l.readChar()
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
+// readString reads a string quoted with quote, which is " or '
+func (l *Lexer) readString(quote rune) string {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
+		if l.ch == quote || l.ch == 0 {
 			break
 		}
 	}
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) isLetter(ch rune) bool {
+// IsLetter reports whether ch can start an identifier
+func IsLetter(ch rune) bool {
 	// Check for both English and Nepali letters
-	return unicode.IsLetter(ch) || 
-		('अ' <= ch && ch <= 'ह') || 
-		('ऀ' <= ch && ch <= 'ॐ') || 
-		('०' <= ch && ch <= '९') || 
+	return unicode.IsLetter(ch) ||
+		('अ' <= ch && ch <= 'ह') ||
+		('ऀ' <= ch && ch <= 'ॐ') ||
 		ch == '_'
 }
 
-func (l *Lexer) isDigit(ch rune) bool {
+// IsDigit reports whether ch is a digit, which can continue an identifier
+func IsDigit(ch rune) bool {
 	// Check for both Arabic and Devanagari numerals
 	return ('0' <= ch && ch <= '9') ||
 		('०' <= ch && ch <= '९')
@@ -81,3 +81,49 @@ func TestNepaliKeywords(t *testing.T) {
 		}
 	}
 }
+
+func TestLines(t *testing.T) {
+	input := "लेट x = \"क\nख\";\n\nx"
+
+	expected := []int{1, 1, 1, 1, 2, 4, 4}
+
+	l := New(input)
+	for i, line := range expected {
+		if tok := l.NextToken(); tok.Line != line {
+			t.Errorf("tests[%d] - line of %q wrong. expected=%d, got=%d", i, tok.Literal, line, tok.Line)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "# शीर्षक\nलेट x = '\"क\"' # उद्धृत  \n\n  #अन्त्य"
+
+	expected := []Token{
+		{Type: LET, Literal: "लेट", Line: 2, Column: 1},
+		{Type: IDENT, Literal: "x", Line: 2, Column: 5},
+		{Type: ASSIGN, Literal: "=", Line: 2, Column: 7},
+		{Type: STRING, Literal: "\"क\"", Line: 2, Column: 9},
+		{Type: EOF, Literal: "", Line: 4, Column: 10},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		if tok := l.NextToken(); tok != tt {
+			t.Errorf("tests[%d] - wrong token. expected=%+v, got=%+v", i, tt, tok)
+		}
+	}
+
+	comments := []Comment{
+		{Text: "# शीर्षक", Line: 1},
+		{Text: "# उद्धृत", Line: 2, Trailing: true},
+		{Text: "#अन्त्य", Line: 4},
+	}
+	if got := l.Comments(); len(got) != len(comments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d", len(comments), len(got))
+	}
+	for i, c := range comments {
+		if got := l.Comments()[i]; got != c {
+			t.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, c, got)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package lint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ConfigFile is the name of a project's lint configuration, which applies
+// to the files in its directory and those below
+const ConfigFile = ".nepalilint.json"
+
+// Config enables and disables rules by name, as in
+//
+//	{"rules": {"unused-parameter": false}}
+//
+// Rules it does not name are enabled.
+type Config struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// Enabled reports whether config leaves rule enabled
+func (c Config) Enabled(rule string) bool {
+	enabled, ok := c.Rules[rule]
+	return !ok || enabled
+}
+
+// ReadConfig reads the configuration at path
+func ReadConfig(path string) (Config, error) {
+	var config Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	for name := range config.Rules {
+		if !isRule(name) {
+			return config, fmt.Errorf("%s: unknown rule %s", path, name)
+		}
+	}
+	return config, nil
+}
+
+// FindConfig reads the ConfigFile in dir or the nearest directory above it,
+// returning an empty Config, which enables every rule, if there is none
+func FindConfig(dir string) (Config, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return Config{}, err
+	}
+
+	for {
+		config, err := ReadConfig(filepath.Join(dir, ConfigFile))
+		if !errors.Is(err, os.ErrNotExist) {
+			return config, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return Config{}, nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,116 @@
+package lint
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+)
+
+// Comments can turn rules off for part of a file:
+//
+//	# lint:disable shadow, arity   turns the rules off from its line on
+//	# lint:enable shadow           turns them back on after its line
+//	# lint:ignore unused-variable  turns them off for its own line, after
+//	                               code, or for the next, on a line alone
+//
+// Without rule names, a directive applies to every rule, and enable turns
+// back on all that are off. Enabling a rule after disabling every rule
+// leaves the others off. Directives and rules that do not exist are
+// reported under the directive rule.
+const directivePrefix = "lint:"
+
+// span is a run of lines, from and to included, where rule, or every rule
+// if it is "", is off
+type span struct {
+	rule     string
+	from, to int
+}
+
+type suppressions []span
+
+func (s suppressions) covers(rule string, line int) bool {
+	for _, sp := range s {
+		if (sp.rule == "" || sp.rule == rule) && sp.from <= line && line <= sp.to {
+			return true
+		}
+	}
+	return false
+}
+
+// directives returns the lines each rule is turned off for by comments,
+// and the problems with the comments themselves
+func directives(comments []lexer.Comment) (suppressions, []Diagnostic) {
+	var s suppressions
+	var problems []Diagnostic
+	problem := func(line int, format string, args ...interface{}) {
+		problems = append(problems, Diagnostic{Rule: "directive", Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+	disabled := map[string]int{} // rules turned off, and the line they were
+
+	for _, c := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "#"))
+		if !strings.HasPrefix(text, directivePrefix) {
+			continue
+		}
+		verb, args, _ := strings.Cut(strings.TrimPrefix(text, directivePrefix), " ")
+		rules := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' })
+		if verb != "disable" && verb != "enable" && verb != "ignore" {
+			problem(c.Line, "unknown lint directive %s", verb)
+			continue
+		}
+		named := len(rules) > 0
+		known := rules[:0]
+		for _, rule := range rules {
+			if isRule(rule) {
+				known = append(known, rule)
+			} else {
+				problem(c.Line, "unknown lint rule %s", rule)
+			}
+		}
+		rules = known
+		if len(rules) == 0 {
+			if named {
+				continue // every rule it names is unknown
+			}
+			rules = []string{""}
+		}
+
+		for _, rule := range rules {
+			switch verb {
+			case "disable":
+				if _, ok := disabled[rule]; !ok {
+					disabled[rule] = c.Line
+				}
+			case "enable":
+				if from, ok := disabled[""]; ok && rule != "" {
+					// The rest stay off from here
+					for _, r := range Rules {
+						if _, ok := disabled[r.Name]; !ok {
+							disabled[r.Name] = from
+						}
+					}
+					delete(disabled, "")
+				}
+				for name, from := range disabled {
+					if rule == "" || rule == name {
+						s = append(s, span{name, from, c.Line})
+						delete(disabled, name)
+					}
+				}
+			case "ignore":
+				line := c.Line
+				if !c.Trailing {
+					line++
+				}
+				s = append(s, span{rule, line, line})
+			}
+		}
+	}
+
+	for rule, from := range disabled {
+		s = append(s, span{rule, from, math.MaxInt})
+	}
+	return s, problems
+}
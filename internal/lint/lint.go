@@ -0,0 +1,720 @@
+// Package lint finds likely mistakes in programs without running them, for
+// `nepali lint`
+package lint
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/evaluator"
+	"github.com/SunilNeupane77/nepali/internal/format"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+// Rule is one kind of problem the linter looks for
+type Rule struct {
+	Name        string
+	Description string
+}
+
+// Rules are the problems the linter looks for. All are enabled unless a
+// Config or an inline comment disables them.
+var Rules = []Rule{
+	{"unused-variable", "a variable is bound but never used"},
+	{"unused-parameter", "a function parameter is never used"},
+	{"shadow", "a name hides the same name bound in an enclosing scope"},
+	{"unreachable", "a statement comes after " + lexer.RETURN + " and can never run"},
+	{"constant-comparison", "a comparison always gives the same result"},
+	{"arity", "a call passes arguments the function it calls cannot take"},
+	{"mixed-digits", "a number mixes Devanagari and ASCII digits"},
+	{"directive", "a lint comment names a directive or rule that does not exist"},
+}
+
+func isRule(name string) bool {
+	for _, r := range Rules {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Diagnostic is a problem found in a program
+type Diagnostic struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ParseErrors reports source that does not parse, and so cannot be linted
+type ParseErrors []string
+
+func (e ParseErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+// File lints the file at path
+func File(path string, config Config) ([]Diagnostic, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	diagnostics, err := Source(string(source), config)
+	for i := range diagnostics {
+		diagnostics[i].File = path
+	}
+	return diagnostics, err
+}
+
+// Source lints source, returning the problems found by the rules config
+// and the source's comments leave enabled, in line order.
+//
+// Names are scoped as the resolver scopes them: each function call,
+// comprehension and मिलान case has a scope of its own, and a name refers
+// to the innermost scope that binds it anywhere, except that until its
+// scope binds it, it refers to the name outside if there is one. Names
+// starting with _ are exempt from the unused rules.
+func Source(source string, config Config) ([]Diagnostic, error) {
+	l := lexer.New(source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, ParseErrors(p.Errors())
+	}
+
+	c := &checker{scopes: make(map[interface{}]*scope)}
+	c.top = newScope(nil, false)
+
+	c.declaring = true
+	c.current = c.top
+	c.statements(program.Statements)
+
+	c.declaring = false
+	c.current = c.top
+	c.statements(program.Statements)
+
+	c.unused()
+	c.mixedDigits(source)
+
+	suppressed, problems := directives(l.Comments())
+	c.diagnostics = append(c.diagnostics, problems...)
+	var diagnostics []Diagnostic
+	for _, d := range c.diagnostics {
+		if config.Enabled(d.Rule) && !suppressed.covers(d.Rule, d.Line) {
+			diagnostics = append(diagnostics, d)
+		}
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool { return diagnostics[i].Line < diagnostics[j].Line })
+
+	return diagnostics, nil
+}
+
+// binding is a name bound in a scope, perhaps by several statements
+type binding struct {
+	name      string
+	line      int // of its first binding
+	parameter bool
+	exported  bool
+	used      bool
+	defined   bool                 // whether the check has passed its first binding
+	checked   bool                 // for hiding an outer name
+	count     int                  // how many times it is bound
+	function  *ast.FunctionLiteral // what it is bound to, if a function bound once without decorators
+}
+
+type scope struct {
+	outer    *scope
+	function bool // the scope of a function call
+	names    map[string]*binding
+	order    []*binding
+}
+
+func newScope(outer *scope, function bool) *scope {
+	return &scope{outer: outer, function: function, names: make(map[string]*binding)}
+}
+
+// lookup returns the binding name refers to from s, or nil
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// The checker walks the program twice, like the resolver: first to find
+// the names each scope binds, then to check what uses them
+type checker struct {
+	scopes      map[interface{}]*scope // the scope each function, comprehension and case opens
+	order       []*scope               // the same, in the order they open
+	top         *scope
+	current     *scope
+	declaring   bool
+	diagnostics []Diagnostic
+}
+
+func (c *checker) report(rule string, line int, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{Rule: rule, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// open enters the scope node opens, creating it on the first pass
+func (c *checker) open(node interface{}) *scope {
+	s, ok := c.scopes[node]
+	if !ok {
+		_, function := node.(*ast.FunctionLiteral)
+		s = newScope(c.current, function)
+		c.scopes[node] = s
+		c.order = append(c.order, s)
+	}
+	c.current = s
+	return s
+}
+
+func (c *checker) close(s *scope) {
+	c.current = s.outer
+}
+
+// bind binds id in the current scope, to value if it is known. Once every
+// scope's names are known, it reports names hiding those of enclosing
+// scopes.
+func (c *checker) bind(id *ast.Identifier, value ast.Expression, parameter bool) *binding {
+	b, ok := c.current.names[id.Value]
+	if !c.declaring {
+		b.defined = true
+		if !b.checked {
+			b.checked = true
+			if outer := c.current.outer.lookup(id.Value); outer != nil {
+				c.report("shadow", b.line, "%s hides the %s bound on line %d", id.Value, id.Value, outer.line)
+			}
+		}
+		return b
+	}
+
+	if !ok {
+		b = &binding{name: id.Value, line: id.Token.Line, parameter: parameter}
+		c.current.names[id.Value] = b
+		c.current.order = append(c.current.order, b)
+	}
+
+	b.count++
+	b.function, _ = value.(*ast.FunctionLiteral)
+	if b.count > 1 {
+		b.function = nil
+	}
+	return b
+}
+
+// resolve returns the binding name refers to where the check is, or nil
+// for a builtin or undefined name. A name its scope has not bound yet, as
+// in लेट x = x + १, refers to what it means outside the scope, unless the
+// use is inside a function, which may run after the binding.
+func (c *checker) resolve(name string) *binding {
+	crossed := false
+	for s := c.current; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok && (crossed || b.defined || !c.visibleBeyond(s, name)) {
+			return b
+		}
+		if s.function {
+			crossed = true
+		}
+	}
+	return nil
+}
+
+// visibleBeyond reports whether name means something outside s
+func (c *checker) visibleBeyond(s *scope, name string) bool {
+	if s.outer.lookup(name) != nil {
+		return true
+	}
+	_, ok := builtins.Lookup(name)
+	return ok
+}
+
+func (c *checker) use(id *ast.Identifier) {
+	if c.declaring {
+		return
+	}
+	if b := c.resolve(id.Value); b != nil {
+		b.used = true
+	}
+}
+
+// unused reports the bindings never used, scope by scope
+func (c *checker) unused() {
+	for _, s := range append([]*scope{c.top}, c.order...) {
+		for _, b := range s.order {
+			if b.used || b.exported || strings.HasPrefix(b.name, "_") {
+				continue
+			}
+			if b.parameter {
+				c.report("unused-parameter", b.line, "parameter %s is never used", b.name)
+			} else {
+				c.report("unused-variable", b.line, "%s is bound but never used", b.name)
+			}
+		}
+	}
+}
+
+// mixedDigits reports numbers written with both kinds of digit
+func (c *checker) mixedDigits(source string) {
+	l := lexer.New(source)
+	for tok := l.NextToken(); tok.Type != lexer.EOF; tok = l.NextToken() {
+		if tok.Type != lexer.INT {
+			continue
+		}
+		ascii := strings.ContainsAny(tok.Literal, "0123456789")
+		devanagari := strings.ContainsAny(tok.Literal, "०१२३४५६७८९")
+		if ascii && devanagari {
+			c.report("mixed-digits", tok.Line, "%s mixes Devanagari and ASCII digits", tok.Literal)
+		}
+	}
+}
+
+func (c *checker) statements(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		c.statement(stmt)
+		if terminates(stmt) && i+1 < len(stmts) && !c.declaring {
+			c.report("unreachable", statementLine(stmts[i+1]), "unreachable code after %s", lexer.RETURN)
+			for _, rest := range stmts[i+1:] {
+				c.statement(rest)
+			}
+			return
+		}
+	}
+}
+
+func (c *checker) block(block *ast.BlockStatement) {
+	if block != nil {
+		c.statements(block.Statements)
+	}
+}
+
+func (c *checker) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt)
+	case *ast.ExportStatement:
+		if b := c.let(stmt.Statement); b != nil {
+			b.exported = true
+		}
+	case *ast.ReturnStatement:
+		c.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		c.block(stmt)
+	case *ast.ImportStatement:
+		// A module imported whole is bound by a name the path gives, and
+		// imports are used for their effects too, so only named imports
+		// are checked
+		for _, n := range stmt.Names {
+			c.bind(n, nil, false)
+		}
+		if stmt.Alias != nil {
+			c.bind(stmt.Alias, nil, false)
+		}
+	case *ast.WithStatement:
+		c.expression(stmt.Context)
+		if stmt.Name != nil {
+			c.bind(stmt.Name, nil, false)
+		}
+		c.block(stmt.Body)
+	}
+}
+
+func (c *checker) let(let *ast.LetStatement) *binding {
+	c.expressions(let.Decorators)
+	c.expression(let.Value)
+
+	// A decorated function is bound to whatever its decorators return
+	var value ast.Expression
+	if len(let.Decorators) == 0 {
+		value = let.Value
+	}
+	return c.bind(let.Name, value, false)
+}
+
+func (c *checker) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		c.expression(exp)
+	}
+}
+
+func (c *checker) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		c.use(exp)
+	case *ast.PrefixExpression:
+		c.expression(exp.Right)
+	case *ast.InfixExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Right)
+		if !c.declaring {
+			c.comparison(exp)
+		}
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		c.block(exp.Consequence)
+		c.block(exp.Alternative)
+	case *ast.FunctionLiteral:
+		c.function(exp)
+	case *ast.CallExpression:
+		c.expression(exp.Function)
+		c.expressions(exp.Arguments)
+		if !c.declaring {
+			c.call(exp)
+		}
+	case *ast.KeywordArgument:
+		c.expression(exp.Value)
+	case *ast.SpreadArgument:
+		c.expression(exp.Value)
+	case *ast.ArrayLiteral:
+		c.expressions(exp.Elements)
+	case *ast.IndexExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Index)
+	case *ast.SliceExpression:
+		c.expression(exp.Left)
+		c.expression(exp.Start)
+		c.expression(exp.End)
+		c.expression(exp.Step)
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			c.expression(pair.Key)
+			c.expression(pair.Value)
+		}
+	case *ast.SetLiteral:
+		c.expressions(exp.Elements)
+	case *ast.AwaitExpression:
+		c.expression(exp.Value)
+	case *ast.MemberExpression:
+		c.expression(exp.Object)
+	case *ast.ListComprehension:
+		c.comprehension(exp, exp.Clauses, exp.Element)
+	case *ast.SetComprehension:
+		c.comprehension(exp, exp.Clauses, exp.Element)
+	case *ast.HashComprehension:
+		c.comprehension(exp, exp.Clauses, exp.Key, exp.Value)
+	case *ast.MatchExpression:
+		c.match(exp)
+	}
+}
+
+// function checks a function literal. Defaults are evaluated where the
+// function is defined, so they belong to the scope outside it.
+func (c *checker) function(fn *ast.FunctionLiteral) {
+	for _, p := range fn.Parameters {
+		c.expression(p.Default)
+	}
+
+	s := c.open(fn)
+	for _, p := range fn.Parameters {
+		c.bind(p.Name, nil, true)
+	}
+	c.block(fn.Body)
+	c.close(s)
+}
+
+func (c *checker) comprehension(node ast.Node, clauses []*ast.ComprehensionClause, results ...ast.Expression) {
+	s := c.open(node)
+	for _, clause := range clauses {
+		c.expression(clause.Iterable)
+		for _, n := range clause.Names {
+			c.bind(n, nil, false)
+		}
+		c.expressions(clause.Filters)
+	}
+	c.expressions(results)
+	c.close(s)
+}
+
+func (c *checker) match(node *ast.MatchExpression) {
+	c.expression(node.Subject)
+
+	for _, mc := range node.Cases {
+		s := c.open(mc)
+		c.pattern(mc.Pattern)
+		c.expression(mc.Guard)
+		c.block(mc.Body)
+		c.close(s)
+	}
+}
+
+func (c *checker) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		c.expression(pattern.Value)
+	case *ast.BindingPattern:
+		if !pattern.IsWildcard() {
+			c.bind(pattern.Name, nil, false)
+		}
+	case *ast.ArrayPattern:
+		for _, p := range pattern.Before {
+			c.pattern(p)
+		}
+		for _, p := range pattern.After {
+			c.pattern(p)
+		}
+		c.rest(pattern.Rest)
+	case *ast.HashPattern:
+		c.expressions(pattern.Keys)
+		for _, p := range pattern.Values {
+			c.pattern(p)
+		}
+		c.rest(pattern.Rest)
+	}
+}
+
+func (c *checker) rest(rest *ast.Identifier) {
+	if rest != nil && rest.Value != "_" {
+		c.bind(rest, nil, false)
+	}
+}
+
+// comparison reports comparisons of literals, and of an operand with
+// itself, whose result is known without running them
+func (c *checker) comparison(exp *ast.InfixExpression) {
+	var always bool
+	switch {
+	case !isComparison(exp.Operator):
+		return
+	case isLiteral(exp.Left) && isLiteral(exp.Right):
+		// Comparing literals of different types is an error, for the
+		// evaluator to report when it runs
+		result, ok := evaluator.Eval(exp, nil).(*object.Boolean)
+		if !ok {
+			return
+		}
+		always = result.Value
+	case isPure(exp.Left) && format.Expression(exp.Left) == format.Expression(exp.Right):
+		always = exp.Operator == lexer.EQ || exp.Operator == lexer.LT_EQ || exp.Operator == lexer.GT_EQ
+	default:
+		return
+	}
+
+	result := lexer.FALSE
+	if always {
+		result = lexer.TRUE
+	}
+	c.report("constant-comparison", exp.Token.Line, "%s is always %s", format.Expression(exp), result)
+}
+
+func isComparison(operator string) bool {
+	switch operator {
+	case lexer.EQ, lexer.NOT_EQ, lexer.LT, lexer.GT, lexer.LT_EQ, lexer.GT_EQ:
+		return true
+	}
+	return false
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+// isPure reports whether evaluating exp twice gives the same value both
+// times
+func isPure(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.Identifier, *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	case *ast.PrefixExpression:
+		return isPure(exp.Right)
+	case *ast.InfixExpression:
+		return isPure(exp.Left) && isPure(exp.Right)
+	case *ast.MemberExpression:
+		return isPure(exp.Object)
+	case *ast.IndexExpression:
+		return isPure(exp.Left) && isPure(exp.Index)
+	}
+	return false
+}
+
+// call reports a call to a function bound once in the program, or to a
+// builtin, that passes arguments the function cannot take, as the
+// evaluator would when the call runs
+func (c *checker) call(call *ast.CallExpression) {
+	id, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return
+	}
+	b := c.resolve(id.Value)
+	if b == nil {
+		c.builtinCall(id.Value, call)
+		return
+	}
+	if b.function == nil {
+		return
+	}
+	fn := b.function
+
+	var keywords []string
+	positional := 0
+	for _, arg := range call.Arguments {
+		switch arg := arg.(type) {
+		case *ast.SpreadArgument:
+			return // its length is not known until it runs
+		case *ast.KeywordArgument:
+			keywords = append(keywords, arg.Name.Value)
+		default:
+			positional++
+		}
+	}
+
+	bound := make([]bool, len(fn.Parameters))
+	rest, keywordRest := false, false
+	accepts := 0 // parameters that can be passed by position
+	for i, param := range fn.Parameters {
+		switch {
+		case param.Rest:
+			rest = true
+		case param.KeywordRest:
+			keywordRest = true
+		case !rest:
+			if accepts < positional {
+				bound[i] = true
+			}
+			accepts++
+		}
+	}
+	if !rest && positional > accepts {
+		c.report("arity", call.Token.Line, "%s takes %s positional arguments, got %d",
+			id.Value, positionalRange(fn, accepts), positional)
+		return
+	}
+
+	for _, name := range keywords {
+		i := parameterIndex(fn, name)
+		switch {
+		case i >= 0 && bound[i]:
+			c.report("arity", call.Token.Line, "%s gets multiple values for argument %s", id.Value, name)
+			return
+		case i >= 0:
+			bound[i] = true
+		case !keywordRest:
+			c.report("arity", call.Token.Line, "%s has no parameter %s", id.Value, name)
+			return
+		}
+	}
+
+	var missing []string
+	for i, param := range fn.Parameters {
+		if !param.Rest && !param.KeywordRest && !bound[i] && param.Default == nil {
+			missing = append(missing, param.Name.Value)
+		}
+	}
+	switch len(missing) {
+	case 0:
+	case 1:
+		c.report("arity", call.Token.Line, "%s is missing argument %s", id.Value, missing[0])
+	default:
+		c.report("arity", call.Token.Line, "%s is missing arguments %s", id.Value, strings.Join(missing, ", "))
+	}
+}
+
+// builtinCall reports a call to the builtin called name with arguments its
+// signature does not allow
+func (c *checker) builtinCall(name string, call *ast.CallExpression) {
+	min, max, ok := builtins.Arity(name)
+	if !ok {
+		return
+	}
+
+	count := 0
+	for _, arg := range call.Arguments {
+		switch arg.(type) {
+		case *ast.SpreadArgument:
+			return
+		case *ast.KeywordArgument:
+			c.report("arity", call.Token.Line, "%s takes no keyword arguments", name)
+			return
+		}
+		count++
+	}
+
+	switch {
+	case count >= min && (max < 0 || count <= max):
+	case min == max:
+		c.report("arity", call.Token.Line, "%s takes %d arguments, got %d", name, min, count)
+	case max < 0:
+		c.report("arity", call.Token.Line, "%s takes at least %d arguments, got %d", name, min, count)
+	default:
+		c.report("arity", call.Token.Line, "%s takes %d to %d arguments, got %d", name, min, max, count)
+	}
+}
+
+// positionalRange describes how many of the first accepts parameters of fn
+// a call must pass
+func positionalRange(fn *ast.FunctionLiteral, accepts int) string {
+	required := 0
+	for _, param := range fn.Parameters[:accepts] {
+		if param.Default == nil {
+			required++
+		}
+	}
+	if required < accepts {
+		return fmt.Sprintf("%d to %d", required, accepts)
+	}
+	return fmt.Sprint(accepts)
+}
+
+// parameterIndex returns the index of fn's parameter called name that can
+// be passed by keyword, or -1
+func parameterIndex(fn *ast.FunctionLiteral, name string) int {
+	for i, param := range fn.Parameters {
+		if param.Name.Value == name && !param.Rest && !param.KeywordRest {
+			return i
+		}
+	}
+	return -1
+}
+
+// terminates reports whether running stmt always leaves the function
+func terminates(stmt ast.Statement) bool {
+	switch stmt := stmt.(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		ie, ok := stmt.Expression.(*ast.IfExpression)
+		return ok && ie.Alternative != nil && blockTerminates(ie.Consequence) && blockTerminates(ie.Alternative)
+	}
+	return false
+}
+
+func blockTerminates(block *ast.BlockStatement) bool {
+	n := len(block.Statements)
+	return n > 0 && terminates(block.Statements[n-1])
+}
+
+// statementLine returns the line stmt starts on
+func statementLine(stmt ast.Statement) int {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token.Line
+	case *ast.ExportStatement:
+		return stmt.Token.Line
+	case *ast.ReturnStatement:
+		return stmt.Token.Line
+	case *ast.ExpressionStatement:
+		return stmt.Token.Line
+	case *ast.ImportStatement:
+		return stmt.Token.Line
+	case *ast.WithStatement:
+		return stmt.Token.Line
+	case *ast.BlockStatement:
+		return stmt.Token.Line
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// lint lints input and lists what it finds as line:rule: message
+func lint(t *testing.T, input string, config Config) []string {
+	t.Helper()
+
+	diagnostics, err := Source(input, config)
+	if err != nil {
+		t.Fatalf("%q - unexpected error: %s", input, err)
+	}
+
+	var out []string
+	for _, d := range diagnostics {
+		out = append(out, fmt.Sprintf("%d:%s: %s", d.Line, d.Rule, d.Message))
+	}
+	return out
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"लेट a = १; फन f(x) { x + a }; f(२)", nil},
+		// Unused variables and parameters
+		{"लेट a = १\nलेट _b = २", []string{"1:unused-variable: a is bound but never used"}},
+		{"फन f(x, y) {\n लेट z = x\n}; f(१, २)", []string{
+			"1:unused-parameter: parameter y is never used",
+			"2:unused-variable: z is bound but never used",
+		}},
+		{"निर्यात लेट a = १; बाट \"म\" आयात b", []string{"1:unused-variable: b is bound but never used"}},
+		{"[१ लागि x मा [२]]; मिलान ३ { अवस्था [_, *r] { ० } }", []string{
+			"1:unused-variable: x is bound but never used",
+			"1:unused-variable: r is bound but never used",
+		}},
+		// A name used before its let still counts
+		{"फन f() { g() }; फन g() { १ }; f()", nil},
+		// ...but until then, outside a function, means the name outside
+		{"लेट x = १; फन f() { लेट x = x + १; x }; प्रिन्ट(f())", []string{"1:shadow: x hides the x bound on line 1"}},
+		{"लेट y = [१]; प्रिन्ट([y लागि y मा y])", []string{"1:shadow: y hides the y bound on line 1"}},
+		{"फन f() { लेट x = x; x }; f()", nil},
+		// Shadowing
+		{"लेट n = १\nफन f(n) {\n n\n}\nf(n)", []string{"2:shadow: n hides the n bound on line 1"}},
+		{"फन f() {\n [x लागि x मा [x]]\n}\nलेट x = १; f(); x", []string{"2:shadow: x hides the x bound on line 4"}},
+		// Unreachable code
+		{"फन f() {\n प्रतिफल १\n २\n ३\n}; f()", []string{"3:unreachable: unreachable code after प्रतिफल"}},
+		{
+			"फन f(x) {\n यदि (x) { प्रतिफल १ } अन्यथा { प्रतिफल २ }\n x\n}; f(१)",
+			[]string{"3:unreachable: unreachable code after प्रतिफल"},
+		},
+		{"फन f(x) { यदि (x) { प्रतिफल १ }; २ }; f(१)", nil},
+		// Comparisons with a known result
+		{"लेट x = १\nx == x; x < x; x.a[१] >= x.a[१]; x == x + १", []string{
+			"2:constant-comparison: x == x is always सत्य",
+			"2:constant-comparison: x < x is always मिथ्या",
+			"2:constant-comparison: x.a[१] >= x.a[१] is always सत्य",
+		}},
+		{"१ < २; \"क\" == 1; f() == f()", []string{
+			"1:constant-comparison: १ < २ is always सत्य",
+			"1:constant-comparison: \"क\" == 1 is always मिथ्या",
+		}},
+		// Calls with the wrong arguments
+		{"फन f(a, b = १) { a + b }\nf(); f(१, २, ३); f(१, c = २); f(१, a = २); f(b = २); f(*[१, २, ३])", []string{
+			"2:arity: f is missing argument a",
+			"2:arity: f takes 1 to 2 positional arguments, got 3",
+			"2:arity: f has no parameter c",
+			"2:arity: f gets multiple values for argument a",
+			"2:arity: f is missing argument a",
+		}},
+		{"फन f(a, *r, k, **kw) { [a, r, k, kw] }\nf(१, २, ३, k = ४, z = ५); f(१)", []string{
+			"2:arity: f is missing argument k",
+		}},
+		{"लेन(१, २); लेन(); प्रिन्ट(); अहिले(१); टुक्रा([१], १, २, ३, ४); टुक्रा([१]); लेन(a = १); लेन(*[१, २])", []string{
+			"1:arity: लेन takes 1 arguments, got 2",
+			"1:arity: लेन takes 1 arguments, got 0",
+			"1:arity: अहिले takes 0 arguments, got 1",
+			"1:arity: टुक्रा takes 1 to 4 arguments, got 5",
+			"1:arity: लेन takes no keyword arguments",
+		}},
+		{"फन लेन(a, b) { a + b }; लेन(१, २)", nil},
+		// Only functions bound once, without decorators, are known
+		{"लेट f = फन(a) { a }; लेट f = फन() { १ }; f()\n@d\nफन g(a) { a }; g(); फन d(h) { h }", nil},
+		// Mixed digits
+		{"लेट x = 1२3 + १२; x", []string{"1:mixed-digits: 1२3 mixes Devanagari and ASCII digits"}},
+	}
+
+	for _, tt := range tests {
+		got := lint(t, tt.input, Config{})
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%q - wrong diagnostics.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDirectives(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"लेट a = १ # lint:ignore\nलेट b = २", []string{"2:unused-variable: b is bound but never used"}},
+		{"# lint:ignore unused-variable\nलेट a = १\nलेट b = २", []string{"3:unused-variable: b is bound but never used"}},
+		{"लेट a = १ # lint:ignore shadow", []string{"1:unused-variable: a is bound but never used"}},
+		{
+			"# lint:disable unused-variable, mixed-digits\nलेट a = 1२\n# lint:enable mixed-digits\nलेट b = 1२\n# lint:enable\nलेट c = १",
+			[]string{"4:mixed-digits: 1२ mixes Devanagari and ASCII digits", "6:unused-variable: c is bound but never used"},
+		},
+		{"# lint:disable\nलेट a = 1२", nil},
+		{"# lint:disable\nलेट a = 1२\n# lint:enable shadow\nलेट n = १; फन f(n) { n }; f(n)", []string{
+			"4:shadow: n hides the n bound on line 4",
+		}},
+		{"# lint:disable shadwo, unused-variable\nलेट a = १ # lint:ignroe\nलेट b = 1२ # lint:ignore mixed-digit", []string{
+			"1:directive: unknown lint rule shadwo",
+			"2:directive: unknown lint directive ignroe",
+			"3:mixed-digits: 1२ mixes Devanagari and ASCII digits",
+			"3:directive: unknown lint rule mixed-digit",
+		}},
+	}
+
+	for _, tt := range tests {
+		got := lint(t, tt.input, Config{})
+		if fmt.Sprint(got) != fmt.Sprint(tt.expected) {
+			t.Errorf("%q - wrong diagnostics.\nexpected=%q\ngot=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "src", "lib")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), []byte(`{"rules": {"unused-variable": false}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := FindConfig(sub)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if config.Enabled("unused-variable") || !config.Enabled("shadow") {
+		t.Errorf("wrong rules enabled: %v", config.Rules)
+	}
+	if got := lint(t, "लेट a = 1२", config); fmt.Sprint(got) != "[1:mixed-digits: 1२ mixes Devanagari and ASCII digits]" {
+		t.Errorf("wrong diagnostics with config: %q", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(sub, ConfigFile), []byte(`{"rules": {"unsued": false}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindConfig(sub); err == nil || !strings.Contains(err.Error(), "unknown rule unsued") {
+		t.Errorf("expected an unknown rule error, got=%v", err)
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	diagnostics := []Diagnostic{{File: filepath.Join("क", "ख.nep"), Line: 3, Rule: "shadow", Message: "n hides the n bound on line 1"}}
+
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, diagnostics); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var log struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct{ ID string }
+				}
+			}
+			Results []struct {
+				RuleID    string
+				RuleIndex int
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct{ URI string }
+						Region           struct{ StartLine int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("wrong log: %s", buf.String())
+	}
+	run := log.Runs[0]
+	result := run.Results[0]
+	if run.Tool.Driver.Rules[result.RuleIndex].ID != "shadow" || result.RuleID != "shadow" {
+		t.Errorf("wrong rule: %+v", result)
+	}
+	location := result.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "क/ख.nep" || location.Region.StartLine != 3 || result.Message.Text != diagnostics[0].Message {
+		t.Errorf("wrong result: %+v", result)
+	}
+}
@@ -0,0 +1,549 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/format"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+type symbolKind int
+
+const (
+	variableSymbol symbolKind = iota
+	constantSymbol
+	functionSymbol
+	parameterSymbol
+	importSymbol
+)
+
+var symbolKindNames = map[symbolKind]string{
+	variableSymbol:  "variable",
+	constantSymbol:  "constant",
+	functionSymbol:  "function",
+	parameterSymbol: "parameter",
+	importSymbol:    "import",
+}
+
+// symbol is a name bound in a scope, perhaps by several statements
+type symbol struct {
+	name   string
+	kind   symbolKind
+	scope  *scope
+	def    *ast.Identifier   // its first binding
+	bound  bool              // whether the second walk has passed def
+	refs   []*ast.Identifier // every binding and use, in source order
+	values []ast.Expression  // what each binding binds, nil where that is not known
+
+	// known is the type of a name whose binding fixes it, as *rest
+	// parameters and patterns do
+	known object.ObjectType
+}
+
+// pos is a place in the source: a line and a column in characters, both
+// from 1, as tokens have them
+type pos struct {
+	line, column int
+}
+
+func (p pos) before(q pos) bool {
+	return p.line < q.line || p.line == q.line && p.column < q.column
+}
+
+func start(tok lexer.Token) pos {
+	return pos{tok.Line, tok.Column}
+}
+
+// end returns the place just after id
+func end(id *ast.Identifier) pos {
+	return pos{id.Token.Line, id.Token.Column + utf8.RuneCountInString(id.Token.Literal)}
+}
+
+type scope struct {
+	outer      *scope
+	function   bool // the scope of a function call
+	names      map[string]*symbol
+	start, end pos // the source the scope covers; the top-level scope covers it all
+}
+
+func newScope(outer *scope, function bool) *scope {
+	return &scope{outer: outer, function: function, names: make(map[string]*symbol)}
+}
+
+// lookup returns the symbol name refers to from s, or nil
+func (s *scope) lookup(name string) *symbol {
+	for ; s != nil; s = s.outer {
+		if sym, ok := s.names[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// index records the symbol each identifier in a program binds or uses.
+// Names are scoped as the resolver scopes them: each function call,
+// comprehension and मिलान case has a scope of its own, and a name refers
+// to the innermost scope that binds it anywhere, except that until its
+// scope binds it, as in लेट x = x + १ or the iterable of
+// [x लागि x मा …], it refers to the name outside if there is one.
+//
+// Like the resolver, it walks the program twice: first to find the names
+// each scope binds, then to find what uses them.
+type index struct {
+	idents  []*ast.Identifier           // in source order
+	symbols map[*ast.Identifier]*symbol // nil for names bound nowhere, such as builtins
+
+	scopes    map[interface{}]*scope // the scope each function, comprehension and case opens
+	order     []*scope               // the same, in the order they open
+	top       *scope
+	current   *scope
+	declaring bool
+}
+
+func newIndex(program *ast.Program) *index {
+	ix := &index{
+		symbols: make(map[*ast.Identifier]*symbol),
+		scopes:  make(map[interface{}]*scope),
+	}
+	ix.top = newScope(nil, false)
+
+	ix.declaring = true
+	ix.current = ix.top
+	ix.statements(program.Statements)
+
+	ix.declaring = false
+	ix.current = ix.top
+	ix.statements(program.Statements)
+
+	sort.Slice(ix.idents, func(i, j int) bool {
+		return start(ix.idents[i].Token).before(start(ix.idents[j].Token))
+	})
+	for _, id := range ix.idents {
+		if sym := ix.symbols[id]; sym != nil {
+			sym.refs = append(sym.refs, id)
+		}
+	}
+	return ix
+}
+
+// identAt returns the identifier at p, including just after its end
+func (ix *index) identAt(p pos) *ast.Identifier {
+	for _, id := range ix.idents {
+		if !p.before(start(id.Token)) && !end(id).before(p) {
+			return id
+		}
+	}
+	return nil
+}
+
+// scopeAt returns the innermost scope covering p
+func (ix *index) scopeAt(p pos) *scope {
+	found := ix.top
+	for _, s := range ix.order {
+		if !p.before(s.start) && !s.end.before(p) && found.start.before(s.start) {
+			found = s
+		}
+	}
+	return found
+}
+
+func (ix *index) record(id *ast.Identifier, sym *symbol) {
+	ix.idents = append(ix.idents, id)
+	ix.symbols[id] = sym
+
+	// A comprehension has no closing token of its own, so its scope covers
+	// up to the last name in it
+	for s := ix.current; s != ix.top; s = s.outer {
+		if s.end.before(end(id)) {
+			s.end = end(id)
+		}
+	}
+}
+
+// open enters the scope node opens, creating it on the first pass
+func (ix *index) open(node interface{}, from lexer.Token) *scope {
+	s, ok := ix.scopes[node]
+	if !ok {
+		_, function := node.(*ast.FunctionLiteral)
+		s = newScope(ix.current, function)
+		s.start, s.end = start(from), start(from)
+		ix.scopes[node] = s
+		ix.order = append(ix.order, s)
+	}
+	ix.current = s
+	return s
+}
+
+// close leaves s, which covers the source up to the token to
+func (ix *index) close(s *scope, to *lexer.Token) {
+	if to != nil && s.end.before(start(*to)) {
+		s.end = start(*to)
+	}
+	ix.current = s.outer
+}
+
+// bind binds id in the current scope, to value if it is known
+func (ix *index) bind(id *ast.Identifier, kind symbolKind, value ast.Expression) *symbol {
+	if !ix.declaring {
+		sym := ix.current.names[id.Value]
+		sym.bound = true
+		return sym
+	}
+
+	sym, ok := ix.current.names[id.Value]
+	if !ok {
+		sym = &symbol{name: id.Value, kind: kind, scope: ix.current, def: id}
+		ix.current.names[id.Value] = sym
+	}
+	sym.values = append(sym.values, value)
+	ix.record(id, sym)
+	return sym
+}
+
+func (ix *index) use(id *ast.Identifier) {
+	if !ix.declaring {
+		ix.record(id, ix.resolve(id.Value))
+	}
+}
+
+// resolve returns the symbol name refers to where the walk is, or nil. A
+// name its scope has not bound yet refers to what it means outside the
+// scope, unless the use is inside a function, which may run after the
+// binding.
+func (ix *index) resolve(name string) *symbol {
+	crossed := false
+	for s := ix.current; s != nil; s = s.outer {
+		if sym, ok := s.names[name]; ok && (crossed || sym.bound || !visibleBeyond(s, name)) {
+			return sym
+		}
+		if s.function {
+			crossed = true
+		}
+	}
+	return nil
+}
+
+// visibleBeyond reports whether name means something outside s
+func visibleBeyond(s *scope, name string) bool {
+	if s.outer.lookup(name) != nil {
+		return true
+	}
+	_, ok := builtins.Lookup(name)
+	return ok
+}
+
+func (ix *index) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		ix.statement(stmt)
+	}
+}
+
+func (ix *index) block(block *ast.BlockStatement) {
+	if block != nil {
+		ix.statements(block.Statements)
+	}
+}
+
+func (ix *index) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		ix.let(stmt)
+	case *ast.ExportStatement:
+		ix.let(stmt.Statement)
+	case *ast.ReturnStatement:
+		ix.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		ix.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		ix.block(stmt)
+	case *ast.ImportStatement:
+		for _, n := range stmt.Names {
+			ix.bind(n, importSymbol, nil)
+		}
+		if stmt.Alias != nil {
+			ix.bind(stmt.Alias, importSymbol, nil)
+		}
+	case *ast.WithStatement:
+		ix.expression(stmt.Context)
+		if stmt.Name != nil {
+			ix.bind(stmt.Name, variableSymbol, nil)
+		}
+		ix.block(stmt.Body)
+	}
+}
+
+func (ix *index) let(let *ast.LetStatement) {
+	ix.expressions(let.Decorators)
+	ix.expression(let.Value)
+
+	// A decorated function is bound to whatever its decorators return
+	var value ast.Expression
+	if len(let.Decorators) == 0 {
+		value = let.Value
+	}
+
+	kind := variableSymbol
+	if _, ok := value.(*ast.FunctionLiteral); ok {
+		kind = functionSymbol
+	} else if let.Token.Type == lexer.CONST {
+		kind = constantSymbol
+	}
+	ix.bind(let.Name, kind, value)
+}
+
+func (ix *index) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		ix.expression(exp)
+	}
+}
+
+func (ix *index) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		ix.use(exp)
+	case *ast.PrefixExpression:
+		ix.expression(exp.Right)
+	case *ast.InfixExpression:
+		ix.expression(exp.Left)
+		ix.expression(exp.Right)
+	case *ast.IfExpression:
+		ix.expression(exp.Condition)
+		ix.block(exp.Consequence)
+		ix.block(exp.Alternative)
+	case *ast.FunctionLiteral:
+		ix.function(exp)
+	case *ast.CallExpression:
+		ix.expression(exp.Function)
+		ix.expressions(exp.Arguments)
+	case *ast.KeywordArgument:
+		ix.expression(exp.Value)
+	case *ast.SpreadArgument:
+		ix.expression(exp.Value)
+	case *ast.ArrayLiteral:
+		ix.expressions(exp.Elements)
+	case *ast.IndexExpression:
+		ix.expression(exp.Left)
+		ix.expression(exp.Index)
+	case *ast.SliceExpression:
+		ix.expression(exp.Left)
+		ix.expression(exp.Start)
+		ix.expression(exp.End)
+		ix.expression(exp.Step)
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			ix.expression(pair.Key)
+			ix.expression(pair.Value)
+		}
+	case *ast.SetLiteral:
+		ix.expressions(exp.Elements)
+	case *ast.AwaitExpression:
+		ix.expression(exp.Value)
+	case *ast.MemberExpression:
+		ix.expression(exp.Object)
+	case *ast.ListComprehension:
+		ix.comprehension(exp, exp.Token, exp.Clauses, exp.Element)
+	case *ast.SetComprehension:
+		ix.comprehension(exp, exp.Token, exp.Clauses, exp.Element)
+	case *ast.HashComprehension:
+		ix.comprehension(exp, exp.Token, exp.Clauses, exp.Key, exp.Value)
+	case *ast.MatchExpression:
+		ix.match(exp)
+	}
+}
+
+// function indexes a function literal. Defaults are evaluated where the
+// function is defined, so they belong to the scope outside it.
+func (ix *index) function(fn *ast.FunctionLiteral) {
+	for _, p := range fn.Parameters {
+		ix.expression(p.Default)
+	}
+
+	s := ix.open(fn, fn.Token)
+	for _, p := range fn.Parameters {
+		sym := ix.bind(p.Name, parameterSymbol, nil)
+		switch {
+		case p.Rest:
+			sym.known = object.ARRAY_OBJ
+		case p.KeywordRest:
+			sym.known = object.HASH_OBJ
+		}
+	}
+	ix.block(fn.Body)
+	ix.close(s, &fn.Body.Rbrace)
+}
+
+func (ix *index) comprehension(node ast.Node, from lexer.Token, clauses []*ast.ComprehensionClause, results ...ast.Expression) {
+	s := ix.open(node, from)
+	for _, clause := range clauses {
+		ix.expression(clause.Iterable)
+		for _, n := range clause.Names {
+			ix.bind(n, variableSymbol, nil)
+		}
+		ix.expressions(clause.Filters)
+	}
+	ix.expressions(results)
+	ix.close(s, nil)
+}
+
+func (ix *index) match(node *ast.MatchExpression) {
+	ix.expression(node.Subject)
+
+	for _, mc := range node.Cases {
+		s := ix.open(mc, mc.Token)
+		ix.pattern(mc.Pattern)
+		ix.expression(mc.Guard)
+		ix.block(mc.Body)
+		ix.close(s, &mc.Body.Rbrace)
+	}
+}
+
+func (ix *index) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		ix.expression(pattern.Value)
+	case *ast.BindingPattern:
+		if !pattern.IsWildcard() {
+			ix.bind(pattern.Name, variableSymbol, nil)
+		}
+	case *ast.ArrayPattern:
+		for _, p := range pattern.Before {
+			ix.pattern(p)
+		}
+		for _, p := range pattern.After {
+			ix.pattern(p)
+		}
+		ix.rest(pattern.Rest, object.ARRAY_OBJ)
+	case *ast.HashPattern:
+		ix.expressions(pattern.Keys)
+		for _, p := range pattern.Values {
+			ix.pattern(p)
+		}
+		ix.rest(pattern.Rest, object.HASH_OBJ)
+	}
+}
+
+// rest binds the rest of an array or hash pattern, which collects a value
+// of type t
+func (ix *index) rest(rest *ast.Identifier, t object.ObjectType) {
+	if rest != nil && rest.Value != "_" {
+		ix.bind(rest, variableSymbol, nil).known = t
+	}
+}
+
+// typeOf returns the type of the value sym holds, or "" if it cannot be
+// told without running the program
+func (ix *index) typeOf(sym *symbol) object.ObjectType {
+	return ix.symbolType(sym, map[*symbol]bool{})
+}
+
+func (ix *index) symbolType(sym *symbol, seen map[*symbol]bool) object.ObjectType {
+	if seen[sym] {
+		return ""
+	}
+	seen[sym] = true
+	defer delete(seen, sym)
+
+	if sym.known != "" {
+		return sym.known
+	}
+
+	var t object.ObjectType
+	for i, value := range sym.values {
+		vt := ix.expressionType(value, seen)
+		if vt == "" || i > 0 && vt != t {
+			return ""
+		}
+		t = vt
+	}
+	return t
+}
+
+func (ix *index) expressionType(exp ast.Expression, seen map[*symbol]bool) object.ObjectType {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ
+	case *ast.StringLiteral:
+		return object.STRING_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.ArrayLiteral, *ast.ListComprehension:
+		return object.ARRAY_OBJ
+	case *ast.HashLiteral, *ast.HashComprehension:
+		return object.HASH_OBJ
+	case *ast.SetLiteral, *ast.SetComprehension:
+		return object.SET_OBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ
+	case *ast.Identifier:
+		if sym := ix.symbols[exp]; sym != nil {
+			return ix.symbolType(sym, seen)
+		}
+	case *ast.PrefixExpression:
+		if exp.Operator == lexer.BANG {
+			return object.BOOLEAN_OBJ
+		}
+		if ix.expressionType(exp.Right, seen) == object.INTEGER_OBJ {
+			return object.INTEGER_OBJ
+		}
+	case *ast.InfixExpression:
+		switch exp.Operator {
+		case lexer.EQ, lexer.NOT_EQ, lexer.LT, lexer.GT, lexer.LT_EQ, lexer.GT_EQ:
+			return object.BOOLEAN_OBJ
+		}
+		left, right := ix.expressionType(exp.Left, seen), ix.expressionType(exp.Right, seen)
+		switch {
+		case left == object.INTEGER_OBJ && right == object.INTEGER_OBJ:
+			return object.INTEGER_OBJ
+		case exp.Operator == lexer.PLUS && left == right && (left == object.STRING_OBJ || left == object.ARRAY_OBJ):
+			return left
+		}
+	case *ast.SliceExpression:
+		switch t := ix.expressionType(exp.Left, seen); t {
+		case object.STRING_OBJ, object.ARRAY_OBJ:
+			return t
+		}
+	}
+	return ""
+}
+
+// signature describes how to call fn, as in f(a, b = १, *c)
+func signature(name string, fn *ast.FunctionLiteral) string {
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		switch {
+		case p.Rest:
+			params[i] = "*" + p.Name.Value
+		case p.KeywordRest:
+			params[i] = "**" + p.Name.Value
+		case p.Default != nil:
+			params[i] = p.Name.Value + " = " + format.Expression(p.Default)
+		default:
+			params[i] = p.Name.Value
+		}
+	}
+
+	prefix := ""
+	if fn.IsAsync {
+		prefix = lexer.ASYNC + " "
+	}
+	return prefix + name + "(" + strings.Join(params, ", ") + ")"
+}
+
+// describe gives the first line of a hover over sym
+func (ix *index) describe(sym *symbol) string {
+	if sym.kind == functionSymbol {
+		if fn, ok := sym.values[0].(*ast.FunctionLiteral); ok {
+			return "(function) " + signature(sym.name, fn)
+		}
+	}
+	out := "(" + symbolKindNames[sym.kind] + ") " + sym.name
+	if t := ix.typeOf(sym); t != "" {
+		out += ": " + string(t)
+	}
+	return out
+}
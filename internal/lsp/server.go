@@ -0,0 +1,571 @@
+// Package lsp implements a Language Server Protocol server for Nepali
+// programs, so that editors can show their errors, complete and navigate
+// their names, and format them, for `nepali lsp`
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/evaluator"
+	"github.com/SunilNeupane77/nepali/internal/format"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/lint"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+// Serve answers the client whose messages arrive on r, writing responses
+// and notifications to w, until the client sends exit or r ends. Documents
+// are synced whole on every change.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{out: w, documents: make(map[string]*document)}
+	in := bufio.NewReader(r)
+
+	for {
+		content, err := readMessage(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			s.reply(nil, nil, &responseError{parseError, err.Error()})
+		} else if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		} else {
+			result, err := s.call(req)
+			if req.ID != nil {
+				s.reply(*req.ID, result, err)
+			}
+		}
+		if s.err != nil {
+			return s.err
+		}
+	}
+}
+
+type server struct {
+	out         io.Writer
+	err         error // the first error writing to out
+	initialized bool
+	shutdown    bool
+	documents   map[string]*document
+}
+
+func (s *server) write(v interface{}) {
+	if s.err == nil {
+		s.err = writeMessage(s.out, v)
+	}
+}
+
+func (s *server) reply(id json.RawMessage, result interface{}, err *responseError) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if err != nil {
+		s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: err})
+	} else {
+		s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+	}
+}
+
+func (s *server) notify(method string, params interface{}) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// call handles req, returning its result. A request that fails is reported
+// to the client rather than stopping the server.
+func (s *server) call(req request) (result interface{}, rerr *responseError) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, rerr = nil, &responseError{internalError, fmt.Sprintf("%s: %v", req.Method, r)}
+		}
+	}()
+
+	switch {
+	case req.Method == "initialize":
+		s.initialized = true
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           1, // the whole text on every change
+				"hoverProvider":              true,
+				"completionProvider":         map[string]interface{}{},
+				"definitionProvider":         true,
+				"referencesProvider":         true,
+				"renameProvider":             true,
+				"documentSymbolProvider":     true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "nepali lsp"},
+		}, nil
+	case !s.initialized:
+		return nil, &responseError{serverNotInitialized, "the server has not been initialized"}
+	case s.shutdown:
+		return nil, &responseError{invalidRequest, "the server has shut down"}
+	}
+
+	switch req.Method {
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+	case "textDocument/hover":
+		var params positionParams
+		d, err := s.document(req.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.hover(params.Position), nil
+	case "textDocument/completion":
+		var params positionParams
+		d, err := s.document(req.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.completion(params.Position), nil
+	case "textDocument/definition":
+		var params positionParams
+		d, err := s.document(req.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.definition(params.Position), nil
+	case "textDocument/references":
+		var params referenceParams
+		d, err := s.document(req.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.references(params.Position, params.Context.IncludeDeclaration), nil
+	case "textDocument/rename":
+		var params renameParams
+		d, err := s.document(req.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.rename(params.Position, params.NewName)
+	case "textDocument/documentSymbol":
+		var params documentParams
+		d, err := s.document(req.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.symbols(), nil
+	case "textDocument/formatting":
+		var params documentParams
+		d, err := s.document(req.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.format(), nil
+	}
+
+	if req.ID == nil {
+		return nil, nil // notifications the server does not handle are ignored
+	}
+	return nil, &responseError{methodNotFound, "unknown method " + req.Method}
+}
+
+func unmarshal(params json.RawMessage, v interface{}) *responseError {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{invalidParams, err.Error()}
+	}
+	return nil
+}
+
+// document decodes params into v and returns the open document they name
+func (s *server) document(params json.RawMessage, v interface{}) (*document, *responseError) {
+	if err := unmarshal(params, v); err != nil {
+		return nil, err
+	}
+	var named documentParams
+	json.Unmarshal(params, &named)
+
+	d, ok := s.documents[named.TextDocument.URI]
+	if !ok {
+		return nil, &responseError{invalidParams, "document not open: " + named.TextDocument.URI}
+	}
+	return d, nil
+}
+
+// update sets the text of the document at uri and publishes its problems
+func (s *server) update(uri, text string) {
+	d, ok := s.documents[uri]
+	if !ok {
+		d = &document{uri: uri}
+		s.documents[uri] = d
+	}
+	d.update(text)
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: d.diagnostics})
+}
+
+// document is a file open in the editor
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	// program and index are of the last text that parsed, so that names
+	// can be looked up and completed while the text being typed does not
+	program *ast.Program
+	index   *index
+	parsed  bool // whether the current text parsed
+
+	diagnostics []Diagnostic
+}
+
+func (d *document) update(text string) {
+	d.text = text
+	d.lines = strings.Split(text, "\n")
+	d.diagnostics = []Diagnostic{}
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		d.parsed = false
+		for i, msg := range p.Errors() {
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Range:    d.lineRange(p.ErrorLines()[i]),
+				Severity: severityError,
+				Source:   "nepali",
+				Message:  msg,
+			})
+		}
+		return
+	}
+
+	d.program, d.index, d.parsed = program, newIndex(program), true
+
+	found, err := lint.Source(text, d.lintConfig())
+	if err != nil {
+		return
+	}
+	for _, f := range found {
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.lineRange(f.Line),
+			Severity: severityWarning,
+			Code:     f.Rule,
+			Source:   "nepali lint",
+			Message:  f.Message,
+		})
+	}
+}
+
+// lintConfig returns the lint configuration for the document's file, the
+// nearest lint.ConfigFile above it, or one enabling every rule
+func (d *document) lintConfig() lint.Config {
+	u, err := url.Parse(d.uri)
+	if err != nil || u.Scheme != "file" {
+		return lint.Config{}
+	}
+	config, err := lint.FindConfig(filepath.Dir(filepath.FromSlash(u.Path)))
+	if err != nil {
+		return lint.Config{}
+	}
+	return config
+}
+
+// position returns the protocol's position for p
+func (d *document) position(p pos) Position {
+	line := ""
+	if p.line-1 < len(d.lines) {
+		line = d.lines[p.line-1]
+	}
+	character := 0
+	for i, r := range []rune(line) {
+		if i >= p.column-1 {
+			break
+		}
+		character += utf16Len(r)
+	}
+	return Position{Line: p.line - 1, Character: character}
+}
+
+// pos returns the place in the source at position
+func (d *document) pos(position Position) pos {
+	p := pos{line: position.Line + 1, column: 1}
+	if position.Line >= len(d.lines) {
+		return p
+	}
+	character := 0
+	for _, r := range d.lines[position.Line] {
+		if character >= position.Character {
+			break
+		}
+		character += utf16Len(r)
+		p.column++
+	}
+	return p
+}
+
+// utf16Len returns how many UTF-16 code units encode r
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (d *document) identRange(id *ast.Identifier) Range {
+	return Range{Start: d.position(start(id.Token)), End: d.position(end(id))}
+}
+
+// lineRange returns the range of the text on line, from 1, without the
+// indentation
+func (d *document) lineRange(line int) Range {
+	if line < 1 {
+		line = 1
+	}
+	if line > len(d.lines) {
+		line = len(d.lines)
+	}
+	text := strings.TrimRight(d.lines[line-1], " \t\r")
+	indent := utf8.RuneCountInString(text) - utf8.RuneCountInString(strings.TrimLeft(text, " \t"))
+	return Range{
+		Start: d.position(pos{line, indent + 1}),
+		End:   d.position(pos{line, utf8.RuneCountInString(text) + 1}),
+	}
+}
+
+// symbolAt returns the identifier at position, and the symbol it binds or
+// uses
+func (d *document) symbolAt(position Position) (*ast.Identifier, *symbol) {
+	if d.index == nil {
+		return nil, nil
+	}
+	id := d.index.identAt(d.pos(position))
+	if id == nil {
+		return nil, nil
+	}
+	return id, d.index.symbols[id]
+}
+
+func (d *document) hover(position Position) *Hover {
+	id, sym := d.symbolAt(position)
+	if id == nil {
+		return nil
+	}
+
+	var value string
+	if sym != nil {
+		value = "```nepali\n" + d.index.describe(sym) + "\n```"
+	} else if doc, ok := evaluator.BuiltinDoc(id.Value); ok {
+		// A builtin's documentation starts with its signature
+		signature, text := doc, ""
+		if i := strings.Index(doc, ") "); i >= 0 {
+			signature, text = doc[:i+1], doc[i+2:]
+		}
+		value = "```nepali\n(builtin) " + signature + "\n```\n" + text
+	} else {
+		return nil
+	}
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: d.identRange(id)}
+}
+
+// completion suggests the names in scope, builtins and keywords that start
+// with the word before position
+func (d *document) completion(position Position) []CompletionItem {
+	p := d.pos(position)
+	var before []rune
+	if p.line-1 < len(d.lines) {
+		before = []rune(d.lines[p.line-1])[:p.column-1]
+	}
+	i := len(before)
+	for i > 0 && (lexer.IsLetter(before[i-1]) || lexer.IsDigit(before[i-1])) {
+		i--
+	}
+	prefix := string(before[i:])
+
+	items := []CompletionItem{}
+	if i > 0 && before[i-1] == '.' {
+		return items // the members of a value are not known until it runs
+	}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if !seen[item.Label] && strings.HasPrefix(item.Label, prefix) {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	if ix := d.index; ix != nil {
+		for s := ix.scopeAt(p); s != nil; s = s.outer {
+			for _, name := range sortedNames(s) {
+				sym := s.names[name]
+				kind := completionVariable
+				switch sym.kind {
+				case functionSymbol:
+					kind = completionFunction
+				case constantSymbol:
+					kind = completionConstant
+				}
+				add(CompletionItem{Label: name, Kind: kind, Detail: ix.describe(sym)})
+			}
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		doc, _ := evaluator.BuiltinDoc(name)
+		add(CompletionItem{Label: name, Kind: completionFunction, Detail: doc})
+	}
+	for _, keyword := range lexer.Keywords() {
+		add(CompletionItem{Label: keyword, Kind: completionKeyword})
+	}
+	return items
+}
+
+func (d *document) definition(position Position) *Location {
+	_, sym := d.symbolAt(position)
+	if sym == nil {
+		return nil
+	}
+	return &Location{URI: d.uri, Range: d.identRange(sym.def)}
+}
+
+func (d *document) references(position Position, includeDeclaration bool) []Location {
+	_, sym := d.symbolAt(position)
+	if sym == nil {
+		return nil
+	}
+	locations := []Location{}
+	for _, id := range sym.refs {
+		if id != sym.def || includeDeclaration {
+			locations = append(locations, Location{URI: d.uri, Range: d.identRange(id)})
+		}
+	}
+	return locations
+}
+
+func (d *document) rename(position Position, name string) (*WorkspaceEdit, *responseError) {
+	if !d.parsed {
+		return nil, &responseError{requestFailed, "cannot rename until the file parses"}
+	}
+	id, sym := d.symbolAt(position)
+	switch {
+	case id == nil:
+		return nil, nil
+	case sym == nil:
+		return nil, &responseError{requestFailed, id.Value + " is not bound in this file"}
+	case !isIdentifier(name):
+		return nil, &responseError{invalidParams, fmt.Sprintf("%q is not a valid name", name)}
+	}
+	// Renaming to a name the same scope binds would merge two variables
+	if _, ok := sym.scope.names[name]; ok && name != sym.name {
+		return nil, &responseError{requestFailed, name + " is already bound in the same scope"}
+	}
+
+	edits := []TextEdit{}
+	for _, ref := range sym.refs {
+		edits = append(edits, TextEdit{Range: d.identRange(ref), NewText: name})
+	}
+	return &WorkspaceEdit{Changes: map[string][]TextEdit{d.uri: edits}}, nil
+}
+
+// isIdentifier reports whether name lexes as a single identifier
+func isIdentifier(name string) bool {
+	l := lexer.New(name)
+	tok := l.NextToken()
+	return tok.Type == lexer.IDENT && tok.Literal == name && l.NextToken().Type == lexer.EOF
+}
+
+// symbols returns the names the document binds at the top level
+func (d *document) symbols() []DocumentSymbol {
+	if d.program == nil {
+		return nil
+	}
+
+	symbols := []DocumentSymbol{}
+	for _, stmt := range d.program.Statements {
+		from := lexer.Token{}
+		let, ok := stmt.(*ast.LetStatement)
+		if export, isExport := stmt.(*ast.ExportStatement); isExport {
+			let, ok, from = export.Statement, true, export.Token
+		}
+		if !ok {
+			continue
+		}
+		if from.Line == 0 {
+			from = let.Token
+		}
+
+		kind := symbolVariable
+		r := Range{Start: d.position(start(from)), End: d.lineRange(let.Name.Token.Line).End}
+		if fn, isFunction := let.Value.(*ast.FunctionLiteral); isFunction {
+			kind = symbolFunction
+			r.End = d.position(pos{fn.Body.Rbrace.Line, fn.Body.Rbrace.Column + 1})
+		} else if let.Token.Type == lexer.CONST {
+			kind = symbolConstant
+		}
+		symbols = append(symbols, DocumentSymbol{
+			Name:           let.Name.Value,
+			Kind:           kind,
+			Range:          r,
+			SelectionRange: d.identRange(let.Name),
+		})
+	}
+	return symbols
+}
+
+// format returns the edit laying the document out canonically, as
+// `nepali fmt` would, replacing all its text
+func (d *document) format() []TextEdit {
+	formatted, err := format.Source(d.text, format.Options{})
+	if err != nil {
+		return nil
+	}
+	if formatted == d.text {
+		return []TextEdit{}
+	}
+
+	last := len(d.lines) - 1
+	all := Range{End: d.position(pos{last + 1, utf8.RuneCountInString(d.lines[last]) + 1})}
+	return []TextEdit{{Range: all, NewText: formatted}}
+}
+
+func sortedNames(s *scope) []string {
+	names := make([]string, 0, len(s.names))
+	for name := range s.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
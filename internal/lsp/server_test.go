@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSessions plays the scripted sessions in testdata. In a script, a line
+// starting "--> " is a message the client sends, and one starting "<-- " is
+// the next message the server must send, compared as JSON. Lines starting
+// with # are comments.
+func TestSessions(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no sessions in testdata")
+	}
+
+	for _, script := range scripts {
+		script := script
+		t.Run(strings.TrimSuffix(filepath.Base(script), ".txt"), func(t *testing.T) {
+			playSession(t, script)
+		})
+	}
+}
+
+func playSession(t *testing.T, script string) {
+	data, err := os.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientR, clientW := io.Pipe()
+	serverR, serverW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(clientR, serverW)
+		serverW.Close()
+	}()
+
+	// Collect what the server sends as it sends it, so that it never waits
+	// on the script
+	messages := make(chan []byte, 100)
+	go func() {
+		in := bufio.NewReader(serverR)
+		for {
+			content, err := readMessage(in)
+			if err != nil {
+				close(messages)
+				return
+			}
+			messages <- content
+		}
+	}()
+
+	for n, line := range strings.Split(string(data), "\n") {
+		n++
+		switch {
+		case strings.HasPrefix(line, "--> "):
+			if err := writeMessage(clientW, json.RawMessage(line[4:])); err != nil {
+				t.Fatalf("line %d: sending: %s", n, err)
+			}
+		case strings.HasPrefix(line, "<-- "):
+			select {
+			case got, ok := <-messages:
+				if !ok {
+					t.Fatalf("line %d: the server stopped before sending %s", n, line[4:])
+				}
+				if !sameJSON(t, got, []byte(line[4:])) {
+					t.Errorf("line %d: wrong message.\nexpected: %s\ngot:      %s", n, line[4:], got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("line %d: timed out waiting for %s", n, line[4:])
+			}
+		case line == "" || strings.HasPrefix(line, "#"):
+		default:
+			t.Fatalf("line %d: not part of a session: %q", n, line)
+		}
+	}
+
+	clientW.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Serve returned an error: %s", err)
+	}
+	for extra := range messages {
+		t.Errorf("unexpected message: %s", extra)
+	}
+}
+
+func sameJSON(t *testing.T, a, b []byte) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("bad JSON %s: %s", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("bad JSON %s: %s", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestExitBeforeShutdown(t *testing.T) {
+	var in strings.Builder
+	writeMessage(&in, json.RawMessage(`{"jsonrpc":"2.0","method":"exit"}`))
+
+	if err := Serve(strings.NewReader(in.String()), io.Discard); err == nil {
+		t.Errorf("expected an error for exit before shutdown")
+	}
+}
@@ -0,0 +1,244 @@
+package object
+
+import (
+	"sort"
+	"strings"
+)
+
+// typeRank orders values of different types: null, booleans, integers,
+// strings, arrays, hashes, sets, then everything else by type name
+var typeRank = map[ObjectType]int{
+	NULL_OBJ:    0,
+	BOOLEAN_OBJ: 1,
+	INTEGER_OBJ: 2,
+	STRING_OBJ:  3,
+	ARRAY_OBJ:   4,
+	HASH_OBJ:    5,
+	SET_OBJ:     6,
+}
+
+const otherRank = 7
+
+// Equal reports whether a and b are structurally equal. Arrays are equal
+// when their elements are pairwise equal; hashes when they hold equal values
+// under the same keys, and sets when they hold the same elements, regardless
+// of insertion order. Values with no
+// structure of their own, such as functions, are equal only to themselves.
+// Cyclic values are handled: a pair already being compared further up is
+// assumed equal.
+func Equal(a, b Object) bool {
+	return newComparer().equal(a, b)
+}
+
+// Compare returns -1, 0 or 1 as a sorts before, equal to or after b. It is
+// a total order over all values, so it can back sorting builtins, and for
+// data values it agrees with Equal. Values of different types order by type
+// first.
+func Compare(a, b Object) int {
+	return newComparer().compare(a, b)
+}
+
+type objectPair struct {
+	a, b Object
+}
+
+// comparer remembers the composite pairs currently being compared, to stop
+// cycles recursing forever
+type comparer struct {
+	active map[objectPair]bool
+}
+
+func newComparer() *comparer {
+	return &comparer{active: make(map[objectPair]bool)}
+}
+
+func (c *comparer) equal(a, b Object) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil || a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer, *Boolean, *String:
+		return KeysEqual(a, b)
+	case *Null:
+		return true
+	case *Array:
+		b := b.(*Array)
+		if len(a.Elements) != len(b.Elements) {
+			return false
+		}
+		if c.enter(a, b) {
+			return true
+		}
+		defer c.leave(a, b)
+
+		for i := range a.Elements {
+			if !c.equal(a.Elements[i], b.Elements[i]) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b := b.(*Hash)
+		if a.Len() != b.Len() {
+			return false
+		}
+		if c.enter(a, b) {
+			return true
+		}
+		defer c.leave(a, b)
+
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !c.equal(pair.Value, other) {
+				return false
+			}
+		}
+		return true
+	case *Set:
+		b := b.(*Set)
+		if a.Len() != b.Len() {
+			return false
+		}
+		for _, el := range a.Elements() {
+			if !b.Has(el.(Hashable)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *comparer) compare(a, b Object) int {
+	if a == b {
+		return 0
+	}
+
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return sign(ra - rb)
+	}
+	if a.Type() != b.Type() {
+		return strings.Compare(string(a.Type()), string(b.Type()))
+	}
+
+	switch a := a.(type) {
+	case *Null:
+		return 0
+	case *Boolean:
+		return compareBool(a.Value, b.(*Boolean).Value)
+	case *Integer:
+		return compareInt(a.Value, b.(*Integer).Value)
+	case *String:
+		return strings.Compare(a.Value, b.(*String).Value)
+	case *Array:
+		b := b.(*Array)
+		if c.enter(a, b) {
+			return 0
+		}
+		defer c.leave(a, b)
+
+		return c.compareSlices(a.Elements, b.Elements)
+	case *Hash:
+		b := b.(*Hash)
+		if c.enter(a, b) {
+			return 0
+		}
+		defer c.leave(a, b)
+
+		// Hashes equal regardless of insertion order must compare equal, so
+		// compare their pairs sorted by key
+		pa, pb := c.sortedPairs(a), c.sortedPairs(b)
+		for i := 0; i < len(pa) && i < len(pb); i++ {
+			if r := c.compare(pa[i].Key, pb[i].Key); r != 0 {
+				return r
+			}
+			if r := c.compare(pa[i].Value, pb[i].Value); r != 0 {
+				return r
+			}
+		}
+		return compareInt(int64(len(pa)), int64(len(pb)))
+	case *Set:
+		// Set elements are hashable scalars, so no cycle can pass through them
+		return c.compareSlices(c.sorted(a.Elements()), c.sorted(b.(*Set).Elements()))
+	default:
+		// Opaque values have no order of their own; their printed form keeps
+		// the result stable
+		return strings.Compare(a.Inspect(), b.Inspect())
+	}
+}
+
+func (c *comparer) compareSlices(a, b []Object) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if r := c.compare(a[i], b[i]); r != 0 {
+			return r
+		}
+	}
+	return compareInt(int64(len(a)), int64(len(b)))
+}
+
+func (c *comparer) sorted(objs []Object) []Object {
+	sort.Slice(objs, func(i, j int) bool {
+		return c.compare(objs[i], objs[j]) < 0
+	})
+	return objs
+}
+
+func (c *comparer) sortedPairs(h *Hash) []HashPair {
+	pairs := append([]HashPair{}, h.Pairs()...)
+	sort.Slice(pairs, func(i, j int) bool {
+		return c.compare(pairs[i].Key, pairs[j].Key) < 0
+	})
+	return pairs
+}
+
+// enter marks a and b as being compared, reporting whether they already were
+func (c *comparer) enter(a, b Object) bool {
+	p := objectPair{a, b}
+	if c.active[p] {
+		return true
+	}
+	c.active[p] = true
+	return false
+}
+
+func (c *comparer) leave(a, b Object) {
+	delete(c.active, objectPair{a, b})
+}
+
+func rank(obj Object) int {
+	if r, ok := typeRank[obj.Type()]; ok {
+		return r
+	}
+	return otherRank
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+func sign(n int) int {
+	return compareInt(int64(n), 0)
+}
@@ -0,0 +1,101 @@
+package object
+
+import "testing"
+
+func arr(elements ...Object) *Array {
+	return &Array{Elements: elements}
+}
+
+func hash(pairs ...Object) *Hash {
+	h := NewHash()
+	for i := 0; i < len(pairs); i += 2 {
+		h.Set(pairs[i].(Hashable), pairs[i+1])
+	}
+	return h
+}
+
+func integer(v int64) *Integer { return &Integer{Value: v} }
+
+func str(v string) *String { return &String{Value: v} }
+
+func TestEqual(t *testing.T) {
+	fn := &Function{}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{integer(1), integer(1), true},
+		{integer(1), str("1"), false},
+		{str("नेपाल"), str("नेपाल"), true},
+		{NULL, &Null{}, true},
+		{arr(integer(1), integer(2)), arr(integer(1), integer(2)), true},
+		{arr(integer(1), integer(2)), arr(integer(2), integer(1)), false},
+		{arr(integer(1)), arr(integer(1), integer(2)), false},
+		{arr(arr(str("क")), hash()), arr(arr(str("क")), hash()), true},
+		{hash(str("a"), integer(1), str("b"), integer(2)), hash(str("b"), integer(2), str("a"), integer(1)), true},
+		{hash(str("a"), integer(1)), hash(str("a"), integer(2)), false},
+		{hash(str("a"), integer(1)), hash(str("b"), integer(1)), false},
+		{fn, fn, true},
+		{fn, &Function{}, false},
+	}
+
+	for i, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - Equal(%s, %s) wrong. expected=%t, got=%t",
+				i, tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+	}
+}
+
+func TestEqualCyclic(t *testing.T) {
+	a := arr(integer(1), nil)
+	a.Elements[1] = a
+	b := arr(integer(1), nil)
+	b.Elements[1] = b
+	c := arr(integer(2), nil)
+	c.Elements[1] = c
+
+	if !Equal(a, b) {
+		t.Error("identical cyclic arrays should be equal")
+	}
+	if Equal(a, c) {
+		t.Error("different cyclic arrays should not be equal")
+	}
+	if Compare(a, b) != 0 || Compare(a, c) != -1 {
+		t.Errorf("wrong ordering of cyclic arrays. got=%d, %d", Compare(a, b), Compare(a, c))
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b     Object
+		expected int
+	}{
+		{integer(1), integer(2), -1},
+		{integer(2), integer(2), 0},
+		{FALSE, TRUE, -1},
+		{str("क"), str("ख"), -1},
+		{str("ab"), str("a"), 1},
+		{NULL, FALSE, -1},
+		{TRUE, integer(0), -1},
+		{integer(100), str(""), -1},
+		{str("z"), arr(), -1},
+		{arr(), hash(), -1},
+		{arr(integer(1), integer(2)), arr(integer(1), integer(3)), -1},
+		{arr(integer(1)), arr(integer(1), integer(0)), -1},
+		{hash(str("b"), integer(1), str("a"), integer(1)), hash(str("a"), integer(1), str("b"), integer(1)), 0},
+		{hash(str("a"), integer(1)), hash(str("a"), integer(2)), -1},
+	}
+
+	for i, tt := range tests {
+		if got := Compare(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - Compare(%s, %s) wrong. expected=%d, got=%d",
+				i, tt.a.Inspect(), tt.b.Inspect(), tt.expected, got)
+		}
+		if got := Compare(tt.b, tt.a); got != -tt.expected {
+			t.Errorf("tests[%d] - Compare(%s, %s) not antisymmetric. expected=%d, got=%d",
+				i, tt.b.Inspect(), tt.a.Inspect(), -tt.expected, got)
+		}
+	}
+}
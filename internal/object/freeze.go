@@ -0,0 +1,43 @@
+package object
+
+// Freeze makes obj read-only, along with every array, hash and set reachable
+// from it, and returns obj. Other values are immutable already.
+func Freeze(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return obj
+		}
+		obj.Frozen = true
+		for _, el := range obj.Elements {
+			Freeze(el)
+		}
+	case *Hash:
+		if obj.Frozen {
+			return obj
+		}
+		obj.Frozen = true
+		for _, pair := range obj.pairs {
+			Freeze(pair.Value)
+		}
+	case *Set:
+		// elements are hashable scalars, which cannot change
+		obj.Frozen = true
+	}
+
+	return obj
+}
+
+// IsFrozen reports whether obj is a frozen array, hash or set
+func IsFrozen(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Frozen
+	case *Hash:
+		return obj.Frozen
+	case *Set:
+		return obj.Frozen
+	default:
+		return false
+	}
+}
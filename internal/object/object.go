@@ -2,10 +2,13 @@
 package object
 
 import (
+	"bufio"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/code"
 )
 
 // Object represents a runtime object
@@ -18,16 +21,21 @@ type Object interface {
 type ObjectType string
 
 const (
-	INTEGER_OBJ  = "INTEGER"
-	BOOLEAN_OBJ  = "BOOLEAN"
-	NULL_OBJ     = "NULL"
+	INTEGER_OBJ      = "INTEGER"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
-	ERROR_OBJ    = "ERROR"
-	FUNCTION_OBJ = "FUNCTION"
-	STRING_OBJ   = "STRING"
-	BUILTIN_OBJ  = "BUILTIN"
-	ARRAY_OBJ    = "ARRAY"
-	HASH_OBJ     = "HASH"
+	ERROR_OBJ        = "ERROR"
+	FUNCTION_OBJ     = "FUNCTION"
+	COMPILED_FN_OBJ  = "COMPILED_FUNCTION"
+	STRING_OBJ       = "STRING"
+	BUILTIN_OBJ      = "BUILTIN"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	SET_OBJ          = "SET"
+	PROMISE_OBJ      = "PROMISE"
+	MODULE_OBJ       = "MODULE"
+	FILE_OBJ         = "FILE"
 )
 
 // Integer represents an integer object
@@ -60,7 +68,7 @@ func (b *Boolean) Inspect() string {
 }
 
 // Null represents the null object
-type Null struct {}
+type Null struct{}
 
 func (n *Null) Type() ObjectType {
 	return NULL_OBJ
@@ -70,6 +78,15 @@ func (n *Null) Inspect() string {
 	return "निल"
 }
 
+// NULL, TRUE and FALSE are the shared singleton values. Truthiness checks
+// compare against them by identity, so builtins must return these rather
+// than allocating their own.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
 // ReturnValue represents a return value object
 type ReturnValue struct {
 	Value Object
@@ -86,6 +103,7 @@ func (rv *ReturnValue) Inspect() string {
 // Error represents an error object
 type Error struct {
 	Message string
+	Trace   []string // names of the functions it propagated out of, innermost first
 }
 
 func (e *Error) Type() ObjectType {
@@ -96,11 +114,50 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Backtrace returns e's trace as it is shown, innermost first. A run of
+// calls of one function, as recursion makes, is one entry noting how many
+// there were, so that a stack overflow does not print thousands of lines.
+func (e *Error) Backtrace() []string {
+	lines := []string{}
+	for i := 0; i < len(e.Trace); {
+		n := 1
+		for i+n < len(e.Trace) && e.Trace[i+n] == e.Trace[i] {
+			n++
+		}
+		if n == 1 {
+			lines = append(lines, e.Trace[i])
+		} else {
+			lines = append(lines, fmt.Sprintf("%s (×%d)", e.Trace[i], n))
+		}
+		i += n
+	}
+	return lines
+}
+
+// Value returns e as a value a program can hold, as a सँग block's निकास
+// hook is given it: a hash of its message, under "सन्देश", and its trace,
+// under "ट्रेस". e itself cannot be held, since evaluating to it raises it.
+func (e *Error) Value() *Hash {
+	trace := make([]Object, len(e.Trace))
+	for i, name := range e.Trace {
+		trace[i] = &String{Value: name}
+	}
+
+	h := NewHash()
+	h.Set(&String{Value: "सन्देश"}, &String{Value: e.Message})
+	h.Set(&String{Value: "ट्रेस"}, &Array{Elements: trace})
+	return h
+}
+
 // Function represents a function object
 type Function struct {
+	Name       string // empty for anonymous functions
+	Doc        string // the docstring: a string literal opening the body
 	Parameters []*Parameter
 	Body       *ast.BlockStatement
+	Locals     []string // the slots of a call's environment, parameters first
 	Env        *Environment
+	IsAsync    bool
 }
 
 func (f *Function) Type() ObjectType {
@@ -110,17 +167,99 @@ func (f *Function) Type() ObjectType {
 func (f *Function) Inspect() string {
 	params := []string{}
 	for _, p := range f.Parameters {
-		params = append(params, p.Name)
+		params = append(params, p.String())
 	}
 
-	return fmt.Sprintf("फन(%s) {\n%s\n}",
+	prefix := ""
+	if f.IsAsync {
+		prefix = "एसिन्क "
+	}
+
+	name := ""
+	if f.Name != "" {
+		name = " " + f.Name
+	}
+
+	return fmt.Sprintf("%sफन%s(%s) {\n%s\n}",
+		prefix,
+		name,
 		strings.Join(params, ", "),
 		f.Body.String())
 }
 
 // Parameter represents a function parameter
 type Parameter struct {
-	Name string
+	Name        string
+	Default     Object // evaluated when the function is defined; nil if none
+	HasDefault  bool   // for compiled functions, whose defaults are not known yet
+	Rest        bool   // *name: collects extra positional arguments
+	KeywordRest bool   // **name: collects extra keyword arguments
+}
+
+func (p *Parameter) String() string {
+	switch {
+	case p.Rest:
+		return "*" + p.Name
+	case p.KeywordRest:
+		return "**" + p.Name
+	case p.Default != nil:
+		return p.Name + " = " + p.Default.Inspect()
+	default:
+		return p.Name
+	}
+}
+
+// Callable is implemented by function values that know how to run
+// themselves, such as closures of the virtual machine. Builtins taking
+// callbacks call these directly.
+type Callable interface {
+	Object
+	Call(args ...Object) Object
+}
+
+// CompiledFunction represents a function compiled to bytecode. It is a
+// constant of the code that defines it; running that code turns it into a
+// closure.
+type CompiledFunction struct {
+	Name         string // empty for anonymous functions
+	Doc          string
+	Body         string // the source of the body, for printing
+	Instructions code.Instructions
+	Constants    []Object
+	Parameters   []*Parameter // defaults are evaluated when the closure is made
+	NumLocals    int          // parameters first, then the other locals
+	LocalNames   []string     // by slot
+	Free         []FreeVariable
+	Lines        code.LineTable
+	IsAsync      bool // calling it returns a promise
+}
+
+// FreeVariable describes a variable a closure captures from the function
+// that creates it: a local of that function, or one of its own free
+// variables
+type FreeVariable struct {
+	Name  string
+	Local bool
+	Index int
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FN_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%s]", cf.Name)
+}
+
+// NumDefaults returns how many parameters have default values
+func (cf *CompiledFunction) NumDefaults() int {
+	n := 0
+	for _, p := range cf.Parameters {
+		if p.HasDefault {
+			n++
+		}
+	}
+	return n
 }
 
 // String represents a string object
@@ -154,6 +293,7 @@ func (b *Builtin) Inspect() string {
 // Array represents an array object
 type Array struct {
 	Elements []Object
+	Frozen   bool // set by Freeze; a frozen array must not be modified
 }
 
 func (a *Array) Type() ObjectType {
@@ -161,17 +301,56 @@ func (a *Array) Type() ObjectType {
 }
 
 func (a *Array) Inspect() string {
-	elements := []string{}
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+	return inspect(a, map[Object]bool{})
+}
+
+// inspect returns obj as Inspect does. An array or hash found inside
+// itself, which seen holds those on the way to obj for, is shown as [...]
+// or {...} rather than again.
+func inspect(obj Object, seen map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, seen))
+		}
+		return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+	case *Hash:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s",
+				inspect(pair.Key, seen),
+				inspect(pair.Value, seen)))
+		}
+		return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+	case *Promise:
+		// A promise can hold what holds it
+		if obj.Settled() {
+			return fmt.Sprintf("प्रतिज्ञा{%s}", inspect(obj.Value, seen))
+		}
 	}
-
-	return fmt.Sprintf("[%s]", strings.Join(elements, ", "))
+	return obj.Inspect()
 }
 
-// Hash represents a hash object
+// Hash represents a hash object. Pairs are kept in insertion order, and
+// keys whose HashKey collides are told apart by comparing the keys
+// themselves.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	pairs   []HashPair
+	buckets map[HashKey][]int // HashKey -> indexes into pairs
+	Frozen  bool              // set by Freeze; a frozen hash must not be modified
 }
 
 type HashKey struct {
@@ -184,52 +363,427 @@ type HashPair struct {
 	Value Object
 }
 
+// NewHash creates an empty Hash
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
+}
+
 func (h *Hash) Type() ObjectType {
 	return HASH_OBJ
 }
 
 func (h *Hash) Inspect() string {
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s",
-			pair.Key.Inspect(),
-			pair.Value.Inspect()))
+	return inspect(h, map[Object]bool{})
+}
+
+// Get returns the value stored under key
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	if i, ok := h.find(key); ok {
+		return h.pairs[i].Value, true
+	}
+	return nil, false
+}
+
+// Set stores val under key. A new key goes after all existing ones; an
+// existing key keeps its position.
+func (h *Hash) Set(key Hashable, val Object) {
+	if i, ok := h.find(key); ok {
+		h.pairs[i].Value = val
+		return
+	}
+
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+
+	hk := key.HashKey()
+	h.buckets[hk] = append(h.buckets[hk], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: val})
+}
+
+// Len returns the number of pairs in the hash
+func (h *Hash) Len() int {
+	return len(h.pairs)
+}
+
+// Pairs returns the hash's pairs in insertion order. The slice must not be
+// modified.
+func (h *Hash) Pairs() []HashPair {
+	return h.pairs
+}
+
+// Delete removes key and its value, reporting whether the key was present.
+// The remaining pairs keep their order.
+func (h *Hash) Delete(key Hashable) bool {
+	i, ok := h.find(key)
+	if !ok {
+		return false
+	}
+
+	h.pairs = append(h.pairs[:i], h.pairs[i+1:]...)
+	h.buckets = make(map[HashKey][]int, len(h.pairs))
+	for j, pair := range h.pairs {
+		hk := pair.Key.(Hashable).HashKey()
+		h.buckets[hk] = append(h.buckets[hk], j)
+	}
+	return true
+}
+
+func (h *Hash) find(key Hashable) (int, bool) {
+	for _, i := range h.buckets[key.HashKey()] {
+		if KeysEqual(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// KeysEqual reports whether two hashable keys have the same type and value
+func KeysEqual(a, b Object) bool {
+	switch a := a.(type) {
+	case *Integer:
+		b, ok := b.(*Integer)
+		return ok && a.Value == b.Value
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	default:
+		return a == b
+	}
+}
+
+// Set represents a set of hashable values, kept in insertion order
+type Set struct {
+	items  Hash // each element is stored as its own key
+	Frozen bool // set by Freeze; a frozen set must not be modified
+}
+
+// NewSet creates an empty Set
+func NewSet() *Set {
+	return &Set{}
+}
+
+func (s *Set) Type() ObjectType {
+	return SET_OBJ
+}
+
+func (s *Set) Inspect() string {
+	elements := []string{}
+	for _, e := range s.Elements() {
+		elements = append(elements, e.Inspect())
+	}
+
+	return fmt.Sprintf("सेट{%s}", strings.Join(elements, ", "))
+}
+
+// Add inserts val unless the set already holds it
+func (s *Set) Add(val Hashable) {
+	if _, ok := s.items.Get(val); !ok {
+		s.items.Set(val, val)
+	}
+}
+
+// Remove deletes val from the set, reporting whether it was there
+func (s *Set) Remove(val Hashable) bool {
+	return s.items.Delete(val)
+}
+
+// Has reports whether the set holds val
+func (s *Set) Has(val Hashable) bool {
+	_, ok := s.items.Get(val)
+	return ok
+}
+
+// Len returns the number of elements in the set
+func (s *Set) Len() int {
+	return s.items.Len()
+}
+
+// Elements returns the set's elements in insertion order
+func (s *Set) Elements() []Object {
+	elements := make([]Object, 0, s.items.Len())
+	for _, pair := range s.items.Pairs() {
+		elements = append(elements, pair.Key)
+	}
+	return elements
+}
+
+// PromiseState is the settlement state of a Promise
+type PromiseState int
+
+const (
+	PromisePending PromiseState = iota
+	PromiseFulfilled
+	PromiseRejected
+)
+
+// Promise represents the eventual result of an async function call
+type Promise struct {
+	State PromiseState
+	Value Object // the result once fulfilled, or an *Error once rejected
+
+	callbacks []func()
+}
+
+func (p *Promise) Type() ObjectType {
+	return PROMISE_OBJ
+}
+
+func (p *Promise) Inspect() string {
+	if !p.Settled() {
+		return "प्रतिज्ञा{पर्खँदै}"
+	}
+	return inspect(p, map[Object]bool{})
+}
+
+// Settled reports whether the promise has been fulfilled or rejected
+func (p *Promise) Settled() bool {
+	return p.State != PromisePending
+}
+
+// Resolve fulfills the promise with val and runs any waiting callbacks
+func (p *Promise) Resolve(val Object) {
+	p.settle(PromiseFulfilled, val)
+}
+
+// Reject rejects the promise with err and runs any waiting callbacks
+func (p *Promise) Reject(err *Error) {
+	p.settle(PromiseRejected, err)
+}
+
+// OnSettle registers cb to run once the promise settles. If it already has,
+// cb runs immediately.
+func (p *Promise) OnSettle(cb func()) {
+	if p.Settled() {
+		cb()
+		return
+	}
+	p.callbacks = append(p.callbacks, cb)
+}
+
+func (p *Promise) settle(state PromiseState, val Object) {
+	if p.Settled() {
+		return
+	}
+
+	p.State = state
+	p.Value = val
+
+	callbacks := p.callbacks
+	p.callbacks = nil
+	for _, cb := range callbacks {
+		cb()
 	}
+}
+
+// Module represents an imported source file. Only the names the file
+// exported are reachable through it.
+type Module struct {
+	Name    string
+	Path    string
+	Exports map[string]Object
+}
+
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
 
-	return fmt.Sprintf("{%s}", strings.Join(pairs, ", "))
+func (m *Module) Inspect() string {
+	return fmt.Sprintf("मोड्युल{%s}", m.Name)
 }
 
-// Environment represents the runtime environment
+// File represents an open file
+type File struct {
+	Path   string
+	Mode   string
+	Handle *os.File
+	Reader *bufio.Reader // nil unless the file was opened for reading
+	Closed bool
+}
+
+func (f *File) Type() ObjectType {
+	return FILE_OBJ
+}
+
+func (f *File) Inspect() string {
+	state := ""
+	if f.Closed {
+		state = ", बन्द"
+	}
+	return fmt.Sprintf("फाइल{%s, %s%s}", f.Path, f.Mode, state)
+}
+
+// Environment holds the variables of a scope in numbered slots. The
+// resolver gives every variable its slot ahead of time, so the evaluator
+// reaches it by index; names are kept alongside for lookups by name, as
+// module exports and the REPL make. Slots that are unbound hold nil.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	names     []string // the name of each slot
+	values    []Object
+	index     map[string]int // slot of each name, once names is our own
+	constants map[int]bool   // slots declared with स्थिर in this scope
+	outer     *Environment
+	file      string // for a top-level environment, the file its program came from
 }
 
+// NewEnvironment creates an empty top-level environment, which grows as
+// names are defined in it
 func NewEnvironment() *Environment {
-	return &Environment{
-		store: make(map[string]Object),
-	}
+	return &Environment{index: make(map[string]int)}
 }
 
+// NewEnclosedEnvironment creates an empty environment enclosed by outer
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
 	return env
 }
 
+// NewScope creates an environment enclosed by outer with a slot for each
+// of names, all unbound. names is shared, not copied.
+func NewScope(outer *Environment, names []string) *Environment {
+	return &Environment{
+		names:  names,
+		values: make([]Object, len(names)),
+		outer:  outer,
+	}
+}
+
+// Outer returns the enclosing environment, or nil for a top-level one
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// File returns the file the program run in e came from, or "" for code
+// that did not come from a file
+func (e *Environment) File() string {
+	for e.outer != nil {
+		e = e.outer
+	}
+	return e.file
+}
+
+// SetFile records the file the program run in the top-level environment e
+// comes from
+func (e *Environment) SetFile(path string) {
+	e.file = path
+}
+
+// Names returns the name of each slot, in slot order
+func (e *Environment) Names() []string {
+	return e.names
+}
+
+// Slot returns the slot of name in this scope
+func (e *Environment) Slot(name string) (int, bool) {
+	if e.index != nil {
+		slot, ok := e.index[name]
+		return slot, ok
+	}
+	for slot, n := range e.names {
+		if n == name {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+// Define returns the slot of name in this scope, adding one if it has none
+func (e *Environment) Define(name string) int {
+	if slot, ok := e.Slot(name); ok {
+		return slot
+	}
+
+	// names may be shared with other scopes, so take a copy before growing
+	if e.index == nil {
+		e.names = append([]string{}, e.names...)
+		e.index = make(map[string]int, len(e.names))
+		for slot, n := range e.names {
+			e.index[n] = slot
+		}
+	}
+
+	e.index[name] = len(e.names)
+	e.names = append(e.names, name)
+	e.values = append(e.values, nil)
+	return len(e.names) - 1
+}
+
+// Lookup returns the value in slot of the scope depth levels out from e,
+// or nil if it is unbound
+func (e *Environment) Lookup(depth, slot int) Object {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	if slot < len(e.values) {
+		return e.values[slot]
+	}
+	return nil
+}
+
+// Ancestor returns the scope depth levels out from e
+func (e *Environment) Ancestor(depth int) *Environment {
+	for ; depth > 0; depth-- {
+		e = e.outer
+	}
+	return e
+}
+
+// Get returns the value bound to name, looking in enclosing scopes when
+// it is unbound in this one
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
+	if slot, ok := e.Slot(name); ok && e.values[slot] != nil {
+		return e.values[slot], true
+	}
+	if e.outer != nil {
 		return e.outer.Get(name)
 	}
-	return obj, ok
+	return nil, false
 }
 
+// Set binds name in this scope
 func (e *Environment) Set(name string, val Object) Object {
-	e.store[name] = val
+	return e.Assign(e.Define(name), val)
+}
+
+// Assign binds slot in this scope
+func (e *Environment) Assign(slot int, val Object) Object {
+	for slot >= len(e.values) {
+		e.values = append(e.values, nil)
+	}
+	e.values[slot] = val
 	return val
 }
 
+// SetConst binds name to val and marks the binding as constant
+func (e *Environment) SetConst(name string, val Object) Object {
+	return e.AssignConst(e.Define(name), val)
+}
+
+// AssignConst binds slot to val and marks the binding as constant
+func (e *Environment) AssignConst(slot int, val Object) Object {
+	if e.constants == nil {
+		e.constants = make(map[int]bool)
+	}
+	e.constants[slot] = true
+	return e.Assign(slot, val)
+}
+
+// IsConst reports whether name is a constant of this scope. Constants of
+// enclosing scopes can be shadowed, so they are not consulted.
+func (e *Environment) IsConst(name string) bool {
+	slot, ok := e.Slot(name)
+	return ok && e.constants[slot]
+}
+
+// IsConstSlot reports whether slot holds a constant of this scope
+func (e *Environment) IsConstSlot(slot int) bool {
+	return e.constants[slot]
+}
+
 // Hashable represents an object that can be used as a hash key
 type Hashable interface {
 	Object
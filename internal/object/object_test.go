@@ -0,0 +1,187 @@
+package object
+
+import "testing"
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	h := NewHash()
+	for _, k := range []string{"ग", "क", "ख"} {
+		h.Set(&String{Value: k}, &Integer{Value: int64(len(k))})
+	}
+	h.Set(&String{Value: "क"}, &Integer{Value: 1})
+	h.Set(&Integer{Value: 0}, TRUE)
+
+	expected := "{ग: 3, क: 1, ख: 3, 0: सत्य}"
+	if h.Inspect() != expected {
+		t.Errorf("wrong order. expected=%s, got=%s", expected, h.Inspect())
+	}
+}
+
+func TestHashCollidingKeys(t *testing.T) {
+	a := &String{Value: "b!"}
+	b := &String{Value: "aB"}
+	if a.HashKey() != b.HashKey() {
+		t.Fatalf("test keys no longer collide: %v != %v", a.HashKey(), b.HashKey())
+	}
+
+	h := NewHash()
+	h.Set(a, &Integer{Value: 1})
+	h.Set(b, &Integer{Value: 2})
+
+	if h.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. len=%d", h.Len())
+	}
+
+	for key, expected := range map[string]int64{"b!": 1, "aB": 2} {
+		val, ok := h.Get(&String{Value: key})
+		if !ok {
+			t.Fatalf("key %q not found", key)
+		}
+		if val.(*Integer).Value != expected {
+			t.Errorf("wrong value for %q. expected=%d, got=%d", key, expected, val.(*Integer).Value)
+		}
+	}
+}
+
+func TestHashKeysOfDifferentTypes(t *testing.T) {
+	h := NewHash()
+	h.Set(&Integer{Value: 1}, &String{Value: "integer"})
+	h.Set(TRUE, &String{Value: "boolean"})
+
+	if val, _ := h.Get(&Integer{Value: 1}); val.Inspect() != "integer" {
+		t.Errorf("wrong value for 1. got=%s", val.Inspect())
+	}
+	if val, _ := h.Get(TRUE); val.Inspect() != "boolean" {
+		t.Errorf("wrong value for सत्य. got=%s", val.Inspect())
+	}
+}
+
+func TestSetKeepsFirstInsertion(t *testing.T) {
+	s := NewSet()
+	s.Add(&String{Value: "ख"})
+	s.Add(&Integer{Value: 1})
+	s.Add(&String{Value: "ख"})
+	s.Add(&String{Value: "1"})
+
+	if s.Len() != 3 {
+		t.Errorf("wrong length. expected=3, got=%d", s.Len())
+	}
+	if !s.Has(&Integer{Value: 1}) || s.Has(&Integer{Value: 2}) {
+		t.Errorf("wrong membership")
+	}
+	if s.Inspect() != "सेट{ख, 1, 1}" {
+		t.Errorf("wrong order. got=%s", s.Inspect())
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	inner := &Array{Elements: []Object{&Integer{Value: 1}}}
+	set := NewSet()
+	set.Add(&Integer{Value: 2})
+	hash := NewHash()
+	hash.Set(&String{Value: "a"}, inner)
+	hash.Set(&String{Value: "b"}, set)
+
+	if IsFrozen(hash) || IsFrozen(inner) {
+		t.Fatalf("new values must not be frozen")
+	}
+
+	Freeze(hash)
+
+	for _, obj := range []Object{hash, inner, set} {
+		if !IsFrozen(obj) {
+			t.Errorf("%s not frozen", obj.Inspect())
+		}
+	}
+	if IsFrozen(&Integer{Value: 1}) {
+		t.Errorf("integers are never frozen")
+	}
+}
+
+func TestHashDelete(t *testing.T) {
+	hash := NewHash()
+	for _, k := range []string{"a", "b", "c"} {
+		hash.Set(&String{Value: k}, &Integer{Value: 1})
+	}
+
+	if !hash.Delete(&String{Value: "b"}) {
+		t.Fatalf("Delete returned false for a present key")
+	}
+	if hash.Delete(&String{Value: "b"}) {
+		t.Fatalf("Delete returned true for a missing key")
+	}
+	if _, ok := hash.Get(&String{Value: "b"}); ok {
+		t.Errorf("deleted key still present")
+	}
+	if _, ok := hash.Get(&String{Value: "c"}); !ok || hash.Len() != 2 {
+		t.Errorf("other keys lost. len=%d", hash.Len())
+	}
+}
+
+func TestInspectCycles(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+	if got := array.Inspect(); got != "[1, [...]]" {
+		t.Errorf("wrong array. got=%s", got)
+	}
+
+	hash := NewHash()
+	hash.Set(&String{Value: "a"}, hash)
+	hash.Set(&String{Value: "b"}, &Array{Elements: []Object{hash, array}})
+	if got := hash.Inspect(); got != "{a: {...}, b: [{...}, [1, [...]]]}" {
+		t.Errorf("wrong hash. got=%s", got)
+	}
+
+	// A value seen twice, but not inside itself, is shown both times
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+	pair := &Array{Elements: []Object{shared, shared}}
+	if got := pair.Inspect(); got != "[[2], [2]]" {
+		t.Errorf("wrong shared array. got=%s", got)
+	}
+}
+
+func TestEnvironmentSlots(t *testing.T) {
+	top := NewEnvironment()
+	top.Set("x", &Integer{Value: 1})
+
+	names := []string{"a", "b"}
+	env := NewScope(top, names)
+	env.Assign(1, &Integer{Value: 2})
+
+	if val := env.Lookup(1, 0); val == nil || val.Inspect() != "1" {
+		t.Errorf("wrong value for x. got=%v", val)
+	}
+	if val := env.Lookup(0, 0); val != nil {
+		t.Errorf("unbound slot has a value: %s", val.Inspect())
+	}
+	if val, ok := env.Get("b"); !ok || val.Inspect() != "2" {
+		t.Errorf("wrong value for b. got=%v", val)
+	}
+	if _, ok := env.Get("a"); ok {
+		t.Errorf("unbound name a found")
+	}
+
+	// Growing one scope must not change the names another shares
+	env.Set("c", &Integer{Value: 3})
+	if len(names) != 2 || len(NewScope(top, names).Names()) != 2 {
+		t.Errorf("shared names changed: %v", names)
+	}
+	if slot, ok := env.Slot("c"); !ok || slot != 2 {
+		t.Errorf("wrong slot for c. got=%d", slot)
+	}
+}
+
+func TestErrorBacktrace(t *testing.T) {
+	err := &Error{Message: "x", Trace: []string{"f", "f", "f", "g", "f", "h", "h"}}
+
+	expected := []string{"f (×3)", "g", "f", "h (×2)"}
+	got := err.Backtrace()
+	if len(got) != len(expected) {
+		t.Fatalf("wrong backtrace. expected=%v, got=%v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("wrong backtrace. expected=%v, got=%v", expected, got)
+			break
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package optimizer rewrites a parsed program into a simpler one that
+// behaves the same, by folding constant expressions and removing the
+// branches of यदि that can never run
+package optimizer
+
+import (
+	"fmt"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/evaluator"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Optimize rewrites program in place and returns a description of each
+// change it made, in source order.
+//
+// Prefix and infix expressions over integer, string and boolean literals
+// are folded into the literal they evaluate to, computed by the evaluator
+// so that the result is exactly what running them would give. Expressions
+// that fail, like division by zero, are left alone to fail when they run,
+// where they are written; folded literals keep the token, and so the line,
+// of the expression they replace.
+//
+// A यदि whose condition folds to a literal keeps only the branch that
+// runs. As a statement of its own, the branch's statements take its place,
+// which is exact since यदि blocks share the scope around them; elsewhere,
+// a branch of a single expression does.
+func Optimize(program *ast.Program) []string {
+	o := &optimizer{
+		folded:     make(map[ast.Expression]int),
+		conditions: make(map[*ast.IfExpression]string),
+	}
+	program.Statements = o.statements(program.Statements)
+
+	var changes []string
+	for _, c := range o.changes {
+		if !c.superseded {
+			changes = append(changes, fmt.Sprintf("line %d: %s", c.line, c.description))
+		}
+	}
+	return changes
+}
+
+type change struct {
+	line        int
+	description string
+	superseded  bool // by the folding of a larger expression around it
+}
+
+type optimizer struct {
+	changes    []*change
+	folded     map[ast.Expression]int       // literals made by folding, and their change
+	conditions map[*ast.IfExpression]string // conditions as written, before folding
+}
+
+func (o *optimizer) report(line int, format string, args ...interface{}) {
+	o.changes = append(o.changes, &change{line: line, description: fmt.Sprintf(format, args...)})
+}
+
+func (o *optimizer) statements(stmts []ast.Statement) []ast.Statement {
+	result := make([]ast.Statement, 0, len(stmts))
+
+	for i, stmt := range stmts {
+		stmt = o.statement(stmt)
+
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+		ie, ok := es.Expression.(*ast.IfExpression)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+		taken, known := o.branch(ie)
+		if !known {
+			result = append(result, stmt)
+			continue
+		}
+
+		// The value of a block is that of its last statement, so a यदि
+		// ending one must still give निल when no branch runs
+		last := i == len(stmts)-1
+		if (taken == nil || len(taken.Statements) == 0) && last {
+			result = append(result, stmt)
+			continue
+		}
+
+		o.reportBranch(ie, taken)
+		if taken != nil {
+			result = append(result, taken.Statements...)
+		}
+	}
+
+	return result
+}
+
+// branch returns the block of ie that runs, nil for none, if its
+// condition is a literal
+func (o *optimizer) branch(ie *ast.IfExpression) (*ast.BlockStatement, bool) {
+	if !isLiteral(ie.Condition) {
+		return nil, false
+	}
+	if evaluator.IsTruthy(evaluator.Eval(ie.Condition, nil)) {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+func (o *optimizer) reportBranch(ie *ast.IfExpression, taken *ast.BlockStatement) {
+	condition := o.conditions[ie]
+	switch {
+	case taken == ie.Consequence && ie.Alternative != nil:
+		o.report(ie.Token.Line, "%s %s is always true: removed its %s branch", lexer.IF, condition, lexer.ELSE)
+	case taken == ie.Consequence:
+		o.report(ie.Token.Line, "%s %s is always true: kept its block alone", lexer.IF, condition)
+	case taken != nil:
+		o.report(ie.Token.Line, "%s %s is always false: kept its %s branch alone", lexer.IF, condition, lexer.ELSE)
+	default:
+		o.report(ie.Token.Line, "%s %s is always false: removed it", lexer.IF, condition)
+	}
+}
+
+func (o *optimizer) block(block *ast.BlockStatement) {
+	if block != nil {
+		block.Statements = o.statements(block.Statements)
+	}
+}
+
+func (o *optimizer) statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		o.let(stmt)
+	case *ast.ExportStatement:
+		o.let(stmt.Statement)
+	case *ast.ReturnStatement:
+		stmt.ReturnValue = o.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		stmt.Expression = o.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		o.block(stmt)
+	case *ast.WithStatement:
+		stmt.Context = o.expression(stmt.Context)
+		o.block(stmt.Body)
+	}
+	return stmt
+}
+
+func (o *optimizer) let(let *ast.LetStatement) {
+	o.expressions(let.Decorators)
+	let.Value = o.expression(let.Value)
+}
+
+func (o *optimizer) expressions(exps []ast.Expression) {
+	for i, exp := range exps {
+		exps[i] = o.expression(exp)
+	}
+}
+
+func (o *optimizer) expression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		source := exp.String()
+		exp.Right = o.expression(exp.Right)
+		return o.fold(exp, exp.Token.Line, source, exp.Right)
+	case *ast.InfixExpression:
+		source := exp.String()
+		exp.Left = o.expression(exp.Left)
+		exp.Right = o.expression(exp.Right)
+		return o.fold(exp, exp.Token.Line, source, exp.Left, exp.Right)
+	case *ast.IfExpression:
+		o.conditions[exp] = exp.Condition.String()
+		exp.Condition = o.expression(exp.Condition)
+		o.block(exp.Consequence)
+		o.block(exp.Alternative)
+		return o.ifExpression(exp)
+	case *ast.FunctionLiteral:
+		for _, p := range exp.Parameters {
+			p.Default = o.expression(p.Default)
+		}
+		o.block(exp.Body)
+	case *ast.CallExpression:
+		exp.Function = o.expression(exp.Function)
+		o.expressions(exp.Arguments)
+	case *ast.KeywordArgument:
+		exp.Value = o.expression(exp.Value)
+	case *ast.SpreadArgument:
+		exp.Value = o.expression(exp.Value)
+	case *ast.ArrayLiteral:
+		o.expressions(exp.Elements)
+	case *ast.IndexExpression:
+		exp.Left = o.expression(exp.Left)
+		exp.Index = o.expression(exp.Index)
+	case *ast.SliceExpression:
+		exp.Left = o.expression(exp.Left)
+		exp.Start = o.expression(exp.Start)
+		exp.End = o.expression(exp.End)
+		exp.Step = o.expression(exp.Step)
+	case *ast.HashLiteral:
+		for i := range exp.Pairs {
+			exp.Pairs[i].Key = o.expression(exp.Pairs[i].Key)
+			exp.Pairs[i].Value = o.expression(exp.Pairs[i].Value)
+		}
+	case *ast.SetLiteral:
+		o.expressions(exp.Elements)
+	case *ast.AwaitExpression:
+		exp.Value = o.expression(exp.Value)
+	case *ast.MemberExpression:
+		exp.Object = o.expression(exp.Object)
+	case *ast.ListComprehension:
+		o.clauses(exp.Clauses)
+		exp.Element = o.expression(exp.Element)
+	case *ast.SetComprehension:
+		o.clauses(exp.Clauses)
+		exp.Element = o.expression(exp.Element)
+	case *ast.HashComprehension:
+		o.clauses(exp.Clauses)
+		exp.Key = o.expression(exp.Key)
+		exp.Value = o.expression(exp.Value)
+	case *ast.MatchExpression:
+		exp.Subject = o.expression(exp.Subject)
+		for _, c := range exp.Cases {
+			c.Guard = o.expression(c.Guard)
+			o.block(c.Body)
+		}
+	}
+	return exp
+}
+
+func (o *optimizer) clauses(clauses []*ast.ComprehensionClause) {
+	for _, c := range clauses {
+		c.Iterable = o.expression(c.Iterable)
+		o.expressions(c.Filters)
+	}
+}
+
+// ifExpression prunes a यदि used as a value, when the branch that runs is a
+// single expression that can stand in for it
+func (o *optimizer) ifExpression(ie *ast.IfExpression) ast.Expression {
+	taken, known := o.branch(ie)
+	if !known || taken == nil || len(taken.Statements) != 1 {
+		return ie
+	}
+	es, ok := taken.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return ie
+	}
+
+	o.reportBranch(ie, taken)
+	return es.Expression
+}
+
+// fold replaces exp, whose operands are given, with the literal it
+// evaluates to, if its operands are literals and it evaluates without error
+func (o *optimizer) fold(exp ast.Expression, line int, source string, operands ...ast.Expression) ast.Expression {
+	for _, operand := range operands {
+		if !isLiteral(operand) {
+			return exp
+		}
+	}
+
+	lit := literal(evaluator.Eval(exp, nil), line)
+	if lit == nil {
+		return exp
+	}
+
+	// Report only the largest expression folded
+	for _, operand := range operands {
+		if i, ok := o.folded[operand]; ok {
+			o.changes[i].superseded = true
+		}
+	}
+	o.folded[lit] = len(o.changes)
+	o.report(line, "folded %s to %s", source, lit.String())
+
+	return lit
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+		return true
+	}
+	return false
+}
+
+// literal returns the literal for obj, on line, or nil if obj has none
+func literal(obj object.Object, line int) ast.Expression {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{Token: lexer.Token{Type: lexer.INT, Literal: obj.Inspect(), Line: line}, Value: obj.Value}
+	case *object.String:
+		return &ast.StringLiteral{Token: lexer.Token{Type: lexer.STRING, Literal: obj.Value, Line: line}, Value: obj.Value}
+	case *object.Boolean:
+		tok := lexer.Token{Type: lexer.FALSE, Literal: lexer.FALSE, Line: line}
+		if obj.Value {
+			tok = lexer.Token{Type: lexer.TRUE, Literal: lexer.TRUE, Line: line}
+		}
+		return &ast.Boolean{Token: tok, Value: obj.Value}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package optimizer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/evaluator"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		changes  []string
+	}{
+		{"२४ * ६० * ६०", "86400", []string{"line 1: folded ((२४ * ६०) * ६०) to 86400"}},
+		{`"नमस्" + "ते"`, "नमस्ते", []string{"line 1: folded (नमस् + ते) to नमस्ते"}},
+		{"!(१ == २)", "सत्य", []string{"line 1: folded (!(१ == २)) to सत्य"}},
+		{"-(२ - ५)", "3", []string{"line 1: folded (-(२ - ५)) to 3"}},
+		// Only operands that are literals fold
+		{"x * २ * ३", "((x * २) * ३)", nil},
+		{"x * (२ * ३)", "(x * 6)", []string{"line 1: folded (२ * ३) to 6"}},
+		// Failures are left to happen when the program runs
+		{"१ / (२ - २)", "(१ / 0)", []string{"line 1: folded (२ - २) to 0"}},
+		{`"क" - "ख"`, "(क - ख)", nil},
+		{
+			"यदि (सत्य) { लेट x = १; x } अन्यथा { २ }; x", "लेट x = १;\nxx",
+			[]string{"line 1: यदि सत्य is always true: removed its अन्यथा branch"},
+		},
+		{
+			"यदि (१ > २) { १ }; ३", "३",
+			[]string{"line 1: folded (१ > २) to मिथ्या", "line 1: यदि (१ > २) is always false: removed it"},
+		},
+		// A यदि ending a block still gives निल
+		{"यदि (मिथ्या) { १ }", "ifमिथ्या १", nil},
+		{
+			"लेट y = यदि (१ < २) { \"क\" } अन्यथा { \"ख\" };", "लेट y = क;\n",
+			[]string{"line 1: folded (१ < २) to सत्य", "line 1: यदि (१ < २) is always true: removed its अन्यथा branch"},
+		},
+		{
+			"फन f() {\n  प्रतिफल २ * ३\n}", "लेट f = फन f() प्रतिफल 6;\n;\n",
+			[]string{"line 2: folded (२ * ३) to 6"},
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		changes := Optimize(program)
+
+		if got := program.String(); got != tt.expected {
+			t.Errorf("%q - wrong program. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+		if fmt.Sprint(changes) != fmt.Sprint(tt.changes) {
+			t.Errorf("%q - wrong changes. expected=%q, got=%q", tt.input, tt.changes, changes)
+		}
+	}
+}
+
+// TestSameResults checks that optimized programs give what they did before
+func TestSameResults(t *testing.T) {
+	programs := []string{
+		"लेट दिन = २४ * ६० * ६०; दिन / ६०",
+		"फन f(n) { यदि (सत्य) { लेट m = n * २ }; m + १ }; f(४)",
+		"फन f() { यदि (मिथ्या) { प्रतिफल १ }; २ }; f()",
+		"फन f() { यदि (सत्य) { प्रतिफल १ }; २ }; f()",
+		"लेट x = ५; यदि (मिथ्या) { ७ }",
+		"लेट x = ५; यदि (मिथ्या) { ७ }; x",
+		"[१ / ०]",
+		"१ + \"क\"",
+		"[x * (२ + ३) लागि x मा [१, २] यदि !मिथ्या]",
+		"मिलान ३ { अवस्था n यदि १ < २ { n * (४ - १) } }",
+	}
+
+	for _, input := range programs {
+		expected := evaluator.Eval(parse(t, input), object.NewEnvironment())
+
+		program := parse(t, input)
+		Optimize(program)
+		got := evaluator.Eval(program, object.NewEnvironment())
+
+		if inspect(got) != inspect(expected) {
+			t.Errorf("%s - optimized result differs. expected=%s, got=%s", input, inspect(expected), inspect(got))
+		}
+	}
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	return obj.Inspect()
+}
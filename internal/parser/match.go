@@ -0,0 +1,205 @@
+package parser
+
+import (
+	"fmt"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+)
+
+// parseMatchExpression parses
+//
+//	मिलान subject {
+//		अवस्था pattern [यदि guard] { body }
+//		...
+//	}
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	for p.peekTokenIs(lexer.CASE) {
+		p.nextToken()
+		c := p.parseMatchCase()
+		if c == nil {
+			return nil
+		}
+		exp.Cases = append(exp.Cases, c)
+	}
+
+	if !p.expectPeek(lexer.RBRACE) {
+		return nil
+	}
+	exp.Rbrace = p.curToken
+
+	p.checkReachability(exp)
+	return exp
+}
+
+func (p *Parser) parseMatchCase() *ast.MatchCase {
+	c := &ast.MatchCase{Token: p.curToken}
+
+	p.nextToken()
+	c.Pattern = p.parsePattern()
+	if c.Pattern == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(lexer.IF) {
+		p.nextToken()
+		p.nextToken()
+		c.Guard = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+	c.Body = p.parseScopedBlock()
+
+	return c
+}
+
+// parsePattern parses the pattern starting at the current token
+func (p *Parser) parsePattern() ast.Pattern {
+	switch p.curToken.Type {
+	case lexer.INT, lexer.STRING, lexer.TRUE, lexer.FALSE:
+		tok := p.curToken
+		return &ast.LiteralPattern{Token: tok, Value: p.prefixParseFns[tok.Type]()}
+	case lexer.MINUS:
+		tok := p.curToken
+		if !p.peekTokenIs(lexer.INT) {
+			p.errorAt(p.peekToken.Line, "expected number after - in pattern, got %s instead", p.peekToken.Type)
+			return nil
+		}
+		return &ast.LiteralPattern{Token: tok, Value: p.parsePrefixExpression()}
+	case lexer.IDENT:
+		return &ast.BindingPattern{Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}}
+	case lexer.LBRACKET:
+		return p.parseArrayPattern()
+	case lexer.LBRACE:
+		return p.parseHashPattern()
+	default:
+		p.errorf("unexpected %s in pattern", p.curToken.Type)
+		return nil
+	}
+}
+
+// parseArrayPattern parses [p1, p2, *rest, p3], with at most one *rest
+func (p *Parser) parseArrayPattern() ast.Pattern {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(lexer.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(lexer.ASTERISK) {
+			if pattern.Rest != nil {
+				p.errorf("only one *rest is allowed in an array pattern")
+				return nil
+			}
+			if !p.expectPeek(lexer.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		} else {
+			el := p.parsePattern()
+			if el == nil {
+				return nil
+			}
+			if pattern.Rest == nil {
+				pattern.Before = append(pattern.Before, el)
+			} else {
+				pattern.After = append(pattern.After, el)
+			}
+		}
+
+		if !p.peekTokenIs(lexer.RBRACKET) && !p.expectPeek(lexer.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return pattern
+}
+
+// parseHashPattern parses {"key": pattern, ..., **rest}. Keys are literals.
+func (p *Parser) parseHashPattern() ast.Pattern {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(lexer.RBRACE) {
+		p.nextToken()
+
+		if p.curTokenIs(lexer.ASTERISK) {
+			if !p.expectPeek(lexer.ASTERISK) || !p.expectPeek(lexer.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.peekTokenIs(lexer.RBRACE) {
+				p.errorf("**rest must come last in a hash pattern")
+				return nil
+			}
+			continue
+		}
+
+		key, ok := p.parsePattern().(*ast.LiteralPattern)
+		if !ok {
+			p.errorf("hash pattern keys must be literals")
+			return nil
+		}
+		if !p.expectPeek(lexer.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parsePattern()
+		if value == nil {
+			return nil
+		}
+
+		pattern.Keys = append(pattern.Keys, key.Value)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(lexer.RBRACE) && !p.expectPeek(lexer.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+
+	return pattern
+}
+
+// checkReachability warns about cases no value can reach: those after a
+// case without a guard that matches everything, and repeats of an earlier
+// unguarded case
+func (p *Parser) checkReachability(exp *ast.MatchExpression) {
+	seen := map[string]int{}
+	catchAll := 0 // the first unguarded case matching everything, numbered from 1
+
+	for i, c := range exp.Cases {
+		key := c.Pattern.String()
+
+		switch j, dup := seen[key]; {
+		case catchAll > 0:
+			p.warnings = append(p.warnings, fmt.Sprintf(
+				"unreachable case %d (%s): case %d (%s) matches every value",
+				i+1, key, catchAll, exp.Cases[catchAll-1].Pattern.String()))
+		case dup:
+			p.warnings = append(p.warnings, fmt.Sprintf(
+				"unreachable case %d (%s): case %d has the same pattern", i+1, key, j))
+		case c.Guard == nil:
+			seen[key] = i + 1
+			if irrefutable(c.Pattern) {
+				catchAll = i + 1
+			}
+		}
+	}
+}
+
+// irrefutable reports whether pattern matches every value
+func irrefutable(pattern ast.Pattern) bool {
+	_, ok := pattern.(*ast.BindingPattern)
+	return ok
+}
@@ -1,7 +1,9 @@
+// Package parser implements the parser for the Nepali programming language
 package parser
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/SunilNeupane77/nepali/internal/ast"
 	"github.com/SunilNeupane77/nepali/internal/lexer"
@@ -9,8 +11,16 @@ import (
 
 // Parser represents a parser for the Nepali programming language
 type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+	l        *lexer.Lexer
+	errors   []string
+	warnings []string
+
+	// errorLines holds the line each error was found on
+	errorLines []int
+
+	// scopes holds, for each function body being parsed and the program
+	// around them, the names declared there and whether they are constant
+	scopes []map[string]bool
 
 	curToken  lexer.Token
 	peekToken lexer.Token
@@ -24,7 +34,36 @@ func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
 		errors: []string{},
+		scopes: []map[string]bool{{}},
+	}
+
+	p.prefixParseFns = make(map[lexer.TokenType]prefixParseFn)
+	p.registerPrefix(lexer.IDENT, p.parseIdentifier)
+	p.registerPrefix(lexer.INT, p.parseIntegerLiteral)
+	p.registerPrefix(lexer.STRING, p.parseStringLiteral)
+	p.registerPrefix(lexer.TRUE, p.parseBoolean)
+	p.registerPrefix(lexer.FALSE, p.parseBoolean)
+	p.registerPrefix(lexer.BANG, p.parsePrefixExpression)
+	p.registerPrefix(lexer.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(lexer.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(lexer.IF, p.parseIfExpression)
+	p.registerPrefix(lexer.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(lexer.ASYNC, p.parseAsyncFunctionLiteral)
+	p.registerPrefix(lexer.AWAIT, p.parseAwaitExpression)
+	p.registerPrefix(lexer.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(lexer.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(lexer.MATCH, p.parseMatchExpression)
+
+	p.infixParseFns = make(map[lexer.TokenType]infixParseFn)
+	for _, t := range []lexer.TokenType{
+		lexer.PLUS, lexer.MINUS, lexer.SLASH, lexer.ASTERISK,
+		lexer.EQ, lexer.NOT_EQ, lexer.LT, lexer.GT, lexer.LT_EQ, lexer.GT_EQ,
+	} {
+		p.registerInfix(t, p.parseInfixExpression)
 	}
+	p.registerInfix(lexer.LPAREN, p.parseCallExpression)
+	p.registerInfix(lexer.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(lexer.DOT, p.parseMemberExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -33,11 +72,60 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+func (p *Parser) registerPrefix(tokenType lexer.TokenType, fn prefixParseFn) {
+	p.prefixParseFns[tokenType] = fn
+}
+
+func (p *Parser) registerInfix(tokenType lexer.TokenType, fn infixParseFn) {
+	p.infixParseFns[tokenType] = fn
+}
+
+// declare records a declaration of name in the current scope, reporting
+// an error if it redeclares a constant
+func (p *Parser) declare(name string, constant bool) {
+	scope := p.scopes[len(p.scopes)-1]
+	if scope[name] {
+		p.errorf("cannot reassign constant %s", name)
+	}
+	scope[name] = scope[name] || constant
+}
+
+// parseScopedBlock parses a block that runs in an environment of its own,
+// such as a function body, so its declarations do not clash with outer ones
+func (p *Parser) parseScopedBlock() *ast.BlockStatement {
+	p.scopes = append(p.scopes, map[string]bool{})
+	defer func() { p.scopes = p.scopes[:len(p.scopes)-1] }()
+
+	return p.parseBlockStatement()
+}
+
 // Errors returns the parsing errors
 func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ErrorLines returns the line each of the parsing errors was found on
+func (p *Parser) ErrorLines() []int {
+	return p.errorLines
+}
+
+// errorf records a parsing error at the current token
+func (p *Parser) errorf(format string, args ...interface{}) {
+	p.errorAt(p.curToken.Line, format, args...)
+}
+
+// errorAt records a parsing error at line
+func (p *Parser) errorAt(line int, format string, args ...interface{}) {
+	p.errors = append(p.errors, fmt.Sprintf(format, args...))
+	p.errorLines = append(p.errorLines, line)
+}
+
+// Warnings returns problems found while parsing that do not stop the
+// program from running, such as unreachable match cases
+func (p *Parser) Warnings() []string {
+	return p.warnings
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
@@ -62,9 +150,8 @@ func (p *Parser) expectPeek(t lexer.TokenType) bool {
 }
 
 func (p *Parser) peekError(t lexer.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
+	p.errorAt(p.peekToken.Line, "expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
 }
 
 // ParseProgram parses the entire program
@@ -83,124 +170,174 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+type (
+	prefixParseFn func() ast.Expression
+	infixParseFn  func(ast.Expression) ast.Expression
+)
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case lexer.LET:
-		return p.parseLetStatement()
+	case lexer.LET, lexer.VAR, lexer.CONST:
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case lexer.RETURN:
 		return p.parseReturnStatement()
+	case lexer.IMPORT, lexer.FROM:
+		if stmt := p.parseImportStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.EXPORT:
+		if stmt := p.parseExportStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.WITH:
+		if stmt := p.parseWithStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.AT:
+		if stmt := p.parseDecoratedStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case lexer.FUNCTION:
+		if p.peekTokenIs(lexer.IDENT) {
+			if stmt := p.parseFunctionDeclaration(); stmt != nil {
+				return stmt
+			}
+			return nil
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-type (
-	prefixParseFn func() ast.Expression
-	infixParseFn  func(ast.Expression) ast.Expression
-)
-
-// Parser represents a parser for the Nepali programming language
-type Parser struct {
-	l      *lexer.Lexer
-	errors []string
+func (p *Parser) parseLetStatement() *ast.LetStatement {
+	stmt := &ast.LetStatement{Token: p.curToken}
 
-	curToken  lexer.Token
-	peekToken lexer.Token
+	if !p.expectPeek(lexer.IDENT) {
+		return nil
+	}
 
-	prefixParseFns map[lexer.TokenType]prefixParseFn
-	infixParseFns  map[lexer.TokenType]infixParseFn
-}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.declare(stmt.Name.Value, stmt.Token.Type == lexer.CONST)
 
-// New creates a new Parser
-func New(l *lexer.Lexer) *Parser {
-	p := &Parser{
-		l:      l,
-		errors: []string{},
+	if !p.expectPeek(lexer.ASSIGN) {
+		return nil
 	}
 
-	// Read two tokens, so curToken and peekToken are both set
-	p.nextToken()
 	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
 
-	return p
-}
+	// A function bound by let takes the binding's name
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && fn.Name == "" {
+		fn.Name = stmt.Name.Value
+	}
 
-// Errors returns the parsing errors
-func (p *Parser) Errors() []string {
-	return p.errors
-}
+	if p.peekTokenIs(lexer.SEMICOLON) {
+		p.nextToken()
+	}
 
-func (p *Parser) nextToken() {
-	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	return stmt
 }
 
-func (p *Parser) curTokenIs(t lexer.TokenType) bool {
-	return p.curToken.Type == t
-}
+// parseFunctionDeclaration parses `फन नाम(params) { body }`, which binds the
+// function to नाम like a let statement
+func (p *Parser) parseFunctionDeclaration() *ast.LetStatement {
+	stmt := &ast.LetStatement{
+		Token:         lexer.Token{Type: lexer.LET, Literal: lexer.LET, Line: p.curToken.Line, Column: p.curToken.Column},
+		IsDeclaration: true,
+	}
+	lit := &ast.FunctionLiteral{Token: p.curToken}
 
-func (p *Parser) peekTokenIs(t lexer.TokenType) bool {
-	return p.peekToken.Type == t
-}
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	lit.Name = stmt.Name.Value
+	p.declare(stmt.Name.Value, false)
 
-func (p *Parser) expectPeek(t lexer.TokenType) bool {
-	if p.peekTokenIs(t) {
-		p.nextToken()
-		return true
-	} else {
-		p.peekError(t)
-		return false
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil
 	}
-}
+	lit.Parameters = p.parseFunctionParameters()
 
-func (p *Parser) peekError(t lexer.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
-}
-
-// ParseProgram parses the entire program
-func (p *Parser) ParseProgram() *ast.Program {
-	program := &ast.Program{}
-	program.Statements = []ast.Statement{}
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+	lit.Body = p.parseScopedBlock()
+	markTailCalls(lit.Body, true)
 
-	for p.curToken.Type != lexer.EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
-		}
+	if p.peekTokenIs(lexer.SEMICOLON) {
 		p.nextToken()
 	}
 
-	return program
+	stmt.Value = lit
+	return stmt
 }
 
-func (p *Parser) parseStatement() ast.Statement {
-	switch p.curToken.Type {
-	case lexer.LET:
-		return p.parseLetStatement()
-	case lexer.RETURN:
-		return p.parseReturnStatement()
-	default:
-		return p.parseExpressionStatement()
+// parseDecoratedStatement parses one or more `@expression` decorators and
+// the declaration they apply to: a function declaration, a let statement,
+// or an export of either
+func (p *Parser) parseDecoratedStatement() ast.Statement {
+	decorators := []ast.Expression{}
+	for p.curTokenIs(lexer.AT) {
+		p.nextToken()
+		decorators = append(decorators, p.parseExpression(LOWEST))
+		p.nextToken()
 	}
-}
-
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(lexer.IDENT) {
+	var stmt ast.Statement
+	var let *ast.LetStatement
+	switch {
+	case p.curTokenIs(lexer.FUNCTION) && p.peekTokenIs(lexer.IDENT):
+		let = p.parseFunctionDeclaration()
+		stmt = let
+	case p.curTokenIs(lexer.LET) || p.curTokenIs(lexer.VAR) || p.curTokenIs(lexer.CONST):
+		let = p.parseLetStatement()
+		stmt = let
+	case p.curTokenIs(lexer.EXPORT):
+		export := p.parseExportStatement()
+		if export == nil {
+			return nil
+		}
+		let = export.Statement
+		stmt = export
+	default:
+		p.errorf("decorators must be followed by a declaration, got %s instead", p.curToken.Type)
 		return nil
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-
-	if !p.expectPeek(lexer.ASSIGN) {
+	if let == nil {
 		return nil
 	}
+	let.Decorators = decorators
+	return stmt
+}
+
+// parseWithStatement parses `सँग expression [जस्तो नाम] { body }`
+func (p *Parser) parseWithStatement() *ast.WithStatement {
+	stmt := &ast.WithStatement{Token: p.curToken}
 
 	p.nextToken()
-	stmt.Value = p.parseExpression(LOWEST)
+	stmt.Context = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(lexer.AS) {
+		p.nextToken()
+		if !p.expectPeek(lexer.IDENT) {
+			return nil
+		}
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.declare(stmt.Name.Value, false)
+	}
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
 
 	if p.peekTokenIs(lexer.SEMICOLON) {
 		p.nextToken()
@@ -222,6 +359,66 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// parseImportStatement parses `आयात "path" [जस्तो नाम]` and
+// `बाट "path" आयात a, b`
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	from := p.curTokenIs(lexer.FROM)
+
+	if !p.expectPeek(lexer.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if from {
+		if !p.expectPeek(lexer.IMPORT) || !p.expectPeek(lexer.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		for p.peekTokenIs(lexer.COMMA) {
+			p.nextToken()
+			if !p.expectPeek(lexer.IDENT) {
+				return nil
+			}
+			stmt.Names = append(stmt.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		}
+	} else if p.peekTokenIs(lexer.AS) {
+		p.nextToken()
+		if !p.expectPeek(lexer.IDENT) {
+			return nil
+		}
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if p.peekTokenIs(lexer.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	p.nextToken()
+	switch {
+	case p.curTokenIs(lexer.LET) || p.curTokenIs(lexer.VAR) || p.curTokenIs(lexer.CONST):
+		stmt.Statement = p.parseLetStatement()
+	case p.curTokenIs(lexer.FUNCTION) && p.peekTokenIs(lexer.IDENT):
+		stmt.Statement = p.parseFunctionDeclaration()
+	default:
+		p.errorf("expected a declaration after %s, got %s instead",
+			lexer.EXPORT, p.curToken.Type)
+		return nil
+	}
+	if stmt.Statement == nil {
+		return nil
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -255,205 +452,572 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	return leftExp
 }
 
-// Program represents the root node of the AST
-type Program struct {
-	Statements []Statement
-}
+const (
+	_ int = iota
+	LOWEST
+	EQUALS      // ==
+	LESSGREATER // <, >, <= or >=
+	SUM         // +
+	PRODUCT     // *
+	PREFIX      // -X or !X
+	CALL        // myFunction(X)
+	INDEX       // array[index]
+)
 
-// Statement represents a statement node in the AST
-type Statement interface {
-	statementNode()
+var precedences = map[lexer.TokenType]int{
+	lexer.EQ:       EQUALS,
+	lexer.NOT_EQ:   EQUALS,
+	lexer.LT:       LESSGREATER,
+	lexer.GT:       LESSGREATER,
+	lexer.LT_EQ:    LESSGREATER,
+	lexer.GT_EQ:    LESSGREATER,
+	lexer.PLUS:     SUM,
+	lexer.MINUS:    SUM,
+	lexer.SLASH:    PRODUCT,
+	lexer.ASTERISK: PRODUCT,
+	lexer.LPAREN:   CALL,
+	lexer.LBRACKET: INDEX,
+	lexer.DOT:      INDEX,
 }
 
-// Expression represents an expression node in the AST
-type Expression interface {
-	expressionNode()
-}
+func (p *Parser) peekPrecedence() int {
+	if p, ok := precedences[p.peekToken.Type]; ok {
+		return p
+	}
 
-// LetStatement represents a let statement
-type LetStatement struct {
-	Token lexer.Token
-	Name  *Identifier
-	Value Expression
+	return LOWEST
 }
 
-func (ls *LetStatement) statementNode() {}
+func (p *Parser) curPrecedence() int {
+	if p, ok := precedences[p.curToken.Type]; ok {
+		return p
+	}
 
-// ReturnStatement represents a return statement
-type ReturnStatement struct {
-	Token       lexer.Token
-	ReturnValue Expression
+	return LOWEST
 }
 
-func (rs *ReturnStatement) statementNode() {}
+func (p *Parser) noPrefixParseFnError(t lexer.TokenType) {
+	p.errorf("no prefix parse function for %s found", t)
+}
 
-// ExpressionStatement represents an expression statement
-type ExpressionStatement struct {
-	Token      lexer.Token
-	Expression Expression
+func (p *Parser) parseIdentifier() ast.Expression {
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
-func (es *ExpressionStatement) statementNode() {}
+func (p *Parser) parseIntegerLiteral() ast.Expression {
+	lit := &ast.IntegerLiteral{Token: p.curToken}
 
-// Identifier represents an identifier
-type Identifier struct {
-	Token lexer.Token
-	Value string
+	value, err := strconv.ParseInt(asciiDigits(p.curToken.Literal), 10, 64)
+	if err != nil {
+		p.errorf("could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+
+	lit.Value = value
+	return lit
 }
 
-func (i *Identifier) expressionNode() {}
+// asciiDigits rewrites Devanagari digits (०-९) as their ASCII equivalents
+func asciiDigits(s string) string {
+	out := []rune(s)
+	for i, ch := range out {
+		if '०' <= ch && ch <= '९' {
+			out[i] = '0' + (ch - '०')
+		}
+	}
+	return string(out)
+}
 
-// IntegerLiteral represents an integer literal
-type IntegerLiteral struct {
-	Token lexer.Token
-	Value int64
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
-func (il *IntegerLiteral) expressionNode() {}
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(lexer.TRUE)}
+}
+
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
 
-// Boolean represents a boolean value
-type Boolean struct {
-	Token lexer.Token
-	Value bool
+	p.nextToken()
+	expression.Right = p.parseExpression(PREFIX)
+
+	return expression
 }
 
-func (b *Boolean) expressionNode() {}
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence)
 
-// PrefixExpression represents a prefix expression
-type PrefixExpression struct {
-	Token    lexer.Token
-	Operator string
-	Right    Expression
+	return expression
 }
 
-func (pe *PrefixExpression) expressionNode() {}
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
 
-// InfixExpression represents an infix expression
-type InfixExpression struct {
-	Token    lexer.Token
-	Left     Expression
-	Operator string
-	Right    Expression
+	return exp
 }
 
-func (ie *InfixExpression) expressionNode() {}
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	expression.Consequence = p.parseBlockStatement()
+
+	if p.peekTokenIs(lexer.ELSE) {
+		p.nextToken()
+
+		if !p.expectPeek(lexer.LBRACE) {
+			return nil
+		}
+
+		expression.Alternative = p.parseBlockStatement()
+	}
 
-// IfExpression represents an if expression
-type IfExpression struct {
-	Token       lexer.Token
-	Condition   Expression
-	Consequence *BlockStatement
-	Alternative *BlockStatement
+	return expression
 }
 
-func (ie *IfExpression) expressionNode() {}
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
 
-// BlockStatement represents a block statement
-type BlockStatement struct {
-	Token      lexer.Token
-	Statements []Statement
+	p.nextToken()
+
+	for !p.curTokenIs(lexer.RBRACE) && !p.curTokenIs(lexer.EOF) {
+		stmt := p.parseStatement()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	block.Rbrace = p.curToken
+
+	return block
 }
 
-func (bs *BlockStatement) statementNode() {}
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expectPeek(lexer.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
 
-// FunctionLiteral represents a function literal
-type FunctionLiteral struct {
-	Token      lexer.Token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	if !p.expectPeek(lexer.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseScopedBlock()
+	markTailCalls(lit.Body, true)
+
+	return lit
 }
 
-func (fl *FunctionLiteral) expressionNode() {}
+// parseAsyncFunctionLiteral parses `एसिन्क फन(...) {...}`
+func (p *Parser) parseAsyncFunctionLiteral() ast.Expression {
+	if !p.expectPeek(lexer.FUNCTION) {
+		return nil
+	}
 
-// CallExpression represents a function call
-type CallExpression struct {
-	Token     lexer.Token
-	Function  Expression
-	Arguments []Expression
+	lit, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+	if !ok {
+		return nil
+	}
+
+	lit.IsAsync = true
+	return lit
 }
 
-func (ce *CallExpression) expressionNode() {}
+func (p *Parser) parseAwaitExpression() ast.Expression {
+	expression := &ast.AwaitExpression{Token: p.curToken}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(PREFIX)
 
-// StringLiteral represents a string literal
-type StringLiteral struct {
-	Token lexer.Token
-	Value string
+	return expression
 }
 
-func (sl *StringLiteral) expressionNode() {}
+// parseFunctionParameters parses a parameter list such as
+// (a, b = २, *rest, c = ३, **options). Parameters after *rest can only be
+// passed by keyword.
+func (p *Parser) parseFunctionParameters() []*ast.Parameter {
+	params := []*ast.Parameter{}
+
+	if p.peekTokenIs(lexer.RPAREN) {
+		p.nextToken()
+		return params
+	}
+
+	seen := map[string]bool{}
+	sawDefault, sawRest, sawKeywordRest := false, false, false
+
+	for {
+		p.nextToken()
+		param := &ast.Parameter{}
+
+		if p.curTokenIs(lexer.ASTERISK) {
+			if p.peekTokenIs(lexer.ASTERISK) {
+				p.nextToken()
+				param.KeywordRest = true
+			} else {
+				param.Rest = true
+			}
+			p.nextToken()
+		}
+
+		if !p.curTokenIs(lexer.IDENT) {
+			p.errorf("expected parameter name, got %s instead", p.curToken.Type)
+			return nil
+		}
+		param.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !param.Rest && !param.KeywordRest && p.peekTokenIs(lexer.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			param.Default = p.parseExpression(LOWEST)
+		}
+
+		switch {
+		case seen[param.Name.Value]:
+			p.errorf("duplicate parameter %s", param.Name.Value)
+			return nil
+		case sawKeywordRest:
+			p.errorf("parameter %s follows **%s", param.Name.Value, params[len(params)-1].Name.Value)
+			return nil
+		case param.Rest && sawRest:
+			p.errorf("only one *rest parameter is allowed")
+			return nil
+		case param.Default == nil && sawDefault && !sawRest && !param.Rest && !param.KeywordRest:
+			p.errorf("parameter %s without a default follows a parameter with one", param.Name.Value)
+			return nil
+		}
+
+		seen[param.Name.Value] = true
+		sawDefault = sawDefault || param.Default != nil
+		sawRest = sawRest || param.Rest
+		sawKeywordRest = param.KeywordRest
+		params = append(params, param)
 
-// ArrayLiteral represents an array literal
-type ArrayLiteral struct {
-	Token    lexer.Token
-	Elements []Expression
+		if !p.peekTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
+
+	return params
 }
 
-func (al *ArrayLiteral) expressionNode() {}
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseCallArguments()
+	exp.Rparen = p.curToken
+	return exp
+}
+
+// parseCallArguments parses call arguments, which besides expressions may be
+// keyword arguments (name = value) and spreads (*array, **hash). Positional
+// arguments cannot follow keyword ones.
+func (p *Parser) parseCallArguments() []ast.Expression {
+	args := []ast.Expression{}
+
+	if p.peekTokenIs(lexer.RPAREN) {
+		p.nextToken()
+		return args
+	}
+
+	sawKeyword := false
+	for {
+		p.nextToken()
+
+		var arg ast.Expression
+		switch {
+		case p.curTokenIs(lexer.ASTERISK):
+			spread := &ast.SpreadArgument{Token: p.curToken}
+			if p.peekTokenIs(lexer.ASTERISK) {
+				p.nextToken()
+				spread.Keywords = true
+			}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			arg = spread
+		case p.curTokenIs(lexer.IDENT) && p.peekTokenIs(lexer.ASSIGN):
+			kw := &ast.KeywordArgument{Token: p.curToken}
+			kw.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			p.nextToken()
+			p.nextToken()
+			kw.Value = p.parseExpression(LOWEST)
+			arg = kw
+		default:
+			arg = p.parseExpression(LOWEST)
+		}
+
+		switch arg := arg.(type) {
+		case *ast.KeywordArgument:
+			sawKeyword = true
+		case *ast.SpreadArgument:
+			if arg.Keywords {
+				sawKeyword = true
+			} else if sawKeyword {
+				p.errorf("positional argument follows keyword argument")
+				return nil
+			}
+		default:
+			if sawKeyword {
+				p.errorf("positional argument follows keyword argument")
+				return nil
+			}
+		}
+		args = append(args, arg)
+
+		if !p.peekTokenIs(lexer.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(lexer.RPAREN) {
+		return nil
+	}
 
-// IndexExpression represents an index expression
-type IndexExpression struct {
-	Token lexer.Token
-	Left  Expression
-	Index Expression
+	return args
 }
 
-func (ie *IndexExpression) expressionNode() {}
+// parseArrayLiteral parses an array literal, or a list comprehension such
+// as [x * x लागि x मा सूची]
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	tok := p.curToken
 
-// HashLiteral represents a hash literal
-type HashLiteral struct {
-	Token lexer.Token
-	Pairs map[Expression]Expression
+	if p.peekTokenIs(lexer.RBRACKET) {
+		p.nextToken()
+		return &ast.ArrayLiteral{Token: tok, Elements: []ast.Expression{}, Rbracket: p.curToken}
+	}
+
+	p.nextToken()
+	first := p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(lexer.FOR) {
+		clauses := p.parseComprehensionClauses(lexer.RBRACKET)
+		if clauses == nil {
+			return nil
+		}
+		return &ast.ListComprehension{Token: tok, Element: first, Clauses: clauses}
+	}
+
+	elements := p.parseExpressionListFrom(first, lexer.RBRACKET)
+	if elements == nil {
+		return nil
+	}
+	return &ast.ArrayLiteral{Token: tok, Elements: elements, Rbracket: p.curToken}
 }
 
-func (hl *HashLiteral) expressionNode() {}
+// parseExpressionListFrom parses the rest of a comma-separated list whose
+// first expression has been parsed, up to and including end
+func (p *Parser) parseExpressionListFrom(first ast.Expression, end lexer.TokenType) []ast.Expression {
+	list := []ast.Expression{first}
 
-// WhileExpression represents a while loop
-type WhileExpression struct {
-	Token     lexer.Token
-	Condition Expression
-	Body      *BlockStatement
+	for p.peekTokenIs(lexer.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return list
 }
 
-func (we *WhileExpression) expressionNode() {}
+// parseComprehensionClauses parses one or more `लागि names मा iterable`
+// clauses, each followed by any number of `यदि condition` filters, up to and
+// including end
+func (p *Parser) parseComprehensionClauses(end lexer.TokenType) []*ast.ComprehensionClause {
+	clauses := []*ast.ComprehensionClause{}
 
-const (
-	_ int = iota
-	LOWEST
-	EQUALS      // ==
-	LESSGREATER // < or >
-	SUM         // +
-	PRODUCT     // *
-	PREFIX      // -X or !X
-	CALL        // myFunction(X)
-)
+	for p.peekTokenIs(lexer.FOR) {
+		p.nextToken()
+		clause := &ast.ComprehensionClause{Token: p.curToken}
+
+		for {
+			if !p.expectPeek(lexer.IDENT) {
+				return nil
+			}
+			clause.Names = append(clause.Names, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+			if !p.peekTokenIs(lexer.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+		if len(clause.Names) > 2 {
+			p.errorf("comprehension binds at most 2 names, got %d", len(clause.Names))
+			return nil
+		}
 
-var precedences = map[lexer.TokenType]int{
-	lexer.EQ:       EQUALS,
-	lexer.NOT_EQ:   EQUALS,
-	lexer.LT:       LESSGREATER,
-	lexer.GT:       LESSGREATER,
-	lexer.PLUS:     SUM,
-	lexer.MINUS:    SUM,
-	lexer.SLASH:    PRODUCT,
-	lexer.ASTERISK: PRODUCT,
+		if !p.expectPeek(lexer.IN) {
+			return nil
+		}
+		p.nextToken()
+		clause.Iterable = p.parseExpression(LOWEST)
+
+		for p.peekTokenIs(lexer.IF) {
+			p.nextToken()
+			p.nextToken()
+			clause.Filters = append(clause.Filters, p.parseExpression(LOWEST))
+		}
+
+		clauses = append(clauses, clause)
+	}
+
+	if !p.expectPeek(end) {
+		return nil
+	}
+
+	return clauses
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
-		return p
+// parseIndexExpression parses a[i], or a slice a[start:end:step] in which
+// every part may be left out
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(lexer.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+		if !p.peekTokenIs(lexer.COLON) {
+			if !p.expectPeek(lexer.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+		}
 	}
 
-	return LOWEST
+	slice := &ast.SliceExpression{Token: tok, Left: left, Start: index}
+	p.nextToken() // the first :
+
+	if !p.peekTokenIs(lexer.COLON) && !p.peekTokenIs(lexer.RBRACKET) {
+		p.nextToken()
+		slice.End = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(lexer.COLON) {
+		p.nextToken()
+		if !p.peekTokenIs(lexer.RBRACKET) {
+			p.nextToken()
+			slice.Step = p.parseExpression(LOWEST)
+		}
+	}
+
+	if !p.expectPeek(lexer.RBRACKET) {
+		return nil
+	}
+
+	return slice
 }
 
-func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(lexer.IDENT) {
+		return nil
 	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
-	return LOWEST
+	return exp
 }
 
-func (p *Parser) noPrefixParseFnError(t lexer.TokenType) {
-	p.errors = append(p.errors, fmt.Sprintf("no prefix parse function for %s found", t))
+// parseHashLiteral parses a hash or set literal, or a hash or set
+// comprehension. An empty {} is a hash.
+func (p *Parser) parseHashLiteral() ast.Expression {
+	tok := p.curToken
+	hash := &ast.HashLiteral{Token: tok}
+	hash.Pairs = []ast.HashPair{}
+
+	if p.peekTokenIs(lexer.RBRACE) {
+		p.nextToken()
+		hash.Rbrace = p.curToken
+		return hash
+	}
+
+	p.nextToken()
+	first := p.parseExpression(LOWEST)
+
+	switch {
+	case p.peekTokenIs(lexer.FOR):
+		clauses := p.parseComprehensionClauses(lexer.RBRACE)
+		if clauses == nil {
+			return nil
+		}
+		return &ast.SetComprehension{Token: tok, Element: first, Clauses: clauses}
+	case !p.peekTokenIs(lexer.COLON):
+		elements := p.parseExpressionListFrom(first, lexer.RBRACE)
+		if elements == nil {
+			return nil
+		}
+		return &ast.SetLiteral{Token: tok, Elements: elements, Rbrace: p.curToken}
+	}
+
+	key := first
+	for {
+		if !p.expectPeek(lexer.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+
+		if len(hash.Pairs) == 0 && p.peekTokenIs(lexer.FOR) {
+			clauses := p.parseComprehensionClauses(lexer.RBRACE)
+			if clauses == nil {
+				return nil
+			}
+			return &ast.HashComprehension{Token: tok, Key: key, Value: value, Clauses: clauses}
+		}
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(lexer.RBRACE) && !p.expectPeek(lexer.COMMA) {
+			return nil
+		}
+		if p.peekTokenIs(lexer.RBRACE) {
+			break
+		}
+
+		p.nextToken()
+		key = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(lexer.RBRACE) {
+		return nil
+	}
+	hash.Rbrace = p.curToken
+
+	return hash
 }
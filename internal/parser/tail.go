@@ -0,0 +1,60 @@
+package parser
+
+import "github.com/SunilNeupane77/nepali/internal/ast"
+
+// markTailCalls marks the calls in a function body whose result is the
+// function's: those a प्रतिफल returns, and those giving the value of the
+// body's last statement, through यदि branches and मिलान cases. tail says
+// whether block's own last statement gives the function's value. Calls in
+// a सँग body are not marked, since its exit hook still runs after them.
+func markTailCalls(block *ast.BlockStatement, tail bool) {
+	if block == nil {
+		return
+	}
+
+	for i, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.ReturnStatement:
+			markTail(stmt.ReturnValue)
+		case *ast.ExpressionStatement:
+			if tail && i == len(block.Statements)-1 {
+				markTail(stmt.Expression)
+			} else {
+				markReturns(stmt.Expression)
+			}
+		case *ast.LetStatement:
+			markReturns(stmt.Value)
+		}
+	}
+}
+
+// markTail marks exp, whose value is the function's
+func markTail(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		exp.Tail = true
+	case *ast.IfExpression, *ast.MatchExpression:
+		markBranches(exp, true)
+	}
+}
+
+// markReturns marks the calls returned from the branches of exp, whose
+// own value is not the function's
+func markReturns(exp ast.Expression) {
+	switch exp.(type) {
+	case *ast.IfExpression, *ast.MatchExpression:
+		markBranches(exp, false)
+	}
+}
+
+func markBranches(exp ast.Expression, tail bool) {
+	switch exp := exp.(type) {
+	case *ast.IfExpression:
+		markTailCalls(exp.Consequence, tail)
+		markTailCalls(exp.Alternative, tail)
+	case *ast.MatchExpression:
+		for _, c := range exp.Cases {
+			markTailCalls(c.Body, tail)
+		}
+	}
+}
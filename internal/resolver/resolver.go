@@ -0,0 +1,396 @@
+// Package resolver works out, before a program runs, where each of its
+// variables lives, so that the evaluator can reach them by index rather
+// than by name
+package resolver
+
+import (
+	"fmt"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/imports"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Resolve gives every identifier in program the depth and slot of the
+// variable it names, and every function literal, comprehension and मिलान
+// case the names of its scope's slots. Scopes are those the evaluator
+// creates environments for: the program, each function call, each
+// comprehension and each case; यदि and सँग blocks share the scope around
+// them.
+//
+// A name refers to the innermost scope that binds it anywhere, before or
+// after the use, so that functions can refer to what is defined after
+// them; but where a use runs before its scope's लेट, as in लेट x = x + १
+// or the iterable of [x लागि x मा …], and the name means something
+// outside, it refers to that instead, as it does on the VM. Names bound
+// nowhere become slots of env, the top-level environment the program will
+// run in; at run time they may be builtins, which known reports.
+//
+// It returns warnings for names used before the scope they belong to
+// defines them, and for names that are not defined at all. Neither stops
+// the program: the evaluator looks an unbound slot up by name in the
+// enclosing scopes, as it always has.
+func Resolve(program *ast.Program, env *object.Environment, known func(name string) bool) []string {
+	r := &resolver{
+		known:  known,
+		scopes: make(map[interface{}]*scope),
+	}
+	r.top = &scope{env: env, slots: make(map[string]int), defined: make(map[string]bool)}
+
+	r.declaring = true
+	r.current = r.top
+	r.program(program)
+
+	r.declaring = false
+	r.current = r.top
+	r.program(program)
+
+	return r.warnings
+}
+
+// scope is one environment's worth of names
+type scope struct {
+	outer    *scope
+	function bool                // the scope of a function call
+	env      *object.Environment // for the top-level scope, which takes its slots from env
+	names    []string            // by slot
+	slots    map[string]int      // the names bound in the scope
+	defined  map[string]bool     // names bound so far, in source order
+}
+
+func newScope(outer *scope, function bool) *scope {
+	return &scope{
+		outer:    outer,
+		function: function,
+		slots:    make(map[string]int),
+		defined:  make(map[string]bool),
+	}
+}
+
+// slot returns the slot of name, if the scope binds it. The top-level
+// scope also counts what env already holds, as in a REPL session.
+func (s *scope) slot(name string) (int, bool) {
+	if slot, ok := s.slots[name]; ok {
+		return slot, true
+	}
+	if s.env != nil {
+		if slot, ok := s.env.Slot(name); ok && s.env.Lookup(0, slot) != nil {
+			return slot, true
+		}
+	}
+	return 0, false
+}
+
+// declare gives name a slot in the scope
+func (s *scope) declare(name string) int {
+	if slot, ok := s.slots[name]; ok {
+		return slot
+	}
+
+	var slot int
+	if s.env != nil {
+		slot = s.env.Define(name)
+	} else {
+		slot = len(s.names)
+		s.names = append(s.names, name)
+	}
+	s.slots[name] = slot
+	return slot
+}
+
+func (s *scope) isDefined(name string) bool {
+	if s.defined[name] {
+		return true
+	}
+	if s.env != nil {
+		if slot, ok := s.env.Slot(name); ok {
+			return s.env.Lookup(0, slot) != nil
+		}
+	}
+	return false
+}
+
+// The resolver walks the program twice: first to find the names each scope
+// binds, then to resolve the identifiers that use them
+type resolver struct {
+	known     func(string) bool
+	scopes    map[interface{}]*scope // the scope each function, comprehension and case opens
+	top       *scope
+	current   *scope
+	declaring bool
+	warnings  []string
+}
+
+func (r *resolver) warn(id *ast.Identifier, format string, args ...interface{}) {
+	r.warnings = append(r.warnings, fmt.Sprintf("line %d: ", id.Token.Line)+fmt.Sprintf(format, args...))
+}
+
+// open enters the scope node opens, creating it on the first pass
+func (r *resolver) open(node interface{}, function bool) *scope {
+	s, ok := r.scopes[node]
+	if !ok {
+		s = newScope(r.current, function)
+		r.scopes[node] = s
+	}
+	r.current = s
+	return s
+}
+
+func (r *resolver) close(s *scope) {
+	r.current = s.outer
+}
+
+// bind binds id in the current scope
+func (r *resolver) bind(id *ast.Identifier) {
+	if r.declaring {
+		r.current.declare(id.Value)
+		return
+	}
+	id.Depth, id.Slot = 0, r.current.declare(id.Value)
+	r.current.defined[id.Value] = true
+}
+
+// bindName binds a name that has no identifier of its own in the source
+func (r *resolver) bindName(name string) {
+	r.current.declare(name)
+	if !r.declaring {
+		r.current.defined[name] = true
+	}
+}
+
+// use resolves id where it is used
+func (r *resolver) use(id *ast.Identifier) {
+	if r.declaring {
+		return
+	}
+
+	depth := 0
+	crossed := false // whether the use is inside a function of the scope
+	for s := r.current; s != nil; s = s.outer {
+		if slot, ok := s.slot(id.Value); ok {
+			pending := !crossed && !s.isDefined(id.Value)
+			if !pending || !r.visibleBeyond(s, id.Value) {
+				id.Depth, id.Slot = depth, slot
+				if pending {
+					r.warn(id, "%s is used before it is defined", id.Value)
+				}
+				return
+			}
+			// Not bound here yet, so the use means the name outside
+		}
+		if s.function {
+			crossed = true
+		}
+		depth++
+	}
+
+	// Bound nowhere: a global of the top level, which may be a builtin
+	id.Depth, id.Slot = depth-1, r.top.declare(id.Value)
+	if !r.known(id.Value) {
+		r.warn(id, "undefined name %s", id.Value)
+	}
+}
+
+// visibleBeyond reports whether name means something outside s
+func (r *resolver) visibleBeyond(s *scope, name string) bool {
+	for s = s.outer; s != nil; s = s.outer {
+		if _, ok := s.slot(name); ok {
+			return true
+		}
+	}
+	return r.known(name)
+}
+
+func (r *resolver) program(program *ast.Program) {
+	for _, stmt := range program.Statements {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		r.let(stmt)
+	case *ast.ExportStatement:
+		r.let(stmt.Statement)
+	case *ast.ReturnStatement:
+		r.expression(stmt.ReturnValue)
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		r.block(stmt)
+	case *ast.ImportStatement:
+		switch {
+		case len(stmt.Names) > 0:
+			for _, n := range stmt.Names {
+				r.bind(n)
+			}
+		case stmt.Alias != nil:
+			r.bind(stmt.Alias)
+		default:
+			r.bindName(imports.Name(stmt.Path.Value))
+		}
+	case *ast.WithStatement:
+		r.expression(stmt.Context)
+		if stmt.Name != nil {
+			r.bind(stmt.Name)
+		}
+		r.block(stmt.Body)
+	}
+}
+
+func (r *resolver) let(let *ast.LetStatement) {
+	for _, d := range let.Decorators {
+		r.expression(d)
+	}
+	r.expression(let.Value)
+	r.bind(let.Name)
+}
+
+func (r *resolver) expressions(exps []ast.Expression) {
+	for _, exp := range exps {
+		r.expression(exp)
+	}
+}
+
+func (r *resolver) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		r.use(exp)
+	case *ast.PrefixExpression:
+		r.expression(exp.Right)
+	case *ast.InfixExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Right)
+	case *ast.IfExpression:
+		r.expression(exp.Condition)
+		r.block(exp.Consequence)
+		r.block(exp.Alternative)
+	case *ast.FunctionLiteral:
+		r.function(exp)
+	case *ast.CallExpression:
+		r.expression(exp.Function)
+		r.expressions(exp.Arguments)
+	case *ast.KeywordArgument:
+		r.expression(exp.Value)
+	case *ast.SpreadArgument:
+		r.expression(exp.Value)
+	case *ast.ArrayLiteral:
+		r.expressions(exp.Elements)
+	case *ast.IndexExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Index)
+	case *ast.SliceExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Start)
+		r.expression(exp.End)
+		r.expression(exp.Step)
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			r.expression(pair.Key)
+			r.expression(pair.Value)
+		}
+	case *ast.SetLiteral:
+		r.expressions(exp.Elements)
+	case *ast.AwaitExpression:
+		r.expression(exp.Value)
+	case *ast.MemberExpression:
+		r.expression(exp.Object)
+	case *ast.ListComprehension:
+		exp.Locals = r.comprehension(exp, exp.Clauses, exp.Element)
+	case *ast.SetComprehension:
+		exp.Locals = r.comprehension(exp, exp.Clauses, exp.Element)
+	case *ast.HashComprehension:
+		exp.Locals = r.comprehension(exp, exp.Clauses, exp.Key, exp.Value)
+	case *ast.MatchExpression:
+		r.match(exp)
+	}
+}
+
+// function resolves a function literal. Defaults are evaluated where the
+// function is defined, so they resolve outside it.
+func (r *resolver) function(fn *ast.FunctionLiteral) {
+	for _, p := range fn.Parameters {
+		r.expression(p.Default)
+	}
+
+	s := r.open(fn, true)
+	for _, p := range fn.Parameters {
+		r.bind(p.Name)
+	}
+	r.block(fn.Body)
+	r.close(s)
+
+	fn.Locals = s.names
+}
+
+// comprehension resolves a comprehension, whose loop variables share one
+// scope, and returns the names of its slots
+func (r *resolver) comprehension(node ast.Node, clauses []*ast.ComprehensionClause, results ...ast.Expression) []string {
+	s := r.open(node, false)
+	for _, clause := range clauses {
+		r.expression(clause.Iterable)
+		for _, n := range clause.Names {
+			r.bind(n)
+		}
+		r.expressions(clause.Filters)
+	}
+	r.expressions(results)
+	r.close(s)
+
+	return s.names
+}
+
+func (r *resolver) match(node *ast.MatchExpression) {
+	r.expression(node.Subject)
+
+	for _, c := range node.Cases {
+		s := r.open(c, false)
+		r.pattern(c.Pattern)
+		r.expression(c.Guard)
+		r.block(c.Body)
+		r.close(s)
+
+		c.Locals = s.names
+	}
+}
+
+func (r *resolver) pattern(pattern ast.Pattern) {
+	switch pattern := pattern.(type) {
+	case *ast.LiteralPattern:
+		r.expression(pattern.Value)
+	case *ast.BindingPattern:
+		if !pattern.IsWildcard() {
+			r.bind(pattern.Name)
+		}
+	case *ast.ArrayPattern:
+		for _, p := range pattern.Before {
+			r.pattern(p)
+		}
+		for _, p := range pattern.After {
+			r.pattern(p)
+		}
+		r.rest(pattern.Rest)
+	case *ast.HashPattern:
+		r.expressions(pattern.Keys)
+		for _, p := range pattern.Values {
+			r.pattern(p)
+		}
+		r.rest(pattern.Rest)
+	}
+}
+
+func (r *resolver) rest(rest *ast.Identifier) {
+	if rest != nil && rest.Value != "_" {
+		r.bind(rest)
+	}
+}
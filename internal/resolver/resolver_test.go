@@ -0,0 +1,158 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func isBuiltin(name string) bool {
+	return name == "लेन"
+}
+
+// uses lists each identifier used in program as name@depth:slot, in the
+// order the resolver meets them
+func uses(program *ast.Program) string {
+	var out []string
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch node := node.(type) {
+		case *ast.Program:
+			for _, s := range node.Statements {
+				walk(s)
+			}
+		case *ast.BlockStatement:
+			for _, s := range node.Statements {
+				walk(s)
+			}
+		case *ast.LetStatement:
+			walk(node.Value)
+		case *ast.ExpressionStatement:
+			walk(node.Expression)
+		case *ast.Identifier:
+			out = append(out, fmt.Sprintf("%s@%d:%d", node.Value, node.Depth, node.Slot))
+		case *ast.InfixExpression:
+			walk(node.Left)
+			walk(node.Right)
+		case *ast.CallExpression:
+			walk(node.Function)
+			for _, a := range node.Arguments {
+				walk(a)
+			}
+		case *ast.ArrayLiteral:
+			for _, e := range node.Elements {
+				walk(e)
+			}
+		case *ast.FunctionLiteral:
+			walk(node.Body)
+		case *ast.ListComprehension:
+			for _, c := range node.Clauses {
+				walk(c.Iterable)
+			}
+			walk(node.Element)
+		case *ast.MatchExpression:
+			walk(node.Subject)
+			for _, c := range node.Cases {
+				walk(c.Body)
+			}
+		}
+	}
+	walk(program)
+	return strings.Join(out, " ")
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"लेट a = १; लेट b = २; [b, a]", "b@0:1 a@0:0"},
+		{"लेट a = १; फन f(x, y) { [y, x, a] }", "y@0:1 x@0:0 a@1:0"},
+		{"फन f() { फन(x) { [x, g] } }; फन g() { १ }", "x@0:0 g@2:1"},
+		{"लेट a = [१]; [x लागि x मा a]", "a@1:0 x@0:0"},
+		{"लेट a = १; मिलान a { अवस्था [x] { [x, a] } }", "a@0:0 x@0:0 a@1:0"},
+		// Builtins and undefined names take top-level slots
+		{"फन f() { लेन(z) }", "लेन@1:1 z@1:2"},
+		// A name refers to its scope even before its लेट
+		{"फन f() { लेट a = b; लेट b = १; a }", "b@0:1 a@0:0"},
+		// ...unless it means something outside until then
+		{"लेट x = १; फन f(a) { लेट x = a + x; x }", "a@0:0 x@1:0 x@0:1"},
+		{"लेट y = [१]; [y लागि y मा y]", "y@1:0 y@0:0"},
+		{"लेट x = १; फन f() { फन g() { x }; लेट x = २ }", "x@1:1"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		Resolve(program, object.NewEnvironment(), isBuiltin)
+
+		if got := uses(program); got != tt.expected {
+			t.Errorf("%s - wrong resolution. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLocals(t *testing.T) {
+	program := parse(t, "फन f(a, b = १) { लेट c = [x लागि x मा a]; यदि (b) { लेट d = c } }")
+	Resolve(program, object.NewEnvironment(), isBuiltin)
+
+	fn := program.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if got := strings.Join(fn.Locals, " "); got != "a b c d" {
+		t.Errorf("wrong function locals. expected=%q, got=%q", "a b c d", got)
+	}
+
+	body := fn.Body.Statements[0].(*ast.LetStatement).Value.(*ast.ListComprehension)
+	if got := strings.Join(body.Locals, " "); got != "x" {
+		t.Errorf("wrong comprehension locals. expected=%q, got=%q", "x", got)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"लेट a = १; फन f() { [a, g()] }; फन g() { लेन([]) }", nil},
+		{"a; लेट a = १", []string{"line 1: a is used before it is defined"}},
+		{"फन f() {\n लेट a = b;\n लेट b = १ }", []string{"line 2: b is used before it is defined"}},
+		// An outer binding is what the use means until then
+		{"लेट b = ०; फन f() { लेट a = b; लेट b = १ }", nil},
+		{"फन f() {\n x + y }", []string{"line 2: undefined name x", "line 2: undefined name y"}},
+		{"मिलान १ { अवस्था [x, *बाँकी] { बाँकी } }; x", []string{"line 1: undefined name x"}},
+	}
+
+	for _, tt := range tests {
+		warnings := Resolve(parse(t, tt.input), object.NewEnvironment(), isBuiltin)
+		if fmt.Sprint(warnings) != fmt.Sprint(tt.expected) {
+			t.Errorf("%q - wrong warnings. expected=%q, got=%q", tt.input, tt.expected, warnings)
+		}
+	}
+}
+
+func TestResolveAgainstEnvironment(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("x", &object.Integer{Value: 1})
+
+	program := parse(t, "लेट y = x; y")
+	if warnings := Resolve(program, env, isBuiltin); len(warnings) != 0 {
+		t.Errorf("unexpected warnings: %q", warnings)
+	}
+	if got := uses(program); got != "x@0:0 y@0:1" {
+		t.Errorf("wrong resolution. got=%q", got)
+	}
+}
@@ -0,0 +1,35 @@
+package trace
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// viewer is a page that steps through a log, which takes the place of
+// {{LOG}} in it
+//
+//go:embed viewer.html
+var viewer string
+
+// WriteJSON writes log to w as JSON
+func WriteJSON(w io.Writer, log *Log) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(log)
+}
+
+// WriteHTML writes to w a page that steps through log, highlighting the
+// line each event happened on and showing the frames of the calls in
+// progress. The page holds the log and all it needs to show it.
+func WriteHTML(w io.Writer, log *Log) error {
+	// Marshal escapes <, > and &, so the log cannot end the script
+	// element holding it
+	data, err := json.Marshal(log)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, strings.Replace(viewer, "{{LOG}}", string(data), 1))
+	return err
+}
@@ -0,0 +1,365 @@
+// Package trace records how a program runs under the tree-walking
+// evaluator, event by event, for `nepali trace`: the statements it runs,
+// what its expressions evaluate to, its calls and returns, the variables
+// it binds and the errors it meets. The log is JSON, and can be stepped
+// through in a page of HTML.
+package trace
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/evaluator"
+	"github.com/SunilNeupane77/nepali/internal/format"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+// DefaultMaxEvents is how many events a trace records, unless told
+// otherwise, before it stops the program
+const DefaultMaxEvents = 100000
+
+// Log is the record of a run of a program
+type Log struct {
+	File   string  `json:"file"`
+	Source string  `json:"source"`
+	Events []Event `json:"events"`
+	Result string  `json:"result"` // what the program evaluated to, shown
+}
+
+// The kinds of event
+const (
+	StatementEvent  = "statement"
+	ExpressionEvent = "expression"
+	CallEvent       = "call"
+	ReturnEvent     = "return"
+	BindEvent       = "bind"
+	ErrorEvent      = "error"
+	OutputEvent     = "output"
+)
+
+// Event is one thing the program did. Which fields are set depends on its
+// Kind.
+type Event struct {
+	Step  int    `json:"step"` // from 1
+	Kind  string `json:"kind"`
+	Line  int    `json:"line"`           // of the statement running
+	File  string `json:"file,omitempty"` // set for statements of other files than the program's
+	Depth int    `json:"depth"`          // the number of calls in progress
+
+	Code     string `json:"code,omitempty"`     // of a statement or expression
+	Function string `json:"function,omitempty"` // called or returning
+	Name     string `json:"name,omitempty"`     // bound
+	Value    string `json:"value,omitempty"`    // of an expression, a binding or a return
+	Type     string `json:"type,omitempty"`     // of Value
+	Message  string `json:"message,omitempty"`  // of an error
+	Text     string `json:"text,omitempty"`     // printed
+
+	// How the event changes the calls in progress, outermost first: the
+	// first Keep frames of those before it stay as they were, and Frames
+	// follow them. Both are unset where nothing changes, as for expression
+	// and output events. A top level is a frame of its own, so there is
+	// always one; Log.Stack replays the changes.
+	Keep   int     `json:"keep,omitempty"`
+	Frames []Frame `json:"frames,omitempty"`
+}
+
+// Stack returns the calls in progress once the event at index i has
+// happened, outermost first
+func (log *Log) Stack(i int) []Frame {
+	var stack []Frame
+	for _, e := range log.Events[:i+1] {
+		if e.Keep > 0 || e.Frames != nil {
+			stack = append(stack[:e.Keep:e.Keep], e.Frames...)
+		}
+	}
+	return stack
+}
+
+// Frame is a call in progress and the variables of its scope
+type Frame struct {
+	Function  string     `json:"function"` // <module> for a top level
+	Line      int        `json:"line"`
+	Variables []Variable `json:"variables"`
+}
+
+// Variable is a name bound in a frame
+type Variable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// Options tunes a trace
+type Options struct {
+	// MaxEvents stops the program once that many events are recorded, so
+	// that one that does not end still makes a log; 0 means
+	// DefaultMaxEvents
+	MaxEvents int
+
+	// Expressions records expression events, which are most of a log
+	Expressions bool
+}
+
+// ParseErrors reports source that does not parse, and so cannot be run
+type ParseErrors []string
+
+func (e ParseErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+// Run runs source, the contents of the file at path, recording what it
+// does. What the program prints is recorded rather than written.
+func Run(path, source string, opts Options) (*Log, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, ParseErrors(p.Errors())
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if opts.MaxEvents == 0 {
+		opts.MaxEvents = DefaultMaxEvents
+	}
+
+	t := &tracer{
+		log:   &Log{File: path, Source: source, Events: []Event{}},
+		lines: strings.Split(source, "\n"),
+		opts:  opts,
+	}
+
+	output := builtins.Output
+	builtins.Output = outputWriter{t}
+	defer func() { builtins.Output = output }()
+
+	evaluator.SetInstrument(t)
+	result := evaluator.EvalFile(program, path, object.NewEnvironment())
+	evaluator.SetInstrument(nil)
+
+	if result != nil {
+		t.log.Result = result.Inspect()
+	}
+	return t.log, nil
+}
+
+// tracer is the evaluator's instrument during a trace
+type tracer struct {
+	log     *Log
+	lines   []string // of the program's source
+	opts    Options
+	frames  []Frame // the calls in progress as of the last event
+	stopped bool    // once MaxEvents is reached
+}
+
+func (t *tracer) Statement(stmt ast.Statement, env *object.Environment) *object.Error {
+	if len(t.log.Events) >= t.opts.MaxEvents {
+		t.stopped = true
+		return &object.Error{Message: fmt.Sprintf("trace stopped after %d events", t.opts.MaxEvents)}
+	}
+	t.add(Event{Kind: StatementEvent}, true)
+
+	// A statement of the program is shown as its line of source, which
+	// reads better than the statement printed back
+	e := &t.log.Events[len(t.log.Events)-1]
+	if e.File == "" && e.Line > 0 && e.Line <= len(t.lines) {
+		e.Code = shorten(strings.TrimSpace(t.lines[e.Line-1]))
+	} else {
+		e.Code = code(stmt)
+	}
+	return nil
+}
+
+func (t *tracer) Expression(exp ast.Expression, value object.Object, env *object.Environment) {
+	if t.opts.Expressions {
+		t.add(Event{Kind: ExpressionEvent, Code: code(exp), Value: show(value), Type: string(value.Type())}, false)
+	}
+}
+
+func (t *tracer) Call(fn *object.Function, env *object.Environment) {
+	t.add(Event{Kind: CallEvent, Function: functionName(fn)}, true)
+}
+
+func (t *tracer) Return(fn *object.Function, result object.Object) {
+	e := Event{Kind: ReturnEvent, Function: functionName(fn)}
+	if err, ok := result.(*object.Error); ok {
+		e.Message = err.Message
+	} else if result != nil {
+		e.Value, e.Type = show(result), string(result.Type())
+	}
+	t.add(e, true)
+}
+
+func (t *tracer) Bind(name string, value object.Object, env *object.Environment) {
+	t.add(Event{Kind: BindEvent, Name: name, Value: show(value), Type: string(value.Type())}, true)
+}
+
+func (t *tracer) Error(err *object.Error, node ast.Node) {
+	// The error that stopped the trace is the tracer's own
+	if t.stopped {
+		return
+	}
+	t.add(Event{Kind: ErrorEvent, Code: code(node), Message: err.Message}, true)
+}
+
+// add records e, where the program is, with the frames if withFrames
+func (t *tracer) add(e Event, withFrames bool) {
+	if len(t.log.Events) >= t.opts.MaxEvents {
+		return // the next statement stops the program
+	}
+	stack := evaluator.CallStack()
+	e.Step = len(t.log.Events) + 1
+	e.Depth = len(stack)
+	// A call that has yet to run a statement is where its caller is
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].Line != 0 {
+			e.Line = stack[i].Line
+			if file := evaluator.File(stack[i].Env); file != t.log.File {
+				e.File = file
+			}
+			break
+		}
+	}
+	if withFrames {
+		t.changeFrames(&e, frames(stack))
+	}
+	t.log.Events = append(t.log.Events, e)
+}
+
+// changeFrames records in e how the calls in progress have become now
+func (t *tracer) changeFrames(e *Event, now []Frame) {
+	keep := 0
+	for keep < len(now) && keep < len(t.frames) && sameFrame(now[keep], t.frames[keep]) {
+		keep++
+	}
+	if keep < len(now) || keep < len(t.frames) {
+		e.Keep, e.Frames = keep, now[keep:]
+		if len(e.Frames) == 0 {
+			e.Frames = nil
+		}
+	}
+	t.frames = now
+}
+
+func sameFrame(a, b Frame) bool {
+	if a.Function != b.Function || a.Line != b.Line || len(a.Variables) != len(b.Variables) {
+		return false
+	}
+	for i := range a.Variables {
+		if a.Variables[i] != b.Variables[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// outputWriter records what the program prints as output events
+type outputWriter struct {
+	t *tracer
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.t.add(Event{Kind: OutputEvent, Text: string(p)}, false)
+	return len(p), nil
+}
+
+// frames describes the calls in progress
+func frames(stack []*evaluator.Frame) []Frame {
+	out := make([]Frame, len(stack))
+	for i, f := range stack {
+		out[i] = Frame{Function: "<module>", Line: f.Line, Variables: variables(f)}
+		if f.Function != nil {
+			out[i].Function = functionName(f.Function)
+		}
+	}
+	return out
+}
+
+// variables returns the variables of a call: those of its own scope and of
+// the scopes nested in it that the statement it is running is in, outer
+// ones first. The scopes a function was defined in are its callers' or
+// the top level's, and are shown there.
+func variables(f *evaluator.Frame) []Variable {
+	var scopes []*object.Environment
+	for env := f.Env; env != nil; env = env.Outer() {
+		if f.Function != nil && env == f.Function.Env {
+			break
+		}
+		scopes = append(scopes, env)
+	}
+
+	vars := []Variable{}
+	for i := len(scopes) - 1; i >= 0; i-- {
+		env := scopes[i]
+		for slot, name := range env.Names() {
+			if value := env.Lookup(0, slot); value != nil {
+				vars = append(vars, Variable{Name: name, Value: show(value), Type: string(value.Type())})
+			}
+		}
+	}
+	return vars
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
+// maxCode is how much of a statement or expression an event shows
+const maxCode = 80
+
+// code returns the source of node as an event shows it: as the formatter
+// writes it, on one line, and cut short if long
+func code(node ast.Node) string {
+	var s string
+	switch node := node.(type) {
+	case *ast.FunctionLiteral:
+		// Its body is shown by the statements it runs
+		fn := *node
+		fn.Body = &ast.BlockStatement{}
+		s = strings.TrimSuffix(format.Expression(&fn), "{}") + "{…}"
+	case ast.Expression:
+		s = format.Expression(node)
+	case ast.Statement:
+		s = format.Statement(node)
+	default:
+		s = node.String()
+	}
+	return shorten(strings.Join(strings.Fields(s), " "))
+}
+
+// show returns value as an event shows it. A function is shown by its
+// signature rather than its body.
+func show(value object.Object) string {
+	if fn, ok := value.(*object.Function); ok {
+		params := make([]string, len(fn.Parameters))
+		for i, p := range fn.Parameters {
+			params[i] = p.String()
+		}
+		prefix := ""
+		if fn.IsAsync {
+			prefix = "एसिन्क "
+		}
+		name := ""
+		if fn.Name != "" {
+			name = " " + fn.Name
+		}
+		return fmt.Sprintf("%sफन%s(%s)", prefix, name, strings.Join(params, ", "))
+	}
+	return value.Inspect()
+}
+
+func shorten(s string) string {
+	runes := []rune(s)
+	if len(runes) <= maxCode {
+		return s
+	}
+	return string(runes[:maxCode-1]) + "…"
+}
@@ -0,0 +1,268 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// summary describes the events of log one a line, without their frames
+func summary(log *Log) []string {
+	var lines []string
+	for _, e := range log.Events {
+		s := fmt.Sprintf("%d %s", e.Line, e.Kind)
+		switch e.Kind {
+		case StatementEvent:
+			s += " " + e.Code
+		case ExpressionEvent:
+			s += fmt.Sprintf(" %s => %s", e.Code, e.Value)
+		case CallEvent:
+			s += " " + e.Function
+		case ReturnEvent:
+			s += fmt.Sprintf(" %s => %s%s", e.Function, e.Value, e.Message)
+		case BindEvent:
+			s += fmt.Sprintf(" %s = %s", e.Name, e.Value)
+		case ErrorEvent:
+			s += fmt.Sprintf(" %s: %s", e.Code, e.Message)
+		case OutputEvent:
+			s += fmt.Sprintf(" %q", e.Text)
+		}
+		lines = append(lines, s)
+	}
+	return lines
+}
+
+func TestEvents(t *testing.T) {
+	tests := []struct {
+		input       string
+		expressions bool
+		expected    []string
+		result      string
+	}{
+		{
+			input: "फन f(n) {\n लेट m = n + १\n m\n}\nप्रिन्टल(f(१))",
+			expected: []string{
+				"1 statement फन f(n) {",
+				"1 bind f = फन f(n)",
+				"5 statement प्रिन्टल(f(१))",
+				"5 call f",
+				"5 bind n = 1",
+				"2 statement लेट m = n + १",
+				"2 bind m = 2",
+				"3 statement m",
+				"3 return f => 2",
+				`5 output "2\n"`,
+			},
+			result: "निल",
+		},
+		{
+			input:       "लेट x = [१, २]\nलेन(x) + १",
+			expressions: true,
+			expected: []string{
+				"1 statement लेट x = [१, २]",
+				"1 expression १ => 1",
+				"1 expression २ => 2",
+				"1 expression [१, २] => [1, 2]",
+				"1 bind x = [1, 2]",
+				"2 statement लेन(x) + १",
+				"2 expression लेन => बिल्टिन फनक्शन",
+				"2 expression x => [1, 2]",
+				"2 expression लेन(x) => 2",
+				"2 expression १ => 1",
+				"2 expression लेन(x) + १ => 3",
+			},
+			result: "3",
+		},
+		// Code is shown as the formatter writes it
+		{
+			input:       "लेट s = \"क\" + \"ख\"\nलेट g = फन(a) { a }",
+			expressions: true,
+			expected: []string{
+				"1 statement लेट s = \"क\" + \"ख\"",
+				"1 expression \"क\" => क",
+				"1 expression \"ख\" => ख",
+				"1 expression \"क\" + \"ख\" => कख",
+				"1 bind s = कख",
+				"2 statement लेट g = फन(a) { a }",
+				"2 expression फन(a) {…} => फन g(a)",
+				"2 bind g = फन g(a)",
+			},
+			result: "",
+		},
+		// Comprehensions and patterns bind names too
+		{
+			input: "लेट r = [x लागि x मा [१, २]]\nमिलान r { अवस्था [a, *rest] { a } }",
+			expected: []string{
+				"1 statement लेट r = [x लागि x मा [१, २]]",
+				"1 bind x = 1",
+				"1 bind x = 2",
+				"1 bind r = [1, 2]",
+				"2 statement मिलान r { अवस्था [a, *rest] { a } }",
+				"2 bind a = 1",
+				"2 bind rest = [2]",
+				"2 statement मिलान r { अवस्था [a, *rest] { a } }",
+			},
+			result: "1",
+		},
+		// An error is reported where it happens, and returns from each
+		// call it leaves, tail calls included
+		{
+			input: "फन f(n) {\n यदि (n == ०) { प्रतिफल १ / n }\n f(n - १)\n}\nf(१)",
+			expected: []string{
+				"1 statement फन f(n) {",
+				"1 bind f = फन f(n)",
+				"5 statement f(१)",
+				"5 call f",
+				"5 bind n = 1",
+				"2 statement यदि (n == ०) { प्रतिफल १ / n }",
+				"3 statement f(n - १)",
+				"3 call f",
+				"3 bind n = 0",
+				"2 statement यदि (n == ०) { प्रतिफल १ / n }",
+				"2 statement यदि (n == ०) { प्रतिफल १ / n }",
+				"2 error १ / n: division by zero",
+				"2 return f => division by zero",
+				"2 return f => division by zero",
+			},
+			result: "ERROR: division by zero",
+		},
+	}
+
+	for _, tt := range tests {
+		log, err := Run("test.nep", tt.input, Options{Expressions: tt.expressions})
+		if err != nil {
+			t.Fatalf("%q: %s", tt.input, err)
+		}
+		if got := summary(log); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q - wrong events.\nexpected:\n\t%s\ngot:\n\t%s", tt.input,
+				strings.Join(tt.expected, "\n\t"), strings.Join(got, "\n\t"))
+		}
+		if log.Result != tt.result {
+			t.Errorf("%q - wrong result. expected=%s, got=%s", tt.input, tt.result, log.Result)
+		}
+		for i, e := range log.Events {
+			if e.Step != i+1 {
+				t.Errorf("%q - event %d has step %d", tt.input, i+1, e.Step)
+			}
+		}
+	}
+}
+
+func TestFrames(t *testing.T) {
+	input := "लेट a = १\nफन f(x) {\n लेट b = x + a\n मिलान b { अवस्था y {\n y\n } }\n}\nf(२)"
+	log, err := Run("test.nep", input, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The frames as the statement in the case starts
+	last := -1
+	for i, e := range log.Events {
+		if e.Kind == StatementEvent && e.Line == 5 {
+			last = i
+		}
+	}
+	if last < 0 {
+		t.Fatal("no statement on line 5")
+	}
+
+	var frames []string
+	for _, f := range log.Stack(last) {
+		var vars []string
+		for _, v := range f.Variables {
+			vars = append(vars, v.Name+"="+v.Value)
+		}
+		frames = append(frames, fmt.Sprintf("%s:%d %s", f.Function, f.Line, strings.Join(vars, " ")))
+	}
+	expected := []string{"<module>:8 a=1 f=फन f(x)", "f:5 x=2 b=3 y=3"}
+	if !reflect.DeepEqual(frames, expected) {
+		t.Errorf("wrong frames. expected=%q, got=%q", expected, frames)
+	}
+}
+
+// TestFrameChanges checks that events record only the frames that change
+func TestFrameChanges(t *testing.T) {
+	input := "लेट a = १\nफन f(x) {\n x\n}\nf(a)\nf(a)"
+	log, err := Run("test.nep", input, Options{Expressions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []string
+	for _, e := range log.Events {
+		if e.Keep == 0 && e.Frames == nil {
+			continue
+		}
+		var names []string
+		for _, f := range e.Frames {
+			names = append(names, fmt.Sprintf("%s:%d", f.Function, f.Line))
+		}
+		changes = append(changes, fmt.Sprintf("%s %d+%s", e.Kind, e.Keep, strings.Join(names, ",")))
+	}
+	expected := []string{
+		"statement 0+<module>:1",
+		"bind 0+<module>:1",
+		"statement 0+<module>:2",
+		"bind 0+<module>:2",
+		"statement 0+<module>:5",
+		"call 1+f:0",
+		"statement 1+f:3",
+		"statement 0+<module>:6",
+		"call 1+f:0",
+		"statement 1+f:3",
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("wrong frame changes.\nexpected=%q\ngot=%q", expected, changes)
+	}
+}
+
+func TestMaxEvents(t *testing.T) {
+	log, err := Run("test.nep", "फन f() { f() }\nf()", Options{MaxEvents: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Events) != 20 {
+		t.Errorf("expected 20 events, got %d", len(log.Events))
+	}
+	if log.Result != "ERROR: trace stopped after 20 events" {
+		t.Errorf("wrong result: %s", log.Result)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Run("test.nep", "लेट = ५", Options{})
+	if _, ok := err.(ParseErrors); !ok {
+		t.Errorf("expected parse errors, got %v", err)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	log, err := Run("test.nep", "प्रिन्टल(\"</script>\")", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := WriteHTML(&out, log); err != nil {
+		t.Fatal(err)
+	}
+	page := out.String()
+
+	start := strings.Index(page, `<script type="application/json" id="log">`)
+	if start < 0 {
+		t.Fatal("the page holds no log")
+	}
+	start += len(`<script type="application/json" id="log">`)
+	end := strings.Index(page[start:], "</script>")
+
+	var embedded Log
+	if err := json.Unmarshal([]byte(page[start:start+end]), &embedded); err != nil {
+		t.Fatalf("the log in the page is not JSON: %s", err)
+	}
+	if !reflect.DeepEqual(&embedded, log) {
+		t.Errorf("the log in the page differs from the log")
+	}
+}
@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/async"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// running is the VM executing compiled code: the one running a program, or
+// the thread of an async call while its body runs. Closures called from Go
+// run on it, on top of whatever it is in the middle of.
+var running *VM
+
+// startAsync starts cl's body, its parameter slots bound, on a thread of its
+// own and returns a promise for its result. The thread is a VM with a stack
+// and frames but no globals, since the frames reach those through their
+// closures.
+func startAsync(cl *Closure, slots []object.Object) *object.Promise {
+	thread := &VM{
+		stack:  make([]object.Object, initialStackSize),
+		frames: make([]Frame, initialFrames),
+	}
+	var prev *VM
+
+	return async.Start(func() object.Object {
+		if err := thread.push(cl); err != nil {
+			return err
+		}
+		bp := thread.sp
+		for _, val := range slots {
+			if err := thread.push(val); err != nil {
+				return err
+			}
+		}
+		if err := thread.pushFrame(cl, bp); err != nil {
+			return err
+		}
+		if err := thread.run(0); err != nil {
+			return err
+		}
+		return thread.pop()
+	}, func(resumed bool) {
+		if resumed {
+			prev, running = running, thread
+		} else {
+			running = prev
+		}
+	})
+}
+
+// await replaces the promise on top of the stack with what it settles to,
+// or returns the error it is rejected with
+func (vm *VM) await() *object.Error {
+	promise, ok := vm.stack[vm.sp-1].(*object.Promise)
+	if !ok {
+		// Awaiting a plain value just yields it
+		return nil
+	}
+
+	val := async.Await(promise)
+	if err, ok := val.(*object.Error); ok {
+		vm.sp--
+		return err
+	}
+	vm.stack[vm.sp-1] = val
+	return nil
+}
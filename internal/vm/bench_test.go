@@ -0,0 +1,60 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/compiler"
+	"github.com/SunilNeupane77/nepali/internal/evaluator"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// benchmarks are run on both engines
+var benchmarks = []struct {
+	name  string
+	input string
+}{
+	{"fibonacci", `
+		फन fib(n) { यदि (n < २) { n } अन्यथा { fib(n - १) + fib(n - २) } }
+		fib(२०)`},
+	{"comprehension", `
+		लेट दस = [१, २, ३, ४, ५, ६, ७, ८, ९, १०];
+		लेट सूची = [a * १०० + b * १० + c लागि a मा दस लागि b मा दस लागि c मा दस];
+		लेन([x * २ + १ लागि x मा सूची यदि x > ५००])`},
+	{"closures", `
+		फन गणक(n) { फन(x) { x + n } }
+		फन दोहोर्याउ(f, n, x) { यदि (n == ०) { x } अन्यथा { दोहोर्याउ(f, n - १, f(x)) } }
+		दोहोर्याउ(गणक(३), २०००, ०)`},
+}
+
+func BenchmarkTreeWalker(b *testing.B) {
+	for _, bm := range benchmarks {
+		program := parse(b, bm.input)
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err, ok := evaluator.Eval(program, object.NewEnvironment()).(*object.Error); ok {
+					b.Fatal(err.Inspect())
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkVM(b *testing.B) {
+	for _, bm := range benchmarks {
+		program := parse(b, bm.input)
+		b.Run(bm.name, func(b *testing.B) {
+			c := compiler.New()
+			if err := c.Compile(program); err != nil {
+				b.Fatal(err)
+			}
+			bytecode := c.Bytecode()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err, ok := New(bytecode).Run().(*object.Error); ok {
+					b.Fatal(err.Inspect())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/code"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// call calls the function below the top argc values of the stack
+func (vm *VM) call(argc int) *object.Error {
+	callee := vm.stack[vm.sp-1-argc]
+
+	if cl, ok := callee.(*Closure); ok && cl.simple && argc == len(cl.Parameters) {
+		return vm.pushFrame(cl, vm.sp-argc)
+	}
+
+	args := make([]object.Object, argc)
+	copy(args, vm.stack[vm.sp-argc:vm.sp])
+	vm.sp -= argc + 1
+
+	return vm.callValue(callee, args, nil)
+}
+
+// callValue calls fn, which is no longer on the stack. A closure starts
+// running in a new frame; anything else, async closures included, leaves
+// its result on the stack.
+func (vm *VM) callValue(fn object.Object, args []object.Object, kwargs *object.Hash) *object.Error {
+	switch fn := fn.(type) {
+	case *Closure:
+		slots, err := bindArguments(fn, args, kwargs)
+		if err != nil {
+			return err
+		}
+		if fn.Fn.IsAsync {
+			return vm.push(startAsync(fn, slots))
+		}
+
+		if err := vm.push(fn); err != nil {
+			return err
+		}
+		bp := vm.sp
+		for _, val := range slots {
+			if err := vm.push(val); err != nil {
+				return err
+			}
+		}
+		return vm.pushFrame(fn, bp)
+
+	case *object.Builtin:
+		if kwargs != nil && kwargs.Len() > 0 {
+			return newError("builtin functions do not take keyword arguments")
+		}
+		result := fn.Fn(args...)
+		if err, ok := result.(*object.Error); ok {
+			return err
+		}
+		return vm.push(orNull(result))
+
+	case object.Callable:
+		result := fn.Call(args...)
+		if err, ok := result.(*object.Error); ok {
+			return err
+		}
+		return vm.push(orNull(result))
+
+	default:
+		return newError("not a function: %s", typeOf(fn))
+	}
+}
+
+// tailCall calls fn, no longer on the stack, from where the running frame
+// returns its result. A closure runs in that frame's place, so that
+// recursion through tail calls takes no more frames however deep it goes;
+// anything else is called as callValue calls it.
+func (vm *VM) tailCall(fn object.Object, args []object.Object, kwargs *object.Hash) *object.Error {
+	cl, ok := fn.(*Closure)
+	if !ok || cl.Fn.IsAsync || !vm.canReplaceFrame() {
+		return vm.callValue(fn, args, kwargs)
+	}
+
+	slots, err := bindArguments(cl, args, kwargs)
+	if err != nil {
+		return err
+	}
+
+	caller := &vm.frames[vm.framesIndex-1]
+	replaced := appendRun(caller.replaced, caller.cl.Name)
+	vm.popFrame()
+	if err := vm.push(cl); err != nil {
+		return err
+	}
+	bp := vm.sp
+	for _, val := range slots {
+		if err := vm.push(val); err != nil {
+			return err
+		}
+	}
+	if err := vm.pushFrame(cl, bp); err != nil {
+		return err
+	}
+	vm.frames[vm.framesIndex-1].replaced = replaced
+	return nil
+}
+
+// canReplaceFrame reports whether the running frame can give way to a
+// tail call: it is a function's, not a top level's, and is not inside a
+// सँग block, whose exit hook must run after the call
+func (vm *VM) canReplaceFrame() bool {
+	if vm.frames[vm.framesIndex-1].cl == vm.main {
+		return false
+	}
+	n := len(vm.handlers)
+	return n == 0 || vm.handlers[n-1].frame != vm.framesIndex
+}
+
+// callSync calls fn and runs it to completion, returning its result or
+// error. It lets the VM call functions from Go: builtins calling back, and
+// the hooks of सँग blocks.
+func (vm *VM) callSync(fn object.Object, args []object.Object, kwargs *object.Hash) object.Object {
+	base, sp := vm.framesIndex, vm.sp
+
+	if err := vm.callValue(fn, args, kwargs); err != nil {
+		vm.sp = sp
+		return err
+	}
+	if vm.framesIndex > base {
+		if err := vm.run(base); err != nil {
+			vm.sp = sp
+			return err
+		}
+	}
+
+	return vm.pop()
+}
+
+// bindArguments binds a call's arguments to cl's parameters, returning the
+// values of its parameter slots. Positional arguments fill the parameters
+// before any *rest in order, keyword arguments fill parameters by name, and
+// defaults fill what is left. Anything unbound or unclaimed is an error.
+func bindArguments(cl *Closure, args []object.Object, kwargs *object.Hash) ([]object.Object, *object.Error) {
+	params := cl.Parameters
+	slots := make([]object.Object, len(params))
+
+	rest, keywordRest := -1, -1
+	positional := 0 // parameters that can be passed by position
+	next := 0       // next positional argument to bind
+
+	for i, param := range params {
+		switch {
+		case param.Rest:
+			rest = i
+		case param.KeywordRest:
+			keywordRest = i
+		case rest == -1:
+			positional++
+			if next < len(args) {
+				slots[i] = args[next]
+				next++
+			}
+		}
+	}
+
+	if rest != -1 {
+		slots[rest] = &object.Array{Elements: append([]object.Object{}, args[next:]...)}
+	} else if next < len(args) {
+		return nil, arityError(params, len(args), positional)
+	}
+
+	extra := object.NewHash()
+	if kwargs != nil {
+		for _, pair := range kwargs.Pairs() {
+			name := pair.Key.(*object.String).Value
+
+			i := findParameter(params, name)
+			switch {
+			case i != -1 && slots[i] != nil:
+				return nil, newError("got multiple values for argument %s", name)
+			case i != -1:
+				slots[i] = pair.Value
+			case keywordRest != -1:
+				extra.Set(pair.Key.(*object.String), pair.Value)
+			default:
+				return nil, newError("unexpected keyword argument %s", name)
+			}
+		}
+	}
+	if keywordRest != -1 {
+		slots[keywordRest] = extra
+	}
+
+	missing := []string{}
+	for i, param := range params {
+		if slots[i] != nil {
+			continue
+		}
+		if param.Default != nil {
+			slots[i] = param.Default
+			continue
+		}
+		missing = append(missing, param.Name)
+	}
+
+	switch len(missing) {
+	case 0:
+		return slots, nil
+	case 1:
+		return nil, newError("missing argument %s", missing[0])
+	default:
+		return nil, newError("missing arguments %s", strings.Join(missing, ", "))
+	}
+}
+
+// arityError reports too many positional arguments
+func arityError(params []*object.Parameter, got, positional int) *object.Error {
+	required := 0
+	for _, param := range params[:positional] {
+		if param.Default == nil {
+			required++
+		}
+	}
+
+	want := fmt.Sprint(positional)
+	if required < positional {
+		want = fmt.Sprintf("%d to %d", required, positional)
+	}
+	return newError("wrong number of arguments. got=%d, want=%s", got, want)
+}
+
+// findParameter returns the index of the parameter called name that can be
+// passed by keyword, or -1
+func findParameter(params []*object.Parameter, name string) int {
+	for i, param := range params {
+		if param.Name == name && !param.Rest && !param.KeywordRest {
+			return i
+		}
+	}
+	return -1
+}
+
+// addArgument adds an argument of a call with keyword or spread arguments
+// to the positional args or the keyword arguments kwargs
+func addArgument(args *object.Array, kwargs *object.Hash, kind int, constants []object.Object, nameIdx int, val object.Object) *object.Error {
+	setKeyword := func(name string, val object.Object) *object.Error {
+		key := &object.String{Value: name}
+		if _, ok := kwargs.Get(key); ok {
+			return newError("keyword argument %s given more than once", name)
+		}
+		kwargs.Set(key, val)
+		return nil
+	}
+
+	switch kind {
+	case code.ArgPositional:
+		args.Elements = append(args.Elements, val)
+
+	case code.ArgSpread:
+		arr, ok := val.(*object.Array)
+		if !ok {
+			return newError("cannot spread %s into positional arguments", typeOf(val))
+		}
+		args.Elements = append(args.Elements, arr.Elements...)
+
+	case code.ArgKeyword:
+		return setKeyword(constants[nameIdx].(*object.String).Value, val)
+
+	case code.ArgKeywordSpread:
+		hash, ok := val.(*object.Hash)
+		if !ok {
+			return newError("cannot spread %s into keyword arguments", typeOf(val))
+		}
+		for _, pair := range hash.Pairs() {
+			name, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("keyword argument names must be STRING, got %s", pair.Key.Type())
+			}
+			if err := setKeyword(name.Value, pair.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// preserveIdentity returns wrapper carrying the name and docstring of the
+// function it wraps. The wrapper is copied, since the decorator may hand out
+// the same function more than once.
+func preserveIdentity(wrapper, original object.Object) object.Object {
+	w, ok := wrapper.(*Closure)
+	if !ok || w == original {
+		return wrapper
+	}
+	orig, ok := original.(*Closure)
+	if !ok {
+		return w
+	}
+
+	copied := *w
+	copied.Name = orig.Name
+	copied.Doc = orig.Doc
+	return &copied
+}
@@ -0,0 +1,65 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Closure is a compiled function together with the variables it captured
+// and the defaults evaluated when it was made. It is a FUNCTION to programs,
+// like the tree-walker's functions.
+type Closure struct {
+	Fn         *object.CompiledFunction
+	Free       []*Upvalue
+	Parameters []*object.Parameter // Fn's parameters, with their defaults
+	Name       string              // taken over from the function a decorator wraps
+	Doc        string
+
+	vm     *VM
+	simple bool // every call binds its arguments to its parameters one to one, in a new frame
+}
+
+func (cl *Closure) Type() object.ObjectType {
+	return object.FUNCTION_OBJ
+}
+
+func (cl *Closure) Inspect() string {
+	params := []string{}
+	for _, p := range cl.Parameters {
+		params = append(params, p.String())
+	}
+
+	name := ""
+	if cl.Name != "" {
+		name = " " + cl.Name
+	}
+
+	return fmt.Sprintf("फन%s(%s) {\n%s\n}", name, strings.Join(params, ", "), cl.Fn.Body)
+}
+
+// Call runs the closure to completion on the VM running, so builtins can
+// call back into compiled code
+func (cl *Closure) Call(args ...object.Object) object.Object {
+	vm := running
+	if vm == nil {
+		vm = cl.vm
+	}
+	return vm.callSync(cl, args, nil)
+}
+
+// Upvalue is a variable captured by a closure. While the function that
+// declared it is running it points into that function's stack slot, so
+// both see every assignment; when that function returns it is closed over
+// its last value.
+type Upvalue struct {
+	location *object.Object
+	closed   object.Object
+	slot     int
+}
+
+func (u *Upvalue) close() {
+	u.closed = *u.location
+	u.location = &u.closed
+}
@@ -0,0 +1,124 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// Names of the hooks a context manager provides, as hash entries or as
+// builtin methods
+const (
+	enterHook = "प्रवेश"
+	exitHook  = "निकास"
+)
+
+// enterWith enters the context manager on top of the stack, replacing it
+// with the value प्रवेश() returns. Until OpWithExit, an error raised in the
+// current frame or any frame it calls first runs the निकास hook.
+func (vm *VM) enterWith(target int) *object.Error {
+	ctx := vm.pop()
+
+	enter, exit, err := contextHooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	entered := vm.callSync(enter, nil, nil)
+	if err, ok := entered.(*object.Error); ok {
+		return err
+	}
+
+	vm.handlers = append(vm.handlers, handler{
+		frame:  vm.framesIndex,
+		sp:     vm.sp,
+		target: target,
+		exit:   exit,
+	})
+	return vm.push(entered)
+}
+
+// exitHandlers runs the exit hooks of the सँग blocks a returning frame is
+// inside, innermost first
+func (vm *VM) exitHandlers() *object.Error {
+	for len(vm.handlers) > 0 {
+		h := vm.handlers[len(vm.handlers)-1]
+		if h.frame != vm.framesIndex {
+			return nil
+		}
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		if err, ok := vm.callSync(h.exit, []object.Object{object.NULL}, nil).(*object.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle passes err to the निकास hooks of the सँग blocks it leaves, above
+// base, innermost first. A truthy result other than निल suppresses it and
+// execution goes on after that block; an error from the hook replaces it.
+// It returns the error left once every block has seen it, with the frames
+// above base unwound.
+func (vm *VM) handle(err *object.Error, base int) *object.Error {
+	for len(vm.handlers) > 0 {
+		h := vm.handlers[len(vm.handlers)-1]
+		if h.frame <= base {
+			break
+		}
+		vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		vm.unwind(err, h.frame)
+		vm.sp = h.sp
+
+		suppress := vm.callSync(h.exit, []object.Object{err.Value()}, nil)
+		if hookErr, ok := suppress.(*object.Error); ok {
+			err = hookErr
+			continue
+		}
+		if suppress != object.NULL && isTruthy(suppress) {
+			vm.frames[vm.framesIndex-1].ip = h.target
+			return nil
+		}
+	}
+
+	vm.unwind(err, base)
+	return err
+}
+
+// unwind pops frames until depth are left, recording the names of the
+// functions err leaves in its trace, those replaced by tail calls included
+func (vm *VM) unwind(err *object.Error, depth int) {
+	for vm.framesIndex > depth {
+		frame := &vm.frames[vm.framesIndex-1]
+		runs := appendRun(append([]tailRun(nil), frame.replaced...), frame.cl.Name)
+		for i := len(runs) - 1; i >= 0; i-- {
+			if runs[i].count == 1 {
+				err.Trace = append(err.Trace, runs[i].name)
+			} else {
+				err.Trace = append(err.Trace, fmt.Sprintf("%s (×%d by tail calls)", runs[i].name, runs[i].count))
+			}
+		}
+		vm.popFrame()
+	}
+}
+
+// contextHooks finds the enter and exit hooks of a context manager
+func contextHooks(ctx object.Object) (object.Object, object.Object, *object.Error) {
+	hooks := [2]object.Object{}
+
+	for i, name := range []string{enterHook, exitHook} {
+		if hash, ok := ctx.(*object.Hash); ok {
+			hooks[i], _ = hash.Get(&object.String{Value: name})
+		} else {
+			hooks[i], _ = builtins.LookupMethod(ctx, name)
+		}
+
+		if hooks[i] == nil {
+			return nil, nil, newError("%s is not a context manager: missing %s", typeOf(ctx), name)
+		}
+	}
+
+	return hooks[0], hooks[1], nil
+}
@@ -0,0 +1,119 @@
+package vm
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SunilNeupane77/nepali/internal/compiler"
+	"github.com/SunilNeupane77/nepali/internal/imports"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+// ModuleLoader resolves, compiles, runs and caches the modules programs
+// import. Each module runs once, on a VM of its own, and is loaded from its
+// compiled file when that is up to date. The file a program is run from is
+// a module like any other, so importing it back is a circular import.
+type ModuleLoader struct {
+	// SearchPath lists directories tried, in order, for imports that are not
+	// relative to the importing file
+	SearchPath []string
+
+	cache   map[string]*object.Module
+	loading []string // files being run, outermost first
+}
+
+// NewModuleLoader creates a ModuleLoader that searches the given directories
+func NewModuleLoader(searchPath ...string) *ModuleLoader {
+	return &ModuleLoader{
+		SearchPath: searchPath,
+		cache:      make(map[string]*object.Module),
+	}
+}
+
+// modules loads every module imported by programs the VM runs
+var modules = NewModuleLoader()
+
+// SetModuleLoader replaces the loader used to resolve imports
+func SetModuleLoader(ml *ModuleLoader) {
+	modules = ml
+}
+
+// load returns the module for importPath, running it on first use
+func (ml *ModuleLoader) load(importPath, from string) object.Object {
+	path, ok := ml.resolve(importPath, from)
+	if !ok {
+		return newError("module not found: %s", importPath)
+	}
+
+	if mod, ok := ml.cache[path]; ok {
+		return mod
+	}
+
+	if cycle, ok := imports.Cycle(ml.loading, path); ok {
+		return newError("circular import: %s", cycle)
+	}
+
+	bytecode, _, err := compiler.Load(path)
+	var parseErrors compiler.ParseErrors
+	switch {
+	case errors.As(err, &parseErrors):
+		return newError("parse errors in module %s: %s", importPath, err)
+	case errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission):
+		return newError("could not read module %s: %s", importPath, err)
+	case err != nil:
+		return newError("could not compile module %s: %s", importPath, err)
+	}
+
+	if err, ok := NewFile(bytecode, path).runMain().(*object.Error); ok {
+		return err
+	}
+	return ml.cache[path]
+}
+
+// enter records that the program of the file at path is running, so that
+// importing it again is found to be circular
+func (ml *ModuleLoader) enter(path string) {
+	ml.loading = append(ml.loading, path)
+}
+
+// leave records that the program of vm's file has finished, caching its
+// module if it ran to completion
+func (ml *ModuleLoader) leave(vm *VM, ok bool) {
+	ml.loading = ml.loading[:len(ml.loading)-1]
+	if !ok {
+		return
+	}
+
+	mod := &object.Module{
+		Name:    imports.Name(vm.file),
+		Path:    vm.file,
+		Exports: make(map[string]object.Object),
+	}
+	for _, name := range vm.exports {
+		for i, global := range vm.globalNames {
+			if global == name {
+				mod.Exports[name] = vm.globals[i]
+			}
+		}
+	}
+	ml.cache[vm.file] = mod
+}
+
+// resolve finds the file an import refers to: its source, or failing that
+// a compiled file deployed in its place, named as the source would be
+func (ml *ModuleLoader) resolve(importPath, from string) (string, bool) {
+	if path, ok := imports.Resolve(importPath, from, ml.SearchPath); ok {
+		return path, true
+	}
+
+	if filepath.Ext(importPath) != "" {
+		return "", false
+	}
+	path, ok := imports.Resolve(importPath+compiler.CompiledExt, from, ml.SearchPath)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSuffix(path, compiler.CompiledExt) + imports.SourceExt, true
+}
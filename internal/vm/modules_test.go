@@ -0,0 +1,191 @@
+package vm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/compiler"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+func writeModules(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testRunFile(t *testing.T, path string, searchPath ...string) object.Object {
+	t.Helper()
+
+	prev := modules
+	SetModuleLoader(NewModuleLoader(searchPath...))
+	t.Cleanup(func() { SetModuleLoader(prev) })
+
+	bytecode, _, err := compiler.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewFile(bytecode, path).Run()
+}
+
+func TestImportModule(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"विद्यार्थी.nep": `
+निर्यात लेट नयाँ = फन(नाम, कक्षा) { {"नाम": नाम, "कक्षा": कक्षा} };
+निर्यात लेट पूर्वनिर्धारित_कक्षा = १०;
+लेट निजी = ४२;
+निर्यात फन निजी_पढ() { निजी }
+`,
+		"main.nep": `
+आयात "./विद्यार्थी";
+आयात "./विद्यार्थी" जस्तो व;
+बाट "./विद्यार्थी" आयात नयाँ, निजी_पढ;
+लेट निजी = ०;
+[विद्यार्थी.नयाँ("राम", व.पूर्वनिर्धारित_कक्षा)["कक्षा"], नयाँ("सीता", ९)["नाम"], विद्यार्थी == व, निजी_पढ()]
+`,
+	})
+
+	result := testRunFile(t, filepath.Join(dir, "main.nep"))
+	expected := "[10, सीता, सत्य, 42]"
+	if inspect(result) != expected {
+		t.Errorf("wrong result. expected=%s, got=%s", expected, inspect(result))
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"क.nep":       `आयात "./ख"; निर्यात लेट x = १;`,
+		"ख.nep":       `आयात "./ग";`,
+		"ग.nep":       `आयात "./क";`,
+		"निजी.nep":    `लेट गोप्य = १; निर्यात लेट खुला = २;`,
+		"खराब.nep":    `लेट = ;`,
+		"cycle.nep":   `आयात "./क";`,
+		"private.nep": `आयात "./निजी"; निजी.गोप्य`,
+		"missing.nep": `आयात "./छैन";`,
+		"from.nep":    `बाट "./निजी" आयात गोप्य;`,
+		"broken.nep":  `आयात "./खराब";`,
+	})
+
+	tests := []struct {
+		file     string
+		expected string
+	}{
+		{"cycle.nep", "circular import: क.nep -> ख.nep -> ग.nep -> क.nep"},
+		{"private.nep", "module निजी has no exported name गोप्य"},
+		{"missing.nep", "module not found: ./छैन"},
+		{"from.nep", "module निजी has no exported name गोप्य"},
+		{"broken.nep", "parse errors in module ./खराब: expected next token to be IDENT, got = instead; no prefix parse function for = found"},
+	}
+
+	for _, tt := range tests {
+		err, ok := testRunFile(t, filepath.Join(dir, tt.file)).(*object.Error)
+		if !ok {
+			t.Errorf("%s - expected ERROR", tt.file)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("%s - wrong error. expected=%q, got=%q", tt.file, tt.expected, err.Message)
+		}
+	}
+}
+
+func TestImportCycleThroughEntryFile(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"p.nep": `प्रिन्ट("p सुरु"); आयात "./q";`,
+		"q.nep": `आयात "./p"; निर्यात लेट x = १;`,
+	})
+
+	var out bytes.Buffer
+	output := builtins.Output
+	builtins.Output = &out
+	t.Cleanup(func() { builtins.Output = output })
+
+	result := testRunFile(t, filepath.Join(dir, "p.nep"))
+	expected := "circular import: p.nep -> q.nep -> p.nep"
+	if err, ok := result.(*object.Error); !ok || err.Message != expected {
+		t.Errorf("wrong result. expected=%q, got=%s", expected, inspect(result))
+	}
+	if out.String() != "p सुरु \n" {
+		t.Errorf("entry file run more than once. output=%q", out.String())
+	}
+}
+
+func TestImportLeavesAsyncWorkRunning(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"m.nep": `निर्यात लेट x = १;`,
+		"main.nep": `लेट लग = []
+			लेट f = एसिन्क फन() { पर्ख सुत्नुहोस्(१); लग.थप("f") }
+			लेट p = f()
+			आयात "./m"
+			लग.थप("आयात")
+			पर्ख p
+			लग`,
+	})
+
+	// The import must not run f to completion
+	result := testRunFile(t, filepath.Join(dir, "main.nep"))
+	if inspect(result) != "[आयात, f]" {
+		t.Errorf("wrong result. expected=[आयात, f], got=%s", inspect(result))
+	}
+}
+
+func TestImportSearchPathAndCaching(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	writeModules(t, dir, map[string]string{
+		"lib/गणित.nep": `निर्यात लेट वर्ग = फन(x) { x * x };`,
+		"lib/क.nep":    `आयात "गणित"; निर्यात लेट ग = गणित;`,
+		"main.nep":     `आयात "गणित"; आयात "क"; [गणित.वर्ग(७), गणित == क.ग]`,
+	})
+
+	result := testRunFile(t, filepath.Join(dir, "main.nep"), lib)
+	expected := "[49, सत्य]"
+	if inspect(result) != expected {
+		t.Errorf("wrong result. expected=%s, got=%s", expected, inspect(result))
+	}
+
+	// Running leaves no compiled files behind
+	for _, pattern := range []string{"*", "lib/*"} {
+		if compiled, _ := filepath.Glob(filepath.Join(dir, pattern+compiler.CompiledExt)); len(compiled) != 0 {
+			t.Errorf("compiled files written: %v", compiled)
+		}
+	}
+}
+
+func TestImportCompiledOnly(t *testing.T) {
+	dir := t.TempDir()
+	writeModules(t, dir, map[string]string{
+		"गणित.nep": `निर्यात लेट वर्ग = फन(x) { x * x };`,
+		"main.nep": `बाट "./गणित" आयात वर्ग; वर्ग(९)`,
+	})
+
+	// Compiled files can be deployed alone
+	for _, name := range []string{"गणित.nep", "main.nep"} {
+		if _, err := compiler.CompileFile(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := testRunFile(t, filepath.Join(dir, "main.nep"))
+	if inspect(result) != "81" {
+		t.Errorf("wrong result. expected=81, got=%s", inspect(result))
+	}
+}
@@ -0,0 +1,332 @@
+package vm
+
+import (
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/code"
+	"github.com/SunilNeupane77/nepali/internal/grapheme"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLess:         "<",
+	code.OpGreater:      ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+func binaryOp(op code.Opcode, left, right object.Object) (object.Object, *object.Error) {
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			return integerOp(op, l.Value, r.Value)
+		}
+	}
+
+	operator := operators[op]
+	switch {
+	case op == code.OpEqual:
+		return nativeBoolToBooleanObject(object.Equal(left, right)), nil
+	case op == code.OpNotEqual:
+		return nativeBoolToBooleanObject(!object.Equal(left, right)), nil
+	case left.Type() != right.Type():
+		return nil, newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case left.Type() == object.STRING_OBJ && op == code.OpAdd:
+		return &object.String{Value: left.(*object.String).Value + right.(*object.String).Value}, nil
+	case (left.Type() == object.STRING_OBJ || left.Type() == object.ARRAY_OBJ) && isOrdering(op):
+		return ordering(op, object.Compare(left, right)), nil
+	default:
+		return nil, newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func integerOp(op code.Opcode, left, right int64) (object.Object, *object.Error) {
+	switch op {
+	case code.OpAdd:
+		return integer(left + right), nil
+	case code.OpSub:
+		return integer(left - right), nil
+	case code.OpMul:
+		return integer(left * right), nil
+	case code.OpDiv:
+		if right == 0 {
+			return nil, newError("division by zero")
+		}
+		return integer(left / right), nil
+	case code.OpEqual:
+		return nativeBoolToBooleanObject(left == right), nil
+	case code.OpNotEqual:
+		return nativeBoolToBooleanObject(left != right), nil
+	case code.OpLess:
+		return nativeBoolToBooleanObject(left < right), nil
+	case code.OpGreater:
+		return nativeBoolToBooleanObject(left > right), nil
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(left <= right), nil
+	default:
+		return nativeBoolToBooleanObject(left >= right), nil
+	}
+}
+
+func isOrdering(op code.Opcode) bool {
+	switch op {
+	case code.OpLess, code.OpGreater, code.OpLessEqual, code.OpGreaterEqual:
+		return true
+	default:
+		return false
+	}
+}
+
+// ordering applies an ordering operator to the result of object.Compare
+func ordering(op code.Opcode, cmp int) object.Object {
+	switch op {
+	case code.OpLess:
+		return nativeBoolToBooleanObject(cmp < 0)
+	case code.OpGreater:
+		return nativeBoolToBooleanObject(cmp > 0)
+	case code.OpLessEqual:
+		return nativeBoolToBooleanObject(cmp <= 0)
+	default:
+		return nativeBoolToBooleanObject(cmp >= 0)
+	}
+}
+
+// smallIntegers are shared by arithmetic results in their range, which
+// spares loops most of their allocations. Integers are never modified in
+// place, so sharing them is safe.
+var smallIntegers = func() []object.Integer {
+	ints := make([]object.Integer, smallIntegerMax-smallIntegerMin+1)
+	for i := range ints {
+		ints[i].Value = int64(i + smallIntegerMin)
+	}
+	return ints
+}()
+
+const (
+	smallIntegerMin = -128
+	smallIntegerMax = 1023
+)
+
+func integer(v int64) *object.Integer {
+	if v >= smallIntegerMin && v <= smallIntegerMax {
+		return &smallIntegers[v-smallIntegerMin]
+	}
+	return &object.Integer{Value: v}
+}
+
+func evalIndex(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		idx, err := sequenceIndex(index, len(elements))
+		if err != nil {
+			return err
+		}
+		return elements[idx]
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		s := left.(*object.String).Value
+		idx, err := sequenceIndex(index, grapheme.Count(s))
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: grapheme.Slice(s, idx, idx+1)}
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		value, ok := left.(*object.Hash).Get(key)
+		if !ok {
+			return object.NULL
+		}
+		return value
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+// sequenceIndex resolves an index into a sequence of the given length,
+// counting negative indexes from the end
+func sequenceIndex(index object.Object, length int) (int, *object.Error) {
+	idx := index.(*object.Integer).Value
+	if idx < 0 {
+		idx += int64(length)
+	}
+
+	if idx < 0 || idx >= int64(length) {
+		return 0, newError("index out of range: %d with length %d", index.(*object.Integer).Value, length)
+	}
+
+	return int(idx), nil
+}
+
+func member(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Module:
+		val, ok := obj.Exports[name]
+		if !ok {
+			return newError("module %s has no exported name %s", obj.Name, name)
+		}
+		return val
+	case *object.Hash:
+		// Keys win over the hash methods they share a name with
+		if val, ok := obj.Get(&object.String{Value: name}); ok {
+			return val
+		}
+		if method, ok := builtins.LookupMethod(obj, name); ok {
+			return method
+		}
+		return object.NULL
+	case *Closure:
+		switch name {
+		case "नाम":
+			return &object.String{Value: obj.Name}
+		case "विवरण":
+			return &object.String{Value: obj.Doc}
+		}
+	}
+
+	if method, ok := builtins.LookupMethod(obj, name); ok {
+		return method
+	}
+
+	return newError("member access not supported: %s.%s", obj.Type(), name)
+}
+
+// iterator walks the values a comprehension clause binds
+type iterator struct {
+	keys    []object.Object // bound before each value by a clause with two names
+	values  []object.Object
+	indexed bool // the keys are the positions of the values
+	pos     int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// next returns what the next pass binds, or false when there is none left
+func (it *iterator) next() (key, value object.Object, ok bool) {
+	if it.pos == len(it.values) {
+		return nil, nil, false
+	}
+	key, value = nil, it.values[it.pos]
+	if it.indexed {
+		key = integer(int64(it.pos))
+	} else if it.keys != nil {
+		key = it.keys[it.pos]
+	}
+	it.pos++
+	return key, value, true
+}
+
+// newIterator returns an iterator over what a loop over obj binds on each
+// pass. With one name that is each element of an array or set, each
+// character of a string, each key of a hash or each line left in a file;
+// with two, an index and element, or a key and value.
+func newIterator(obj object.Object, names int) (*iterator, *object.Error) {
+	it := &iterator{indexed: names == 2}
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		it.values = obj.Elements[:len(obj.Elements):len(obj.Elements)]
+	case *object.String:
+		for _, c := range grapheme.Split(obj.Value) {
+			it.values = append(it.values, &object.String{Value: c})
+		}
+	case *object.Set:
+		if names == 2 {
+			return nil, newError("cannot bind 2 names when iterating over SET")
+		}
+		it.values = obj.Elements()
+	case *object.Hash:
+		it.indexed = false
+		for _, pair := range obj.Pairs() {
+			it.keys = append(it.keys, pair.Key)
+			it.values = append(it.values, pair.Value)
+		}
+		if names == 1 {
+			it.keys, it.values = nil, it.keys
+		}
+	case *object.File:
+		lines, err := builtins.Lines(obj)
+		if err != nil {
+			return nil, err
+		}
+		it.values = lines
+	default:
+		return nil, newError("cannot iterate over %s", obj.Type())
+	}
+
+	return it, nil
+}
+
+func setPair(hash *object.Hash, key, val object.Object) *object.Error {
+	hashKey, ok := key.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", key.Type())
+	}
+	hash.Set(hashKey, val)
+	return nil
+}
+
+func addToSet(set *object.Set, val object.Object) *object.Error {
+	key, ok := val.(object.Hashable)
+	if !ok {
+		return newError("unusable as set element: %s", val.Type())
+	}
+	set.Add(key)
+	return nil
+}
+
+// hashRest returns the pairs of hash whose keys are not among keys
+func hashRest(hash *object.Hash, keys []object.Object) *object.Hash {
+	used := object.NewHash()
+	for _, k := range keys {
+		used.Set(k.(object.Hashable), object.TRUE)
+	}
+
+	rest := object.NewHash()
+	for _, pair := range hash.Pairs() {
+		key := pair.Key.(object.Hashable)
+		if _, ok := used.Get(key); !ok {
+			rest.Set(key, pair.Value)
+		}
+	}
+	return rest
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return object.TRUE
+	}
+	return object.FALSE
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case nil:
+		return false
+	case object.FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func orNull(obj object.Object) object.Object {
+	if obj == nil {
+		return object.NULL
+	}
+	return obj
+}
+
+func typeOf(obj object.Object) object.ObjectType {
+	if obj == nil {
+		return object.NULL_OBJ
+	}
+	return obj.Type()
+}
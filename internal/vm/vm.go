@@ -0,0 +1,730 @@
+// Package vm implements a stack-based virtual machine running the bytecode
+// the compiler produces. It behaves as the tree-walking evaluator does.
+package vm
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/SunilNeupane77/nepali/internal/async"
+	"github.com/SunilNeupane77/nepali/internal/builtins"
+	"github.com/SunilNeupane77/nepali/internal/code"
+	"github.com/SunilNeupane77/nepali/internal/compiler"
+	"github.com/SunilNeupane77/nepali/internal/object"
+)
+
+const (
+	// StackSize is the number of values the stack can hold
+	StackSize = 1 << 16
+	// MaxFrames is how deeply calls can nest
+	MaxFrames = 1 << 14
+
+	// The stack and frames start this small and grow as they fill, so a
+	// short program or an async call does not pay for the most it could use
+	initialStackSize = 1 << 8
+	initialFrames    = 1 << 4
+)
+
+// Frame is a running call
+type Frame struct {
+	cl *Closure
+	ip int // the next instruction
+	bp int // the stack slot of the first local
+
+	// The functions whose frame this one took over by tail calls, for
+	// traces, outermost first
+	replaced []tailRun
+}
+
+// tailRun is a function that replaced its own frame count times in a row
+type tailRun struct {
+	name  string
+	count int
+}
+
+func appendRun(runs []tailRun, name string) []tailRun {
+	switch {
+	case name == "":
+		return runs
+	case len(runs) > 0 && runs[len(runs)-1].name == name:
+		runs[len(runs)-1].count++
+		return runs
+	default:
+		return append(runs, tailRun{name: name, count: 1})
+	}
+}
+
+// handler is a सँग block being run
+type handler struct {
+	frame  int // the number of frames when it was entered
+	sp     int
+	target int // where the block ends
+	exit   object.Object
+}
+
+// VM runs one compiled program. Functions it makes keep using its globals
+// and imports when another VM calls them, as functions exported from a
+// module do.
+type VM struct {
+	globals     []object.Object
+	globalNames []string
+	imports     []string
+	exports     []string
+	file        string // the file the program came from, if any
+
+	stack []object.Object
+	sp    int // the next free slot
+
+	frames      []Frame
+	framesIndex int // the number of running frames
+
+	open     []*Upvalue // upvalues still pointing into the stack, by slot
+	handlers []handler
+
+	main *Closure
+}
+
+// New returns a VM ready to run bytecode
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewFile(bytecode, "")
+}
+
+// NewFile returns a VM ready to run bytecode compiled from the file at path,
+// so that its imports resolve relative to that file
+func NewFile(bytecode *compiler.Bytecode, path string) *VM {
+	if abs, err := filepath.Abs(path); err == nil && path != "" {
+		path = abs
+	}
+
+	vm := &VM{
+		globals:     make([]object.Object, len(bytecode.Globals)),
+		globalNames: bytecode.Globals,
+		imports:     bytecode.Imports,
+		exports:     bytecode.Exports,
+		file:        path,
+		stack:       make([]object.Object, initialStackSize),
+		frames:      make([]Frame, initialFrames),
+	}
+	vm.main = &Closure{Fn: bytecode.Main, vm: vm, simple: true}
+	return vm
+}
+
+// Run runs the program, returning its value as Eval would: the value of its
+// last statement, nil if that is not an expression, or the error that
+// stopped it. A program from a file is loaded as that file's module while
+// it runs, and cached as it once it has. The async work the program
+// starts but never awaits runs to completion after it.
+func (vm *VM) Run() object.Object {
+	result := vm.runMain()
+	if _, failed := result.(*object.Error); !failed {
+		prev := running
+		running = vm
+		async.Finish()
+		running = prev
+	}
+	return result
+}
+
+// runMain runs the program as Run does, but leaves its async work be, as
+// importing it as a module does
+func (vm *VM) runMain() (result object.Object) {
+	prev := running
+	running = vm
+	defer func() { running = prev }()
+
+	if vm.file != "" {
+		modules.enter(vm.file)
+		defer func() {
+			_, failed := result.(*object.Error)
+			modules.leave(vm, !failed)
+		}()
+	}
+
+	vm.sp = 0
+	if err := vm.push(vm.main); err != nil {
+		return err
+	}
+	if err := vm.pushFrame(vm.main, vm.sp); err != nil {
+		return err
+	}
+	if err := vm.run(0); err != nil {
+		return err
+	}
+	return vm.pop()
+}
+
+// run executes until the frame above base returns. An error escaping every
+// सँग block of those frames unwinds them and is returned.
+func (vm *VM) run(base int) *object.Error {
+	for {
+		err := vm.execute(base)
+		if err == nil {
+			return nil
+		}
+		if err = vm.handle(err, base); err != nil {
+			return err
+		}
+	}
+}
+
+func (vm *VM) execute(base int) *object.Error {
+	frame := &vm.frames[vm.framesIndex-1]
+	ins := frame.cl.Fn.Instructions
+
+	for {
+		op := code.Opcode(ins[frame.ip])
+		frame.ip++
+
+		switch op {
+		case code.OpConstant:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			if err := vm.push(frame.cl.Fn.Constants[idx]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.sp--
+
+		case code.OpTrue:
+			if err := vm.push(object.TRUE); err != nil {
+				return err
+			}
+		case code.OpFalse:
+			if err := vm.push(object.FALSE); err != nil {
+				return err
+			}
+		case code.OpNull:
+			if err := vm.push(object.NULL); err != nil {
+				return err
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual,
+			code.OpLess, code.OpGreater, code.OpLessEqual, code.OpGreaterEqual:
+			right := vm.stack[vm.sp-1]
+			left := vm.stack[vm.sp-2]
+			result, err := binaryOp(op, left, right)
+			if err != nil {
+				return err
+			}
+			vm.sp--
+			vm.stack[vm.sp-1] = result
+
+		case code.OpMinus:
+			operand := vm.stack[vm.sp-1]
+			i, ok := operand.(*object.Integer)
+			if !ok {
+				return newError("unknown operator: -%s", operand.Type())
+			}
+			vm.stack[vm.sp-1] = integer(-i.Value)
+
+		case code.OpBang:
+			switch vm.stack[vm.sp-1] {
+			case object.FALSE:
+				vm.stack[vm.sp-1] = object.TRUE
+			default:
+				vm.stack[vm.sp-1] = object.FALSE
+			}
+
+		case code.OpJump:
+			frame.ip = int(code.ReadUint16(ins[frame.ip:]))
+
+		case code.OpJumpNotTruthy:
+			target := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if !isTruthy(vm.pop()) {
+				frame.ip = target
+			}
+
+		case code.OpGetGlobal:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			val, err := frame.cl.vm.global(int(idx))
+			if err != nil {
+				return err
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpSetGlobal:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			frame.cl.vm.globals[idx] = vm.pop()
+
+		case code.OpGetLocal:
+			idx := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			val := vm.stack[frame.bp+idx]
+			if val == nil {
+				return newError("identifier not found: " + frame.cl.Fn.LocalNames[idx])
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			idx := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			vm.stack[frame.bp+idx] = vm.pop()
+
+		case code.OpGetFree:
+			idx := code.ReadUint8(ins[frame.ip:])
+			frame.ip++
+			val := *frame.cl.Free[idx].location
+			if val == nil {
+				return newError("identifier not found: " + frame.cl.Fn.Free[idx].Name)
+			}
+			if err := vm.push(val); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			fn := frame.cl.Fn.Constants[idx].(*object.CompiledFunction)
+			if err := vm.push(vm.makeClosure(fn, frame)); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			argc := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+			if err := vm.call(argc); err != nil {
+				return err
+			}
+			frame = &vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+
+		case code.OpCallSpread:
+			kwargs := vm.pop().(*object.Hash)
+			args := vm.pop().(*object.Array)
+			fn := vm.pop()
+			if err := vm.callValue(fn, args.Elements, kwargs); err != nil {
+				return err
+			}
+			frame = &vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+
+		case code.OpTailCall:
+			argc := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+			args := make([]object.Object, argc)
+			copy(args, vm.stack[vm.sp-argc:vm.sp])
+			vm.sp -= argc
+			if err := vm.tailCall(vm.pop(), args, nil); err != nil {
+				return err
+			}
+			frame = &vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+
+		case code.OpTailCallSpread:
+			kwargs := vm.pop().(*object.Hash)
+			args := vm.pop().(*object.Array)
+			if err := vm.tailCall(vm.pop(), args.Elements, kwargs); err != nil {
+				return err
+			}
+			frame = &vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+
+		case code.OpReturnValue, code.OpReturn:
+			var result object.Object = object.NULL
+			if op == code.OpReturnValue {
+				result = vm.pop()
+			} else if frame.cl == vm.main {
+				result = nil
+			}
+
+			if err := vm.exitHandlers(); err != nil {
+				return err
+			}
+			vm.popFrame()
+			vm.stack[vm.sp] = result
+			vm.sp++
+
+			if vm.framesIndex == base {
+				return nil
+			}
+			frame = &vm.frames[vm.framesIndex-1]
+			ins = frame.cl.Fn.Instructions
+
+		case code.OpDecorate:
+			fn := vm.stack[vm.sp-1]
+			decorator := vm.stack[vm.sp-2]
+			vm.stack[vm.sp-2] = fn
+			vm.stack[vm.sp-1] = decorator
+			if err := vm.push(fn); err != nil {
+				return err
+			}
+
+		case code.OpPreserve:
+			wrapper := vm.pop()
+			vm.stack[vm.sp-1] = preserveIdentity(wrapper, vm.stack[vm.sp-1])
+
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			elements := make([]object.Object, n)
+			copy(elements, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			hash := object.NewHash()
+			for i := vm.sp - 2*n; i < vm.sp; i += 2 {
+				if err := setPair(hash, vm.stack[i], vm.stack[i+1]); err != nil {
+					return err
+				}
+			}
+			vm.sp -= 2 * n
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpSet:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			set := object.NewSet()
+			for _, el := range vm.stack[vm.sp-n : vm.sp] {
+				if err := addToSet(set, el); err != nil {
+					return err
+				}
+			}
+			vm.sp -= n
+			if err := vm.push(set); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			result := evalIndex(vm.stack[vm.sp-1], index)
+			if err, ok := result.(*object.Error); ok {
+				return err
+			}
+			vm.stack[vm.sp-1] = result
+
+		case code.OpSlice:
+			flags := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+			bounds := make([]object.Object, 3)
+			for i := 2; i >= 0; i-- {
+				if flags&(1<<i) != 0 {
+					bounds[i] = vm.pop()
+				}
+			}
+			result := builtins.Slice(vm.stack[vm.sp-1], bounds[0], bounds[1], bounds[2])
+			if err, ok := result.(*object.Error); ok {
+				return err
+			}
+			vm.stack[vm.sp-1] = result
+
+		case code.OpMember:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			name := frame.cl.Fn.Constants[idx].(*object.String).Value
+			result := member(vm.stack[vm.sp-1], name)
+			if err, ok := result.(*object.Error); ok {
+				return err
+			}
+			vm.stack[vm.sp-1] = result
+
+		case code.OpAppend:
+			val := vm.pop()
+			arr := vm.stack[vm.sp-1].(*object.Array)
+			arr.Elements = append(arr.Elements, val)
+
+		case code.OpSetAdd:
+			val := vm.pop()
+			if err := addToSet(vm.stack[vm.sp-1].(*object.Set), val); err != nil {
+				return err
+			}
+
+		case code.OpSetPair:
+			val := vm.pop()
+			key := vm.pop()
+			if err := setPair(vm.stack[vm.sp-1].(*object.Hash), key, val); err != nil {
+				return err
+			}
+
+		case code.OpArgument:
+			kind := int(code.ReadUint8(ins[frame.ip:]))
+			idx := code.ReadUint16(ins[frame.ip+1:])
+			frame.ip += 3
+			val := vm.pop()
+			args := vm.stack[vm.sp-2].(*object.Array)
+			kwargs := vm.stack[vm.sp-1].(*object.Hash)
+			if err := addArgument(args, kwargs, kind, frame.cl.Fn.Constants, int(idx), val); err != nil {
+				return err
+			}
+
+		case code.OpIter:
+			names := int(code.ReadUint8(ins[frame.ip:]))
+			frame.ip++
+			it, err := newIterator(vm.stack[vm.sp-1], names)
+			if err != nil {
+				return err
+			}
+			vm.stack[vm.sp-1] = it
+
+		case code.OpIterNext:
+			target := int(code.ReadUint16(ins[frame.ip+1:]))
+			frame.ip += 3
+			key, value, ok := vm.pop().(*iterator).next()
+			if !ok {
+				frame.ip = target
+				continue
+			}
+			if key != nil {
+				if err := vm.push(key); err != nil {
+					return err
+				}
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+
+		case code.OpMatchArray:
+			fixed := int(code.ReadUint16(ins[frame.ip:]))
+			rest := code.ReadUint8(ins[frame.ip+2:]) == 1
+			frame.ip += 3
+			arr, ok := vm.stack[vm.sp-1].(*object.Array)
+			matched := ok && (len(arr.Elements) == fixed || rest && len(arr.Elements) > fixed)
+			vm.stack[vm.sp-1] = nativeBoolToBooleanObject(matched)
+
+		case code.OpMatchHash:
+			_, ok := vm.stack[vm.sp-1].(*object.Hash)
+			vm.stack[vm.sp-1] = nativeBoolToBooleanObject(ok)
+
+		case code.OpHasKey:
+			key, ok := vm.pop().(object.Hashable)
+			if !ok {
+				return newError("unusable as hash key: %s", vm.stack[vm.sp].Inspect())
+			}
+			_, found := vm.stack[vm.sp-1].(*object.Hash).Get(key)
+			vm.stack[vm.sp-1] = nativeBoolToBooleanObject(found)
+
+		case code.OpHashRest:
+			n := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			keys := vm.stack[vm.sp-n : vm.sp]
+			vm.sp -= n
+			vm.stack[vm.sp-1] = hashRest(vm.stack[vm.sp-1].(*object.Hash), keys)
+
+		case code.OpWithEnter:
+			target := int(code.ReadUint16(ins[frame.ip:]))
+			frame.ip += 2
+			if err := vm.enterWith(target); err != nil {
+				return err
+			}
+			// The hook's frames may have grown the frames
+			frame = &vm.frames[vm.framesIndex-1]
+
+		case code.OpWithExit:
+			h := vm.handlers[len(vm.handlers)-1]
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+			if err, ok := vm.callSync(h.exit, []object.Object{object.NULL}, nil).(*object.Error); ok {
+				return err
+			}
+			frame = &vm.frames[vm.framesIndex-1]
+
+		case code.OpImport:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			owner := frame.cl.vm
+			mod := modules.load(owner.imports[idx], owner.file)
+			if err, ok := mod.(*object.Error); ok {
+				return err
+			}
+			if err := vm.push(mod); err != nil {
+				return err
+			}
+
+		case code.OpImportName:
+			idx := code.ReadUint16(ins[frame.ip:])
+			frame.ip += 2
+			mod := vm.stack[vm.sp-1].(*object.Module)
+			name := frame.cl.Fn.Constants[idx].(*object.String).Value
+			val, ok := mod.Exports[name]
+			if !ok {
+				return newError("module %s has no exported name %s", mod.Name, name)
+			}
+			vm.stack[vm.sp-1] = val
+
+		case code.OpAwait:
+			if err := vm.await(); err != nil {
+				return err
+			}
+
+		default:
+			def, _ := code.Lookup(byte(op))
+			return newError("unknown opcode %v", def)
+		}
+	}
+}
+
+func (vm *VM) push(obj object.Object) *object.Error {
+	if vm.sp >= len(vm.stack) {
+		if err := vm.growStack(vm.sp + 1); err != nil {
+			return err
+		}
+	}
+
+	vm.stack[vm.sp] = obj
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+// global returns the global at idx. Until the program binds it, a global
+// named after a builtin is that builtin.
+func (vm *VM) global(idx int) (object.Object, *object.Error) {
+	if val := vm.globals[idx]; val != nil {
+		return val, nil
+	}
+
+	name := vm.globalNames[idx]
+	if builtin, ok := builtins.Lookup(name); ok {
+		return builtin, nil
+	}
+	return nil, newError("identifier not found: " + name)
+}
+
+// pushFrame starts running cl with its locals from stack slot bp, where the
+// arguments already are
+func (vm *VM) pushFrame(cl *Closure, bp int) *object.Error {
+	if vm.framesIndex == len(vm.frames) {
+		if vm.framesIndex == MaxFrames {
+			return newError("stack overflow")
+		}
+		frames := make([]Frame, min(2*len(vm.frames), MaxFrames))
+		copy(frames, vm.frames)
+		vm.frames = frames
+	}
+	if bp+cl.Fn.NumLocals >= len(vm.stack) {
+		if err := vm.growStack(bp + cl.Fn.NumLocals + 1); err != nil {
+			return err
+		}
+	}
+
+	for i := vm.sp; i < bp+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = bp + cl.Fn.NumLocals
+
+	vm.frames[vm.framesIndex] = Frame{cl: cl, bp: bp}
+	vm.framesIndex++
+	return nil
+}
+
+// growStack makes room for at least size values on the stack, moving the
+// upvalues that point into it along with it
+func (vm *VM) growStack(size int) *object.Error {
+	if size > StackSize {
+		return newError("stack overflow")
+	}
+
+	n := len(vm.stack)
+	for n < size {
+		n *= 2
+	}
+	stack := make([]object.Object, min(n, StackSize))
+	copy(stack, vm.stack)
+	vm.stack = stack
+
+	for _, u := range vm.open {
+		u.location = &vm.stack[u.slot]
+	}
+	return nil
+}
+
+// popFrame leaves the current frame, dropping its locals and the closure
+// below them
+func (vm *VM) popFrame() {
+	frame := &vm.frames[vm.framesIndex-1]
+	vm.closeUpvalues(frame.bp)
+	vm.framesIndex--
+	vm.sp = frame.bp - 1
+}
+
+func (vm *VM) makeClosure(fn *object.CompiledFunction, frame *Frame) *Closure {
+	cl := &Closure{
+		Fn:         fn,
+		Free:       make([]*Upvalue, len(fn.Free)),
+		Parameters: fn.Parameters,
+		Name:       fn.Name,
+		Doc:        fn.Doc,
+		vm:         frame.cl.vm,
+		simple:     !fn.IsAsync,
+	}
+
+	if n := fn.NumDefaults(); n > 0 {
+		defaults := vm.stack[vm.sp-n : vm.sp]
+		vm.sp -= n
+
+		cl.Parameters = make([]*object.Parameter, len(fn.Parameters))
+		for i, p := range fn.Parameters {
+			copied := *p
+			if p.HasDefault {
+				copied.Default = defaults[0]
+				defaults = defaults[1:]
+			}
+			cl.Parameters[i] = &copied
+		}
+	}
+
+	for _, p := range cl.Parameters {
+		if p.HasDefault || p.Rest || p.KeywordRest {
+			cl.simple = false
+		}
+	}
+
+	for i, free := range fn.Free {
+		if free.Local {
+			cl.Free[i] = vm.capture(frame.bp + free.Index)
+		} else {
+			cl.Free[i] = frame.cl.Free[free.Index]
+		}
+	}
+
+	return cl
+}
+
+// capture returns the open upvalue of a stack slot, opening one if needed
+func (vm *VM) capture(slot int) *Upvalue {
+	i := len(vm.open)
+	for i > 0 && vm.open[i-1].slot >= slot {
+		if vm.open[i-1].slot == slot {
+			return vm.open[i-1]
+		}
+		i--
+	}
+
+	u := &Upvalue{location: &vm.stack[slot], slot: slot}
+	vm.open = append(vm.open, nil)
+	copy(vm.open[i+1:], vm.open[i:])
+	vm.open[i] = u
+	return u
+}
+
+// closeUpvalues closes the upvalues of slots from slot up
+func (vm *VM) closeUpvalues(slot int) {
+	i := len(vm.open)
+	for i > 0 && vm.open[i-1].slot >= slot {
+		vm.open[i-1].close()
+		i--
+	}
+	vm.open = vm.open[:i]
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
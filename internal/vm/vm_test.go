@@ -0,0 +1,327 @@
+package vm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/compiler"
+	"github.com/SunilNeupane77/nepali/internal/evaluator"
+	"github.com/SunilNeupane77/nepali/internal/lexer"
+	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+)
+
+func parse(t testing.TB, input string) *ast.Program {
+	t.Helper()
+
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func testRun(t testing.TB, input string) object.Object {
+	t.Helper()
+
+	c := compiler.New()
+	if err := c.Compile(parse(t, input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return New(c.Bytecode()).Run()
+}
+
+// inspect prints a result; the tree-walker leaves some results as Go nil
+// where the VM has निल
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return object.NULL.Inspect()
+	}
+	return obj.Inspect()
+}
+
+// programs are run by both engines, which must agree on the result and, for
+// errors, on the trace
+var programs = []string{
+	// expressions
+	"१ + २ * ३ - ४ / २",
+	"-(५ - १०)",
+	"!सत्य == मिथ्या",
+	"!५",
+	`"नमस्ते" + " " + "संसार"`,
+	`"क" < "ख"`,
+	"[१, २] < [१, ३]",
+	`१ == "१"`,
+	`{"a": [१]} == {"a": [१]}`,
+	`"क" - "ख"`,
+	`"क" < १`,
+	"५ + सत्य",
+	"-सत्य",
+	"१ / ०",
+
+	// bindings and conditionals
+	"लेट x = ५; लेट y = x * २; x + y",
+	"लेट x = १; लेट x = x + १; x",
+	"स्थिर पाई = ३; पाई * २",
+	"संख्या x = ७; x",
+	"यदि (१ < २) { १० } अन्यथा { २० }",
+	"यदि (१ > २) { १० }",
+	"यदि (मिथ्या) { लेट y = १ }; y",
+	"यदि (सत्य) { लेट y = १ }; y",
+	"यदि (सत्य) { लेट z = २ }",
+	"लेट x = ५;",
+	"अज्ञात",
+	"प्रतिफल ५; ६",
+
+	// functions and closures
+	"फन जोड(a, b) { a + b }; जोड(२, ३)",
+	"लेट f = फन(x) { प्रतिफल x * २; ० }; f(४)",
+	"फन f() { यदि (सत्य) { यदि (सत्य) { प्रतिफल १ } }; २ }; f()",
+	"फन f() { लेट a = १ }; f()",
+	"फन f() { }; f()",
+	"लेट बनाउ = फन(x) { फन(y) { x + y } }; लेट थप२ = बनाउ(२); थप२(५)",
+	"फन गणक() { लेट n = ० ; फन() { n } }; गणक()()",
+	"फन fib(n) { यदि (n < २) { n } अन्यथा { fib(n - १) + fib(n - २) } }; fib(१५)",
+	"फन बाहिर() { लेट f = फन() { x }; लेट x = ७; f() }; बाहिर()",
+	"फन बाहिर() { लेट x = १; लेट f = फन() { x }; लेट x = २; f() }; बाहिर()",
+	"फन बाहिर() { फन सम(n) { यदि (n == ०) { सत्य } अन्यथा { विषम(n - १) } }; फन विषम(n) { यदि (n == ०) { मिथ्या } अन्यथा { सम(n - १) } }; सम(१०) }; बाहिर()",
+	"लेट x = १०; फन f() { लेट x = x + १; x }; [f(), x]",
+	"फन f() { लेट g = फन() { x }; लेट r = g(); लेट x = २; r }; f()",
+	"फन f() { g() }; फन g() { ४२ }; f()",
+	"फन f(x) { x + सत्य }; फन g(x) { f(x) }; g(१)",
+	"५()",
+	"फन f(a) { a }; f(१, २)",
+	"फन f(a, b) { a }; f()",
+	"फन f(a, b = a) { b }",
+	"लेट n = ३; फन f(a, b = n * २) { [a, b] }; लेट n = १००; [f(१), f(१, २), f(b = ५, a = ६)]",
+	"फन f(a, *बाँकी, **kw) { [a, बाँकी, kw] }; f(१, २, ३, x = ४)",
+	"फन f(a, b) { [a, b] }; f(*[१, २])",
+	`फन f(a, b) { [a, b] }; f(**{"b": १, "a": २})`,
+	"फन f(a) { a }; f(१, a = २)",
+	"फन f(a) { a }; f(b = २)",
+	"फन f(a) { a }; f(*५)",
+	"लेन(x = १)",
+	"फन जोड(a, b) { \"दुई संख्या जोड्छ\"; a + b }; [जोड.नाम, जोड.विवरण, टाइप(जोड)]",
+	"फन जोड(a, b = २) { a + b }; जोड",
+	"फन(x) { x }",
+
+	// builtins and methods
+	`लेन("नमस्ते")`,
+	"लेन([१, २, ३])",
+	"क्रमबद्ध([३, १, २], {\"कुञ्जी\": फन(x) { -x }})",
+	"क्रमबद्ध([३, १, २], {\"तुलना\": फन(a, b) { a - b }})",
+	"क्रमबद्ध([१, २], {\"कुञ्जी\": फन(x) { x + \"\" }})",
+	"लेट a = [१]; a.थप(२, ३); a",
+	"लेट a = जमाउनुहोस्([१]); a.थप(२)",
+	`लेट h = {"थप": १}; h.थप`,
+	`{"क": १}.अज्ञात`,
+	"५.अज्ञात",
+
+	// collections
+	"[१, २, ३][१]",
+	"[१, २, ३][-१]",
+	"[१, २, ३][३]",
+	`{"क": १, "ख": २}["ख"]`,
+	`{"क": १}["ग"]`,
+	`{[१]: २}`,
+	"{१, २, २, ३}",
+	"{[१]}",
+	`"नमस्ते"[०]`,
+	"[१, २, ३, ४, ५][१:४]",
+	"[१, २, ३, ४, ५][::-२]",
+	`"नमस्ते"[१:]`,
+	"[१, २][::०]",
+	"५[०]",
+
+	// comprehensions
+	"[x * x लागि x मा [१, २, ३, ४] यदि x > १]",
+	"[[i, x] लागि i, x मा [\"क\", \"ख\"]]",
+	"{[x] लागि x मा [१]}",
+	"{x लागि x मा [१, २, १]}",
+	`{k: v * २ लागि k, v मा {"क": १, "ख": २}}`,
+	"[[x, y] लागि x मा [१, २] लागि y मा [३, ४] यदि x + y > ४]",
+	"[x लागि x मा ५]",
+	"लेट x = १००; [x लागि x मा [१, २]]; x",
+	"[फन() { x } लागि x मा [१, २, ३]][०]()",
+	"[c लागि c मा \"नमस्ते\"]",
+
+	// match
+	`फन वर्ग(x) {
+		मिलान x {
+			अवस्था ० { "शून्य" }
+			अवस्था -१ { "ऋण एक" }
+			अवस्था "नमस्ते" { "अभिवादन" }
+			अवस्था [] { "खाली" }
+			अवस्था [a] { "एउटा " + स्ट्रिंग(a) }
+			अवस्था [a, b] यदि a > b { "घट्दो" }
+			अवस्था [पहिलो, *बाँकी, अन्तिम] { [पहिलो, बाँकी, अन्तिम] }
+			अवस्था {"नाम": n, "उमेर": u} यदि u >= १८ { n + " वयस्क" }
+			अवस्था {"नाम": n, **अरू} { [n, अरू] }
+			अवस्था [a, *_] { a }
+			अवस्था _ { "अरू" }
+		}
+	}
+	[वर्ग(०), वर्ग(-१), वर्ग("नमस्ते"), वर्ग([]), वर्ग([७]), वर्ग([२, १]), वर्ग([१, २]),
+	 वर्ग([१, २, ३, ४]), वर्ग({"नाम": "राम", "उमेर": २०}),
+	 वर्ग({"उमेर": १०, "नाम": "सीता", "गाउँ": "क"}), वर्ग(मिथ्या)]`,
+	"मिलान ५ { अवस्था ६ { १ } }",
+	"लेट x = १; मिलान २ { अवस्था x { x } }; x",
+	"मिलान [१, [२, ३]] { अवस्था [a, [b, c]] { a + b + c } }",
+	"मिलान {} { अवस्था {\"क\": x} { x } अवस्था {**x} { x } }",
+
+	// decorators
+	`फन दोब्बर(f) { फन(x) { f(x) * २ } }
+	 @दोब्बर
+	 फन थप(x) { x + १ }
+	 थप(३)`,
+	`फन थप(f) { फन(x) { f(x) + १ } }
+	 फन दोब्बर(f) { फन(x) { f(x) * २ } }
+	 @थप
+	 @दोब्बर
+	 फन पहिचान(x) { x }
+	 पहिचान(५)`,
+	`फन लग(f) { फन(*args) { f(*args) } }
+	 @लग
+	 फन जोड(a, b) { "दुई संख्या जोड्छ"; a + b }
+	 [जोड.नाम, जोड.विवरण, जोड(१, २)]`,
+	"@५ फन f() { १ }",
+
+	// context managers
+	`सँग {"प्रवेश": फन() { ५ }, "निकास": फन(e) { मिथ्या }} जस्तो x { x + १ }; x`,
+	`सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { १ + e }} { "भित्र" }`,
+	`सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { e == "type mismatch: INTEGER + BOOLEAN" }} { १ + सत्य }; "पछि"`,
+	`सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { मिथ्या }} { १ + सत्य }; "पछि"`,
+	`फन f() {
+		सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { १ + e }} { प्रतिफल "फिर्ता" }
+		"पुगिएन"
+	 }
+	 f()`,
+	`फन f() {
+		सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { मिथ्या }} { प्रतिफल "फिर्ता" }
+		"पुगिएन"
+	 }
+	 f()`,
+	`फन भित्र() { १ + सत्य }
+	 फन f() {
+		सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { सत्य }} { भित्र() }
+		"पछि"
+	 }
+	 f()`,
+	`फन भित्र() { १ + सत्य }
+	 फन f() { सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { मिथ्या }} { भित्र() } }
+	 f()`,
+	`लेट लग = [];
+	 सँग {"प्रवेश": फन() { लग.थप("बाहिर") }, "निकास": फन(e) { लग.थप(e) }} {
+		सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { लग.थप(e); सत्य }} { अज्ञात }
+		लग.थप("बीचमा")
+	 }
+	 लग`,
+	`लेट लग = [];
+	 फन भित्र() { १ + सत्य }; फन बाहिर() { भित्र() }
+	 सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { लग.थप(e); e.सन्देश == "type mismatch: INTEGER + BOOLEAN" }} { बाहिर() }
+	 लग`,
+	`सँग {"प्रवेश": फन() { १ }} { "भित्र" }`,
+	`सँग ५ { "भित्र" }`,
+	// async functions and पर्ख
+	"लेट f = एसिन्क फन(x) { x * २ }; पर्ख f(२१)",
+	"लेट f = एसिन्क फन() { पर्ख सुत्नुहोस्(१); प्रतिफल ७ }; पर्ख f()",
+	"लेट f = एसिन्क फन(x) { x + १ }; लेट g = एसिन्क फन(x) { पर्ख f(x) * २ }; पर्ख g(४)",
+	"पर्ख ५",
+	"फन f() { पर्ख ५ }; f()",
+	"लेट f = एसिन्क फन() { १ }; f()",
+	"लेट f = एसिन्क फन(x) { x }; f()",
+	`लेट लग = []
+	 लेट काम = एसिन्क फन(नाम, ms) { पर्ख सुत्नुहोस्(ms); लग.थप(नाम); नाम };
+	 [पर्ख सबै([काम("क", ३), काम("ख", १), काम("ग", २)]), लग]`,
+	`लेट काम = एसिन्क फन(x) { पर्ख सुत्नुहोस्(१); x }
+	 लेट सब = एसिन्क फन(xs) { [(पर्ख काम(x)) लागि x मा xs] }
+	 पर्ख सब([१, २, ३])`,
+	`फन भित्र() { १ + सत्य }
+	 लेट बाहिर = एसिन्क फन() { पर्ख सुत्नुहोस्(१); भित्र() }
+	 लेट f = एसिन्क फन() { पर्ख बाहिर() }
+	 पर्ख f()`,
+	`लेट f = एसिन्क फन() { पर्ख सुत्नुहोस्(१); -मिथ्या }
+	 सँग {"प्रवेश": फन() { १ }, "निकास": फन(e) { सत्य }} { पर्ख f() }
+	 "पछि"`,
+	`लेट लग = []
+	 लेट f = एसिन्क फन() { पर्ख सुत्नुहोस्(१); लग.थप("पछि") }
+	 f(); लग.थप("पहिले"); लग`,
+	`लेट f = एसिन्क फन(xs) { पर्ख सुत्नुहोस्(१); क्रमबद्ध(xs, {"कुञ्जी": फन(x) { -x }}) }; पर्ख f([१, ३, २])`,
+	// tail calls take no frames, and still show in traces
+	"फन फन१(n, acc) { यदि (n == ०) { प्रतिफल acc } प्रतिफल फन१(n - १, acc + n) }; फन१(१०००००, ०)",
+	"फन f(n, acc = ०) { यदि (n == ०) { acc } अन्यथा { f(*[n - १], acc = acc + n) } }; f(१०००००)",
+	"फन सम(n) { यदि (n == ०) { सत्य } अन्यथा { विषम(n - १) } }; फन विषम(n) { यदि (n == ०) { मिथ्या } अन्यथा { सम(n - १) } }; सम(१०००१)",
+	"फन f(n) { यदि (n == ०) { १ + सत्य } अन्यथा { f(n - १) } }; फन g() { f(३) }; g()",
+	"फन f(n) { यदि (n == ०) { लेन(n) } अन्यथा { f(n - १) } }; f(२)",
+}
+
+func TestEngineParity(t *testing.T) {
+	for _, input := range programs {
+		expected := evaluator.Eval(parse(t, input), object.NewEnvironment())
+		result := testRun(t, input)
+
+		if inspect(result) != inspect(expected) {
+			t.Errorf("%s\nwrong result. tree-walker=%q, vm=%q", input, inspect(expected), inspect(result))
+			continue
+		}
+
+		if err, ok := expected.(*object.Error); ok {
+			if trace := result.(*object.Error).Trace; !reflect.DeepEqual(trace, err.Trace) {
+				t.Errorf("%s\nwrong trace. tree-walker=%v, vm=%v", input, err.Trace, trace)
+			}
+		}
+	}
+}
+
+func TestClosuresShareVariables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// a closure sees its variables as they are when it is called, not
+		// when it was made
+		{"फन f() { लेट x = १; लेट g = फन() { x }; लेट x = २; g() }; f()", "2"},
+		{"फन f() { लेट x = १; लेट g = फन() { फन() { x } }; लेट x = ३; g()() }; f()", "3"},
+		{"फन f() { लेट x = १; [फन() { x }, फन() { x }] }; लेट fs = f(); [fs[०](), fs[१]()]", "[1, 1]"},
+		{"फन f(n) { यदि (n == ०) { ० } अन्यथा { n + f(n - १) } }; f(५००)", "125250"},
+	}
+
+	for i, tt := range tests {
+		if result := testRun(t, tt.input); inspect(result) != tt.expected {
+			t.Errorf("tests[%d] wrong. expected=%q, got=%q", i, tt.expected, inspect(result))
+		}
+	}
+}
+
+func TestIterateOverFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "सूची.txt")
+	if err := os.WriteFile(path, []byte("क\nख\nग\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := `लेट f = खोल्नुहोस्("` + path + `"); f.लाइन_पढ(); [[i, l] लागि i, l मा f]`
+	if result := testRun(t, input); inspect(result) != "[[0, ख], [1, ग]]" {
+		t.Errorf("wrong result. got=%q", inspect(result))
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	result := testRun(t, "फन f(n) { f(n + १) + १ }; f(०)")
+
+	err, ok := result.(*object.Error)
+	if !ok || err.Message != "stack overflow" {
+		t.Fatalf("expected stack overflow, got=%s", inspect(result))
+	}
+	if len(err.Trace) == 0 || strings.Trim(strings.Join(err.Trace, ""), "f") != "" {
+		t.Errorf("wrong trace: %v", err.Trace[:3])
+	}
+	if bt := err.Backtrace(); len(bt) != 1 || bt[0] != fmt.Sprintf("f (×%d)", len(err.Trace)) {
+		t.Errorf("wrong backtrace: %v", bt)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SunilNeupane77/nepali/internal/lint"
+)
+
+// lintCommand handles `nepali lint [--format=text|json|sarif]
+// [--config=file] <file>...`. Each file is checked with the rules of the
+// given configuration, or else of the nearest .nepalilint.json above it.
+// It exits with status 1 if it finds any problem.
+func lintCommand(args []string) {
+	var outputFormat, configPath string
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.StringVar(&outputFormat, "format", "text", "output format: text, json or sarif")
+	flags.StringVar(&configPath, "config", "", "configuration file, instead of the nearest "+lint.ConfigFile)
+	flags.Parse(args)
+
+	if flags.NArg() == 0 || (outputFormat != "text" && outputFormat != "json" && outputFormat != "sarif") {
+		fmt.Fprintf(os.Stderr, "usage: nepali lint [--format=text|json|sarif] [--config=file] <file>...\n")
+		os.Exit(2)
+	}
+
+	var diagnostics []lint.Diagnostic
+	failed := false
+	for _, filename := range flags.Args() {
+		var config lint.Config
+		var err error
+		if configPath != "" {
+			config, err = lint.ReadConfig(configPath)
+		} else {
+			config, err = lint.FindConfig(filepath.Dir(filename))
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading lint configuration: %s\n", err)
+			os.Exit(2)
+		}
+
+		found, err := lint.File(filename, config)
+		if parseErrors, ok := err.(lint.ParseErrors); ok {
+			fmt.Fprintf(os.Stderr, "%s: ", filename)
+			for _, msg := range parseErrors {
+				fmt.Fprintf(os.Stderr, "\t%s\n", msg)
+			}
+			failed = true
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading file: %s\n", err)
+			os.Exit(2)
+		}
+		diagnostics = append(diagnostics, found...)
+	}
+
+	switch outputFormat {
+	case "json":
+		lint.WriteJSON(os.Stdout, diagnostics)
+	case "sarif":
+		lint.WriteSARIF(os.Stdout, diagnostics)
+	default:
+		for _, d := range diagnostics {
+			fmt.Printf("%s:%d: %s (%s)\n", d.File, d.Line, d.Message, d.Rule)
+		}
+	}
+
+	if failed || len(diagnostics) > 0 {
+		os.Exit(1)
+	}
+}
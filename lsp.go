@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/SunilNeupane77/nepali/internal/lsp"
+)
+
+// lspCommand handles `nepali lsp`, which runs a language server for
+// editors, speaking the protocol on standard input and output
+func lspCommand() {
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "nepali lsp: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,63 +1,209 @@
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/SunilNeupane77/nepali/internal/ast"
+	"github.com/SunilNeupane77/nepali/internal/compiler"
 	"github.com/SunilNeupane77/nepali/internal/evaluator"
 	"github.com/SunilNeupane77/nepali/internal/lexer"
-	"github.com/SunilNeupane77/nepali/internal/parser"
 	"github.com/SunilNeupane77/nepali/internal/object"
+	"github.com/SunilNeupane77/nepali/internal/optimizer"
+	"github.com/SunilNeupane77/nepali/internal/parser"
+	"github.com/SunilNeupane77/nepali/internal/vm"
 )
 
 func main() {
-	if len(os.Args) > 1 {
-		filename := os.Args[1]
-		source, err := os.ReadFile(filename)
-		if err != nil {
-			fmt.Printf("Error reading file: %s\n", err)
-			os.Exit(1)
-		}
-		run(string(source))
+	// NEPALI_PATH lists extra directories to search for imported modules
+	if path := os.Getenv("NEPALI_PATH"); path != "" {
+		evaluator.SetModuleLoader(evaluator.NewModuleLoader(filepath.SplitList(path)...))
+		vm.SetModuleLoader(vm.NewModuleLoader(filepath.SplitList(path)...))
+	}
+
+	if len(os.Args) > 2 && os.Args[1] == "run" {
+		runCommand(os.Args[2:])
+	} else if len(os.Args) > 2 && os.Args[1] == "compile" {
+		compileCommand(os.Args[2:])
+	} else if len(os.Args) > 2 && os.Args[1] == "disasm" {
+		disasmCommand(os.Args[2:])
+	} else if len(os.Args) > 2 && os.Args[1] == "fmt" {
+		fmtCommand(os.Args[2:])
+	} else if len(os.Args) > 2 && os.Args[1] == "lint" {
+		lintCommand(os.Args[2:])
+	} else if len(os.Args) > 2 && os.Args[1] == "trace" {
+		traceCommand(os.Args[2:])
+	} else if len(os.Args) == 2 && os.Args[1] == "lsp" {
+		lspCommand()
+	} else if len(os.Args) == 2 && os.Args[1] == "debug" {
+		debugCommand()
+	} else if len(os.Args) > 1 {
+		runFile(os.Args[1], runOptions{engine: "tree"})
 	} else {
-		fmt.Printf("नेपाली प्रोग्रामिङ भाषा\n")
-		fmt.Printf("त्याहाँ लाइन टाइप गर्नुहोस् `अन्त्य` लाइन टाइप गर्नुहोस्\n")
+		repl()
+	}
+}
+
+// repl reads and evaluates a line at a time, all in one environment, so
+// later lines see what earlier ones defined. `:vars` lists those
+// definitions, and `:debug <file>` runs a file under the debugger.
+func repl() {
+	fmt.Printf("नेपाली प्रोग्रामिङ भाषा\n")
+	fmt.Printf("त्याहाँ लाइन टाइप गर्नुहोस् `अन्त्य` लाइन टाइप गर्नुहोस्\n")
+
+	env := object.NewEnvironment()
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
 		fmt.Printf("> ")
-		
-		for {
-			var input string
-			if _, err := fmt.Scanln(&input); err != nil {
-				fmt.Printf("Error reading input: %s\n", err)
-				continue
-			}
-			
-			if strings.TrimSpace(input) == "अन्त्य" {
-				break
+		if !scanner.Scan() {
+			return
+		}
+
+		input := strings.TrimSpace(scanner.Text())
+		switch input {
+		case "":
+			continue
+		case "अन्त्य":
+			return
+		case ":vars":
+			printVars(env)
+			continue
+		}
+		if filename, ok := strings.CutPrefix(input, ":debug "); ok {
+			debugFile(strings.TrimSpace(filename), scanner)
+			continue
+		}
+
+		program := parse(input)
+		if program == nil {
+			continue
+		}
+		printWarnings(evaluator.Check(program, env))
+		printResult(evaluator.Eval(program, env))
+	}
+}
+
+// printVars lists the variables bound in env, in the order they were
+// first defined
+func printVars(env *object.Environment) {
+	for slot, name := range env.Names() {
+		if val := env.Lookup(0, slot); val != nil {
+			fmt.Printf("%s = %s\n", name, val.Inspect())
+		}
+	}
+}
+
+// runOptions are the flags of `nepali run`
+type runOptions struct {
+	engine     string // "tree" or "vm"
+	optimize   bool   // run the optimizer over the program first
+	explainOpt bool   // report what the optimizer changed
+}
+
+// runCommand handles `nepali run [--engine=tree|vm] [--optimize]
+// [--explain-opt] <file>`
+func runCommand(args []string) {
+	var opts runOptions
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.StringVar(&opts.engine, "engine", "tree", "engine to run the program with: tree or vm")
+	flags.BoolVar(&opts.optimize, "optimize", false, "fold constant expressions and remove dead यदि branches before running")
+	flags.BoolVar(&opts.explainOpt, "explain-opt", false, "optimize, reporting each change made")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || (opts.engine != "tree" && opts.engine != "vm") {
+		fmt.Fprintf(os.Stderr, "usage: nepali run [--engine=tree|vm] [--optimize] [--explain-opt] <file>\n")
+		os.Exit(2)
+	}
+	opts.optimize = opts.optimize || opts.explainOpt
+	runFile(flags.Arg(0), opts)
+}
+
+func runFile(filename string, opts runOptions) {
+	// A compiled file, or one deployed without its source, has no source
+	// to check
+	_, statErr := os.Stat(filename)
+	if opts.engine == "vm" && !opts.optimize &&
+		(filepath.Ext(filename) == compiler.CompiledExt || errors.Is(statErr, os.ErrNotExist)) {
+		printResult(vm.NewFile(loadBytecode(filename), filename).Run())
+		return
+	}
+
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("Error reading file: %s\n", err)
+		os.Exit(1)
+	}
+
+	program := parse(string(source))
+	if program == nil {
+		os.Exit(1)
+	}
+
+	if opts.optimize {
+		changes := optimizer.Optimize(program)
+		if opts.explainOpt {
+			for _, msg := range changes {
+				fmt.Fprintf(os.Stderr, "अनुकूलन: %s\n", msg)
 			}
-			
-			run(input)
-			fmt.Printf("> ")
 		}
 	}
+
+	// Both engines warn of the same names
+	env := object.NewEnvironment()
+	printWarnings(evaluator.Check(program, env))
+
+	if opts.engine == "vm" {
+		// Optimized programs are compiled afresh rather than loaded from a
+		// compiled file, which holds what the source compiles to as written
+		var bytecode *compiler.Bytecode
+		if opts.optimize {
+			bytecode = compileProgram(program)
+		} else {
+			// Its parser warnings are those parse printed
+			bytecode, _, err = compiler.Load(filename)
+			exitOnCompileError(err)
+		}
+		printResult(vm.NewFile(bytecode, filename).Run())
+		return
+	}
+
+	printResult(evaluator.EvalFile(program, filename, env))
 }
 
-func run(source string) {
-	l := lexer.New(source)
-	p := parser.New(l)
+// parse parses source, printing its warnings. It prints its errors and
+// returns nil if it does not parse.
+func parse(source string) *ast.Program {
+	p := parser.New(lexer.New(source))
 	program := p.ParseProgram()
 
 	if len(p.Errors()) != 0 {
 		printParserErrors(p.Errors())
-		os.Exit(1)
+		return nil
 	}
+	printWarnings(p.Warnings())
+	return program
+}
 
-	env := object.NewEnvironment()
-	evaluated := evaluator.Eval(program, env)
+func printWarnings(warnings []string) {
+	for _, msg := range warnings {
+		fmt.Fprintf(os.Stderr, "चेतावनी: %s\n", msg)
+	}
+}
 
+func printResult(evaluated object.Object) {
 	if evaluated != nil {
 		fmt.Printf("%s\n", evaluated.Inspect())
 	}
+	if err, ok := evaluated.(*object.Error); ok {
+		for _, name := range err.Backtrace() {
+			fmt.Printf("\tफन %s भित्र\n", name)
+		}
+	}
 }
 
 func printParserErrors(errors []string) {
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/SunilNeupane77/nepali/internal/trace"
+)
+
+// traceCommand handles `nepali trace [--format=json|html]
+// [--expressions=false] [--max-events=N] <file>`, which runs the file
+// recording each statement, expression, call, return, binding and error,
+// and writes the log: as JSON, or as a page that steps through it.
+// Expressions are recorded unless --expressions=false.
+func traceCommand(args []string) {
+	var outputFormat string
+	var opts trace.Options
+	flags := flag.NewFlagSet("trace", flag.ExitOnError)
+	flags.StringVar(&outputFormat, "format", "json", "output format: json, or html for a page that steps through the log")
+	flags.BoolVar(&opts.Expressions, "expressions", true, "record what each expression evaluates to")
+	flags.IntVar(&opts.MaxEvents, "max-events", trace.DefaultMaxEvents, "stop the program after recording this many events")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || (outputFormat != "json" && outputFormat != "html") || opts.MaxEvents < 1 {
+		fmt.Fprintf(os.Stderr, "usage: nepali trace [--format=json|html] [--expressions=false] [--max-events=N] <file>\n")
+		os.Exit(2)
+	}
+	filename := flags.Arg(0)
+
+	source, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %s\n", err)
+		os.Exit(1)
+	}
+
+	log, err := trace.Run(filename, string(source), opts)
+	if parseErrors, ok := err.(trace.ParseErrors); ok {
+		fmt.Fprintf(os.Stderr, "%s: ", filename)
+		for _, msg := range parseErrors {
+			fmt.Fprintf(os.Stderr, "\t%s\n", msg)
+		}
+		os.Exit(1)
+	}
+
+	if outputFormat == "html" {
+		err = trace.WriteHTML(os.Stdout, log)
+	} else {
+		err = trace.WriteJSON(os.Stdout, log)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "nepali trace: %s\n", err)
+		os.Exit(1)
+	}
+}